@@ -0,0 +1,406 @@
+package audit
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/yaoapp/gou/process"
+	"github.com/yaoapp/gou/session"
+	"github.com/yaoapp/gou/types"
+	"github.com/yaoapp/kun/any"
+	"github.com/yaoapp/kun/log"
+	"github.com/yaoapp/yao/widgets/binding"
+)
+
+// MaxRows the rows read per query by the updatewhere, updatein, deletewhere and deletein actions.
+// All the matched rows are recorded, the rows are read page by page in the order of the primary key.
+var MaxRows = 1000
+
+// writeActions the audited actions
+var writeActions = map[string]bool{
+	"save": true, "create": true, "insert": true,
+	"update": true, "updatewhere": true, "updatein": true,
+	"delete": true, "deletewhere": true, "deletein": true,
+}
+
+// Trail the audit trail of a write action, the rows are snapshotted before and after the process is executed
+type Trail struct {
+	widget   string
+	widgetID string
+	action   string
+	model    string
+	primary  string
+	sid      string
+	args     []interface{}
+	exclude  map[string]bool
+	before   map[string]map[string]interface{}
+	keys     []string // the primary keys of the rows in order
+	seen     map[string]bool
+}
+
+// Begin begin the trail of the widget action, returns nil if the action is not audited.
+// name is the action name (e.g. yao.table.Update), bind is the process bound to the action (e.g. models.pet.Update)
+func (option *Option) Begin(widget string, widgetID string, name string, bind string, sid string, args []interface{}) *Trail {
+	if !option.Enabled() {
+		return nil
+	}
+
+	namer := strings.Split(strings.ToLower(name), ".")
+	action := namer[len(namer)-1]
+	if !writeActions[action] || !option.audited(action) {
+		return nil
+	}
+
+	mod, err := binding.Model(bind, "the audit")
+	if err != nil {
+		log.Warn("[audit] %s %s %s %s", widget, widgetID, action, err.Error())
+		return nil
+	}
+
+	trail := &Trail{
+		widget:   widget,
+		widgetID: widgetID,
+		action:   action,
+		model:    mod.ID,
+		primary:  mod.PrimaryKey,
+		sid:      sid,
+		args:     args,
+		exclude:  map[string]bool{},
+		before:   map[string]map[string]interface{}{},
+		keys:     []string{},
+		seen:     map[string]bool{},
+	}
+
+	for _, column := range option.Exclude {
+		trail.exclude[column] = true
+	}
+
+	for _, column := range mod.Columns {
+		if column.Crypt == "PASSWORD" {
+			trail.exclude[column.Name] = true
+		}
+	}
+
+	trail.snapshot()
+	return trail
+}
+
+// Commit record the changes after the process is executed, res is the result of the process.
+// The errors are logged, the write action is not affected.
+func (trail *Trail) Commit(res interface{}) {
+	if trail == nil {
+		return
+	}
+
+	after := map[string]map[string]interface{}{}
+	switch trail.action {
+	case "save", "create":
+		id := res
+		if id == nil && len(trail.keys) > 0 {
+			id = trail.keys[0]
+		}
+		if row := trail.find(id); row != nil {
+			key := fmt.Sprintf("%v", row[trail.primary])
+			after[key] = row
+			trail.addKey(key)
+		}
+
+	case "update":
+		for _, key := range trail.keys {
+			if row := trail.find(key); row != nil {
+				after[key] = row
+			}
+		}
+
+	case "updatewhere", "updatein":
+		for start := 0; start < len(trail.keys); start += MaxRows {
+			end := start + MaxRows
+			if end > len(trail.keys) {
+				end = len(trail.keys)
+			}
+
+			keys := []interface{}{}
+			for _, key := range trail.keys[start:end] {
+				keys = append(keys, key)
+			}
+			for _, row := range trail.get([]types.QueryWhere{{Column: trail.primary, OP: "in", Value: keys}}) {
+				after[fmt.Sprintf("%v", row[trail.primary])] = row
+			}
+		}
+
+	case "insert":
+		for i, row := range insertRows(trail.args) {
+			key := fmt.Sprintf("%v", row[trail.primary])
+			if row[trail.primary] == nil {
+				key = fmt.Sprintf("#%d", i+1)
+			}
+			after[key] = row
+			trail.addKey(key)
+		}
+	}
+
+	records := []Record{}
+	actor := Actor(trail.sid)
+	now := time.Now()
+	for _, key := range trail.keys {
+		changes := diff(trail.before[key], after[key], trail.exclude)
+		if len(changes) == 0 {
+			continue
+		}
+
+		primary := key
+		if strings.HasPrefix(key, "#") {
+			primary = ""
+		}
+
+		records = append(records, Record{
+			Widget:    trail.widget,
+			WidgetID:  trail.widgetID,
+			Action:    trail.action,
+			Model:     trail.model,
+			Primary:   primary,
+			Actor:     actor,
+			Changes:   changes,
+			CreatedAt: now,
+		})
+	}
+
+	err := Write(records)
+	if err != nil {
+		log.Error("[audit] %s %s %s %s", trail.widget, trail.widgetID, trail.action, err.Error())
+	}
+}
+
+// Actor the session user_id, the user.id is used if the user_id is not set
+func Actor(sid string) string {
+	id, err := session.Global().ID(sid).Get("user_id")
+	if err == nil && id != nil {
+		return fmt.Sprintf("%v", id)
+	}
+
+	user, err := session.Global().ID(sid).Get("user")
+	if err == nil && user != nil {
+		if id := any.Of(user).MapStr().Get("id"); id != nil {
+			return fmt.Sprintf("%v", id)
+		}
+	}
+	return ""
+}
+
+// snapshot the rows before the process is executed
+func (trail *Trail) snapshot() {
+	switch trail.action {
+	case "save":
+		row := binding.Copy(argOf(trail.args, 0))
+		if row == nil || row[trail.primary] == nil {
+			return
+		}
+		trail.snapshotRow(row[trail.primary])
+
+	case "update", "delete":
+		trail.snapshotRow(argOf(trail.args, 0))
+
+	case "updatewhere", "updatein", "deletewhere", "deletein":
+		param, ok := queryParamOf(argOf(trail.args, 0))
+		if !ok {
+			log.Warn("[audit] %s %s %s the query param is invalid", trail.widget, trail.widgetID, trail.action)
+			return
+		}
+		for _, row := range trail.get(param.Wheres) {
+			key := fmt.Sprintf("%v", row[trail.primary])
+			trail.before[key] = row
+			trail.addKey(key)
+		}
+	}
+}
+
+func (trail *Trail) snapshotRow(id interface{}) {
+	if id == nil {
+		return
+	}
+
+	key := fmt.Sprintf("%v", id)
+	if row := trail.find(id); row != nil {
+		trail.before[key] = row
+	}
+	trail.addKey(key)
+}
+
+func (trail *Trail) addKey(key string) {
+	if trail.seen[key] {
+		return
+	}
+	trail.seen[key] = true
+	trail.keys = append(trail.keys, key)
+}
+
+// find the row of the primary key, nil if the row does not exist
+func (trail *Trail) find(id interface{}) map[string]interface{} {
+	if id == nil {
+		return nil
+	}
+
+	res, err := process.New(fmt.Sprintf("models.%s.Find", trail.model), id, types.QueryParam{}).WithSID(trail.sid).Exec()
+	if err != nil {
+		return nil
+	}
+	return binding.Copy(res)
+}
+
+// get all the rows of the wheres, MaxRows rows per query in the order of the primary key.
+// If a query fails, the rows read before are returned and the truncated trail is logged.
+func (trail *Trail) get(wheres []types.QueryWhere) []map[string]interface{} {
+	rows := []map[string]interface{}{}
+	var last interface{}
+	for {
+		param := types.QueryParam{
+			Wheres: []types.QueryWhere{},
+			Orders: []types.QueryOrder{{Column: trail.primary, Option: "asc"}},
+			Limit:  MaxRows,
+		}
+
+		// the wheres are grouped, the orwhere of the wheres does not affect the page condition
+		if len(wheres) > 0 {
+			param.Wheres = append(param.Wheres, types.QueryWhere{Wheres: wheres})
+		}
+
+		if last != nil {
+			param.Wheres = append(param.Wheres, types.QueryWhere{Column: trail.primary, OP: "gt", Value: last})
+		}
+
+		page, err := trail.page(param)
+		if err != nil {
+			log.Error("[audit] %s %s %s the trail is truncated after %d rows: %s", trail.widget, trail.widgetID, trail.action, len(rows), err.Error())
+			return rows
+		}
+
+		rows = append(rows, page...)
+		if len(page) < MaxRows {
+			return rows
+		}
+
+		last = page[len(page)-1][trail.primary]
+		if last == nil {
+			log.Error("[audit] %s %s %s the trail is truncated after %d rows: the primary key %s is not found", trail.widget, trail.widgetID, trail.action, len(rows), trail.primary)
+			return rows
+		}
+	}
+}
+
+// page the rows of the query param
+func (trail *Trail) page(param types.QueryParam) ([]map[string]interface{}, error) {
+	res, err := process.New(fmt.Sprintf("models.%s.Get", trail.model), param).WithSID(trail.sid).Exec()
+	if err != nil {
+		return nil, err
+	}
+
+	rows := []map[string]interface{}{}
+	values := reflect.ValueOf(res)
+	if values.Kind() != reflect.Slice {
+		return rows, nil
+	}
+
+	for i := 0; i < values.Len(); i++ {
+		if row := binding.Copy(values.Index(i).Interface()); row != nil {
+			rows = append(rows, row)
+		}
+	}
+	return rows, nil
+}
+
+// audited check if the action is audited
+func (option *Option) audited(action string) bool {
+	if len(option.Actions) == 0 {
+		return true
+	}
+
+	for _, name := range option.Actions {
+		if strings.ToLower(strings.ReplaceAll(name, "-", "")) == action {
+			return true
+		}
+	}
+	return false
+}
+
+// diff the changed fields of the row
+func diff(before map[string]interface{}, after map[string]interface{}, exclude map[string]bool) map[string]Change {
+	changes := map[string]Change{}
+	for name, value := range before {
+		if exclude[name] {
+			continue
+		}
+
+		var next interface{}
+		if after != nil {
+			next = after[name]
+		}
+
+		if !equal(value, next) {
+			changes[name] = Change{Before: value, After: next}
+		}
+	}
+
+	for name, value := range after {
+		if _, has := before[name]; has || exclude[name] || value == nil {
+			continue
+		}
+		changes[name] = Change{Before: nil, After: value}
+	}
+	return changes
+}
+
+// equal compare the values, the numbers read from the different drivers are compared by the text
+func equal(a interface{}, b interface{}) bool {
+	if reflect.DeepEqual(a, b) {
+		return true
+	}
+
+	if a == nil || b == nil {
+		return false
+	}
+	return fmt.Sprintf("%v", a) == fmt.Sprintf("%v", b)
+}
+
+// insertRows the rows of the insert args (columns, values)
+func insertRows(args []interface{}) []map[string]interface{} {
+	rows := []map[string]interface{}{}
+	columns, ok := argOf(args, 0).([]string)
+	if !ok {
+		return rows
+	}
+
+	values, ok := argOf(args, 1).([][]interface{})
+	if !ok {
+		return rows
+	}
+
+	for _, value := range values {
+		row := map[string]interface{}{}
+		for i, column := range columns {
+			if i < len(value) {
+				row[column] = value[i]
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func queryParamOf(value interface{}) (types.QueryParam, bool) {
+	switch param := value.(type) {
+	case nil:
+		return types.QueryParam{}, true
+	case types.QueryParam:
+		return param, true
+	}
+	return types.AnyToQueryParam(value)
+}
+
+func argOf(args []interface{}, i int) interface{} {
+	if len(args) <= i {
+		return nil
+	}
+	return args[i]
+}
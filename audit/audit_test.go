@@ -0,0 +1,173 @@
+package audit
+
+import (
+	"testing"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/yaoapp/gou/model"
+	"github.com/yaoapp/gou/process"
+	"github.com/yaoapp/gou/session"
+	"github.com/yaoapp/gou/types"
+	"github.com/yaoapp/xun/capsule"
+	"github.com/yaoapp/yao/config"
+	"github.com/yaoapp/yao/test"
+)
+
+func TestOption(t *testing.T) {
+	var option *Option
+	err := jsoniter.Unmarshal([]byte(`true`), &option)
+	assert.Nil(t, err)
+	assert.NotNil(t, option)
+	assert.Nil(t, option.Begin("table", "pet", "yao.table.Search", "models.pet.Paginate", "", nil))
+
+	err = jsoniter.Unmarshal([]byte(`{"actions": ["delete-where"], "exclude": ["cost"]}`), &option)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"cost"}, option.Exclude)
+	assert.True(t, option.audited("deletewhere"))
+	assert.False(t, option.audited("update"))
+
+	var disabled *Option
+	err = jsoniter.Unmarshal([]byte(`false`), &disabled)
+	assert.Nil(t, err)
+	assert.Nil(t, disabled.Begin("table", "pet", "yao.table.Update", "models.pet.Update", "", []interface{}{1, nil}))
+
+	raw, err := jsoniter.Marshal(disabled)
+	assert.Nil(t, err)
+	assert.Equal(t, "false", string(raw))
+}
+
+func TestTrail(t *testing.T) {
+	test.Prepare(t, config.Conf)
+	defer test.Clean()
+	prepare(t)
+	defer session.Global().Set("user_id", nil)
+
+	session.Global().Set("user_id", 7)
+	option := &Option{Exclude: []string{"updated_at"}}
+
+	// Update
+	args := []interface{}{1, map[string]interface{}{"name": "New Cookie"}}
+	trail := option.Begin("table", "pet", "yao.table.Update", "models.pet.Update", "", args)
+	trail.Commit(run(t, "models.pet.Update", args...))
+
+	// Create
+	args = []interface{}{map[string]interface{}{"name": "Poo", "type": "others", "status": "checked", "mode": "enabled", "stay": 199, "cost": 66, "doctor_id": 1}}
+	trail = option.Begin("form", "pet", "yao.form.Create", "models.pet.Create", "", args)
+	trail.Commit(run(t, "models.pet.Create", args...))
+
+	// DeleteWhere
+	args = []interface{}{types.QueryParam{Wheres: []types.QueryWhere{{Column: "name", Value: "Baby"}}}}
+	trail = option.Begin("table", "pet", "yao.table.DeleteWhere", "models.pet.DeleteWhere", "", args)
+	trail.Commit(run(t, "models.pet.DeleteWhere", args...))
+
+	// Search
+	res, err := Search(types.QueryParam{}, 1, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 3, res["total"])
+
+	data := res["data"].([]map[string]interface{})
+	assert.Equal(t, "deletewhere", data[0]["action"])
+	assert.Equal(t, "create", data[1]["action"])
+	assert.Equal(t, "update", data[2]["action"])
+	assert.Equal(t, "7", data[2]["actor"])
+	assert.Equal(t, "1", data[2]["primary_key"])
+	assert.Equal(t, "name", data[2]["fields"])
+
+	changes := data[2]["changes"].(map[string]Change)
+	assert.Equal(t, "Cookie", changes["name"].Before)
+	assert.Equal(t, "New Cookie", changes["name"].After)
+
+	changes = data[0]["changes"].(map[string]Change)
+	assert.Equal(t, "Baby", changes["name"].Before)
+	assert.Nil(t, changes["name"].After)
+
+	res, err = Search(types.QueryParam{Wheres: []types.QueryWhere{{Column: "action", Value: "create"}}}, 1, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 1, res["total"])
+
+	_, err = Search(types.QueryParam{Wheres: []types.QueryWhere{{Column: "changes", Value: "Baby"}}}, 1, 20)
+	assert.Error(t, err)
+
+	// Find
+	record, err := process.New("yao.audit.Find", data[1]["id"]).Exec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes = record.(map[string]interface{})["changes"].(map[string]Change)
+	assert.Nil(t, changes["name"].Before)
+	assert.Equal(t, "Poo", changes["name"].After)
+}
+
+func TestTrailPages(t *testing.T) {
+	test.Prepare(t, config.Conf)
+	defer test.Clean()
+	prepare(t)
+
+	// one row per query
+	MaxRows = 1
+	defer func() { MaxRows = 1000 }()
+
+	option := &Option{Exclude: []string{"updated_at"}}
+	args := []interface{}{
+		types.QueryParam{Wheres: []types.QueryWhere{{Column: "doctor_id", Value: 1}}},
+		map[string]interface{}{"mode": "disabled"},
+	}
+	trail := option.Begin("table", "pet", "yao.table.UpdateWhere", "models.pet.UpdateWhere", "", args)
+	trail.Commit(run(t, "models.pet.UpdateWhere", args...))
+
+	res, err := Search(types.QueryParam{}, 1, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 2, res["total"])
+
+	data := res["data"].([]map[string]interface{})
+	for _, record := range data {
+		changes := record["changes"].(map[string]Change)
+		assert.Equal(t, "enabled", changes["mode"].Before)
+		assert.Equal(t, "disabled", changes["mode"].After)
+	}
+}
+
+func prepare(t *testing.T) {
+	reset()
+	err := capsule.Global.Schema().DropTableIfExists(Table)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pet := model.Select("pet")
+	err = pet.DropTable()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = pet.Migrate(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = pet.Insert(
+		[]string{"name", "type", "status", "mode", "stay", "cost", "doctor_id"},
+		[][]interface{}{
+			{"Cookie", "cat", "checked", "enabled", 200, 105, 1},
+			{"Baby", "dog", "checked", "enabled", 186, 24, 1},
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func run(t *testing.T, name string, args ...interface{}) interface{} {
+	res, err := process.New(name, args...).Exec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
@@ -0,0 +1,45 @@
+package audit
+
+import (
+	"github.com/yaoapp/gou/process"
+	"github.com/yaoapp/kun/exception"
+)
+
+func init() {
+	process.Register("yao.audit.Search", processSearch)
+	process.Register("yao.audit.Find", processFind)
+}
+
+// processSearch yao.audit.Search (:queryParam, :page, :pagesize)
+func processSearch(process *process.Process) interface{} {
+	param, ok := queryParamOf(argOf(process.Args, 0))
+	if !ok {
+		exception.New("the query param is invalid", 400).Throw()
+	}
+
+	page := 1
+	if len(process.Args) > 1 {
+		page = process.ArgsInt(1, 1)
+	}
+
+	pagesize := 20
+	if len(process.Args) > 2 {
+		pagesize = process.ArgsInt(2, 20)
+	}
+
+	res, err := Search(param, page, pagesize)
+	if err != nil {
+		exception.New(err.Error(), 400).Throw()
+	}
+	return res
+}
+
+// processFind yao.audit.Find (:id)
+func processFind(process *process.Process) interface{} {
+	process.ValidateArgNums(1)
+	res, err := Find(process.Args[0])
+	if err != nil {
+		exception.New(err.Error(), 404).Throw()
+	}
+	return res
+}
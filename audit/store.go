@@ -0,0 +1,305 @@
+package audit
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/yaoapp/gou/connector"
+	"github.com/yaoapp/gou/types"
+	"github.com/yaoapp/xun"
+	"github.com/yaoapp/xun/capsule"
+	"github.com/yaoapp/xun/dbal/query"
+	"github.com/yaoapp/xun/dbal/schema"
+)
+
+// Connector the database connector of the audit table, default is the default database
+var Connector = "default"
+
+// Table the name of the audit table, the table is created on the first use
+var Table = "yao_audit"
+
+var store query.Query
+var storeLock sync.Mutex
+
+// columns the queryable columns
+var columns = map[string]bool{
+	"id": true, "widget": true, "widget_id": true, "action": true, "model": true,
+	"primary_key": true, "actor": true, "created_at": true,
+}
+
+// operators the where operators
+var operators = map[string]string{
+	"": "=", "eq": "=", "ne": "<>", "gt": ">", "ge": ">=", "lt": "<", "le": "<=", "like": "like", "match": "like",
+}
+
+// Write write the records
+func Write(records []Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	qb, err := newQuery()
+	if err != nil {
+		return err
+	}
+
+	values := []map[string]interface{}{}
+	for _, record := range records {
+		changes, err := jsoniter.MarshalToString(record.Changes)
+		if err != nil {
+			return err
+		}
+
+		values = append(values, map[string]interface{}{
+			"widget":      record.Widget,
+			"widget_id":   record.WidgetID,
+			"action":      record.Action,
+			"model":       record.Model,
+			"primary_key": record.Primary,
+			"actor":       record.Actor,
+			"changes":     changes,
+			"created_at":  record.CreatedAt,
+		})
+	}
+	return qb.Insert(values)
+}
+
+// Search search the records, the where columns: id, widget, widget_id, action, model, primary_key, actor, created_at.
+// returns the same structure as the model Paginate process
+func Search(param types.QueryParam, page int, pagesize int) (map[string]interface{}, error) {
+	if page < 1 {
+		page = 1
+	}
+
+	if pagesize < 1 {
+		pagesize = 20
+	}
+
+	qb, err := newQuery()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, where := range param.Wheres {
+		err = withWhere(qb, where)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	total, err := qb.Clone().Count()
+	if err != nil {
+		return nil, err
+	}
+
+	orders := 0
+	for _, order := range param.Orders {
+		column := fmt.Sprintf("%v", order.Column)
+		if !columns[column] {
+			return nil, fmt.Errorf("the audit records can not be ordered by %s", column)
+		}
+		direction := "asc"
+		if strings.ToLower(order.Option) == "desc" {
+			direction = "desc"
+		}
+		qb.OrderBy(column, direction)
+		orders++
+	}
+
+	if orders == 0 {
+		qb.OrderBy("id", "desc")
+	}
+
+	rows, err := qb.Offset((page - 1) * pagesize).Limit(pagesize).Get()
+	if err != nil {
+		return nil, err
+	}
+
+	data := []map[string]interface{}{}
+	for _, row := range rows {
+		data = append(data, recordOf(row))
+	}
+
+	pagecnt := int(math.Ceil(float64(total) / float64(pagesize)))
+	next := page + 1
+	if next > pagecnt {
+		next = -1
+	}
+
+	prev := page - 1
+	if prev < 1 {
+		prev = -1
+	}
+
+	return map[string]interface{}{
+		"data":     data,
+		"total":    int(total),
+		"page":     page,
+		"pagesize": pagesize,
+		"pagecnt":  pagecnt,
+		"next":     next,
+		"prev":     prev,
+	}, nil
+}
+
+// Find get the record
+func Find(id interface{}) (map[string]interface{}, error) {
+	qb, err := newQuery()
+	if err != nil {
+		return nil, err
+	}
+
+	row, err := qb.Where("id", id).First()
+	if err != nil {
+		return nil, err
+	}
+
+	if row.Get("id") == nil {
+		return nil, fmt.Errorf("the audit record %v does not exist", id)
+	}
+	return recordOf(row), nil
+}
+
+// withWhere add the where condition, the nested wheres are not supported
+func withWhere(qb query.Query, where types.QueryWhere) error {
+	column := fmt.Sprintf("%v", where.Column)
+	if !columns[column] || len(where.Wheres) > 0 {
+		return fmt.Errorf("the audit records can not be queried by %s", column)
+	}
+
+	or := strings.ToLower(where.Method) == "orwhere"
+	if strings.ToLower(where.OP) == "in" {
+		if or {
+			qb.OrWhereIn(column, where.Value)
+			return nil
+		}
+		qb.WhereIn(column, where.Value)
+		return nil
+	}
+
+	op, has := operators[strings.ToLower(where.OP)]
+	if !has {
+		return fmt.Errorf("the audit records do not support the operator %s", where.OP)
+	}
+
+	value := where.Value
+	if op == "like" {
+		value = fmt.Sprintf("%%%v%%", value)
+	}
+
+	if or {
+		qb.OrWhere(column, op, value)
+		return nil
+	}
+	qb.Where(column, op, value)
+	return nil
+}
+
+// recordOf cast the row to the record map, the changes are decoded and the changed fields are listed
+func recordOf(row xun.R) map[string]interface{} {
+	record := map[string]interface{}{}
+	for key, value := range row {
+		record[key] = value
+	}
+
+	changes := map[string]Change{}
+	switch value := row["changes"].(type) {
+	case string:
+		jsoniter.UnmarshalFromString(value, &changes)
+	case []byte:
+		jsoniter.Unmarshal(value, &changes)
+	}
+
+	fields := []string{}
+	for name := range changes {
+		fields = append(fields, name)
+	}
+	sort.Strings(fields)
+
+	record["changes"] = changes
+	record["fields"] = strings.Join(fields, ", ")
+	return record
+}
+
+// newQuery the query of the audit table, the table is created if it does not exist
+func newQuery() (query.Query, error) {
+	storeLock.Lock()
+	defer storeLock.Unlock()
+
+	if store == nil {
+		qb, sch, err := connect()
+		if err != nil {
+			return nil, err
+		}
+
+		err = initTable(sch)
+		if err != nil {
+			return nil, err
+		}
+		store = qb
+	}
+
+	qb := store.New()
+	qb.Table(Table)
+	return qb, nil
+}
+
+func connect() (query.Query, schema.Schema, error) {
+	if Connector == "default" {
+		return capsule.Global.Query(), capsule.Global.Schema(), nil
+	}
+
+	conn, err := connector.Select(Connector)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !conn.Is(connector.DATABASE) {
+		return nil, nil, fmt.Errorf("the connector %s is not a database connector", Connector)
+	}
+
+	qb, err := conn.Query()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sch, err := conn.Schema()
+	if err != nil {
+		return nil, nil, err
+	}
+	return qb, sch, nil
+}
+
+func initTable(sch schema.Schema) error {
+	has, err := sch.HasTable(Table)
+	if err != nil {
+		return err
+	}
+
+	if has {
+		return nil
+	}
+
+	return sch.CreateTable(Table, func(table schema.Blueprint) {
+		table.ID("id")
+		table.String("widget", 20).Index()
+		table.String("widget_id", 200).Index()
+		table.String("action", 20).Index()
+		table.String("model", 200).Null().Index()
+		table.String("primary_key", 200).Null().Index()
+		table.String("actor", 200).Null().Index()
+		table.JSON("changes").Null()
+		table.TimestampTz("created_at").Index()
+	})
+}
+
+// reset drop the cached query, the table is checked again on the next use
+func reset() {
+	storeLock.Lock()
+	defer storeLock.Unlock()
+	store = nil
+}
@@ -0,0 +1,66 @@
+package audit
+
+import (
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// Option the audit option of the table, form and list widgets. "audit": true records all the write actions
+type Option struct {
+	Actions  []string `json:"actions,omitempty"` // the audited actions, e.g. ["update", "delete"], empty for all the write actions
+	Exclude  []string `json:"exclude,omitempty"` // the fields not recorded, the PASSWORD columns of the model are always excluded
+	disabled bool
+}
+
+// Record the audit record, one record per changed row
+type Record struct {
+	ID        int64             `json:"id"`
+	Widget    string            `json:"widget"`      // table, form, list
+	WidgetID  string            `json:"widget_id"`   // the widget id, e.g. pet
+	Action    string            `json:"action"`      // save, create, insert, update, updatewhere, updatein, delete, deletewhere, deletein
+	Model     string            `json:"model"`       // the model bound to the action
+	Primary   string            `json:"primary_key"` // the primary key of the row
+	Actor     string            `json:"actor"`       // the session user_id
+	Changes   map[string]Change `json:"changes"`     // the changed fields
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+// Change the field value before and after the write, the before value of the created rows and the after value of the deleted rows are nil
+type Change struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// Enabled check if the audit is enabled
+func (option *Option) Enabled() bool {
+	return option != nil && !option.disabled
+}
+
+// UnmarshalJSON accepts the bool value, false disables the audit
+func (option *Option) UnmarshalJSON(data []byte) error {
+	var enabled bool
+	if err := jsoniter.Unmarshal(data, &enabled); err == nil {
+		*option = Option{disabled: !enabled}
+		return nil
+	}
+
+	type alias Option
+	var value alias
+	err := jsoniter.Unmarshal(data, &value)
+	if err != nil {
+		return err
+	}
+	*option = Option(value)
+	return nil
+}
+
+// MarshalJSON the disabled option is encoded as false
+func (option Option) MarshalJSON() ([]byte, error) {
+	if option.disabled {
+		return []byte("false"), nil
+	}
+
+	type alias Option
+	return jsoniter.Marshal(alias(option))
+}
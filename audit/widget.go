@@ -0,0 +1,98 @@
+package audit
+
+import (
+	"fmt"
+	"strings"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// WidgetID the id of the table widget browsing the audit records, the application can override it with tables/__yao/audit.tab.yao
+const WidgetID = "__yao.audit"
+
+// Source the source of the audit trail widget, the guard is the default guard of all the actions.
+// The audit records have the values of all the audited widgets, the guard is required and "-" is not allowed.
+func Source(guard string) ([]byte, error) {
+	guard = strings.TrimSpace(guard)
+	if guard == "" || guard == "-" {
+		return nil, fmt.Errorf("the guard of the audit trail widget is required")
+	}
+
+	source := map[string]interface{}{}
+	err := jsoniter.Unmarshal(Widget, &source)
+	if err != nil {
+		return nil, err
+	}
+
+	source["action"].(map[string]interface{})["guard"] = guard
+	return jsoniter.Marshal(source)
+}
+
+// Widget the source of the table widget browsing the audit records, it is read-only. Use Source to set the guard
+var Widget = []byte(`{
+  "name": "Audit Trail",
+  "action": {
+    "search": { "process": "yao.audit.Search" },
+    "find": { "process": "yao.audit.Find" }
+  },
+  "layout": {
+    "primary": "id",
+    "header": { "preset": {}, "actions": [] },
+    "filter": {
+      "columns": [
+        { "name": "Actor", "width": 4 },
+        { "name": "Widget", "width": 4 },
+        { "name": "Action", "width": 4 },
+        { "name": "Primary Key", "width": 4 }
+      ]
+    },
+    "table": {
+      "columns": [
+        { "name": "ID", "width": 80 },
+        { "name": "Time", "width": 200 },
+        { "name": "Actor", "width": 120 },
+        { "name": "Widget", "width": 160 },
+        { "name": "Action", "width": 120 },
+        { "name": "Primary Key", "width": 120 },
+        { "name": "Fields" }
+      ],
+      "operation": { "hide": true, "actions": [] }
+    }
+  },
+  "fields": {
+    "filter": {
+      "Actor": { "bind": "where.actor.eq", "edit": { "type": "Input", "props": { "placeholder": "User ID" } } },
+      "Widget": { "bind": "where.widget_id.eq", "edit": { "type": "Input", "props": { "placeholder": "Widget" } } },
+      "Action": {
+        "bind": "where.action.eq",
+        "edit": {
+          "type": "Select",
+          "props": {
+            "placeholder": "Action",
+            "options": [
+              { "label": "save", "value": "save" },
+              { "label": "create", "value": "create" },
+              { "label": "insert", "value": "insert" },
+              { "label": "update", "value": "update" },
+              { "label": "updatewhere", "value": "updatewhere" },
+              { "label": "updatein", "value": "updatein" },
+              { "label": "delete", "value": "delete" },
+              { "label": "deletewhere", "value": "deletewhere" },
+              { "label": "deletein", "value": "deletein" }
+            ]
+          }
+        }
+      },
+      "Primary Key": { "bind": "where.primary_key.eq", "edit": { "type": "Input", "props": { "placeholder": "Primary Key" } } }
+    },
+    "table": {
+      "ID": { "bind": "id", "view": { "type": "Text", "props": {} } },
+      "Time": { "bind": "created_at", "view": { "type": "Text", "props": {} } },
+      "Actor": { "bind": "actor", "view": { "type": "Text", "props": {} } },
+      "Widget": { "bind": "widget_id", "view": { "type": "Text", "props": {} } },
+      "Action": { "bind": "action", "view": { "type": "Tag", "props": {} } },
+      "Primary Key": { "bind": "primary_key", "view": { "type": "Text", "props": {} } },
+      "Fields": { "bind": "fields", "view": { "type": "Text", "props": {} } }
+    }
+  }
+}`)
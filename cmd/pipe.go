@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/yaoapp/yao/config"
+	"github.com/yaoapp/yao/engine"
+	"github.com/yaoapp/yao/pipe"
+)
+
+var graphFormat string
+
+var pipeCmd = &cobra.Command{
+	Use:   "pipe",
+	Short: L("Pipe commands"),
+	Long:  L("Pipe commands"),
+	Args:  cobra.MinimumNArgs(1),
+	CompletionOptions: cobra.CompletionOptions{
+		DisableDefaultCmd: true,
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Fprintln(os.Stderr, L("One or more arguments are not correct"), args)
+		os.Exit(1)
+	},
+}
+
+var pipeCheckCmd = &cobra.Command{
+	Use:   "check",
+	Short: L("Validate the pipes"),
+	Long:  L("Validate the pipes"),
+	Run: func(cmd *cobra.Command, args []string) {
+		Boot()
+		err := engine.Load(config.Conf, engine.LoadOption{Action: "pipe.check"})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, color.RedString(L("Fatal: %s"), err.Error()))
+			os.Exit(1)
+		}
+
+		issues, err := pipe.ValidateAll()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, color.RedString(L("Fatal: %s"), err.Error()))
+			os.Exit(1)
+		}
+
+		errors := 0
+		warnings := 0
+		for _, issue := range issues {
+			if len(args) > 0 && issue.Pipe != args[0] {
+				continue
+			}
+
+			name := issue.Pipe
+			if issue.Node != "" {
+				name = fmt.Sprintf("%s [%s]", issue.Pipe, issue.Node)
+			}
+
+			if issue.Level == pipe.IssueError {
+				errors++
+				fmt.Println(color.RedString("  ERROR  "), color.WhiteString(name), issue.Message)
+				continue
+			}
+			warnings++
+			fmt.Println(color.YellowString("WARNING  "), color.WhiteString(name), issue.Message)
+		}
+
+		if errors > 0 {
+			fmt.Println(color.RedString(L("%d errors, %d warnings"), errors, warnings))
+			os.Exit(1)
+		}
+		fmt.Println(color.GreenString(L("✨DONE✨")), color.WhiteString(L("%d errors, %d warnings"), errors, warnings))
+	},
+}
+
+var pipeGraphCmd = &cobra.Command{
+	Use:   "graph",
+	Short: L("Export the pipe flowchart"),
+	Long:  L("Export the pipe flowchart"),
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 1 {
+			fmt.Fprintln(os.Stderr, color.RedString(L("yao pipe graph <pipe> [--format mermaid|dot]")))
+			os.Exit(1)
+		}
+
+		Boot()
+		err := engine.Load(config.Conf, engine.LoadOption{Action: "pipe.graph"})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, color.RedString(L("Fatal: %s"), err.Error()))
+			os.Exit(1)
+		}
+
+		p, err := pipe.Get(args[0])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, color.RedString(L("Fatal: %s"), err.Error()))
+			os.Exit(1)
+		}
+
+		switch graphFormat {
+		case "dot":
+			fmt.Print(p.DOT())
+		case "mermaid", "":
+			fmt.Print(p.Mermaid())
+		default:
+			fmt.Fprintln(os.Stderr, color.RedString(L("yao pipe graph <pipe> [--format mermaid|dot]")))
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	pipeGraphCmd.PersistentFlags().StringVarP(&graphFormat, "format", "F", "mermaid", L("Graph format, mermaid or dot"))
+	pipeCmd.AddCommand(pipeCheckCmd)
+	pipeCmd.AddCommand(pipeGraphCmd)
+}
@@ -63,6 +63,11 @@ var langs = map[string]string{
 	"🎉Successfully updated to version: %s🎉":      "🎉成功更新到版本: %s🎉",
 	"Print all version information":              "显示详细版本信息",
 	"SUI Template Engine":                        "SUI 模板引擎命令",
+	"Pipe commands":                              "Pipe 命令",
+	"Validate the pipes":                         "检查 Pipe 配置",
+	"Export the pipe flowchart":                  "导出 Pipe 流程图",
+	"Graph format, mermaid or dot":               "流程图格式, mermaid 或 dot",
+	"%d errors, %d warnings":                     "%d 个错误, %d 个警告",
 }
 
 // L Language switch
@@ -152,6 +157,7 @@ func init() {
 		// packCmd,
 		// studioCmd,
 		suiCmd,
+		pipeCmd,
 		// upgradeCmd,
 	)
 	// rootCmd.SetHelpCommand(helpCmd)
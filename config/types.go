@@ -20,6 +20,7 @@ type Config struct {
 	LogMaxBackups int      `json:"log_max_backups" env:"YAO_LOG_MAX_BACKUPS" envDefault:"3"`        // The max log backups, the default is 3
 	LogLocalTime  bool     `json:"log_local_time" env:"YAO_LOG_LOCAL_TIME" envDefault:"true"`
 	JWTSecret     string   `json:"jwt_secret,omitempty" env:"YAO_JWT_SECRET"`                 // The JWT Secret
+	AuditGuard    string   `json:"audit_guard,omitempty" env:"YAO_AUDIT_GUARD"`               // The guard of the audit trail widget, e.g. "bearer-jwt,scripts.guard.Admin". The widget is not loaded if it is not set
 	DB            Database `json:"db,omitempty"`                                              // The database config
 	AllowFrom     []string `json:"allowfrom,omitempty" envSeparator:"|" env:"YAO_ALLOW_FROM"` // Domain list the separator is |
 	Session       Session  `json:"session,omitempty"`                                         // Session Config
@@ -44,14 +45,16 @@ type Database struct {
 
 // Session 会话服务器
 type Session struct {
-	Store    string `json:"store,omitempty" env:"YAO_SESSION_STORE" envDefault:"file"`    // The session store. redis | file
-	File     string `json:"file,omitempty" env:"YAO_SESSION_FILE"`                        // The file path
-	Host     string `json:"host,omitempty" env:"YAO_SESSION_HOST" envDefault:"127.0.0.1"` // The redis host
-	Port     string `json:"port,omitempty" env:"YAO_SESSION_PORT" envDefault:"6379"`      // The redis port
-	Password string `json:"password,omitempty" env:"YAO_SESSION_PASSWORD"`                // The redis password
-	Username string `json:"username,omitempty" env:"YAO_SESSION_USERNAME"`                // The redis username
-	DB       string `json:"db,omitempty" env:"YAO_SESSION_DB" envDefault:"1"`             // The redis username
-	IsCLI    bool   `json:"iscli,omitempty" env:"YAO_SESSION_ISCLI" envDefault:"false"`   // Command Line Start
+	Store      string `json:"store,omitempty" env:"YAO_SESSION_STORE" envDefault:"file"`               // The session store. redis | file
+	File       string `json:"file,omitempty" env:"YAO_SESSION_FILE"`                                   // The file path
+	Host       string `json:"host,omitempty" env:"YAO_SESSION_HOST" envDefault:"127.0.0.1"`            // The redis host
+	Port       string `json:"port,omitempty" env:"YAO_SESSION_PORT" envDefault:"6379"`                 // The redis port
+	Password   string `json:"password,omitempty" env:"YAO_SESSION_PASSWORD"`                           // The redis password
+	Username   string `json:"username,omitempty" env:"YAO_SESSION_USERNAME"`                           // The redis username
+	DB         string `json:"db,omitempty" env:"YAO_SESSION_DB" envDefault:"1"`                        // The redis username
+	IsCLI      bool   `json:"iscli,omitempty" env:"YAO_SESSION_ISCLI" envDefault:"false"`              // Command Line Start
+	TokenTTL   int    `json:"token_ttl,omitempty" env:"YAO_SESSION_TOKEN_TTL" envDefault:"28800"`      // The access token lifetime of the login widget in seconds, the default is 8 hours
+	RefreshTTL int    `json:"refresh_ttl,omitempty" env:"YAO_SESSION_REFRESH_TTL" envDefault:"604800"` // The refresh token lifetime of the login widget in seconds, the default is 7 days
 }
 
 // Runtime Config
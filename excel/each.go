@@ -59,9 +59,12 @@ func NextRow(id string) ([]string, error) {
 	return nil, nil
 }
 
-// CloseRow done the sheet
+// CloseRow done the sheet, removes the temporary files of the iterator
 func CloseRow(id string) {
-	openRows.Delete(id)
+	value, ok := openRows.LoadAndDelete(id)
+	if ok {
+		value.(*Rows).Close()
+	}
 }
 
 // OpenColumn each cols of the sheet
@@ -24,6 +24,10 @@ type Excel struct {
 // openFiles the open files
 var openFiles = sync.Map{}
 
+// ReadXMLSizeLimit the read-only files extract the worksheets larger than the limit to
+// the temporary directory instead of the memory, the row iterator reads them as a stream.
+var ReadXMLSizeLimit int64 = 1 << 20
+
 // Open open the excel file
 func Open(path string, writable bool) (string, error) {
 
@@ -70,12 +74,11 @@ func Open(path string, writable bool) (string, error) {
 		return id, nil
 	}
 
-	file, err := os.Open(absPath)
-	if err != nil {
+	if _, err := os.Stat(absPath); err != nil {
 		return "", fmt.Errorf("open file %s failed: %w", absPath, err)
 	}
 
-	excelFile, err := excelize.OpenReader(file)
+	excelFile, err := excelize.OpenFile(absPath, excelize.Options{UnzipXMLSizeLimit: ReadXMLSizeLimit})
 	if err != nil {
 		return "", err
 	}
@@ -3,6 +3,7 @@ package excel
 import (
 	"github.com/xuri/excelize/v2"
 	"github.com/yaoapp/gou/process"
+	"github.com/yaoapp/kun/any"
 	"github.com/yaoapp/kun/exception"
 )
 
@@ -47,6 +48,13 @@ func init() {
 		"each.closecolumn": processCloseColumn,
 		"each.nextcolumn":  processNextColumn,
 
+		"stream.open":        processStreamOpen,
+		"stream.write":       processStreamWrite,
+		"stream.style":       processStreamStyle,
+		"stream.columnwidth": processStreamColumnWidth,
+		"stream.mergecell":   processStreamMergeCell,
+		"stream.close":       processStreamClose,
+
 		"convert.columnnametonumber":    processColumnNameToNumber,
 		"convert.columnnumbertoname":    processColumnNumberToName,
 		"convert.cellnametocoordinates": processCellNameToCoordinates,
@@ -736,3 +744,130 @@ func processSheetExists(process *process.Process) interface{} {
 
 	return xls.SheetExists(name)
 }
+
+// processStreamOpen process the excel.stream.open <file> [sheet]
+func processStreamOpen(process *process.Process) interface{} {
+	process.ValidateArgNums(1)
+	file := process.ArgsString(0)
+	sheet := ""
+	if len(process.Args) > 1 {
+		sheet = process.ArgsString(1)
+	}
+
+	id, err := OpenStream(file, sheet)
+	if err != nil {
+		exception.New("excel.stream.open %s error: %s", 500, file, err.Error()).Throw()
+	}
+	return id
+}
+
+// processStreamWrite process the excel.stream.write <id> <rows> [options]
+// options: {"style": <style id>, "height": <row height>}
+func processStreamWrite(process *process.Process) interface{} {
+	process.ValidateArgNums(2)
+	id := process.ArgsString(0)
+	values := process.Args[1]
+
+	stream, err := GetStream(id)
+	if err != nil {
+		exception.New("excel.stream.write %s error: %s", 500, id, err.Error()).Throw()
+	}
+
+	// Convert data to [][]interface{}
+	var rows [][]interface{}
+	if arr, ok := values.([]interface{}); ok {
+		for _, row := range arr {
+			if rowArr, ok := row.([]interface{}); ok {
+				rows = append(rows, rowArr)
+			} else {
+				rows = append(rows, []interface{}{row})
+			}
+		}
+	} else if arr, ok := values.([][]interface{}); ok {
+		rows = arr
+	} else {
+		rows = [][]interface{}{{values}}
+	}
+
+	opts := []excelize.RowOpts{}
+	if len(process.Args) > 2 {
+		option := process.ArgsMap(2)
+		opts = append(opts, excelize.RowOpts{
+			StyleID: any.Of(option["style"]).CInt(),
+			Height:  any.Of(option["height"]).CFloat64(),
+		})
+	}
+
+	err = stream.Write(rows, opts...)
+	if err != nil {
+		exception.New("excel.stream.write %s error: %s", 500, id, err.Error()).Throw()
+	}
+	return stream.Row()
+}
+
+// processStreamStyle process the excel.stream.style <id> <style>
+func processStreamStyle(process *process.Process) interface{} {
+	process.ValidateArgNums(2)
+	id := process.ArgsString(0)
+
+	stream, err := GetStream(id)
+	if err != nil {
+		exception.New("excel.stream.style %s error: %s", 500, id, err.Error()).Throw()
+	}
+
+	styleID, err := stream.Style(process.Args[1])
+	if err != nil {
+		exception.New("excel.stream.style %s error: %s", 500, id, err.Error()).Throw()
+	}
+	return styleID
+}
+
+// processStreamColumnWidth process the excel.stream.columnwidth <id> <startCol> <endCol> <width>
+func processStreamColumnWidth(process *process.Process) interface{} {
+	process.ValidateArgNums(4)
+	id := process.ArgsString(0)
+	startCol := process.ArgsString(1)
+	endCol := process.ArgsString(2)
+	width := any.Of(process.Args[3]).CFloat64()
+
+	stream, err := GetStream(id)
+	if err != nil {
+		exception.New("excel.stream.columnwidth %s error: %s", 500, id, err.Error()).Throw()
+	}
+
+	err = stream.SetColWidth(startCol, endCol, width)
+	if err != nil {
+		exception.New("excel.stream.columnwidth %s:%s:%s error: %s", 500, id, startCol, endCol, err.Error()).Throw()
+	}
+	return nil
+}
+
+// processStreamMergeCell process the excel.stream.mergecell <id> <start> <end>
+func processStreamMergeCell(process *process.Process) interface{} {
+	process.ValidateArgNums(3)
+	id := process.ArgsString(0)
+	start := process.ArgsString(1)
+	end := process.ArgsString(2)
+
+	stream, err := GetStream(id)
+	if err != nil {
+		exception.New("excel.stream.mergecell %s error: %s", 500, id, err.Error()).Throw()
+	}
+
+	err = stream.MergeCell(start, end)
+	if err != nil {
+		exception.New("excel.stream.mergecell %s:%s:%s error: %s", 500, id, start, end, err.Error()).Throw()
+	}
+	return nil
+}
+
+// processStreamClose process the excel.stream.close <id>
+func processStreamClose(process *process.Process) interface{} {
+	process.ValidateArgNums(1)
+	id := process.ArgsString(0)
+	err := CloseStream(id)
+	if err != nil {
+		exception.New("excel.stream.close %s error: %s", 500, id, err.Error()).Throw()
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+package excel
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/xuri/excelize/v2"
+	"github.com/yaoapp/yao/config"
+)
+
+// Stream the streaming writer, the rows are written to a temporary file
+// instead of being kept in memory. Rows must be written in order.
+type Stream struct {
+	id     string
+	path   string
+	abs    string
+	sheet  string
+	row    int // the last written row
+	create int64
+	file   *excelize.File
+	writer *excelize.StreamWriter
+	mutex  sync.Mutex
+}
+
+// openStreams the open streams
+var openStreams = sync.Map{}
+
+// OpenStream create the excel file for streaming write, the existing file will be overwritten when the stream is closed
+func OpenStream(path string, sheet string) (string, error) {
+	root := config.Conf.DataRoot
+	absPath, err := filepath.Abs(filepath.Join(root, path))
+	if err != nil {
+		return "", err
+	}
+
+	dir := filepath.Dir(absPath)
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		err := os.MkdirAll(dir, 0755)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	file := excelize.NewFile()
+	if sheet == "" {
+		sheet = file.GetSheetName(file.GetActiveSheetIndex())
+	}
+
+	err = file.SetSheetName(file.GetSheetName(file.GetActiveSheetIndex()), sheet)
+	if err != nil {
+		file.Close()
+		return "", err
+	}
+
+	writer, err := file.NewStreamWriter(sheet)
+	if err != nil {
+		file.Close()
+		return "", err
+	}
+
+	id := uuid.NewString()
+	openStreams.Store(id, &Stream{
+		id:     id,
+		path:   path,
+		abs:    absPath,
+		sheet:  sheet,
+		create: time.Now().Unix(),
+		file:   file,
+		writer: writer,
+	})
+	return id, nil
+}
+
+// GetStream get the stream
+func GetStream(id string) (*Stream, error) {
+	stream, ok := openStreams.Load(id)
+	if !ok {
+		return nil, fmt.Errorf("stream %s not found", id)
+	}
+	return stream.(*Stream), nil
+}
+
+// CloseStream flush the rows, save and close the file
+func CloseStream(id string) error {
+	stream, err := GetStream(id)
+	if err != nil {
+		return err
+	}
+
+	openStreams.Delete(id)
+	stream.mutex.Lock()
+	defer stream.mutex.Unlock()
+	defer stream.file.Close()
+
+	err = stream.writer.Flush()
+	if err != nil {
+		return err
+	}
+	return stream.file.SaveAs(stream.abs)
+}
+
+// Write append the rows. The cell value could be a map with the keys value, style and formula
+func (stream *Stream) Write(rows [][]interface{}, opts ...excelize.RowOpts) error {
+	stream.mutex.Lock()
+	defer stream.mutex.Unlock()
+
+	for _, row := range rows {
+		values := make([]interface{}, len(row))
+		for i, value := range row {
+			values[i] = streamCell(value)
+		}
+
+		cell, err := excelize.CoordinatesToCellName(1, stream.row+1)
+		if err != nil {
+			return err
+		}
+
+		err = stream.writer.SetRow(cell, values, opts...)
+		if err != nil {
+			return err
+		}
+		stream.row++
+	}
+	return nil
+}
+
+// Style create the style, returns the style ID
+func (stream *Stream) Style(style interface{}) (int, error) {
+	var value excelize.Style
+	raw, err := jsoniter.Marshal(style)
+	if err != nil {
+		return 0, err
+	}
+
+	err = jsoniter.Unmarshal(raw, &value)
+	if err != nil {
+		return 0, err
+	}
+
+	stream.mutex.Lock()
+	defer stream.mutex.Unlock()
+	return stream.file.NewStyle(&value)
+}
+
+// SetColWidth set the width of the columns, must be called before writing the rows
+func (stream *Stream) SetColWidth(startCol string, endCol string, width float64) error {
+	start, err := excelize.ColumnNameToNumber(startCol)
+	if err != nil {
+		return err
+	}
+
+	end, err := excelize.ColumnNameToNumber(endCol)
+	if err != nil {
+		return err
+	}
+
+	stream.mutex.Lock()
+	defer stream.mutex.Unlock()
+	return stream.writer.SetColWidth(start, end, width)
+}
+
+// MergeCell merge the cells
+func (stream *Stream) MergeCell(start string, end string) error {
+	stream.mutex.Lock()
+	defer stream.mutex.Unlock()
+	return stream.writer.MergeCell(start, end)
+}
+
+// Row the last written row number, 0 if no rows are written
+func (stream *Stream) Row() int {
+	stream.mutex.Lock()
+	defer stream.mutex.Unlock()
+	return stream.row
+}
+
+// streamCell convert the {"value": any, "style": int, "formula": string} map to the cell
+func streamCell(value interface{}) interface{} {
+	data, ok := value.(map[string]interface{})
+	if !ok {
+		return value
+	}
+
+	_, hasValue := data["value"]
+	_, hasStyle := data["style"]
+	_, hasFormula := data["formula"]
+	if !hasValue && !hasStyle && !hasFormula {
+		return value
+	}
+
+	cell := excelize.Cell{Value: data["value"]}
+	switch style := data["style"].(type) {
+	case int:
+		cell.StyleID = style
+	case int64:
+		cell.StyleID = int(style)
+	case float64:
+		cell.StyleID = int(style)
+	}
+
+	if formula, ok := data["formula"].(string); ok {
+		cell.Formula = formula
+	}
+	return cell
+}
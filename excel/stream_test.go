@@ -0,0 +1,192 @@
+package excel
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xuri/excelize/v2"
+	"github.com/yaoapp/gou/process"
+	"github.com/yaoapp/yao/config"
+	"github.com/yaoapp/yao/test"
+)
+
+func TestStream(t *testing.T) {
+	test.Prepare(t, config.Conf)
+	defer test.Clean()
+
+	file := filepath.Join("excel", "stream-test.xlsx")
+	defer os.Remove(filepath.Join(config.Conf.DataRoot, file))
+
+	id, err := OpenStream(file, "Orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stream, err := GetStream(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = stream.SetColWidth("A", "B", 24)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	style, err := stream.Style(map[string]interface{}{"font": map[string]interface{}{"bold": true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = stream.Write([][]interface{}{{"Order Report"}}, excelize.RowOpts{StyleID: style})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = stream.MergeCell("A1", "C1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = stream.Write([][]interface{}{{
+		map[string]interface{}{"value": "SN", "style": style},
+		map[string]interface{}{"value": "Amount", "style": style},
+		"Total",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rows := [][]interface{}{}
+	for i := 1; i <= 20000; i++ {
+		rows = append(rows, []interface{}{fmt.Sprintf("SO-%05d", i), i, map[string]interface{}{"formula": fmt.Sprintf("B%d*2", i+2)}})
+		if len(rows) == 1000 {
+			err = stream.Write(rows)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rows = [][]interface{}{}
+		}
+	}
+	assert.Equal(t, 20002, stream.Row())
+
+	// The column width must be set before writing the rows
+	assert.Error(t, stream.SetColWidth("C", "C", 10))
+
+	err = CloseStream(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = GetStream(id)
+	assert.Error(t, err)
+
+	// Read as a stream
+	h, err := Open(file, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Close(h)
+
+	xls, err := Get(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"Orders"}, xls.GetSheetList())
+
+	merged, err := xls.GetMergeCells("Orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assert.Equal(t, 1, len(merged)) {
+		assert.Equal(t, "A1", merged[0].GetStartAxis())
+		assert.Equal(t, "C1", merged[0].GetEndAxis())
+	}
+
+	width, err := xls.GetColWidth("Orders", "B")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, float64(24), width)
+
+	formula, err := xls.GetCellFormula("Orders", "C3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "B3*2", formula)
+
+	rid, err := xls.OpenRow("Orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer CloseRow(rid)
+
+	count := 0
+	var last []string
+	for row, err := NextRow(rid); err == nil && row != nil; row, err = NextRow(rid) {
+		count++
+		last = row
+	}
+	assert.Equal(t, 20002, count)
+	assert.Equal(t, "SO-20000", last[0])
+}
+
+func TestProcessStream(t *testing.T) {
+	test.Prepare(t, config.Conf)
+	defer test.Clean()
+
+	file := filepath.Join("excel", "stream-process-test.xlsx")
+	defer os.Remove(filepath.Join(config.Conf.DataRoot, file))
+
+	id, err := process.New("excel.stream.open", file).Exec()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = process.New("excel.stream.columnwidth", id, "A", "C", 18).Exec()
+	assert.Nil(t, err)
+
+	style, err := process.New("excel.stream.style", id, map[string]interface{}{"fill": map[string]interface{}{"type": "pattern", "pattern": 1, "color": []interface{}{"#E0EBF5"}}}).Exec()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	row, err := process.New("excel.stream.write", id, []interface{}{[]interface{}{"Name", "Age"}}, map[string]interface{}{"style": style, "height": 20}).Exec()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, row)
+
+	row, err = process.New("excel.stream.write", id, []interface{}{
+		[]interface{}{"Alice", 30},
+		[]interface{}{"Bob", 25},
+	}).Exec()
+	assert.Nil(t, err)
+	assert.Equal(t, 3, row)
+
+	_, err = process.New("excel.stream.mergecell", id, "C1", "C3").Exec()
+	assert.Nil(t, err)
+
+	_, err = process.New("excel.stream.close", id).Exec()
+	assert.Nil(t, err)
+
+	_, err = process.New("excel.stream.write", id, []interface{}{}).Exec()
+	assert.Error(t, err)
+
+	h, err := Open(file, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Close(h)
+
+	xls, err := Get(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := xls.ReadSheet("Sheet1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, [][]interface{}{{"Name", "Age"}, {"Alice", "30"}, {"Bob", "25"}}, data)
+}
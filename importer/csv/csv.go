@@ -1 +1,512 @@
 package csv
+
+import (
+	"bufio"
+	"bytes"
+	stdcsv "encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/yaoapp/kun/exception"
+	"github.com/yaoapp/kun/log"
+	"github.com/yaoapp/yao/importer/from"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// The encodings of the file
+const (
+	UTF8    = "utf-8"
+	UTF16LE = "utf-16le"
+	UTF16BE = "utf-16be"
+	GBK     = "gbk"
+)
+
+// sampleSize the bytes read for the encoding and delimiter detection
+const sampleSize = 64 * 1024
+
+// sampleRows the rows read for the header detection and the column types inference
+const sampleRows = 100
+
+// delimiters the candidates of the delimiter detection
+var delimiters = []rune{',', '\t', ';', '|'}
+
+// dateLayouts the layouts of the datetime column
+var dateLayouts = []string{
+	"2006-01-02",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04:05Z07:00",
+	"2006/01/02",
+	"2006/01/02 15:04:05",
+	"2006/1/2",
+	"2006/1/2 15:04:05",
+	"2006年1月2日",
+}
+
+// CSV csv or tsv file
+type CSV struct {
+	File      *os.File
+	Name      string
+	Encoding  string // utf-8, utf-16le, utf-16be, gbk
+	Delimiter rune
+	ColStart  int
+	RowStart  int
+	Header    bool // the file has the header row
+
+	columns []from.Column
+}
+
+// Open 打开 CSV/TSV 文件, 识别文件编码、分隔符和标题行
+func Open(filename string) *CSV {
+	file, err := os.Open(filename)
+	if err != nil {
+		exception.New("打开文件错误 %s", 400, err.Error()).Throw()
+	}
+
+	sample := make([]byte, sampleSize)
+	n, err := io.ReadFull(file, sample)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		file.Close()
+		exception.New("读取文件错误 %s", 400, err.Error()).Throw()
+	}
+	sample = sample[:n]
+
+	name := filepath.Base(filename)
+	csv := &CSV{
+		File:     file,
+		Name:     strings.TrimSuffix(name, filepath.Ext(name)),
+		Encoding: DetectEncoding(sample),
+	}
+
+	text, _, err := transform.Bytes(csv.decoder(), sample)
+	if err != nil {
+		file.Close()
+		exception.New("文件编码错误 %s %s", 400, csv.Encoding, err.Error()).Throw()
+	}
+
+	csv.Delimiter = DetectDelimiter(string(text))
+	if strings.ToLower(filepath.Ext(name)) == ".tsv" {
+		csv.Delimiter = '\t'
+	}
+
+	csv.inspect()
+	return csv
+}
+
+// Close 关闭文件句柄
+func (csv *CSV) Close() error {
+	if err := csv.File.Close(); err != nil {
+		log.Error("Close file error: %s", err.Error())
+		return err
+	}
+	return nil
+}
+
+// Inspect 基本信息
+func (csv *CSV) Inspect() from.Inspect {
+	return from.Inspect{
+		SheetName:  csv.Name,
+		SheetIndex: 0,
+		RowStart:   csv.RowStart,
+		ColStart:   csv.ColStart,
+	}
+}
+
+// Columns 读取列, 类型根据前 100 行数据推断
+func (csv *CSV) Columns() []from.Column {
+	return csv.columns
+}
+
+// Data 读取数据, row 从 0 开始
+func (csv *CSV) Data(row int, size int, axises []string) [][]interface{} {
+	data := [][]interface{}{}
+	reader := csv.reader()
+	for line := 0; line < row+size; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			log.With(log.F{"file": csv.Name, "line": line}).Error("读取数据出错 %s", err.Error())
+			break
+		}
+
+		if line < row {
+			continue
+		}
+
+		values, end := readLine(record, axises)
+		if end {
+			break
+		}
+		data = append(data, values)
+	}
+	return data
+}
+
+// Chunk 遍历数据, 遇到空行结束
+func (csv *CSV) Chunk(size int, axises []string, cb func(line int, data [][]interface{})) {
+	if size < 1 {
+		size = 1
+	}
+
+	reader := csv.reader()
+	line := 0
+	data := [][]interface{}{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			log.With(log.F{"file": csv.Name, "line": line}).Error("读取数据出错 %s", err.Error())
+			break
+		}
+
+		line++
+		if line <= csv.RowStart {
+			continue
+		}
+
+		values, end := readLine(record, axises)
+		if end {
+			break
+		}
+
+		data = append(data, values)
+		if len(data) == size {
+			cb(line, data)
+			data = [][]interface{}{}
+		}
+	}
+
+	// 最后一批数据
+	if len(data) > 0 {
+		cb(line, data)
+	}
+}
+
+// inspect 扫描标题行和列, 跳过开头的空行和单元格的标题
+func (csv *CSV) inspect() {
+	rows := [][]string{}
+	reader := csv.reader()
+	for len(rows) < sampleRows {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			exception.New("文件 %s 扫描行 %d 信息失败 %s", 400, csv.Name, len(rows), err.Error()).Throw()
+		}
+		rows = append(rows, record)
+	}
+
+	// 第一个不为空的行
+	start := -1
+	for i, row := range rows {
+		if countCells(row) > 0 {
+			start = i
+			break
+		}
+	}
+
+	if start == -1 {
+		csv.columns = []from.Column{}
+		return
+	}
+
+	// 只有一个单元格的标题 (例如: 订单列表), 下一行的单元格更多
+	if countCells(rows[start]) == 1 {
+		for i := start + 1; i < len(rows); i++ {
+			if n := countCells(rows[i]); n > 0 {
+				if n > 1 {
+					start = i
+				}
+				break
+			}
+		}
+	}
+
+	header := rows[start]
+	csv.Header = isHeader(header)
+	csv.RowStart = start
+	if csv.Header {
+		csv.RowStart = start + 1
+	}
+
+	// 数据行, 用于推断列类型
+	samples := rows[csv.RowStart:]
+
+	csv.columns = []from.Column{}
+	for i, cell := range header {
+		if strings.TrimSpace(cell) == "" {
+			continue
+		}
+
+		if csv.ColStart == 0 {
+			csv.ColStart = i + 1
+		}
+
+		name := strings.TrimSpace(cell)
+		if !csv.Header {
+			name = columnName(i)
+		}
+
+		csv.columns = append(csv.columns, from.Column{
+			Name: name,
+			Axis: positionToAxis(start, i),
+			Type: inferType(samples, i),
+		})
+	}
+}
+
+// reader returns the reader from the beginning of the file
+func (csv *CSV) reader() *stdcsv.Reader {
+	_, err := csv.File.Seek(0, io.SeekStart)
+	if err != nil {
+		exception.New("读取文件错误 %s", 400, err.Error()).Throw()
+	}
+
+	return csvReader(transform.NewReader(bufio.NewReader(csv.File), csv.decoder()), csv.Delimiter)
+}
+
+// decoder returns the decoder of the encoding, the BOM is removed
+func (csv *CSV) decoder() transform.Transformer {
+	var fallback encoding.Encoding = unicode.UTF8
+	switch csv.Encoding {
+	case UTF16LE:
+		fallback = unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM)
+	case UTF16BE:
+		fallback = unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM)
+	case GBK:
+		fallback = simplifiedchinese.GBK
+	}
+	return unicode.BOMOverride(fallback.NewDecoder())
+}
+
+// DetectEncoding detect the encoding of the sample, utf-8, utf-16le, utf-16be or gbk
+func DetectEncoding(sample []byte) string {
+	switch {
+	case bytes.HasPrefix(sample, []byte{0xEF, 0xBB, 0xBF}):
+		return UTF8
+	case bytes.HasPrefix(sample, []byte{0xFF, 0xFE}):
+		return UTF16LE
+	case bytes.HasPrefix(sample, []byte{0xFE, 0xFF}):
+		return UTF16BE
+	}
+
+	// The UTF-16 without BOM, the ASCII characters have the zero high bytes
+	if len(sample) >= 2 {
+		even, odd := 0, 0
+		for i := 0; i+1 < len(sample); i += 2 {
+			if sample[i] == 0 {
+				even++
+			}
+			if sample[i+1] == 0 {
+				odd++
+			}
+		}
+
+		pairs := len(sample) / 2
+		if odd > pairs/3 && even*10 < odd {
+			return UTF16LE
+		}
+		if even > pairs/3 && odd*10 < even {
+			return UTF16BE
+		}
+	}
+
+	// The sample may end in the middle of a character
+	valid := sample
+	for i := 0; i < utf8.UTFMax && len(valid) > 0 && !utf8.Valid(valid); i++ {
+		valid = valid[:len(valid)-1]
+	}
+
+	if utf8.Valid(valid) {
+		return UTF8
+	}
+	return GBK
+}
+
+// DetectDelimiter detect the delimiter of the text
+// The delimiter splits the most lines into the same number of the fields, the title lines (e.g. 订单列表) are ignored.
+func DetectDelimiter(text string) rune {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+
+	// The last line may be incomplete
+	if len(lines) > 1 {
+		lines = lines[:len(lines)-1]
+	}
+
+	if len(lines) > 20 {
+		lines = lines[:20]
+	}
+
+	sample := strings.Join(lines, "\n")
+	best := ','
+	bestLines, bestFields := 0, 0
+	for _, delimiter := range delimiters {
+		counts := map[int]int{}
+		reader := csvReader(strings.NewReader(sample), delimiter)
+		for {
+			record, err := reader.Read()
+			if err != nil {
+				break
+			}
+			counts[len(record)]++
+		}
+
+		for fields, n := range counts {
+			if fields < 2 {
+				continue
+			}
+
+			if n > bestLines || (n == bestLines && fields > bestFields) {
+				best = delimiter
+				bestLines, bestFields = n, fields
+			}
+		}
+	}
+	return best
+}
+
+func csvReader(r io.Reader, delimiter rune) *stdcsv.Reader {
+	reader := stdcsv.NewReader(r)
+	reader.Comma = delimiter
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	return reader
+}
+
+// readLine 读取给定列, 全部为空时返回 end
+func readLine(record []string, axises []string) ([]interface{}, bool) {
+	row := []interface{}{}
+	end := true
+	for _, axis := range axises {
+		c := axisToColumn(axis)
+		value := ""
+		if c >= 0 && c < len(record) {
+			value = record[c]
+		}
+
+		row = append(row, value)
+		if value != "" {
+			end = false
+		}
+	}
+	return row, end
+}
+
+// isHeader the header row has no numbers and dates
+func isHeader(row []string) bool {
+	for _, cell := range row {
+		switch cellType(strings.TrimSpace(cell)) {
+		case from.TNumber, from.TDatetime:
+			return false
+		}
+	}
+	return true
+}
+
+// inferType the type of the column, TString if the values are mixed
+func inferType(rows [][]string, col int) byte {
+	typ := from.TUnknown
+	for _, row := range rows {
+		if col >= len(row) {
+			continue
+		}
+
+		t := cellType(strings.TrimSpace(row[col]))
+		if t == from.TUnknown {
+			continue
+		}
+
+		if typ == from.TUnknown {
+			typ = t
+			continue
+		}
+
+		if typ != t {
+			return from.TString
+		}
+	}
+	return typ
+}
+
+func cellType(value string) byte {
+	if value == "" {
+		return from.TUnknown
+	}
+
+	switch strings.ToLower(value) {
+	case "true", "false":
+		return from.TBool
+	}
+
+	if _, err := strconv.ParseFloat(strings.ReplaceAll(value, ",", ""), 64); err == nil {
+		return from.TNumber
+	}
+
+	for _, layout := range dateLayouts {
+		if _, err := time.Parse(layout, value); err == nil {
+			return from.TDatetime
+		}
+	}
+	return from.TString
+}
+
+func countCells(row []string) int {
+	n := 0
+	for _, cell := range row {
+		if strings.TrimSpace(cell) != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// columnName the name of the column without header, A, B, ... AA
+func columnName(col int) string {
+	name := ""
+	col++
+	for col > 0 {
+		col--
+		name = fmt.Sprintf("%c%s", 'A'+col%26, name)
+		col /= 26
+	}
+	return name
+}
+
+func positionToAxis(row, col int) string {
+	if row < 0 || col < 0 {
+		return ""
+	}
+	return columnName(col) + strconv.Itoa(row+1)
+}
+
+// axisToColumn returns the column index of the axis, -1 if the axis is invalid
+func axisToColumn(axis string) int {
+	col := 0
+	for _, char := range axis {
+		if char >= 'A' && char <= 'Z' {
+			col = col*26 + int(char-'A'+1)
+		} else if char >= 'a' && char <= 'z' {
+			col = col*26 + int(char-'a'+1)
+		} else {
+			break
+		}
+	}
+	return col - 1
+}
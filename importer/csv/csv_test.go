@@ -0,0 +1,147 @@
+package csv
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yaoapp/yao/importer/from"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+const simple = "订单列表\n\n订单号,客户,金额,下单时间,已付款\nSO-001,张三,12.50,2024-01-02,true\nSO-002,李四,\"1,200\",2024-01-03 10:00:00,false\nSO-003,王五,8,2024/1/4,true\n"
+
+func TestOpen(t *testing.T) {
+	file := Open(write(t, "simple.csv", []byte(simple)))
+	defer file.Close()
+
+	assert.Equal(t, UTF8, file.Encoding)
+	assert.Equal(t, ',', file.Delimiter)
+	assert.True(t, file.Header)
+
+	inspect := file.Inspect()
+	assert.Equal(t, "simple", inspect.SheetName)
+	assert.Equal(t, 1, inspect.ColStart)
+	assert.Equal(t, 2, inspect.RowStart)
+
+	columns := file.Columns()
+	assert.Equal(t, []from.Column{
+		{Name: "订单号", Axis: "A2", Type: from.TString},
+		{Name: "客户", Axis: "B2", Type: from.TString},
+		{Name: "金额", Axis: "C2", Type: from.TNumber},
+		{Name: "下单时间", Axis: "D2", Type: from.TDatetime},
+		{Name: "已付款", Axis: "E2", Type: from.TBool},
+	}, columns)
+
+	data := file.Data(inspect.RowStart, 2, []string{"A2", "C2", "Z2"})
+	assert.Equal(t, [][]interface{}{{"SO-001", "12.50", ""}, {"SO-002", "1,200", ""}}, data)
+}
+
+func TestOpenEncoding(t *testing.T) {
+	gbk, err := simplifiedchinese.GBK.NewEncoder().String(simple)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	utf16, err := unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewEncoder().String(strings.ReplaceAll(simple, ",", "\t"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	utf16be, err := unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM).NewEncoder().String(strings.ReplaceAll(simple, ",", ";"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]struct {
+		content   []byte
+		encoding  string
+		delimiter rune
+	}{
+		"gbk.csv":     {[]byte(gbk), GBK, ','},
+		"bom.csv":     {append([]byte{0xEF, 0xBB, 0xBF}, []byte(simple)...), UTF8, ','},
+		"utf16.tsv":   {[]byte(utf16), UTF16LE, '\t'},
+		"utf16be.csv": {[]byte(utf16be), UTF16BE, ';'},
+	}
+
+	for name, f := range files {
+		file := Open(write(t, name, f.content))
+		assert.Equal(t, f.encoding, file.Encoding, name)
+		assert.Equal(t, f.delimiter, file.Delimiter, name)
+
+		columns := file.Columns()
+		if assert.Equal(t, 5, len(columns), name) {
+			assert.Equal(t, "订单号", columns[0].Name, name)
+		}
+
+		data := file.Data(file.RowStart, 1, []string{"B2"})
+		assert.Equal(t, [][]interface{}{{"张三"}}, data, name)
+		file.Close()
+	}
+}
+
+func TestOpenWithoutHeader(t *testing.T) {
+	file := Open(write(t, "data.tsv", []byte("\t1001\t12.5\n\t1002\t8\n")))
+	defer file.Close()
+
+	assert.False(t, file.Header)
+	assert.Equal(t, 2, file.ColStart)
+	assert.Equal(t, 0, file.RowStart)
+	assert.Equal(t, []from.Column{
+		{Name: "B", Axis: "B1", Type: from.TNumber},
+		{Name: "C", Axis: "C1", Type: from.TNumber},
+	}, file.Columns())
+
+	data := file.Data(file.RowStart, 10, []string{"B1", "C1"})
+	assert.Equal(t, [][]interface{}{{"1001", "12.5"}, {"1002", "8"}}, data)
+}
+
+func TestChunk(t *testing.T) {
+	content := strings.Builder{}
+	content.WriteString("name,value\n")
+	for i := 0; i < 2500; i++ {
+		content.WriteString("row,1\n")
+	}
+	content.WriteString(",\nafter,1\n")
+
+	file := Open(write(t, "large.csv", []byte(content.String())))
+	defer file.Close()
+
+	lines := []int{}
+	total := 0
+	file.Chunk(1000, []string{"A1", "B1"}, func(line int, data [][]interface{}) {
+		lines = append(lines, line)
+		total += len(data)
+		assert.Equal(t, []interface{}{"row", "1"}, data[0])
+	})
+
+	// The chunk ends at the empty row
+	assert.Equal(t, []int{1001, 2001, 2502}, lines)
+	assert.Equal(t, 2500, total)
+}
+
+func TestDetect(t *testing.T) {
+	assert.Equal(t, UTF8, DetectEncoding([]byte("hello")))
+	assert.Equal(t, UTF8, DetectEncoding([]byte("你好")[:4])) // truncated in the middle of the character
+	assert.Equal(t, GBK, DetectEncoding([]byte{0xC4, 0xE3, 0xBA, 0xC3, 0x2C, 0x61}))
+	assert.Equal(t, UTF16LE, DetectEncoding([]byte{'a', 0, ',', 0, 'b', 0}))
+	assert.Equal(t, UTF16BE, DetectEncoding([]byte{0, 'a', 0, ',', 0, 'b'}))
+
+	assert.Equal(t, ',', DetectDelimiter("a,b\n1,2\n"))
+	assert.Equal(t, '\t', DetectDelimiter("a\tb\tc,d\n1\t2\t3,4\n"))
+	assert.Equal(t, ';', DetectDelimiter("a;b;c\n\"1;\";2;3\n"))
+	assert.Equal(t, '|', DetectDelimiter("a|b\n1|2\nincomplete"))
+	assert.Equal(t, ',', DetectDelimiter("single"))
+}
+
+func write(t *testing.T, name string, content []byte) string {
+	file := filepath.Join(t.TempDir(), name)
+	err := os.WriteFile(file, content, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
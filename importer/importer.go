@@ -1,6 +1,7 @@
 package importer
 
 import (
+	"context"
 	"crypto/sha256"
 	"fmt"
 	"path/filepath"
@@ -8,6 +9,7 @@ import (
 	"strings"
 
 	"github.com/google/uuid"
+	jsoniter "github.com/json-iterator/go"
 	"github.com/yaoapp/gou/application"
 	"github.com/yaoapp/gou/fs"
 	"github.com/yaoapp/gou/process"
@@ -15,6 +17,7 @@ import (
 	"github.com/yaoapp/kun/exception"
 	"github.com/yaoapp/kun/log"
 	"github.com/yaoapp/yao/config"
+	"github.com/yaoapp/yao/importer/csv"
 	"github.com/yaoapp/yao/importer/from"
 	"github.com/yaoapp/yao/importer/xlsx"
 	"github.com/yaoapp/yao/share"
@@ -54,6 +57,16 @@ func Load(cfg config.Config) error {
 			return fmt.Errorf("%s 导入配置错误. %s", id, err.Error())
 		}
 
+		if importer.Target != nil {
+			err = importer.Target.validate(&importer)
+			if err != nil {
+				return fmt.Errorf("%s 导入配置错误. %s", id, err.Error())
+			}
+		} else if importer.Process == "" {
+			return fmt.Errorf("%s 导入配置错误. 未设置导入处理器 (process) 或数据模型 (target)", id)
+		}
+
+		importer.ID = id
 		Importers[id] = &importer
 		return nil
 	}, exts...)
@@ -75,6 +88,9 @@ func Open(name string) from.Source {
 	case "xlsx":
 		file := filepath.Join(DataRoot, name)
 		return xlsx.Open(file)
+	case "csv", "tsv":
+		file := filepath.Join(DataRoot, name)
+		return csv.Open(file)
 	}
 	exception.New("暂不支持: %s 文件导入", 400, ext).Throw()
 	return nil
@@ -86,10 +102,17 @@ func (imp *Importer) WithSid(sid string) *Importer {
 	return imp
 }
 
-// AutoMapping 根据文件信息获取字段映射表
+// AutoMapping 根据文件信息获取字段映射表, 优先使用已保存的映射模板
 func (imp *Importer) AutoMapping(src from.Source) *Mapping {
-	sourceColumns := getSourceColumns(src)
+	columns := src.Columns()
 	sourceInspect := src.Inspect()
+	if imp.Option.UseTemplate {
+		if mapping := imp.templateMapping(fingerprint(columns), sourceInspect); mapping != nil {
+			return mapping
+		}
+	}
+
+	sourceColumns := getSourceColumns(columns)
 	mapping := &Mapping{
 		Columns:          []*Binding{},
 		AutoMatching:     true,
@@ -150,20 +173,30 @@ func (imp *Importer) Chunk(src from.Source, mapping *Mapping, cb func(line int,
 
 // DataClean 清洗数据
 func (imp *Importer) DataClean(data [][]interface{}, bindings []*Binding) ([]string, [][]interface{}) {
+	columns, data, _ := imp.dataClean(data, bindings)
+	return columns, data
+}
+
+// dataClean 清洗数据, 同时返回未通过清洗规则的数据行(本批数据中的序号)和原因
+func (imp *Importer) dataClean(data [][]interface{}, bindings []*Binding) ([]string, [][]interface{}, map[int]string) {
 	columns := []string{}
 	new := [][]interface{}{}
+	reasons := map[int]string{}
 
 	for _, binding := range bindings {
 		columns = append(columns, binding.Field)
 	}
 	// 清洗数据
-	for _, row := range data {
+	for idx, row := range data {
 		success := true
 		for i, binding := range bindings { // 调用字段清洗处理器
 			for _, rule := range binding.Rules {
 				update, ok := DataValidate(row, row[i], rule)
 				if !ok {
 					success = false
+					if _, has := reasons[idx]; !has {
+						reasons[idx] = fmt.Sprintf("%s 未通过清洗规则 %s", binding.Label, imp.ruleLabel(rule))
+					}
 				} else {
 					row = update
 				}
@@ -174,7 +207,7 @@ func (imp *Importer) DataClean(data [][]interface{}, bindings []*Binding) ([]str
 	}
 
 	columns = append(columns, "__effected")
-	return columns, new
+	return columns, new, reasons
 }
 
 // DataValidate 数值校验
@@ -238,11 +271,7 @@ func (imp *Importer) DataPreview(src from.Source, page int, size int, mapping *M
 // MappingPreview 预览字段映射关系
 func (imp *Importer) MappingPreview(src from.Source) *Mapping {
 
-	// 模板匹配(下一版实现)
-	// tpl := imp.Fingerprint(src)
-	// 查找已有模板
-
-	mapping := imp.AutoMapping(src) // 自动匹配
+	mapping := imp.AutoMapping(src) // 模板匹配或自动匹配
 
 	// 预设值
 	columns, rows := imp.DataGet(src, 1, 1, mapping)
@@ -384,8 +413,11 @@ func (imp *Importer) MappingSetting(src from.Source) map[string]interface{} {
 
 // Fingerprint 文件结构指纹
 func (imp *Importer) Fingerprint(src from.Source) string {
+	return fingerprint(src.Columns())
+}
+
+func fingerprint(columns []from.Column) string {
 	keys := []string{}
-	columns := src.Columns()
 	for _, col := range columns {
 		keys = append(keys, fmt.Sprintf("%s|%d", col.Name, col.Type))
 	}
@@ -395,83 +427,134 @@ func (imp *Importer) Fingerprint(src from.Source) string {
 	return fmt.Sprintf("%x", hash.Sum(nil))
 }
 
-// SaveAsTemplate 保存为映射模板
-func (imp *Importer) SaveAsTemplate(src from.Source) {
+// Run 运行导入(同步)
+func (imp *Importer) Run(src from.Source, mapping *Mapping) interface{} {
+	return imp.execute(context.Background(), src, mapping, &Job{})
 }
 
-// Run 运行导入
-func (imp *Importer) Run(src from.Source, mapping *Mapping) interface{} {
+// DryRun 试运行, 校验数据但不写入. 写入数据模型时执行相同的写入操作后回滚
+func (imp *Importer) DryRun(src from.Source, mapping *Mapping) map[string]interface{} {
+	job := &Job{DryRun: true}
+	output := imp.execute(context.Background(), src, mapping, job)
+	res := map[string]interface{}{}
+	for key, value := range output.(map[string]int) {
+		res[key] = value
+	}
+	res["errors"] = job.Errors
+	return res
+}
+
+// execute 逐批导入数据, 记录导入进度和失败的数据行, ctx 取消后跳过剩余的数据
+func (imp *Importer) execute(ctx context.Context, src from.Source, mapping *Mapping, job *Job) interface{} {
 	if mapping == nil {
 		mapping = imp.AutoMapping(src)
 	}
 
+	if imp.Target != nil {
+		err := imp.writeModel(ctx, src, mapping, job)
+		if err != nil {
+			exception.New("导入失败: %s", 500, err.Error()).Throw()
+		}
+	} else {
+		imp.callProcess(ctx, src, mapping, job)
+	}
+
+	total, failed, ignore := job.counts()
+	output := map[string]int{
+		"total":   total,
+		"success": total - failed - ignore,
+		"failure": failed,
+		"ignore":  ignore,
+	}
+
+	if imp.Output != "" && !job.DryRun {
+		res, err := process.New(imp.Output, output).WithSID(imp.Sid).Exec()
+		if err != nil {
+			log.With(log.F{"output": imp.Output}).Error(err.Error())
+			return output
+		}
+		return res
+	}
+
+	return output
+}
+
+// callProcess 逐批调用导入处理器
+func (imp *Importer) callProcess(ctx context.Context, src from.Source, mapping *Mapping, job *Job) {
 	id := uuid.NewString()
 	page := 0
-	total := 0
-	failed := 0
-	ignore := 0
 	imp.Chunk(src, mapping, func(line int, data [][]interface{}) {
+		if ctx.Err() != nil {
+			return
+		}
+
 		page++
 		length := len(data)
-		total = total + length
-		columns, data := imp.DataClean(data, mapping.Columns)
+		first := line - length + 1 // 本批数据第一行的行号
+		columns, data, reasons := imp.dataClean(data, mapping.Columns)
+		if job.DryRun { // 试运行只校验清洗规则
+			job.chunk(first, data, len(reasons), 0, reasons, nil)
+			return
+		}
+
 		process, err := process.Of(imp.Process, columns, data, id, page)
 		if err != nil {
-			failed = failed + length
+			job.chunk(first, data, length, 0, nil, err)
 			log.With(log.F{"line": line}).Error("导入失败: %s", err.Error())
 			return
 		}
 
 		response, err := process.WithSID(imp.Sid).Exec()
 		if err != nil {
-			failed = failed + length
+			job.chunk(first, data, length, 0, nil, err)
 			log.With(log.F{"line": line}).Error("导入失败: %s", err.Error())
 			return
 		}
 
 		if res, ok := response.([]int); ok && len(res) > 1 {
-			failed = failed + res[0]
-			ignore = ignore + res[1]
+			job.chunk(first, data, res[0], res[1], reasons, nil)
 			return
 		} else if res, ok := response.([]int64); ok && len(res) > 1 {
-			failed = failed + int(res[0])
-			ignore = ignore + int(res[1])
+			job.chunk(first, data, int(res[0]), int(res[1]), reasons, nil)
 			return
 		} else if res, ok := response.([]interface{}); ok && len(res) > 1 {
-			failed = failed + any.Of(res[0]).CInt()
-			ignore = ignore + any.Of(res[1]).CInt()
+			if len(res) > 2 {
+				rowErrors(reasons, res[2])
+			}
+			job.chunk(first, data, any.Of(res[0]).CInt(), any.Of(res[1]).CInt(), reasons, nil)
 			return
 		}
 
+		job.chunk(first, data, 0, 0, reasons, nil)
 		log.With(log.F{"line": line, "response": response, "length": length}).Error("导入处理器未返回失败结果")
 	})
+}
 
-	output := map[string]int{
-		"total":   total,
-		"success": total - failed - ignore,
-		"failure": failed,
-		"ignore":  ignore,
+// rowErrors 读取导入处理器返回的失败数据行 [{"row": 本批数据中的序号, "message": "失败原因"}]
+func rowErrors(reasons map[int]string, value interface{}) {
+	raw, err := jsoniter.Marshal(value)
+	if err != nil {
+		return
 	}
 
-	if imp.Output != "" {
-		res, err := process.New(imp.Output, output).WithSID(imp.Sid).Exec()
-		if err != nil {
-			log.With(log.F{"output": imp.Output}).Error(err.Error())
-			return output
-		}
-		return res
+	rows := []struct {
+		Row     int    `json:"row"`
+		Message string `json:"message"`
+	}{}
+	err = jsoniter.Unmarshal(raw, &rows)
+	if err != nil {
+		log.With(log.F{"errors": value}).Error("导入处理器返回的失败数据行格式不正确: %s", err.Error())
+		return
 	}
 
-	return output
+	for _, row := range rows {
+		reasons[row.Row] = row.Message
+	}
 }
 
-// Start 运行导入(异步)
-func (imp *Importer) Start() {}
-
 // getSourceColumns 读取源数据字段映射表
-func getSourceColumns(src from.Source) map[string]from.Column {
+func getSourceColumns(columns []from.Column) map[string]from.Column {
 	res := map[string]from.Column{}
-	columns := src.Columns()
 	for _, col := range columns {
 		name := col.Name
 		if name != "" {
@@ -515,6 +598,13 @@ func (imp *Importer) getSourceOption(src from.Source) []map[string]interface{} {
 	return option
 }
 
+func (imp *Importer) ruleLabel(rule string) string {
+	if label, has := imp.Rules[rule]; has && label != "" {
+		return label
+	}
+	return rule
+}
+
 func (imp *Importer) getRulesOption() []map[string]interface{} {
 	option := []map[string]interface{}{}
 	keys := []string{}
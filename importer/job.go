@@ -0,0 +1,318 @@
+package importer
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yaoapp/kun/log"
+	"github.com/yaoapp/yao/excel"
+	"github.com/yaoapp/yao/importer/from"
+)
+
+// 导入任务状态
+const (
+	JobPending  = "pending"
+	JobRunning  = "running"
+	JobDone     = "done"
+	JobFailed   = "failed"
+	JobCanceled = "canceled"
+)
+
+// jobMaxErrors 每个任务最多记录的失败数据行
+const jobMaxErrors = 10000
+
+// jobTTL 任务结束后保留的时长, 过期后不能再查询进度
+var jobTTL = 24 * time.Hour
+
+// jobs 导入任务
+var jobs = sync.Map{}
+
+// Job 导入任务(异步)
+type Job struct {
+	ID        string      `json:"id"`
+	Importer  string      `json:"importer"`
+	File      string      `json:"file"`
+	Sid       string      `json:"-"`
+	DryRun    bool        `json:"dry_run"` // 试运行, 校验数据不写入
+	Status    string      `json:"status"`
+	Done      int         `json:"done"`             // 已处理的数据行
+	Failure   int         `json:"failure"`          // 失败的数据行
+	Ignore    int         `json:"ignore"`           // 忽略的数据行
+	Errors    []RowError  `json:"-"`                // 失败的数据行, 最多记录 jobMaxErrors 行
+	Report    string      `json:"report,omitempty"` // 错误报告文件(数据目录), 通过 fs.system.Download 下载
+	Output    interface{} `json:"output,omitempty"`
+	Error     string      `json:"error,omitempty"`
+	CreatedAt time.Time   `json:"created_at"`
+	StartedAt *time.Time  `json:"started_at,omitempty"`
+	EndedAt   *time.Time  `json:"ended_at,omitempty"`
+	cancel    context.CancelFunc
+	mutex     sync.RWMutex
+}
+
+// RowError 失败的数据行
+type RowError struct {
+	Line    int           `json:"line"`    // 数据源行号
+	Data    []interface{} `json:"data"`    // 清洗后的数据
+	Message string        `json:"message"` // 失败原因
+}
+
+// Start 运行导入(异步), 返回导入任务. 任务结束后关闭数据源
+func (imp *Importer) Start(src from.Source, file string, mapping *Mapping, dryRun bool) *Job {
+	if mapping == nil {
+		mapping = imp.AutoMapping(src)
+	}
+
+	sweepJobs()
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &Job{
+		ID:        uuid.NewString(),
+		Importer:  imp.ID,
+		File:      file,
+		Sid:       imp.Sid,
+		DryRun:    dryRun,
+		Status:    JobPending,
+		Errors:    []RowError{},
+		CreatedAt: time.Now(),
+		cancel:    cancel,
+	}
+	jobs.Store(job.ID, job)
+
+	// 导入器为共享配置, 复制一份避免其他请求修改 Sid
+	run := *imp
+	go func() {
+		defer src.Close()
+		defer cancel()
+
+		job.start()
+		output, message := job.run(ctx, &run, src, mapping)
+		job.writeReport(mapping) // 先生成错误报告, 任务结束时可以下载
+		switch {
+		case message != "":
+			job.finish(JobFailed, output, message)
+		case ctx.Err() != nil:
+			job.finish(JobCanceled, output, "")
+		default:
+			job.finish(JobDone, output, "")
+		}
+	}()
+
+	return job
+}
+
+// GetJob 读取导入任务
+func GetJob(id string) (*Job, error) {
+	job, has := jobs.Load(id)
+	if !has {
+		return nil, fmt.Errorf("导入任务 %s 不存在或已过期", id)
+	}
+	return job.(*Job), nil
+}
+
+// CancelJob 取消导入任务, 已导入的数据不会回滚
+func CancelJob(id string) (*Job, error) {
+	job, err := GetJob(id)
+	if err != nil {
+		return nil, err
+	}
+
+	job.mutex.Lock()
+	defer job.mutex.Unlock()
+	if job.Status != JobPending && job.Status != JobRunning {
+		return nil, fmt.Errorf("导入任务 %s 已结束", id)
+	}
+
+	if job.cancel != nil {
+		job.cancel()
+	}
+	return job, nil
+}
+
+// Ended 任务是否已结束
+func (job *Job) Ended() bool {
+	job.mutex.RLock()
+	defer job.mutex.RUnlock()
+	return job.EndedAt != nil
+}
+
+// Map 转换为映射表
+func (job *Job) Map() map[string]interface{} {
+	job.mutex.RLock()
+	defer job.mutex.RUnlock()
+	return map[string]interface{}{
+		"id":         job.ID,
+		"importer":   job.Importer,
+		"file":       job.File,
+		"status":     job.Status,
+		"dry_run":    job.DryRun,
+		"done":       job.Done,
+		"success":    job.Done - job.Failure - job.Ignore,
+		"failure":    job.Failure,
+		"ignore":     job.Ignore,
+		"errors":     len(job.Errors),
+		"report":     job.Report,
+		"output":     job.Output,
+		"error":      job.Error,
+		"created_at": job.CreatedAt,
+		"started_at": job.StartedAt,
+		"ended_at":   job.EndedAt,
+	}
+}
+
+// run 执行导入, 处理器抛出异常时返回异常信息
+func (job *Job) run(ctx context.Context, imp *Importer, src from.Source, mapping *Mapping) (output interface{}, message string) {
+	defer func() {
+		if r := recover(); r != nil {
+			message = fmt.Sprintf("%v", r)
+			log.With(log.F{"job": job.ID, "importer": job.Importer}).Error("导入任务失败: %s", message)
+		}
+	}()
+	return imp.execute(ctx, src, mapping, job), ""
+}
+
+func (job *Job) start() {
+	job.mutex.Lock()
+	defer job.mutex.Unlock()
+	now := time.Now()
+	job.Status = JobRunning
+	job.StartedAt = &now
+}
+
+func (job *Job) finish(status string, output interface{}, message string) {
+	job.mutex.Lock()
+	defer job.mutex.Unlock()
+	if job.EndedAt != nil {
+		return
+	}
+	now := time.Now()
+	job.Status = status
+	job.Output = output
+	job.Error = message
+	job.EndedAt = &now
+}
+
+// chunk 记录一批数据的导入结果. data 为清洗后的数据(最后一列为 __effected)
+// 调用处理器出错时 err 不为空, 整批数据记为失败
+func (job *Job) chunk(first int, data [][]interface{}, failed int, ignore int, reasons map[int]string, err error) {
+	job.mutex.Lock()
+	defer job.mutex.Unlock()
+
+	job.Done = job.Done + len(data)
+	job.Failure = job.Failure + failed
+	job.Ignore = job.Ignore + ignore
+
+	for i, row := range data {
+		message := ""
+		if err != nil {
+			message = err.Error()
+		} else if reason, has := reasons[i]; has {
+			message = reason
+		} else {
+			continue
+		}
+
+		if len(job.Errors) >= jobMaxErrors {
+			return
+		}
+
+		values := row
+		if len(values) > 0 {
+			values = values[:len(values)-1]
+		}
+		job.Errors = append(job.Errors, RowError{Line: first + i, Data: values, Message: message})
+	}
+}
+
+// rollback 事务回滚后已处理的数据行全部记为失败
+func (job *Job) rollback() {
+	job.mutex.Lock()
+	defer job.mutex.Unlock()
+	job.Failure = job.Done
+	job.Ignore = 0
+}
+
+// counts 已处理, 失败和忽略的数据行
+func (job *Job) counts() (int, int, int) {
+	job.mutex.RLock()
+	defer job.mutex.RUnlock()
+	return job.Done, job.Failure, job.Ignore
+}
+
+// writeReport 生成错误报告 /importer/reports/<job>.xlsx, 没有失败的数据行不生成
+func (job *Job) writeReport(mapping *Mapping) {
+	job.mutex.RLock()
+	errors := job.Errors
+	job.mutex.RUnlock()
+	if len(errors) == 0 || mapping == nil {
+		return
+	}
+
+	header := []interface{}{"行号"}
+	for _, binding := range mapping.Columns {
+		header = append(header, binding.Label)
+	}
+	header = append(header, "错误原因")
+
+	rows := [][]interface{}{header}
+	for _, e := range errors {
+		row := []interface{}{e.Line}
+		for i := range mapping.Columns {
+			var value interface{} = nil
+			if i < len(e.Data) {
+				value = e.Data[i]
+			}
+			row = append(row, value)
+		}
+		row = append(row, e.Message)
+		rows = append(rows, row)
+	}
+
+	name := "/" + filepath.ToSlash(filepath.Join("importer", "reports", job.ID+".xlsx"))
+	err := writeWorkbook(name, rows)
+	if err != nil {
+		log.With(log.F{"job": job.ID, "importer": job.Importer}).Error("生成错误报告失败: %s", err.Error())
+		return
+	}
+
+	job.mutex.Lock()
+	job.Report = name
+	job.mutex.Unlock()
+}
+
+// writeWorkbook 写入工作簿(数据目录)的第一个工作表, 逐行写入临时文件, 失败数据行较多时不占用过多内存
+func writeWorkbook(name string, rows [][]interface{}) error {
+	id, err := excel.OpenStream(name, "")
+	if err != nil {
+		return err
+	}
+
+	stream, err := excel.GetStream(id)
+	if err != nil {
+		return err
+	}
+
+	err = stream.Write(rows)
+	if err != nil {
+		excel.CloseStream(id)
+		return err
+	}
+	return excel.CloseStream(id)
+}
+
+// sweepJobs 清理过期的导入任务
+func sweepJobs() {
+	expired := time.Now().Add(-jobTTL)
+	jobs.Range(func(key, value interface{}) bool {
+		job := value.(*Job)
+		job.mutex.RLock()
+		ended := job.EndedAt
+		job.mutex.RUnlock()
+		if ended != nil && ended.Before(expired) {
+			jobs.Delete(key)
+		}
+		return true
+	})
+}
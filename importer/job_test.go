@@ -0,0 +1,210 @@
+package importer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yaoapp/gou/process"
+	"github.com/yaoapp/yao/config"
+	"github.com/yaoapp/yao/excel"
+	"github.com/yaoapp/yao/importer/from"
+	"github.com/yaoapp/yao/test"
+)
+
+func TestJob(t *testing.T) {
+	test.Prepare(t, config.Conf)
+	defer test.Clean()
+	prepareJob()
+
+	rows := [][]interface{}{}
+	for i := 0; i < 25; i++ {
+		rows = append(rows, []interface{}{fmt.Sprintf("SO-%03d", i), i})
+	}
+	rows[3][0] = "bad"
+	rows[21][0] = "bad"
+
+	imp := jobImporter()
+	job := imp.Start(&memSource{rows: rows}, "memory", jobMapping(), false)
+	waitJob(t, job)
+
+	res := job.Map()
+	assert.Equal(t, JobDone, res["status"])
+	assert.Equal(t, 25, res["done"])
+	assert.Equal(t, 2, res["failure"])
+	assert.Equal(t, 23, res["success"])
+	assert.Equal(t, 2, res["errors"])
+	assert.Equal(t, map[string]int{"total": 25, "success": 23, "failure": 2, "ignore": 0}, res["output"])
+	assert.Equal(t, []RowError{
+		{Line: 5, Data: []interface{}{"bad", 3}, Message: "订单号不能为 bad"},
+		{Line: 23, Data: []interface{}{"bad", 21}, Message: "订单号不能为 bad"},
+	}, job.Errors)
+
+	// The error report
+	report, ok := res["report"].(string)
+	if !assert.True(t, ok) || !assert.NotEmpty(t, report) {
+		return
+	}
+	defer os.Remove(filepath.Join(config.Conf.DataRoot, report))
+
+	handle, err := excel.Open(report, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer excel.Close(handle)
+
+	xls, err := excel.Get(handle)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := xls.GetRows(xls.ListSheets()[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, [][]string{
+		{"行号", "订单号", "数量", "错误原因"},
+		{"5", "bad", "3", "订单号不能为 bad"},
+		{"23", "bad", "21", "订单号不能为 bad"},
+	}, data)
+
+	found, err := GetJob(job.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, job, found)
+
+	_, err = CancelJob(job.ID)
+	assert.NotNil(t, err)
+}
+
+func TestJobCancel(t *testing.T) {
+	test.Prepare(t, config.Conf)
+	defer test.Clean()
+	prepareJob()
+
+	rows := [][]interface{}{}
+	for i := 0; i < 200; i++ {
+		rows = append(rows, []interface{}{fmt.Sprintf("SO-%03d", i), i})
+	}
+
+	imp := jobImporter()
+	imp.Process = "unit.importer.job.slow"
+	job := imp.Start(&memSource{rows: rows}, "memory", jobMapping(), false)
+
+	_, err := CancelJob(job.ID)
+	assert.Nil(t, err)
+	waitJob(t, job)
+
+	res := job.Map()
+	assert.Equal(t, JobCanceled, res["status"])
+	assert.Less(t, res["done"], 200)
+	assert.Empty(t, res["report"])
+}
+
+func TestJobFailed(t *testing.T) {
+	test.Prepare(t, config.Conf)
+	defer test.Clean()
+	prepareJob()
+
+	imp := jobImporter()
+	imp.Process = "unit.importer.job.not-found"
+	job := imp.Start(&memSource{rows: [][]interface{}{{"SO-001", 1}}}, "memory", jobMapping(), false)
+	waitJob(t, job)
+
+	res := job.Map()
+	assert.Equal(t, JobDone, res["status"])
+	assert.Equal(t, 1, res["failure"])
+	assert.Equal(t, 1, res["errors"])
+	assert.NotEmpty(t, res["report"])
+	os.Remove(filepath.Join(config.Conf.DataRoot, res["report"].(string)))
+
+	_, err := GetJob("not-found")
+	assert.NotNil(t, err)
+}
+
+func prepareJob() {
+	process.Register("unit.importer.job", func(process *process.Process) interface{} {
+		data := process.Args[1].([][]interface{})
+		failed := 0
+		errors := []map[string]interface{}{}
+		for i, row := range data {
+			if row[0] == "bad" {
+				failed++
+				errors = append(errors, map[string]interface{}{"row": i, "message": "订单号不能为 bad"})
+			}
+		}
+		return []interface{}{failed, 0, errors}
+	})
+
+	process.Register("unit.importer.job.slow", func(process *process.Process) interface{} {
+		time.Sleep(20 * time.Millisecond)
+		return []int{0, 0}
+	})
+}
+
+func jobImporter() *Importer {
+	return &Importer{
+		ID:      "unit.job",
+		Process: "unit.importer.job",
+		Option:  Option{ChunkSize: 10},
+	}
+}
+
+func jobMapping() *Mapping {
+	return &Mapping{
+		RowStart: 1,
+		Columns: []*Binding{
+			{Label: "订单号", Field: "sn", Name: "订单号", Axis: "A", Rules: []string{}},
+			{Label: "数量", Field: "amount", Name: "数量", Axis: "B", Rules: []string{}},
+		},
+	}
+}
+
+func waitJob(t *testing.T, job *Job) {
+	timeout := time.After(5 * time.Second)
+	for !job.Ended() {
+		select {
+		case <-timeout:
+			t.Fatal("the import job is not finished")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+// memSource the source in memory, the first row is the header
+type memSource struct {
+	rows [][]interface{}
+}
+
+func (mem *memSource) Data(row int, size int, axises []string) [][]interface{} {
+	return mem.rows
+}
+
+func (mem *memSource) Columns() []from.Column {
+	return []from.Column{{Name: "订单号", Axis: "A", Type: from.TString}, {Name: "数量", Axis: "B", Type: from.TNumber}}
+}
+
+func (mem *memSource) Chunk(size int, axises []string, cb func(line int, data [][]interface{})) {
+	for start := 0; start < len(mem.rows); start += size {
+		end := start + size
+		if end > len(mem.rows) {
+			end = len(mem.rows)
+		}
+
+		data := [][]interface{}{}
+		for _, row := range mem.rows[start:end] {
+			data = append(data, append([]interface{}{}, row...))
+		}
+		cb(end+1, data)
+	}
+}
+
+func (mem *memSource) Inspect() from.Inspect {
+	return from.Inspect{SheetName: "memory", ColStart: 1, RowStart: 1}
+}
+
+func (mem *memSource) Close() error {
+	return nil
+}
@@ -48,6 +48,10 @@ func OptionOf(data map[string]interface{}) (*Option, error) {
 		option.DataPreview = getPreviewOption(dataPreview)
 	}
 
+	if templateStore, ok := data["templateStore"].(string); ok {
+		option.TemplateStore = templateStore
+	}
+
 	return option, nil
 }
 
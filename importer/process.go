@@ -4,6 +4,7 @@ import (
 	jsoniter "github.com/json-iterator/go"
 	"github.com/yaoapp/gou/process"
 	"github.com/yaoapp/kun/exception"
+	"github.com/yaoapp/kun/log"
 )
 
 func init() {
@@ -22,6 +23,14 @@ func init() {
 	process.Alias("xiang.import.DataSetting", "yao.import.DataSetting")
 	process.Alias("xiang.import.Mapping", "yao.import.Mapping")
 	process.Alias("xiang.import.MappingSetting", "yao.import.MappingSetting")
+
+	process.Register("yao.import.Templates", ProcessTemplates)
+	process.Register("yao.import.SaveTemplate", ProcessSaveTemplate)
+	process.Register("yao.import.DeleteTemplate", ProcessDeleteTemplate)
+	process.Register("yao.import.Start", ProcessStart)
+	process.Register("yao.import.DryRun", ProcessDryRun)
+	process.Register("yao.import.Job", ProcessJob)
+	process.Register("yao.import.Cancel", ProcessCancel)
 }
 
 // ProcessRun xiang.import.Run
@@ -34,7 +43,86 @@ func ProcessRun(process *process.Process) interface{} {
 	src := Open(filename)
 	defer src.Close()
 	mapping := anyToMapping(process.Args[2])
-	return imp.Run(src, mapping)
+	output := imp.Run(src, mapping)
+
+	// 保存用户确认的字段映射, 相同结构的文件再次导入时自动匹配
+	if imp.Option.UseTemplate && len(mapping.Columns) > 0 {
+		tplSrc := Open(filename)
+		defer tplSrc.Close()
+		if _, err := imp.SaveAsTemplate(tplSrc, mapping); err != nil {
+			log.With(log.F{"importer": name, "file": filename}).Error("保存映射模板失败: %s", err.Error())
+		}
+	}
+	return output
+}
+
+// ProcessStart yao.import.Start
+// 后台导入数据, 返回导入任务. 字段映射表为空时自动匹配, dryRun 为 true 时试运行
+func ProcessStart(process *process.Process) interface{} {
+	process.ValidateArgNums(2)
+	name := process.ArgsString(0)
+	imp := Select(name).WithSid(process.Sid)
+	filename := process.ArgsString(1)
+	mapping := argsMapping(process, 2)
+	dryRun := false
+	if process.NumOfArgs() > 3 {
+		dryRun, _ = process.Args[3].(bool)
+	}
+
+	// 保存用户确认的字段映射, 相同结构的文件再次导入时自动匹配
+	if mapping != nil && !dryRun && imp.Option.UseTemplate && len(mapping.Columns) > 0 {
+		tplSrc := Open(filename)
+		defer tplSrc.Close()
+		if _, err := imp.SaveAsTemplate(tplSrc, mapping); err != nil {
+			log.With(log.F{"importer": name, "file": filename}).Error("保存映射模板失败: %s", err.Error())
+		}
+	}
+
+	src := Open(filename) // 导入任务结束后关闭
+	return imp.Start(src, filename, mapping, dryRun).Map()
+}
+
+// ProcessDryRun yao.import.DryRun
+// 试运行, 返回导入结果和失败的数据行, 不写入数据
+func ProcessDryRun(process *process.Process) interface{} {
+	process.ValidateArgNums(2)
+	name := process.ArgsString(0)
+	imp := Select(name).WithSid(process.Sid)
+	src := Open(process.ArgsString(1))
+	defer src.Close()
+	return imp.DryRun(src, argsMapping(process, 2))
+}
+
+// ProcessJob yao.import.Job
+// 导入任务进度
+func ProcessJob(process *process.Process) interface{} {
+	process.ValidateArgNums(1)
+	return processJob(process).Map()
+}
+
+// ProcessCancel yao.import.Cancel
+// 取消导入任务
+func ProcessCancel(process *process.Process) interface{} {
+	process.ValidateArgNums(1)
+	job := processJob(process)
+	_, err := CancelJob(job.ID)
+	if err != nil {
+		exception.New(err.Error(), 400).Throw()
+	}
+	return job.Map()
+}
+
+// processJob 读取导入任务, 只能读取当前会话创建的任务
+func processJob(process *process.Process) *Job {
+	job, err := GetJob(process.ArgsString(0))
+	if err != nil {
+		exception.New(err.Error(), 404).Throw()
+	}
+
+	if job.Sid != "" && process.Sid != "" && job.Sid != process.Sid {
+		exception.New("导入任务 %s 不存在或已过期", 404, job.ID).Throw()
+	}
+	return job
 }
 
 // ProcessSetting xiang.import.Setting
@@ -104,6 +192,63 @@ func ProcessMappingSetting(process *process.Process) interface{} {
 	return imp.MappingSetting(src)
 }
 
+// ProcessTemplates yao.import.Templates
+// 映射模板列表
+func ProcessTemplates(process *process.Process) interface{} {
+	process.ValidateArgNums(1)
+	name := process.ArgsString(0)
+	imp := Select(name).WithSid(process.Sid)
+	templates, err := imp.Templates()
+	if err != nil {
+		exception.New("读取映射模板失败 %s", 500, err.Error()).Throw()
+	}
+
+	res := []map[string]interface{}{}
+	for _, tpl := range templates {
+		res = append(res, tpl.Map())
+	}
+	return res
+}
+
+// ProcessSaveTemplate yao.import.SaveTemplate
+// 保存映射模板
+func ProcessSaveTemplate(process *process.Process) interface{} {
+	process.ValidateArgNums(3)
+	name := process.ArgsString(0)
+	imp := Select(name).WithSid(process.Sid)
+
+	filename := process.ArgsString(1)
+	src := Open(filename)
+	defer src.Close()
+
+	tpl, err := imp.SaveAsTemplate(src, anyToMapping(process.Args[2]))
+	if err != nil {
+		exception.New("保存映射模板失败 %s", 500, err.Error()).Throw()
+	}
+	return tpl.Map()
+}
+
+// ProcessDeleteTemplate yao.import.DeleteTemplate
+// 删除映射模板
+func ProcessDeleteTemplate(process *process.Process) interface{} {
+	process.ValidateArgNums(2)
+	name := process.ArgsString(0)
+	imp := Select(name).WithSid(process.Sid)
+	err := imp.DeleteTemplate(process.ArgsString(1))
+	if err != nil {
+		exception.New("删除映射模板失败 %s", 400, err.Error()).Throw()
+	}
+	return nil
+}
+
+// argsMapping 读取字段映射表参数, 未传入时返回 nil
+func argsMapping(process *process.Process, i int) *Mapping {
+	if process.NumOfArgs() <= i || process.Args[i] == nil {
+		return nil
+	}
+	return anyToMapping(process.Args[i])
+}
+
 // 转换为映射表
 func anyToMapping(v interface{}) *Mapping {
 	var mapping Mapping
@@ -1,6 +1,7 @@
 package importer
 
 import (
+	"os"
 	"path/filepath"
 	"testing"
 
@@ -80,4 +81,46 @@ func TestProcessRun(t *testing.T) {
 	response := process.New("yao.import.Run", args...).Run()
 	_, ok := response.(map[string]int)
 	assert.True(t, ok)
+
+	// The mapping is saved as the template
+	templates := process.New("yao.import.Templates", "order").Run().([]map[string]interface{})
+	if assert.Equal(t, 1, len(templates)) {
+		process.New("yao.import.DeleteTemplate", "order", templates[0]["fingerprint"]).Run()
+	}
+
+	mapping = process.New("yao.import.Mapping", "order", simple).Run()
+	assert.False(t, mapping.(*Mapping).TemplateMatching)
+}
+
+func TestProcessStart(t *testing.T) {
+	test.Prepare(t, config.Conf)
+	defer test.Clean()
+	prepare(t, config.Conf)
+
+	simple := filepath.Join("assets", "simple.xlsx")
+	mapping := process.New("yao.import.Mapping", "order", simple).Run()
+	job := process.New("yao.import.Start", "order", simple, mapping).Run().(map[string]interface{})
+	assert.NotEmpty(t, job["id"])
+
+	found, err := GetJob(job["id"].(string))
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitJob(t, found)
+
+	res := process.New("yao.import.Job", job["id"]).Run().(map[string]interface{})
+	assert.Equal(t, JobDone, res["status"])
+	assert.Greater(t, res["done"], 0)
+	if report, ok := res["report"].(string); ok && report != "" {
+		os.Remove(filepath.Join(config.Conf.DataRoot, report))
+	}
+
+	// The finished job can not be canceled
+	_, err = process.New("yao.import.Cancel", job["id"]).Exec()
+	assert.NotNil(t, err)
+
+	templates := process.New("yao.import.Templates", "order").Run().([]map[string]interface{})
+	for _, tpl := range templates {
+		process.New("yao.import.DeleteTemplate", "order", tpl["fingerprint"]).Run()
+	}
 }
@@ -0,0 +1,392 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/yaoapp/gou/connector"
+	"github.com/yaoapp/gou/model"
+	"github.com/yaoapp/kun/log"
+	"github.com/yaoapp/xun/capsule"
+	"github.com/yaoapp/xun/dbal/query"
+	"github.com/yaoapp/yao/importer/from"
+)
+
+// 写入方式
+const (
+	ModeInsert  = "insert"  // 新增
+	ModeUpsert  = "upsert"  // 按主键字段更新, 不存在则新增
+	ModeReplace = "replace" // 按主键字段删除后新增
+)
+
+// 事务
+const (
+	TransactionChunk = "chunk" // 每批数据一个事务, 写入出错的批次回滚
+	TransactionAll   = "all"   // 全部数据一个事务, 任意一行失败全部回滚
+)
+
+// errRollback 回滚事务(试运行或有失败的数据行)
+var errRollback = errors.New("rollback")
+
+// Target 直接写入数据模型, 不需要导入处理器
+type Target struct {
+	Model       string `json:"model"`                 // 数据模型
+	Mode        string `json:"mode,omitempty"`        // 写入方式 insert(默认), upsert, replace
+	Transaction string `json:"transaction,omitempty"` // 事务 chunk(默认), all
+}
+
+// validate 检查写入配置
+func (target *Target) validate(imp *Importer) error {
+	if target.Mode == "" {
+		target.Mode = ModeInsert
+	}
+
+	if target.Transaction == "" {
+		target.Transaction = TransactionChunk
+	}
+
+	if target.Mode != ModeInsert && target.Mode != ModeUpsert && target.Mode != ModeReplace {
+		return fmt.Errorf("写入方式 %s 不正确, 可选值 insert, upsert, replace", target.Mode)
+	}
+
+	if target.Transaction != TransactionChunk && target.Transaction != TransactionAll {
+		return fmt.Errorf("事务 %s 不正确, 可选值 chunk, all", target.Transaction)
+	}
+
+	if _, has := model.Models[target.Model]; !has {
+		return fmt.Errorf("数据模型 %s 尚未加载", target.Model)
+	}
+
+	if target.Mode != ModeInsert && len(imp.primaryColumns()) == 0 {
+		return fmt.Errorf("写入方式 %s 至少需要一个主键字段 (primary)", target.Mode)
+	}
+	return nil
+}
+
+// writeModel 写入数据模型. 试运行时执行相同的写入操作, 然后回滚事务
+func (imp *Importer) writeModel(ctx context.Context, src from.Source, mapping *Mapping, job *Job) error {
+	w, err := imp.newWriter()
+	if err != nil {
+		return err
+	}
+
+	qb, err := w.query()
+	if err != nil {
+		return err
+	}
+
+	// 每批数据一个事务
+	if imp.Target.Transaction != TransactionAll {
+		imp.Chunk(src, mapping, func(line int, data [][]interface{}) {
+			if ctx.Err() != nil {
+				return
+			}
+
+			first := line - len(data) + 1
+			var rows [][]interface{}
+			var reasons map[int]string
+			err := qb.Transaction(func(qb query.Query) error {
+				var err error
+				rows, reasons, err = w.chunk(qb, data, mapping.Columns, true)
+				if err != nil {
+					return err
+				}
+				if job.DryRun {
+					return errRollback
+				}
+				return nil
+			})
+
+			if err != nil && !errors.Is(err, errRollback) {
+				log.With(log.F{"line": line, "model": imp.Target.Model}).Error("导入失败: %s", err.Error())
+			}
+			job.chunk(first, rows, len(reasons), 0, reasons, nil)
+		})
+		return nil
+	}
+
+	// 全部数据一个事务, 出现失败的数据行后只校验不写入
+	failed := false
+	err = qb.Transaction(func(qb query.Query) error {
+		imp.Chunk(src, mapping, func(line int, data [][]interface{}) {
+			if ctx.Err() != nil {
+				return
+			}
+
+			first := line - len(data) + 1
+			rows, reasons, _ := w.chunk(qb, data, mapping.Columns, !failed)
+			failed = failed || len(reasons) > 0
+			job.chunk(first, rows, len(reasons), 0, reasons, nil)
+		})
+
+		if failed || job.DryRun || ctx.Err() != nil {
+			return errRollback
+		}
+		return nil
+	})
+
+	if failed || ctx.Err() != nil {
+		job.rollback()
+	}
+
+	if err != nil && !errors.Is(err, errRollback) {
+		job.rollback()
+		return err
+	}
+	return nil
+}
+
+// writer 数据模型写入. 写入前经过数据模型的校验和字段加密 (crypt), 与数据模型写入一致
+type writer struct {
+	imp         *Importer
+	model       *model.Model
+	mode        string
+	table       string
+	connector   string
+	timestamps  bool
+	softDeletes bool
+	columns     map[string]*Column // 按原始字段名称索引
+	primary     []string
+}
+
+func (imp *Importer) newWriter() (*writer, error) {
+	mod, has := model.Models[imp.Target.Model]
+	if !has {
+		return nil, fmt.Errorf("数据模型 %s 尚未加载", imp.Target.Model)
+	}
+
+	columns := map[string]*Column{}
+	for i := range imp.Columns {
+		columns[imp.Columns[i].Field] = &imp.Columns[i]
+	}
+
+	return &writer{
+		imp:         imp,
+		model:       mod,
+		mode:        imp.Target.Mode,
+		table:       mod.MetaData.Table.Name,
+		connector:   mod.MetaData.Connector,
+		timestamps:  mod.MetaData.Option.Timestamps,
+		softDeletes: mod.MetaData.Option.SoftDeletes,
+		columns:     columns,
+		primary:     imp.primaryColumns(),
+	}, nil
+}
+
+// query 数据模型所在数据库的查询器
+func (w *writer) query() (query.Query, error) {
+	if w.connector == "" || w.connector == "default" {
+		return capsule.Global.Query(), nil
+	}
+
+	conn, err := connector.Select(w.connector)
+	if err != nil {
+		return nil, err
+	}
+
+	if !conn.Is(connector.DATABASE) {
+		return nil, fmt.Errorf("%s 不是数据库连接器", w.connector)
+	}
+	return conn.Query()
+}
+
+// chunk 校验并写入一批数据, 返回清洗后的数据和失败的数据行. write 为 false 时只校验
+// 写入出错时本批数据全部失败, 返回写入错误, 调用者回滚事务
+func (w *writer) chunk(qb query.Query, data [][]interface{}, bindings []*Binding, write bool) ([][]interface{}, map[int]string, error) {
+	_, rows, reasons := w.imp.dataClean(data, bindings)
+
+	records := []map[string]interface{}{}
+	indexes := []int{}
+	for i, row := range rows {
+		if _, has := reasons[i]; has {
+			continue
+		}
+
+		record, err := w.record(row, bindings)
+		if err != nil {
+			reasons[i] = err.Error()
+			continue
+		}
+
+		err = w.validate(record)
+		if err != nil {
+			reasons[i] = err.Error()
+			continue
+		}
+		records = append(records, record)
+		indexes = append(indexes, i)
+	}
+
+	if !write || len(records) == 0 {
+		return rows, reasons, nil
+	}
+
+	err := w.write(qb, records)
+	if err != nil {
+		for _, i := range indexes {
+			reasons[i] = err.Error()
+		}
+	}
+	return rows, reasons, err
+}
+
+// record 转换为数据记录, 检查必填字段
+func (w *writer) record(row []interface{}, bindings []*Binding) (map[string]interface{}, error) {
+	record := map[string]interface{}{}
+	for i, binding := range bindings {
+		column, has := w.columns[binding.Field]
+		if !has || binding.Axis == "" {
+			continue
+		}
+
+		value := row[i]
+		if value == "" {
+			value = nil
+		}
+
+		if value == nil && (!column.Nullable || column.Primary) {
+			return nil, fmt.Errorf("%s 不能为空", column.Label)
+		}
+
+		if !column.IsObject && !column.IsArray {
+			record[column.Name] = value
+			continue
+		}
+
+		// 对象字段 name.key, 数组字段 name[*] 或 name[*].key
+		var item interface{} = value
+		if column.IsObject {
+			object, ok := record[column.Name].(map[string]interface{})
+			if column.IsArray {
+				list, _ := record[column.Name].([]interface{})
+				if len(list) > 0 {
+					object, ok = list[0].(map[string]interface{})
+				}
+			}
+			if !ok {
+				object = map[string]interface{}{}
+			}
+			object[column.Key] = value
+			item = object
+		}
+
+		if column.IsArray {
+			record[column.Name] = []interface{}{item}
+			continue
+		}
+		record[column.Name] = item
+	}
+
+	// 对象和数组字段保存为 JSON
+	for name, value := range record {
+		switch value.(type) {
+		case map[string]interface{}, []interface{}:
+			raw, err := jsoniter.MarshalToString(value)
+			if err != nil {
+				return nil, err
+			}
+			record[name] = raw
+		}
+	}
+	return record, nil
+}
+
+// validate 数据模型校验
+func (w *writer) validate(record map[string]interface{}) error {
+	errs := w.model.Validate(record)
+	if len(errs) == 0 {
+		return nil
+	}
+
+	messages := []string{}
+	for _, err := range errs {
+		messages = append(messages, err.Messages...)
+	}
+	return fmt.Errorf("%s", strings.Join(messages, "; "))
+}
+
+// write 写入数据记录. 字段加密后写入, 软删除的数据模型 replace 时标记删除而不是物理删除
+func (w *writer) write(qb query.Query, records []map[string]interface{}) error {
+	now := time.Now()
+	for _, record := range records {
+		w.model.FliterIn(record)
+	}
+
+	if w.mode == ModeInsert {
+		if w.timestamps {
+			for _, record := range records {
+				record["created_at"] = now
+			}
+		}
+		return w.newQuery(qb).Insert(records)
+	}
+
+	for _, record := range records {
+		where := w.newQuery(qb)
+		for _, name := range w.primary {
+			where.Where(name, record[name])
+		}
+		if w.softDeletes {
+			where.WhereNull("deleted_at")
+		}
+
+		if w.mode == ModeReplace && w.softDeletes {
+			_, err := where.Update(map[string]interface{}{"deleted_at": now})
+			if err != nil {
+				return err
+			}
+		} else if w.mode == ModeReplace {
+			_, err := where.Delete()
+			if err != nil {
+				return err
+			}
+		} else {
+			has, err := where.Exists()
+			if err != nil {
+				return err
+			}
+
+			if has {
+				if w.timestamps {
+					record["updated_at"] = now
+				}
+				_, err = where.Update(record)
+				if err != nil {
+					return err
+				}
+				continue
+			}
+		}
+
+		if w.timestamps {
+			record["created_at"] = now
+		}
+		err := w.newQuery(qb).Insert(record)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newQuery 数据表查询器, 每条语句使用新的查询器避免条件叠加
+func (w *writer) newQuery(qb query.Query) query.Query {
+	q := qb.New()
+	q.Table(w.table)
+	return q
+}
+
+// primaryColumns 主键字段
+func (imp *Importer) primaryColumns() []string {
+	primary := []string{}
+	for _, column := range imp.Columns {
+		if column.Primary {
+			primary = append(primary, column.Name)
+		}
+	}
+	return primary
+}
@@ -0,0 +1,191 @@
+package importer
+
+import (
+	"testing"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/yaoapp/gou/model"
+	"github.com/yaoapp/gou/process"
+	"github.com/yaoapp/xun/capsule"
+	"github.com/yaoapp/yao/config"
+	"github.com/yaoapp/yao/test"
+)
+
+func TestTargetValidate(t *testing.T) {
+	test.Prepare(t, config.Conf)
+	defer test.Clean()
+
+	imp := targetImporter(ModeUpsert, "")
+	err := imp.Target.validate(imp)
+	assert.Nil(t, err)
+	assert.Equal(t, TransactionChunk, imp.Target.Transaction)
+
+	imp.Columns[0].Primary = false
+	err = imp.Target.validate(imp)
+	assert.Contains(t, err.Error(), "主键字段")
+
+	imp = targetImporter("merge", "")
+	err = imp.Target.validate(imp)
+	assert.Contains(t, err.Error(), "merge")
+
+	imp = targetImporter(ModeInsert, "")
+	imp.Target.Model = "not-found"
+	err = imp.Target.validate(imp)
+	assert.Contains(t, err.Error(), "not-found")
+}
+
+func TestTargetInsertAndUpsert(t *testing.T) {
+	test.Prepare(t, config.Conf)
+	defer test.Clean()
+	prepareTarget(t)
+
+	imp := targetImporter(ModeInsert, TransactionChunk)
+	output := imp.Run(&memSource{rows: [][]interface{}{
+		{"Category 1", "active"},
+		{"Category 2", "active"},
+		{"", "active"},
+	}}, targetMapping())
+	assert.Equal(t, map[string]int{"total": 3, "success": 2, "failure": 1, "ignore": 0}, output)
+	assert.Equal(t, map[string]interface{}{"Category 1": "active", "Category 2": "active"}, categories(t))
+
+	imp = targetImporter(ModeUpsert, TransactionChunk)
+	output = imp.Run(&memSource{rows: [][]interface{}{
+		{"Category 1", "disabled"},
+		{"Category 3", "active"},
+	}}, targetMapping())
+	assert.Equal(t, map[string]int{"total": 2, "success": 2, "failure": 0, "ignore": 0}, output)
+	assert.Equal(t, map[string]interface{}{"Category 1": "disabled", "Category 2": "active", "Category 3": "active"}, categories(t))
+}
+
+func TestTargetTransactionAll(t *testing.T) {
+	test.Prepare(t, config.Conf)
+	defer test.Clean()
+	prepareTarget(t)
+
+	// Any failed row rolls back the whole import
+	imp := targetImporter(ModeInsert, TransactionAll)
+	output := imp.Run(&memSource{rows: [][]interface{}{
+		{"Category 1", "active"},
+		{"Category 2", "active"},
+		{"Category 3", "active"},
+		{"", "active"},
+	}}, targetMapping())
+	assert.Equal(t, map[string]int{"total": 4, "success": 0, "failure": 4, "ignore": 0}, output)
+	assert.Empty(t, categories(t))
+
+	output = imp.Run(&memSource{rows: [][]interface{}{
+		{"Category 1", "active"},
+		{"Category 2", "active"},
+		{"Category 3", "active"},
+	}}, targetMapping())
+	assert.Equal(t, map[string]int{"total": 3, "success": 3, "failure": 0, "ignore": 0}, output)
+	assert.Equal(t, 3, len(categories(t)))
+}
+
+func TestTargetDryRun(t *testing.T) {
+	test.Prepare(t, config.Conf)
+	defer test.Clean()
+	prepareTarget(t)
+
+	for _, transaction := range []string{TransactionChunk, TransactionAll} {
+		imp := targetImporter(ModeReplace, transaction)
+		res := imp.DryRun(&memSource{rows: [][]interface{}{
+			{"Category 1", "active"},
+			{"", "active"},
+			{"Category 3", "active"},
+		}}, targetMapping())
+
+		assert.Equal(t, 3, res["total"], transaction)
+		assert.Equal(t, []RowError{{Line: 3, Data: []interface{}{"", "active"}, Message: "名称 不能为空"}}, res["errors"], transaction)
+		assert.Empty(t, categories(t), transaction)
+	}
+}
+
+func TestTargetCrypt(t *testing.T) {
+	test.Prepare(t, config.Conf)
+	defer test.Clean()
+
+	imp := &Importer{
+		ID:     "unit.target.user",
+		Target: &Target{Model: "admin.user", Mode: ModeUpsert},
+		Columns: []Column{
+			{Label: "邮箱", Name: "email", Field: "email", Primary: true},
+			{Label: "密码", Name: "password", Field: "password"},
+		},
+		Option: Option{ChunkSize: 2},
+	}
+
+	output := imp.Run(&memSource{rows: [][]interface{}{{"importer@yao.run", "Import@123"}}}, &Mapping{
+		RowStart: 1,
+		Columns: []*Binding{
+			{Label: "邮箱", Field: "email", Name: "邮箱", Axis: "A", Rules: []string{}},
+			{Label: "密码", Field: "password", Name: "密码", Axis: "B", Rules: []string{}},
+		},
+	})
+	assert.Equal(t, map[string]int{"total": 1, "success": 1, "failure": 0, "ignore": 0}, output)
+
+	// The password is stored encrypted by the model
+	row, err := capsule.Global.Query().
+		Table(model.Models["admin.user"].MetaData.Table.Name).
+		Where("email", "importer@yao.run").
+		First()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NotEmpty(t, row.Get("password"))
+	assert.NotEqual(t, "Import@123", row.Get("password"))
+}
+
+func prepareTarget(t *testing.T) {
+	err := process.New("models.category.Migrate", true).Execute()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func targetImporter(mode string, transaction string) *Importer {
+	return &Importer{
+		ID:     "unit.target",
+		Target: &Target{Model: "category", Mode: mode, Transaction: transaction},
+		Columns: []Column{
+			{Label: "名称", Name: "name", Field: "name", Primary: true},
+			{Label: "状态", Name: "status", Field: "status", Nullable: true},
+		},
+		Option: Option{ChunkSize: 2},
+	}
+}
+
+func targetMapping() *Mapping {
+	return &Mapping{
+		RowStart: 1,
+		Columns: []*Binding{
+			{Label: "名称", Field: "name", Name: "名称", Axis: "A", Rules: []string{}},
+			{Label: "状态", Field: "status", Name: "状态", Axis: "B", Rules: []string{}},
+		},
+	}
+}
+
+func categories(t *testing.T) map[string]interface{} {
+	rows, err := process.New("models.category.Get", map[string]interface{}{"select": []string{"name", "status"}}).Exec()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := jsoniter.Marshal(rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records := []map[string]interface{}{}
+	err = jsoniter.Unmarshal(raw, &records)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := map[string]interface{}{}
+	for _, row := range records {
+		res[row["name"].(string)] = row["status"]
+	}
+	return res
+}
@@ -0,0 +1,372 @@
+package importer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/yaoapp/gou/store"
+	"github.com/yaoapp/yao/importer/from"
+)
+
+// Template 字段映射模板, 按导入器和文件结构指纹保存
+type Template struct {
+	Importer    string    `json:"importer"`    // 导入器
+	Fingerprint string    `json:"fingerprint"` // 文件结构指纹
+	Sheet       string    `json:"sheet"`       // 保存模板时的数据表名称
+	Mapping     *Mapping  `json:"mapping"`     // 字段映射表
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// TemplateStore 映射模板存储
+type TemplateStore interface {
+	Save(tpl *Template) error
+	Get(importer string, fingerprint string) (*Template, error) // 模板不存在返回 nil
+	List(importer string) ([]*Template, error)
+	Delete(importer string, fingerprint string) error
+}
+
+// templateStorePrefix the key prefix of the templates in the kv stores
+const templateStorePrefix = "importer:template:"
+
+// fingerprintRe the fingerprint is the sha256 of the source structure
+var fingerprintRe = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// SaveAsTemplate 保存为映射模板, 相同结构的文件再次导入时自动使用
+func (imp *Importer) SaveAsTemplate(src from.Source, mapping *Mapping) (*Template, error) {
+	if mapping == nil {
+		return nil, fmt.Errorf("导入器 %s 字段映射表不能为空", imp.ID)
+	}
+
+	s, err := imp.templateStore()
+	if err != nil {
+		return nil, err
+	}
+
+	fingerprint := imp.Fingerprint(src)
+	tpl, err := s.Get(imp.ID, fingerprint)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if tpl == nil {
+		tpl = &Template{Importer: imp.ID, Fingerprint: fingerprint, CreatedAt: now}
+	}
+
+	// 不保存示例数据和匹配状态
+	saved := &Mapping{
+		Sheet:    mapping.Sheet,
+		ColStart: mapping.ColStart,
+		RowStart: mapping.RowStart,
+		Columns:  []*Binding{},
+	}
+	for _, binding := range mapping.Columns {
+		if binding == nil {
+			continue
+		}
+		rules := binding.Rules
+		if rules == nil {
+			rules = []string{}
+		}
+		saved.Columns = append(saved.Columns, &Binding{
+			Label: binding.Label,
+			Field: binding.Field,
+			Name:  binding.Name,
+			Axis:  binding.Axis,
+			Rules: rules,
+		})
+	}
+
+	tpl.Sheet = src.Inspect().SheetName
+	tpl.Mapping = saved
+	tpl.UpdatedAt = now
+	err = s.Save(tpl)
+	if err != nil {
+		return nil, err
+	}
+	return tpl, nil
+}
+
+// Templates 导入器的映射模板列表, 最近更新的在前
+func (imp *Importer) Templates() ([]*Template, error) {
+	s, err := imp.templateStore()
+	if err != nil {
+		return nil, err
+	}
+
+	templates, err := s.List(imp.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(templates, func(i, j int) bool {
+		return templates[i].UpdatedAt.After(templates[j].UpdatedAt)
+	})
+	return templates, nil
+}
+
+// DeleteTemplate 删除映射模板
+func (imp *Importer) DeleteTemplate(fingerprint string) error {
+	if !fingerprintRe.MatchString(fingerprint) {
+		return fmt.Errorf("文件结构指纹 %s 格式不正确", fingerprint)
+	}
+
+	s, err := imp.templateStore()
+	if err != nil {
+		return err
+	}
+	return s.Delete(imp.ID, fingerprint)
+}
+
+// templateMapping 使用已保存的模板匹配字段, 没有模板返回 nil
+// 模板保存后新增的目标字段不绑定数据源
+func (imp *Importer) templateMapping(fingerprint string, inspect from.Inspect) *Mapping {
+	s, err := imp.templateStore()
+	if err != nil {
+		return nil
+	}
+
+	tpl, err := s.Get(imp.ID, fingerprint)
+	if err != nil || tpl == nil || tpl.Mapping == nil {
+		return nil
+	}
+
+	bindings := map[string]*Binding{}
+	for _, binding := range tpl.Mapping.Columns {
+		if binding != nil {
+			bindings[binding.Field] = binding
+		}
+	}
+
+	mapping := &Mapping{
+		Columns:          []*Binding{},
+		AutoMatching:     false,
+		TemplateMatching: true,
+		Sheet:            inspect.SheetName,
+		ColStart:         inspect.ColStart,
+		RowStart:         inspect.RowStart,
+	}
+
+	for i := range imp.Columns {
+		col := imp.Columns[i].ToMap()
+		name, ok := col["name"].(string)
+		if !ok {
+			continue
+		}
+
+		binding := Binding{Name: "", Axis: "", Rules: []string{}, Field: name, Label: imp.Columns[i].Label}
+		if saved, has := bindings[name]; has {
+			binding.Name = saved.Name
+			binding.Axis = saved.Axis
+			binding.Rules = saved.Rules
+		}
+		mapping.Columns = append(mapping.Columns, &binding)
+	}
+	return mapping
+}
+
+// templateStore 映射模板存储, 默认保存在数据目录 .importer/templates
+func (imp *Importer) templateStore() (TemplateStore, error) {
+	if imp.Option.TemplateStore == "" {
+		return &fileTemplateStore{root: filepath.Join(DataRoot, ".importer", "templates")}, nil
+	}
+
+	if _, has := store.Pools[imp.Option.TemplateStore]; !has {
+		return nil, fmt.Errorf("导入器 %s 模板存储 %s 不存在", imp.ID, imp.Option.TemplateStore)
+	}
+	return &kvTemplateStore{name: imp.Option.TemplateStore}, nil
+}
+
+// fileTemplateStore 模板保存为 JSON 文件 <root>/<importer>/<fingerprint>.json
+type fileTemplateStore struct {
+	root string
+}
+
+func (files *fileTemplateStore) file(importer string, fingerprint string) string {
+	return filepath.Join(files.root, importer, fingerprint+".json")
+}
+
+func (files *fileTemplateStore) Save(tpl *Template) error {
+	raw, err := jsoniter.Marshal(tpl)
+	if err != nil {
+		return err
+	}
+
+	file := files.file(tpl.Importer, tpl.Fingerprint)
+	err = os.MkdirAll(filepath.Dir(file), os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	// Write to the temp file first, the template is not broken if the writing fails
+	temp := file + ".tmp"
+	err = os.WriteFile(temp, raw, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(temp, file)
+}
+
+func (files *fileTemplateStore) Get(importer string, fingerprint string) (*Template, error) {
+	raw, err := os.ReadFile(files.file(importer, fingerprint))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return parseTemplate(raw)
+}
+
+func (files *fileTemplateStore) List(importer string) ([]*Template, error) {
+	entries, err := os.ReadDir(filepath.Join(files.root, importer))
+	if os.IsNotExist(err) {
+		return []*Template{}, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	templates := []*Template{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+
+		tpl, err := files.Get(importer, strings.TrimSuffix(entry.Name(), ".json"))
+		if err != nil {
+			return nil, err
+		}
+
+		if tpl != nil {
+			templates = append(templates, tpl)
+		}
+	}
+	return templates, nil
+}
+
+func (files *fileTemplateStore) Delete(importer string, fingerprint string) error {
+	err := os.Remove(files.file(importer, fingerprint))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// kvTemplateStore 模板保存在 kv 存储 (stores/*.yao), 不过期
+type kvTemplateStore struct {
+	name string
+}
+
+func (kv *kvTemplateStore) pool() (store.Store, error) {
+	s, has := store.Pools[kv.name]
+	if !has {
+		return nil, fmt.Errorf("store %s not found", kv.name)
+	}
+	return s, nil
+}
+
+func (kv *kvTemplateStore) key(importer string, fingerprint string) string {
+	return fmt.Sprintf("%s%s:%s", templateStorePrefix, importer, fingerprint)
+}
+
+func (kv *kvTemplateStore) Save(tpl *Template) error {
+	s, err := kv.pool()
+	if err != nil {
+		return err
+	}
+
+	raw, err := jsoniter.MarshalToString(tpl)
+	if err != nil {
+		return err
+	}
+	return s.Set(kv.key(tpl.Importer, tpl.Fingerprint), raw, 0)
+}
+
+func (kv *kvTemplateStore) Get(importer string, fingerprint string) (*Template, error) {
+	s, err := kv.pool()
+	if err != nil {
+		return nil, err
+	}
+
+	value, has := s.Get(kv.key(importer, fingerprint))
+	if !has || value == nil {
+		return nil, nil
+	}
+
+	switch v := value.(type) {
+	case string:
+		return parseTemplate([]byte(v))
+	case []byte:
+		return parseTemplate(v)
+	}
+
+	raw, err := jsoniter.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return parseTemplate(raw)
+}
+
+func (kv *kvTemplateStore) List(importer string) ([]*Template, error) {
+	s, err := kv.pool()
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := fmt.Sprintf("%s%s:", templateStorePrefix, importer)
+	templates := []*Template{}
+	for _, key := range s.Keys() {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+
+		tpl, err := kv.Get(importer, strings.TrimPrefix(key, prefix))
+		if err != nil {
+			return nil, err
+		}
+
+		if tpl != nil {
+			templates = append(templates, tpl)
+		}
+	}
+	return templates, nil
+}
+
+func (kv *kvTemplateStore) Delete(importer string, fingerprint string) error {
+	s, err := kv.pool()
+	if err != nil {
+		return err
+	}
+	return s.Del(kv.key(importer, fingerprint))
+}
+
+// Map 转换为映射表
+func (tpl *Template) Map() map[string]interface{} {
+	return map[string]interface{}{
+		"importer":    tpl.Importer,
+		"fingerprint": tpl.Fingerprint,
+		"sheet":       tpl.Sheet,
+		"mapping":     tpl.Mapping,
+		"created_at":  tpl.CreatedAt,
+		"updated_at":  tpl.UpdatedAt,
+	}
+}
+
+func parseTemplate(raw []byte) (*Template, error) {
+	var tpl Template
+	err := jsoniter.Unmarshal(raw, &tpl)
+	if err != nil {
+		return nil, err
+	}
+	return &tpl, nil
+}
@@ -0,0 +1,106 @@
+package importer
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yaoapp/yao/config"
+	"github.com/yaoapp/yao/importer/xlsx"
+	"github.com/yaoapp/yao/test"
+)
+
+func TestTemplate(t *testing.T) {
+	test.Prepare(t, config.Conf)
+	defer test.Clean()
+
+	root := prepare(t, config.Conf)
+	simple := filepath.Join(root, "assets", "simple.xlsx")
+	imp := Select("order")
+
+	file := xlsx.Open(simple)
+	mapping := imp.AutoMapping(file)
+	file.Close()
+	assert.False(t, mapping.TemplateMatching)
+
+	// Change the binding and the rules
+	mapping.Columns[0].Axis = mapping.Columns[1].Axis
+	mapping.Columns[0].Rules = []string{"scripts.importer.order.Validate"}
+	mapping.Columns[0].Value = "sample"
+
+	file = xlsx.Open(simple)
+	tpl, err := imp.SaveAsTemplate(file, mapping)
+	file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer imp.DeleteTemplate(tpl.Fingerprint)
+	assert.Equal(t, "order", tpl.Importer)
+	assert.Equal(t, "", tpl.Mapping.Columns[0].Value)
+
+	file = xlsx.Open(simple)
+	matched := imp.AutoMapping(file)
+	file.Close()
+	assert.True(t, matched.TemplateMatching)
+	assert.False(t, matched.AutoMatching)
+	assert.Equal(t, mapping.Columns[1].Axis, matched.Columns[0].Axis)
+	assert.Equal(t, []string{"scripts.importer.order.Validate"}, matched.Columns[0].Rules)
+	assert.Equal(t, len(imp.Columns), len(matched.Columns))
+
+	templates, err := imp.Templates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 1, len(templates))
+	assert.Equal(t, tpl.Fingerprint, templates[0].Fingerprint)
+
+	// The template is not used if the option is disabled
+	imp.Option.UseTemplate = false
+	file = xlsx.Open(simple)
+	assert.False(t, imp.AutoMapping(file).TemplateMatching)
+	file.Close()
+	imp.Option.UseTemplate = true
+
+	err = imp.DeleteTemplate(tpl.Fingerprint)
+	assert.Nil(t, err)
+	templates, err = imp.Templates()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(templates))
+
+	err = imp.DeleteTemplate("../../order")
+	assert.NotNil(t, err)
+}
+
+func TestFileTemplateStore(t *testing.T) {
+	s := &fileTemplateStore{root: t.TempDir()}
+	tpl, err := s.Get("order", "not-found")
+	assert.Nil(t, err)
+	assert.Nil(t, tpl)
+
+	templates, err := s.List("order")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(templates))
+
+	now := time.Now()
+	err = s.Save(&Template{Importer: "order", Fingerprint: "f1", Mapping: &Mapping{Columns: []*Binding{{Field: "name", Axis: "A1"}}}, UpdatedAt: now})
+	assert.Nil(t, err)
+	err = s.Save(&Template{Importer: "order", Fingerprint: "f2", Mapping: &Mapping{}, UpdatedAt: now})
+	assert.Nil(t, err)
+	err = s.Save(&Template{Importer: "user", Fingerprint: "f3", Mapping: &Mapping{}, UpdatedAt: now})
+	assert.Nil(t, err)
+
+	tpl, err = s.Get("order", "f1")
+	assert.Nil(t, err)
+	assert.Equal(t, "A1", tpl.Mapping.Columns[0].Axis)
+
+	templates, err = s.List("order")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(templates))
+
+	assert.Nil(t, s.Delete("order", "f1"))
+	assert.Nil(t, s.Delete("order", "f1"))
+	templates, err = s.List("order")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(templates))
+}
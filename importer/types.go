@@ -13,11 +13,13 @@ const PreviewNever = "never"
 type Importer struct {
 	Title   string            `json:"title,omitempty"`  // 导入名称
 	Process string            `json:"process"`          // 处理器名称
+	Target  *Target           `json:"target,omitempty"` // 直接写入数据模型, 设置后不调用导入处理器
 	Output  string            `json:"output,omitempty"` // The process import output
 	Columns []Column          `json:"columns"`          // 字段列表
 	Option  Option            `json:"option,omitempty"` // 导入配置项
 	Rules   map[string]string `json:"rules,omitempty"`  // 许可导入规则
 	Sid     string            `json:"-"`                // sid
+	ID      string            `json:"-"`                // 导入器 ID
 }
 
 // Column 导入字段定义
@@ -42,6 +44,7 @@ type Option struct {
 	ChunkSize      int    `json:"chunkSize,omitempty"`      // 每次处理记录数量
 	MappingPreview string `json:"mappingPreview,omitempty"` // 显示字段映射界面方式 auto 匹配模板失败显示, always 一直显示, never 不显示
 	DataPreview    string `json:"dataPreview,omitempty"`    // 数据预览界面方式 auto 有异常数据时显示, always 一直显示, never 不显示
+	TemplateStore  string `json:"templateStore,omitempty"`  // 映射模板存储 (stores/*.yao), 默认保存在数据目录
 }
 
 // Mapping 字段映射表
@@ -1,6 +1,7 @@
 package neo
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"net/url"
@@ -19,6 +20,8 @@ import (
 	chatctx "github.com/yaoapp/yao/neo/context"
 	"github.com/yaoapp/yao/neo/message"
 	"github.com/yaoapp/yao/neo/store"
+	"github.com/yaoapp/yao/neo/transcript"
+	"github.com/yaoapp/yao/widgets/login"
 )
 
 // API registers the Neo API endpoints
@@ -35,6 +38,10 @@ func (neo *DSL) API(router *gin.Engine, path string) error {
 	router.OPTIONS(path+"/status", neo.optionsHandler)
 	router.OPTIONS(path+"/chats", neo.optionsHandler)
 	router.OPTIONS(path+"/chats/:id", neo.optionsHandler)
+	router.OPTIONS(path+"/chats/:id/branches", neo.optionsHandler)
+	router.OPTIONS(path+"/chats/:id/branches/:branch_id", neo.optionsHandler)
+	router.OPTIONS(path+"/chats/:id/export", neo.optionsHandler)
+	router.OPTIONS(path+"/chats/import", neo.optionsHandler)
 	router.OPTIONS(path+"/history", neo.optionsHandler)
 	router.OPTIONS(path+"/upload", neo.optionsHandler)
 	router.OPTIONS(path+"/download", neo.optionsHandler)
@@ -113,9 +120,33 @@ func (neo *DSL) API(router *gin.Engine, path string) error {
 	// curl -X DELETE 'http://localhost:5099/api/__yao/neo/chats/chat_123?token=xxx'
 	router.DELETE(path+"/chats/:id", append(middlewares, neo.handleChatDelete)...)
 
+	// Chat branch endpoints
+	// Send a message with context.parent_id (or the parent_id query param) to edit a message or regenerate a reply,
+	// the new messages are saved as a branch of the parent message, "root" starts over from the beginning.
+	// List branches example:
+	// curl -X GET 'http://localhost:5099/api/__yao/neo/chats/chat_123/branches?token=xxx'
+	router.GET(path+"/chats/:id/branches", append(middlewares, neo.handleChatBranches)...)
+
+	// Switch branch example (any message on the branch):
+	// curl -X POST 'http://localhost:5099/api/__yao/neo/chats/chat_123/branches/message_123?token=xxx'
+	router.POST(path+"/chats/:id/branches/:branch_id", append(middlewares, neo.handleChatBranchSwitch)...)
+
+	// Chat transcript endpoints
+	// Export example (format: md, json, html, default json, the JSON transcript can be imported):
+	// curl -X GET 'http://localhost:5099/api/__yao/neo/chats/chat_123/export?format=md&token=xxx'
+	router.GET(path+"/chats/:id/export", append(middlewares, neo.handleChatExport)...)
+
+	// Import example (chat_id is optional, defaults to the chat id of the transcript).
+	// Only the JSON transcript can be imported, the md and html transcripts are rejected with 400 "only JSON transcripts can be imported":
+	// curl -X POST 'http://localhost:5099/api/__yao/neo/chats/import?chat_id=chat_456&token=xxx' \
+	//   -H 'Content-Type: application/json' \
+	//   -d @chat_123.json
+	router.POST(path+"/chats/import", append(middlewares, neo.handleChatImport)...)
+
 	// Chat history endpoint
 	// Example:
 	// curl -X GET 'http://localhost:5099/api/__yao/neo/history?chat_id=chat_123&token=xxx'
+	// curl -X GET 'http://localhost:5099/api/__yao/neo/history?chat_id=chat_123&branch=message_123&token=xxx'
 	router.GET(path+"/history", append(middlewares, neo.handleChatHistory)...)
 
 	// File management endpoints
@@ -238,6 +269,11 @@ func (neo *DSL) handleChat(c *gin.Context) {
 		ctx = chatctx.WithAssistantID(ctx, assistantID)
 	}
 
+	// Set the parent message ID, edit or regenerate a message
+	if parentID := c.Query("parent_id"); parentID != "" {
+		ctx.ParentID = parentID
+	}
+
 	err := neo.Answer(ctx, content, c)
 
 	// Error handling
@@ -297,7 +333,13 @@ func (neo *DSL) handleChatHistory(c *gin.Context) {
 	}
 
 	cid := c.Query("chat_id")
-	history, err := neo.Store.GetHistory(sid, cid)
+	history, err := neo.Store.GetHistoryWithFilter(sid, cid, store.ChatFilter{Branch: c.Query("branch")})
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(404, gin.H{"message": err.Error(), "code": 404})
+		c.Done()
+		return
+	}
+
 	if err != nil {
 		c.JSON(500, gin.H{"message": err.Error(), "code": 500})
 		c.Done()
@@ -461,6 +503,11 @@ func (neo *DSL) defaultGuard(c *gin.Context) {
 	}
 
 	user := helper.JwtValidate(token)
+	if login.Revoked(user.SID) {
+		c.JSON(401, gin.H{"message": "token revoked", "code": 401})
+		c.Abort()
+		return
+	}
 	c.Set("__sid", user.SID)
 	c.Next()
 }
@@ -717,6 +764,152 @@ func (neo *DSL) handleChatDelete(c *gin.Context) {
 	c.Done()
 }
 
+// handleChatBranches handles listing the branches of a chat
+func (neo *DSL) handleChatBranches(c *gin.Context) {
+	sid := c.GetString("__sid")
+	if sid == "" {
+		c.JSON(400, gin.H{"message": "sid is required", "code": 400})
+		c.Done()
+		return
+	}
+
+	chatID := c.Param("id")
+	if chatID == "" {
+		c.JSON(400, gin.H{"message": "chat id is required", "code": 400})
+		c.Done()
+		return
+	}
+
+	branches, err := neo.Store.GetBranches(sid, chatID)
+	if err != nil {
+		c.JSON(500, gin.H{"message": err.Error(), "code": 500})
+		c.Done()
+		return
+	}
+
+	c.JSON(200, map[string]interface{}{"data": branches})
+	c.Done()
+}
+
+// handleChatBranchSwitch handles switching the active branch of a chat, returns the history of the branch
+func (neo *DSL) handleChatBranchSwitch(c *gin.Context) {
+	sid := c.GetString("__sid")
+	if sid == "" {
+		c.JSON(400, gin.H{"message": "sid is required", "code": 400})
+		c.Done()
+		return
+	}
+
+	chatID := c.Param("id")
+	if chatID == "" {
+		c.JSON(400, gin.H{"message": "chat id is required", "code": 400})
+		c.Done()
+		return
+	}
+
+	branchID := c.Param("branch_id")
+	if branchID == "" {
+		c.JSON(400, gin.H{"message": "branch id is required", "code": 400})
+		c.Done()
+		return
+	}
+
+	err := neo.Store.SwitchBranch(sid, chatID, branchID)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(404, gin.H{"message": err.Error(), "code": 404})
+		c.Done()
+		return
+	}
+
+	if err != nil {
+		c.JSON(500, gin.H{"message": err.Error(), "code": 500})
+		c.Done()
+		return
+	}
+
+	history, err := neo.Store.GetHistory(sid, chatID)
+	if err != nil {
+		c.JSON(500, gin.H{"message": err.Error(), "code": 500})
+		c.Done()
+		return
+	}
+
+	c.JSON(200, map[string]interface{}{"data": history})
+	c.Done()
+}
+
+// handleChatExport handles exporting a chat as a transcript file
+func (neo *DSL) handleChatExport(c *gin.Context) {
+	sid := c.GetString("__sid")
+	if sid == "" {
+		c.JSON(400, gin.H{"message": "sid is required", "code": 400})
+		c.Done()
+		return
+	}
+
+	chatID := c.Param("id")
+	if chatID == "" {
+		c.JSON(400, gin.H{"message": "chat id is required", "code": 400})
+		c.Done()
+		return
+	}
+
+	format := c.DefaultQuery("format", transcript.FormatJSON)
+	data, err := neo.ExportChat(sid, chatID, format)
+	if err != nil {
+		c.JSON(500, gin.H{"message": err.Error(), "code": 500})
+		c.Done()
+		return
+	}
+
+	contentType, ext := transcript.ContentType(format)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", chatID+"."+ext))
+	c.Data(200, contentType, data)
+	c.Done()
+}
+
+// handleChatImport handles importing a JSON transcript as a new chat
+func (neo *DSL) handleChatImport(c *gin.Context) {
+	sid := c.GetString("__sid")
+	if sid == "" {
+		c.JSON(400, gin.H{"message": "sid is required", "code": 400})
+		c.Done()
+		return
+	}
+
+	// Only the JSON transcripts can be imported
+	err := transcript.Importable(c.Query("format"), c.ContentType())
+	if err != nil {
+		c.JSON(400, gin.H{"message": err.Error(), "code": 400})
+		c.Done()
+		return
+	}
+
+	data, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(400, gin.H{"message": "invalid request body", "code": 400})
+		c.Done()
+		return
+	}
+
+	t, err := transcript.Parse(data)
+	if err != nil {
+		c.JSON(400, gin.H{"message": err.Error(), "code": 400})
+		c.Done()
+		return
+	}
+
+	chatID, err := neo.ImportChat(sid, t, c.Query("chat_id"))
+	if err != nil {
+		c.JSON(500, gin.H{"message": err.Error(), "code": 500})
+		c.Done()
+		return
+	}
+
+	c.JSON(200, map[string]interface{}{"data": map[string]interface{}{"chat_id": chatID}})
+	c.Done()
+}
+
 // handleChatsDeleteAll handles deleting all chats for a user
 func (neo *DSL) handleChatsDeleteAll(c *gin.Context) {
 	sid := c.GetString("__sid")
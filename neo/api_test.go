@@ -179,6 +179,40 @@ func TestAPIAuth(t *testing.T) {
 	}
 }
 
+func TestAPIChatImport(t *testing.T) {
+	test.Prepare(t, config.Conf)
+	defer test.Clean()
+
+	router := testRouter(t)
+	err := Neo.API(router, "/neo/chat")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the JSON transcripts can be imported
+	tests := []struct {
+		name        string
+		url         string
+		contentType string
+		body        string
+	}{
+		{"Markdown Format", "/neo/chat/chats/import?format=md&token=%s", "application/json", `{"version": 1}`},
+		{"HTML Content Type", "/neo/chat/chats/import?token=%s", "text/html", "<html></html>"},
+		{"Markdown Body", "/neo/chat/chats/import?token=%s", "application/json", "# Weather\n\n**user**: hello"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", fmt.Sprintf(tt.url, testToken()), strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			router.ServeHTTP(response, req)
+			assert.Equal(t, http.StatusBadRequest, response.Code)
+			assert.Contains(t, response.Body.String(), "only JSON transcripts can be imported")
+		})
+	}
+}
+
 // Helper functions
 func testServer(t *testing.T, router *gin.Engine) (string, func()) {
 	l, err := net.Listen("tcp4", ":0")
@@ -17,6 +17,7 @@ import (
 	chatctx "github.com/yaoapp/yao/neo/context"
 	"github.com/yaoapp/yao/neo/message"
 	chatMessage "github.com/yaoapp/yao/neo/message"
+	api "github.com/yaoapp/yao/openai"
 )
 
 // Get get the assistant by id
@@ -388,8 +389,10 @@ func (ast *Assistant) streamChat(
 	tokenID := ""
 	beganAt := int64(0)
 	var retry error = nil
-	var result interface{} = nil // To save the result
-	var content string = ""      // To save the content
+	var toolCalls []ToolCall = nil       // The tool calls bound to processes
+	var nativeCalls []api.ToolCall = nil // The native tool_calls deltas
+	var result interface{} = nil         // To save the result
+	var content string = ""              // To save the content
 	err := ast.Chat(c.Request.Context(), messages, options, func(data []byte) int {
 
 		select {
@@ -465,6 +468,7 @@ func (ast *Assistant) streamChat(
 
 			// for native tool_calls response, keep the first tool_calls_native message
 			if msg.Type == "tool_calls_native" {
+				nativeCalls = appendToolCalls(nativeCalls, msg.ToolCalls)
 
 				if toolsCount > 1 {
 					msg.Text = "" // clear the text
@@ -615,6 +619,28 @@ func (ast *Assistant) streamChat(
 
 				// Remove the last empty data
 				contents.RemoveLastEmpty()
+
+				// Run the tools bound to processes and continue the chat
+				if calls := ast.boundToolCalls(ctx, nativeCalls, contents); len(calls) > 0 {
+					toolCalls = calls
+					return 0 // break
+				}
+
+				// Validate the structured output, and retry if it does not match the schema
+				if ast.Schema != nil {
+					data, err := ast.Schema.Parse(ast.Schema.output(contents))
+					if err != nil {
+						retry = err
+						return 0 // break
+					}
+
+					output := ast.Schema.data(contents, data)
+					output.Retry = ctx.Retry
+					output.Silent = ctx.Silent
+					output.Callback(cb).Write(c.Writer)
+					result = data
+				}
+
 				res, hookErr := ast.HookDone(c, ctx, messages, contents)
 
 				// Some error occurred in the hook, return the error
@@ -623,8 +649,9 @@ func (ast *Assistant) streamChat(
 					return 0 // break
 				}
 
-				// Save the chat history
+				// Save the chat history, the next assistant continues the branch
 				ast.saveChatHistory(ctx, messages, contents)
+				ctx.ParentID = ""
 
 				// If the hook is successful, execute the next action
 				if res != nil && res.Next != nil {
@@ -683,6 +710,18 @@ func (ast *Assistant) streamChat(
 			return nil, retry
 		}
 
+		// The response does not match the schema, the default prompt asks the model to fix it
+		if schemaErr, ok := retry.(*SchemaError); ok {
+			if int(ctx.RetryTimes) > ast.Schema.retries() {
+				color.Red("%s, try to fix the response %d times, but failed", exception.Trim(retry), ast.Schema.retries())
+				return nil, retry
+			}
+
+			if promptAny == nil {
+				promptAny = schemaErr.Prompt(messages)
+			}
+		}
+
 		if promptAny == nil {
 			return nil, retry
 		}
@@ -728,6 +767,11 @@ func (ast *Assistant) streamChat(
 		return nil, err
 	}
 
+	// Tool loop
+	if len(toolCalls) > 0 {
+		return ast.runTools(c, ctx, messages, options, clientBreak, contents, toolCalls, cb)
+	}
+
 	// raw error
 	if errorRaw != "" {
 		msg, err := chatMessage.NewStringError(errorRaw)
@@ -772,7 +816,15 @@ func (ast *Assistant) retryMessages(messages []chatMessage.Message, prompt strin
 // saveChatHistory saves the chat history if storage is available
 func (ast *Assistant) saveChatHistory(ctx chatctx.Context, messages []chatMessage.Message, contents *chatMessage.Contents) {
 	if len(contents.Data) > 0 && ctx.Sid != "" && len(messages) > 0 {
+
+		// The last user message, the tool loop appends the tool results after it
 		userMessage := messages[len(messages)-1]
+		for i := len(messages) - 1; i >= 0; i-- {
+			if messages[i].Role == "user" {
+				userMessage = messages[i]
+				break
+			}
+		}
 		data := []map[string]interface{}{
 			{
 				"role":    "user",
@@ -806,10 +858,20 @@ func (ast *Assistant) withOptions(options map[string]interface{}) map[string]int
 	// Add Custom Options
 	if ast.Options != nil {
 		for key, value := range ast.Options {
+			if key == "history" { // The history strategy, not an AI option
+				continue
+			}
 			options[key] = value
 		}
 	}
 
+	// Add response_format
+	if ast.Schema != nil && options["response_format"] == nil {
+		if settings, has := connectorSettings[ast.Connector]; has && settings.ResponseFormat {
+			options["response_format"] = ast.Schema.responseFormat()
+		}
+	}
+
 	// Add tool_calls
 	if ast.Tools != nil && ast.Tools.Tools != nil && len(ast.Tools.Tools) > 0 {
 		if settings, has := connectorSettings[ast.Connector]; has && settings.Tools {
@@ -912,6 +974,14 @@ func (ast *Assistant) withPrompts(messages []chatMessage.Message) []chatMessage.
 		}
 	}
 
+	// Add the response schema
+	if ast.Schema != nil {
+		settings, has := connectorSettings[ast.Connector]
+		if !has || !settings.ResponseFormat {
+			messages = append(messages, ast.Schema.prompts()...)
+		}
+	}
+
 	return messages
 }
 
@@ -944,40 +1014,36 @@ func (ast *Assistant) withHistory(ctx chatctx.Context, input interface{}) ([]cha
 		return nil, fmt.Errorf("unknown input type: %T", input)
 	}
 
-	messages := []chatMessage.Message{}
-	if storage != nil {
-		history, err := storage.GetHistory(ctx.Sid, ctx.ChatID)
-		if err != nil {
-			return nil, err
-		}
+	// System prompts
+	prompts := ast.withPrompts([]chatMessage.Message{})
 
-		// Add history messages
-		for _, h := range history {
-			msgs, err := chatMessage.NewHistory(h)
-			if err != nil {
-				return nil, err
-			}
-			messages = append(messages, msgs...)
-		}
-	}
-
-	// Add system prompts
-	messages = ast.withPrompts(messages)
-
-	// Add user message
+	// User message and input messages
+	inputs := []chatMessage.Message{}
 	if userMessage != nil {
-		messages = append(messages, *userMessage)
+		inputs = append(inputs, *userMessage)
 	}
 
-	// Add input messages
 	if len(inputMessages) > 0 {
 		for _, msg := range inputMessages {
 			if msg == nil || msg.Role == "" {
 				continue
 			}
-			messages = append(messages, *msg)
+			inputs = append(inputs, *msg)
 		}
 	}
+
+	// Add history messages (trimmed by the history strategy)
+	messages := []chatMessage.Message{}
+	if storage != nil {
+		history, err := ast.history(ctx, append(append([]chatMessage.Message{}, prompts...), inputs...))
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, history...)
+	}
+
+	messages = append(messages, prompts...)
+	messages = append(messages, inputs...)
 	return messages, nil
 }
 
@@ -1027,8 +1093,14 @@ func formatMessages(messages []map[string]interface{}) []map[string]interface{}
 			name = fmt.Sprintf("%v", nameVal)
 		}
 
-		// Create a unique key for this message
+		// Create a unique key for this message, the tool calls and the tool results are kept
 		key := fmt.Sprintf("%s:%s:%s", role, content, name)
+		if calls, has := msg["tool_calls"]; has {
+			key = fmt.Sprintf("%s:%v", key, calls)
+		}
+		if id, has := msg["tool_call_id"]; has {
+			key = fmt.Sprintf("%s:%v", key, id)
+		}
 
 		// If we haven't seen this message before, add it to filtered messages
 		if !seen[key] {
@@ -1070,11 +1142,11 @@ func formatMessages(messages []map[string]interface{}) []map[string]interface{}
 		return systemMessages
 	}
 
-	// Ensure the last message is a user message
+	// Ensure the last message is a user message or a tool result
 	// Remove any trailing assistant messages
 	lastUserIndex := -1
 	for i := len(validOtherMessages) - 1; i >= 0; i-- {
-		if validOtherMessages[i]["role"].(string) == "user" {
+		if role := validOtherMessages[i]["role"].(string); role == "user" || role == "tool" {
 			lastUserIndex = i
 			break
 		}
@@ -1106,7 +1178,10 @@ func formatMessages(messages []map[string]interface{}) []map[string]interface{}
 		}
 
 		// If both current and last messages are from assistant, check if they can be merged
-		if msg["role"].(string) == "assistant" && lastMessage["role"].(string) == "assistant" {
+		// The messages of the tool calls are not merged
+		_, calls := msg["tool_calls"]
+		_, lastCalls := lastMessage["tool_calls"]
+		if msg["role"].(string) == "assistant" && lastMessage["role"].(string) == "assistant" && !calls && !lastCalls {
 			// Get name information
 			nameVal, hasName := msg["name"]
 
@@ -1159,7 +1234,7 @@ func (ast *Assistant) requestMessages(ctx context.Context, messages []chatMessag
 		}
 
 		content := message.String()
-		if content == "" {
+		if content == "" && len(message.ToolCalls) == 0 {
 			// fmt.Println("--------------------------------")
 			// fmt.Println("Request Message Error")
 			// utils.Dump(message)
@@ -1173,6 +1248,26 @@ func (ast *Assistant) requestMessages(ctx context.Context, messages []chatMessag
 			"content": content,
 		}
 
+		// The tool calls of the tool loop, followed by the tool messages of the results
+		if len(message.ToolCalls) > 0 {
+			calls := []map[string]interface{}{}
+			for _, call := range message.ToolCalls {
+				calls = append(calls, map[string]interface{}{
+					"id":       call.ID,
+					"type":     "function",
+					"function": map[string]interface{}{"name": call.Function.Name, "arguments": call.Function.Arguments},
+				})
+			}
+			newMessage["tool_calls"] = calls
+			if content == "" {
+				newMessage["content"] = nil
+			}
+		}
+
+		if message.ToolCallID != "" {
+			newMessage["tool_call_id"] = message.ToolCallID
+		}
+
 		// Keep the name for user messages
 		if name := message.Name; name != "" {
 			if role != "system" {
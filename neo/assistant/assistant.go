@@ -236,6 +236,24 @@ func (ast *Assistant) Clone() *Assistant {
 			clone.Tools.Prompts = make([]Prompt, len(ast.Tools.Prompts))
 			copy(clone.Tools.Prompts, ast.Tools.Prompts)
 		}
+
+		if ast.Tools.Process != nil {
+			clone.Tools.Process = make(map[string]string)
+			for k, v := range ast.Tools.Process {
+				clone.Tools.Process[k] = v
+			}
+		}
+
+		if ast.Tools.Loop != nil {
+			loop := *ast.Tools.Loop
+			clone.Tools.Loop = &loop
+		}
+	}
+
+	// Deep copy schema
+	if ast.Schema != nil {
+		schema := *ast.Schema
+		clone.Schema = &schema
 	}
 
 	// Deep copy flows
@@ -0,0 +1,492 @@
+package assistant
+
+import (
+	"fmt"
+	"strings"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/spf13/cast"
+	"github.com/yaoapp/kun/log"
+	chatctx "github.com/yaoapp/yao/neo/context"
+	chatMessage "github.com/yaoapp/yao/neo/message"
+	"github.com/yaoapp/yao/neo/store"
+	"github.com/yaoapp/yao/openai"
+)
+
+// historySummaryName the name of the summary messages
+const historySummaryName = "HISTORY_SUMMARY"
+
+// historyMessageTokens the tokens of the message envelope (role, name, separators)
+const historyMessageTokens = 4
+
+// historyPageSize the page size of loading the history to trim
+const historyPageSize = 100
+
+// historySummaryPrompt the default prompt of the rolling summary
+const historySummaryPrompt = "Summarize the conversation below for the assistant's own memory. " +
+	"Merge the previous summary if there is one. Keep the facts, decisions, open questions, " +
+	"user preferences and any identifiers, names or numbers that may be needed later. " +
+	"Write in the language of the conversation and reply with the summary only."
+
+// historyItem a stored history message and its token count
+type historyItem struct {
+	messages []chatMessage.Message
+	tokens   int
+	pinned   bool // pinned items are never trimmed
+	summary  bool // the item is a rolling summary
+	position int  // the position in the loaded history
+}
+
+// history load the history of the chat and apply the history strategy
+// fixed: the messages always sent with the history (prompts and user input)
+func (ast *Assistant) history(ctx chatctx.Context, fixed []chatMessage.Message) ([]chatMessage.Message, error) {
+	setting := ast.historySetting()
+	if setting.Strategy != HistoryWindow && setting.Strategy != HistorySummary {
+		items, _, err := ast.loadHistory(ctx, setting, 0)
+		if err != nil {
+			return nil, err
+		}
+		return historyMessages(items), nil
+	}
+
+	window := 0
+	if ast.openai != nil {
+		window = ast.openai.ContextWindow()
+	}
+
+	budget := setting.budget(window) - ast.countTokens(fixed)
+	if budget < 0 {
+		budget = 0
+	}
+
+	items, total, err := ast.loadHistory(ctx, setting, budget)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := 0
+	for _, item := range items {
+		tokens += item.tokens
+	}
+	if tokens <= budget {
+		return historyMessages(items), nil
+	}
+
+	// Sliding window
+	if setting.Strategy == HistoryWindow {
+		kept, _ := trimHistory(items, budget)
+		return historyMessages(kept), nil
+	}
+
+	// Rolling summary, trim to the half of the budget to avoid summarizing on every turn
+	kept, trimmed := trimHistory(items, budget/2)
+	summary, err := ast.summarizeHistory(ctx, setting, trimmed, kept, total)
+	if err != nil {
+		log.Error("[Neo] %s summarize the history of chat %s: %s", ast.ID, ctx.ChatID, err.Error())
+		kept, _ = trimHistory(items, budget)
+		return historyMessages(kept), nil
+	}
+
+	if summary != nil {
+		kept = append([]historyItem{*summary}, kept...)
+	}
+	return historyMessages(kept), nil
+}
+
+// historySetting returns the history setting of the assistant
+// The assistant options.history overrides the neo history setting
+func (ast *Assistant) historySetting() HistorySetting {
+	setting := historySetting
+	if setting.Summary != nil {
+		summary := *setting.Summary
+		setting.Summary = &summary
+	}
+
+	if ast.Options == nil {
+		return setting
+	}
+
+	v, has := ast.Options["history"]
+	if !has || v == nil {
+		return setting
+	}
+
+	raw, err := jsoniter.Marshal(v)
+	if err != nil {
+		log.Error("[Neo] %s history option error: %s", ast.ID, err.Error())
+		return setting
+	}
+
+	err = jsoniter.Unmarshal(raw, &setting)
+	if err != nil {
+		log.Error("[Neo] %s history option error: %s", ast.ID, err.Error())
+	}
+	return setting
+}
+
+// loadHistory load the history items of the chat, returns the items and the size of the loaded history
+// The window and summary strategies load the history pages until it exceeds the budget, see loadRows
+func (ast *Assistant) loadHistory(ctx chatctx.Context, setting HistorySetting, budget int) ([]historyItem, int, error) {
+	var rows []map[string]interface{}
+	var positions []int
+	var total int
+
+	// Editing or regenerating a message loads the branch ending at its parent
+	filter := store.ChatFilter{Branch: ctx.ParentID}
+	switch setting.Strategy {
+	case HistorySummary:
+		silent := true
+		filter.Silent = &silent
+		history, err := ast.loadRows(ctx, filter, budget, true)
+		if err != nil {
+			return nil, 0, err
+		}
+		rows, positions, total = compactHistory(history, setting.pinSystem())
+
+	case HistoryWindow:
+		history, err := ast.loadRows(ctx, filter, budget, false)
+		if err != nil {
+			return nil, 0, err
+		}
+		rows, positions, total = history, historyPositions(len(history)), len(history)
+
+	default:
+		history, err := storage.GetHistoryWithFilter(ctx.Sid, ctx.ChatID, filter)
+		if err != nil {
+			return nil, 0, err
+		}
+		rows, positions, total = history, historyPositions(len(history)), len(history)
+	}
+
+	items := make([]historyItem, 0, len(rows))
+	for i, row := range rows {
+		msgs, err := chatMessage.NewHistory(row)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		item := historyItem{
+			messages: msgs,
+			tokens:   ast.countTokens(msgs),
+			summary:  isHistorySummary(row),
+			position: positions[i],
+		}
+		item.pinned = !item.summary && setting.pinSystem() && row["role"] == "system"
+		items = append(items, item)
+	}
+
+	return items, total, nil
+}
+
+// loadRows load the history pages from the latest message until the history exceeds the budget or is exhausted
+// The summary strategy continues to the latest summary, the messages before it are covered by the summary.
+// The tokens are estimated by the size of the contents (4 bytes per token), the estimate is not more than the tokens
+// of most of the languages, and the history is counted by the tokenizer after it is loaded.
+func (ast *Assistant) loadRows(ctx chatctx.Context, filter store.ChatFilter, budget int, summary bool) ([]map[string]interface{}, error) {
+	rows := []map[string]interface{}{}
+	tokens := 0
+	found := false
+
+	filter.PageSize = historyPageSize
+	for page := 1; ; page++ {
+		filter.Page = page
+		history, err := storage.GetHistoryWithFilter(ctx.Sid, ctx.ChatID, filter)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(append([]map[string]interface{}{}, history...), rows...)
+
+		for _, row := range history {
+			if isHistorySummary(row) {
+				found = true
+				continue
+			}
+			if !cast.ToBool(row["silent"]) {
+				tokens += historyMessageTokens + len(fmt.Sprintf("%v", row["content"]))/4
+			}
+		}
+
+		if len(history) < historyPageSize || (tokens > budget && (!summary || found)) {
+			return rows, nil
+		}
+	}
+}
+
+// summarizeHistory summarize the trimmed items with the previous summary and persist the summary
+// Returns nil if there is nothing to summarize
+func (ast *Assistant) summarizeHistory(ctx chatctx.Context, setting HistorySetting, trimmed []historyItem, kept []historyItem, total int) (*historyItem, error) {
+
+	previous := ""
+	lines := []string{}
+	for _, item := range trimmed {
+		for _, msg := range item.messages {
+			if msg.Type == "tool" || msg.Type == "think" || msg.Type == "error" {
+				continue
+			}
+
+			text := strings.TrimSpace(msg.String())
+			if text == "" {
+				continue
+			}
+
+			if item.summary {
+				previous = text
+				continue
+			}
+			lines = append(lines, fmt.Sprintf("%s: %s", msg.Role, text))
+		}
+	}
+
+	if len(lines) == 0 && previous == "" {
+		return nil, nil
+	}
+
+	text := previous
+	if len(lines) > 0 {
+		var err error
+		text, err = ast.requestSummary(setting, previous, lines)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// The kept messages are stored before the summary
+	keep := 0
+	for _, item := range kept {
+		if !item.pinned && !item.summary {
+			keep = total - item.position
+			break
+		}
+	}
+
+	msg := chatMessage.New()
+	msg.Role = "system"
+	msg.Name = historySummaryName
+	msg.Text = text
+
+	context := ctx.Map()
+	context["silent"] = true
+	context["history_summary"] = keep
+	err := storage.SaveHistory(ctx.Sid, []map[string]interface{}{
+		{"role": msg.Role, "name": msg.Name, "content": msg.Content()},
+	}, ctx.ChatID, context)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := []chatMessage.Message{*msg}
+	return &historyItem{messages: messages, tokens: ast.countTokens(messages), summary: true}, nil
+}
+
+// requestSummary request the connector to summarize the conversation
+func (ast *Assistant) requestSummary(setting HistorySetting, previous string, lines []string) (string, error) {
+	api := ast.openai
+	prompt := historySummaryPrompt
+	options := map[string]interface{}{}
+
+	if setting.Summary != nil {
+		if setting.Summary.Connector != "" && setting.Summary.Connector != ast.Connector {
+			var err error
+			api, err = openai.New(setting.Summary.Connector)
+			if err != nil {
+				return "", err
+			}
+		}
+
+		if setting.Summary.Prompt != "" {
+			prompt = setting.Summary.Prompt
+		}
+
+		if setting.Summary.MaxTokens > 0 {
+			options["max_tokens"] = setting.Summary.MaxTokens
+		}
+	}
+
+	if api == nil {
+		return "", fmt.Errorf("openai is not initialized")
+	}
+
+	content := ""
+	if previous != "" {
+		content = "Previous summary:\n" + previous + "\n\n"
+	}
+	content += "Conversation:\n" + strings.Join(lines, "\n")
+
+	res, ext := api.ChatCompletions([]map[string]interface{}{
+		{"role": "system", "content": prompt},
+		{"role": "user", "content": content},
+	}, options, nil)
+	if ext != nil {
+		return "", fmt.Errorf("summary chat completions error: %s", ext.Message)
+	}
+
+	text, ext := api.GetContent(res)
+	if ext != nil {
+		return "", fmt.Errorf("summary content error: %s", ext.Message)
+	}
+
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return "", fmt.Errorf("summary is empty")
+	}
+	return text, nil
+}
+
+// countTokens count the tokens of the messages
+// Falls back to an estimate when the model has no tokenizer
+func (ast *Assistant) countTokens(messages []chatMessage.Message) int {
+	tokens := 0
+	for _, msg := range messages {
+		text := msg.String()
+		tokens += historyMessageTokens
+		if ast.openai != nil {
+			if n, err := ast.openai.Tiktoken(text); err == nil {
+				tokens += n
+				continue
+			}
+		}
+		tokens += len(text)/4 + 1
+	}
+	return tokens
+}
+
+// budget returns the token budget of the history, prompts and user input
+// window: the context window of the model, the budget if max_tokens is not set
+func (setting HistorySetting) budget(window int) int {
+	budget := setting.MaxTokens
+	if budget <= 0 {
+		budget = window
+	}
+
+	reserved := setting.Reserved
+	if reserved <= 0 {
+		reserved = budget / 4
+	}
+	return budget - reserved
+}
+
+// pinSystem whether to keep the system messages of the history
+func (setting HistorySetting) pinSystem() bool {
+	return setting.PinSystem == nil || *setting.PinSystem
+}
+
+// trimHistory remove the oldest items until the history fits the budget
+// Returns the kept items and the trimmed items
+func trimHistory(items []historyItem, budget int) ([]historyItem, []historyItem) {
+	tokens := 0
+	for _, item := range items {
+		tokens += item.tokens
+	}
+
+	kept := []historyItem{}
+	trimmed := []historyItem{}
+	for _, item := range items {
+		if tokens > budget && !item.pinned {
+			tokens -= item.tokens
+			trimmed = append(trimmed, item)
+			continue
+		}
+		kept = append(kept, item)
+	}
+	return kept, trimmed
+}
+
+// compactHistory drop the silent messages and the messages covered by the latest summary
+// Returns the rows, the positions of the rows and the size of the history
+//
+// The summary stores the number of the messages kept before it (context.history_summary),
+// the result is: pinned messages before the kept messages, the summary, the kept messages and the messages after the summary.
+func compactHistory(history []map[string]interface{}, pinSystem bool) ([]map[string]interface{}, []int, int) {
+	rows := []map[string]interface{}{}
+	for _, row := range history {
+		if isHistorySummary(row) || !cast.ToBool(row["silent"]) {
+			rows = append(rows, row)
+		}
+	}
+
+	last := -1
+	for i := len(rows) - 1; i >= 0; i-- {
+		if isHistorySummary(rows[i]) {
+			last = i
+			break
+		}
+	}
+
+	positions := []int{}
+	if last == -1 {
+		for i := range rows {
+			positions = append(positions, i)
+		}
+		return rows, positions, len(rows)
+	}
+
+	start := last - historySummaryKeep(rows[last])
+	if start < 0 {
+		start = 0
+	}
+
+	res := []map[string]interface{}{}
+	for i := 0; i < start; i++ {
+		if pinSystem && rows[i]["role"] == "system" && !isHistorySummary(rows[i]) {
+			res = append(res, rows[i])
+			positions = append(positions, i)
+		}
+	}
+
+	res = append(res, rows[last])
+	positions = append(positions, last)
+	for i := start; i < len(rows); i++ {
+		if isHistorySummary(rows[i]) {
+			continue
+		}
+		res = append(res, rows[i])
+		positions = append(positions, i)
+	}
+
+	return res, positions, len(rows)
+}
+
+// isHistorySummary check if the history row is a rolling summary
+func isHistorySummary(row map[string]interface{}) bool {
+	return row["role"] == "system" && row["name"] == historySummaryName
+}
+
+// historySummaryKeep returns the number of the messages kept before the summary
+func historySummaryKeep(row map[string]interface{}) int {
+	var context map[string]interface{}
+	switch v := row["context"].(type) {
+	case map[string]interface{}:
+		context = v
+	case string:
+		if err := jsoniter.UnmarshalFromString(v, &context); err != nil {
+			return 0
+		}
+	case []byte:
+		if err := jsoniter.Unmarshal(v, &context); err != nil {
+			return 0
+		}
+	}
+
+	if context == nil {
+		return 0
+	}
+	return cast.ToInt(context["history_summary"])
+}
+
+// historyPositions returns the positions of the history rows
+func historyPositions(size int) []int {
+	positions := make([]int, 0, size)
+	for i := 0; i < size; i++ {
+		positions = append(positions, i)
+	}
+	return positions
+}
+
+// historyMessages flatten the history items
+func historyMessages(items []historyItem) []chatMessage.Message {
+	messages := []chatMessage.Message{}
+	for _, item := range items {
+		messages = append(messages, item.messages...)
+	}
+	return messages
+}
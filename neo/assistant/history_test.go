@@ -0,0 +1,202 @@
+package assistant
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	chatctx "github.com/yaoapp/yao/neo/context"
+	chatMessage "github.com/yaoapp/yao/neo/message"
+	"github.com/yaoapp/yao/neo/store"
+)
+
+func TestHistorySetting(t *testing.T) {
+	defer SetHistory(HistorySetting{Strategy: HistoryFull})
+	SetHistory(HistorySetting{Strategy: HistoryWindow, MaxTokens: 1000, Summary: &SummarySetting{Prompt: "neo"}})
+
+	ast := &Assistant{ID: "test"}
+	setting := ast.historySetting()
+	assert.Equal(t, HistoryWindow, setting.Strategy)
+	assert.Equal(t, 750, setting.budget(0))
+	assert.True(t, setting.pinSystem())
+
+	ast.Options = map[string]interface{}{
+		"history": map[string]interface{}{
+			"strategy":   "summary",
+			"reserved":   100,
+			"pin_system": false,
+			"summary":    map[string]interface{}{"max_tokens": 200},
+		},
+	}
+	setting = ast.historySetting()
+	assert.Equal(t, HistorySummary, setting.Strategy)
+	assert.Equal(t, 900, setting.budget(0))
+	assert.False(t, setting.pinSystem())
+	assert.Equal(t, "neo", setting.Summary.Prompt)
+	assert.Equal(t, 200, setting.Summary.MaxTokens)
+
+	// The neo setting is not changed
+	assert.Equal(t, 0, historySetting.Summary.MaxTokens)
+
+	// The history option is not sent to the connector
+	options := ast.withOptions(nil)
+	assert.NotContains(t, options, "history")
+}
+
+func TestTrimHistory(t *testing.T) {
+	items := []historyItem{
+		{tokens: 10, pinned: true},
+		{tokens: 10, position: 1},
+		{tokens: 10, position: 2},
+		{tokens: 10, position: 3},
+	}
+
+	kept, trimmed := trimHistory(items, 25)
+	assert.Equal(t, 2, len(kept))
+	assert.True(t, kept[0].pinned)
+	assert.Equal(t, 3, kept[1].position)
+	assert.Equal(t, []int{1, 2}, []int{trimmed[0].position, trimmed[1].position})
+
+	kept, trimmed = trimHistory(items, 40)
+	assert.Equal(t, 4, len(kept))
+	assert.Equal(t, 0, len(trimmed))
+}
+
+func TestCompactHistory(t *testing.T) {
+	history := []map[string]interface{}{
+		{"role": "system", "content": "pinned"},
+		{"role": "user", "content": "1"},
+		{"role": "assistant", "content": "2"},
+		{"role": "user", "content": "silent", "silent": true},
+		{"role": "user", "content": "3"},
+		{"role": "system", "name": historySummaryName, "content": "summary", "silent": 1, "context": `{"history_summary": 1}`},
+		{"role": "assistant", "content": "4"},
+	}
+
+	rows, positions, total := compactHistory(history, true)
+	contents := []interface{}{}
+	for _, row := range rows {
+		contents = append(contents, row["content"])
+	}
+	assert.Equal(t, []interface{}{"pinned", "summary", "3", "4"}, contents)
+	assert.Equal(t, []int{0, 4, 3, 5}, positions)
+	assert.Equal(t, 6, total)
+
+	rows, _, _ = compactHistory(history, false)
+	assert.Equal(t, "summary", rows[0]["content"])
+	assert.Equal(t, 3, len(rows))
+}
+
+func TestHistoryWindow(t *testing.T) {
+	mem, err := store.NewMemory(store.Setting{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer SetStorage(storage)
+	defer SetHistory(historySetting)
+	SetStorage(mem)
+	SetHistory(HistorySetting{Strategy: HistoryWindow, MaxTokens: 100, Reserved: 10})
+
+	ctx := chatctx.Context{Sid: "test_user", ChatID: "test_chat"}
+	for i := 0; i < 10; i++ {
+		err := mem.SaveHistory(ctx.Sid, []map[string]interface{}{
+			{"role": "user", "content": fmt.Sprintf(`{"text": "message %02d %s"}`, i, "0123456789012345678901234567890123456789")},
+		}, ctx.ChatID, nil)
+		assert.Nil(t, err)
+	}
+
+	ast := &Assistant{ID: "test"}
+	input := []chatMessage.Message{{Role: "user", Text: "hello"}}
+	messages, err := ast.history(ctx, input)
+	assert.Nil(t, err)
+	assert.Greater(t, len(messages), 0)
+	assert.Less(t, len(messages), 10)
+	assert.Contains(t, messages[len(messages)-1].Text, "message 09")
+
+	tokens := ast.countTokens(append(messages, input...))
+	assert.LessOrEqual(t, tokens, 90)
+
+	// The full strategy keeps everything
+	SetHistory(HistorySetting{})
+	messages, err = ast.history(ctx, input)
+	assert.Nil(t, err)
+	assert.Equal(t, 10, len(messages))
+}
+
+func TestHistoryPages(t *testing.T) {
+	mem, err := store.NewMemory(store.Setting{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer SetStorage(storage)
+	defer SetHistory(historySetting)
+	SetStorage(mem)
+	SetHistory(HistorySetting{Strategy: HistoryWindow, MaxTokens: 100000})
+
+	ctx := chatctx.Context{Sid: "test_user", ChatID: "test_chat"}
+	for i := 0; i < historyPageSize+50; i++ {
+		err := mem.SaveHistory(ctx.Sid, []map[string]interface{}{
+			{"role": "user", "content": fmt.Sprintf(`{"text": "message %03d"}`, i)},
+		}, ctx.ChatID, nil)
+		assert.Nil(t, err)
+	}
+
+	// The history fits the budget is loaded, not only the latest page
+	ast := &Assistant{ID: "test"}
+	messages, err := ast.history(ctx, nil)
+	assert.Nil(t, err)
+	if assert.Equal(t, historyPageSize+50, len(messages)) {
+		assert.Contains(t, messages[0].Text, "message 000")
+	}
+
+	// The pages are loaded until the history exceeds the budget
+	rows, err := ast.loadRows(ctx, store.ChatFilter{}, 10, false)
+	assert.Nil(t, err)
+	assert.Equal(t, historyPageSize, len(rows))
+	assert.Contains(t, rows[len(rows)-1]["content"], "message 149")
+}
+
+func TestHistoryBranch(t *testing.T) {
+	mem, err := store.NewMemory(store.Setting{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer SetStorage(storage)
+	SetStorage(mem)
+
+	ctx := chatctx.Context{Sid: "test_user", ChatID: "test_chat"}
+	for _, text := range []string{"first", "second"} {
+		err := mem.SaveHistory(ctx.Sid, []map[string]interface{}{
+			{"role": "user", "content": fmt.Sprintf(`{"text": "%s question"}`, text)},
+			{"role": "assistant", "content": fmt.Sprintf(`{"text": "%s answer"}`, text)},
+		}, ctx.ChatID, nil)
+		assert.Nil(t, err)
+	}
+
+	history, err := mem.GetHistory(ctx.Sid, ctx.ChatID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ast := &Assistant{ID: "test"}
+	input := []chatMessage.Message{{Role: "user", Text: "edited second question"}}
+	messages, err := ast.history(ctx, input)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(messages))
+
+	// Edit the second question, the history ends at its parent
+	ctx.ParentID = history[1]["message_id"].(string)
+	messages, err = ast.history(ctx, input)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(messages))
+	assert.Contains(t, messages[1].Text, "first answer")
+
+	// Start over
+	ctx.ParentID = store.BranchRoot
+	messages, err = ast.history(ctx, input)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(messages))
+}
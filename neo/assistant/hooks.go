@@ -205,35 +205,11 @@ func (ast *Assistant) HookDone(c *gin.Context, context chatctx.Context, input []
 
 			// parse the tool call message content
 			if data.Type == "tool" && data.Props != nil {
-				props := map[string]interface{}{}
-				if text, ok := data.Props["text"].(string); ok {
-
-					// Extract the content between <tool> and </tool> tags more reliably
-					startTag := "<tool>"
-					endTag := "</tool>"
-					startIndex := strings.Index(text, startTag)
-					if startIndex != -1 {
-						// Find the content after <tool>
-						content := text[startIndex+len(startTag):]
-						endIndex := strings.LastIndex(content, endTag)
-						if endIndex != -1 {
-							// Extract the content between tags
-							text = content[:endIndex]
-							text = strings.TrimSpace(text)
-							if os.Getenv("YAO_AGENT_PRINT_TOOL_CALL") == "true" {
-								log.Trace("[TOOL CALL] %s", text)
-							}
-						}
-					}
-
-					// Parse the text into props
-					err := ParseJSON(text, &props)
-					if err != nil {
-						props["error"] = fmt.Sprintf("Can not parse the tool call: %s\n--original--\n%s", err.Error(), text)
-					}
+				// the tool call is executed by the tool loop
+				if executed, _ := data.Props["executed"].(bool); executed {
+					continue
 				}
-
-				output = append(output, message.Data{Type: "tool", Props: props})
+				output = append(output, message.Data{Type: "tool", Props: toolProps(data)})
 				continue
 			}
 			output = append(output, data)
@@ -378,3 +354,37 @@ func (ast *Assistant) call(ctx context.Context, method string, c *gin.Context, c
 	}
 	return nil, nil
 }
+
+// toolProps parse the tool call message content into props
+func toolProps(data message.Data) map[string]interface{} {
+	props := map[string]interface{}{}
+	text, ok := data.Props["text"].(string)
+	if !ok {
+		return props
+	}
+
+	// Extract the content between <tool> and </tool> tags more reliably
+	startTag := "<tool>"
+	endTag := "</tool>"
+	startIndex := strings.Index(text, startTag)
+	if startIndex != -1 {
+		// Find the content after <tool>
+		content := text[startIndex+len(startTag):]
+		endIndex := strings.LastIndex(content, endTag)
+		if endIndex != -1 {
+			// Extract the content between tags
+			text = content[:endIndex]
+			text = strings.TrimSpace(text)
+			if os.Getenv("YAO_AGENT_PRINT_TOOL_CALL") == "true" {
+				log.Trace("[TOOL CALL] %s", text)
+			}
+		}
+	}
+
+	// Parse the text into props
+	err := ParseJSON(text, &props)
+	if err != nil {
+		props["error"] = fmt.Sprintf("Can not parse the tool call: %s\n--original--\n%s", err.Error(), text)
+	}
+	return props
+}
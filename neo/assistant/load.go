@@ -28,6 +28,7 @@ var rag *RAG = nil
 var connectorSettings map[string]ConnectorSetting = map[string]ConnectorSetting{}
 var vision *neovision.Vision = nil
 var defaultConnector string = "" // default connector
+var historySetting HistorySetting = HistorySetting{Strategy: HistoryFull}
 
 // LoadBuiltIn load the built-in assistants
 func LoadBuiltIn() error {
@@ -147,6 +148,11 @@ func SetConnector(c string) {
 	defaultConnector = c
 }
 
+// SetHistory set the history strategy
+func SetHistory(setting HistorySetting) {
+	historySetting = setting
+}
+
 // SetRAG set the RAG engine
 // e: the RAG engine
 // u: the RAG file uploader
@@ -312,6 +318,17 @@ func LoadPath(path string) (*Assistant, error) {
 		updatedAt = max(updatedAt, ts)
 	}
 
+	// load response schema
+	schemafile := filepath.Join(path, "schema.json")
+	if has, _ := app.Exists(schemafile); has {
+		schema, ts, err := loadSchema(schemafile)
+		if err != nil {
+			return nil, err
+		}
+		data["schema"] = schema
+		updatedAt = max(updatedAt, ts)
+	}
+
 	// load flow
 
 	return loadMap(data)
@@ -511,6 +528,39 @@ func loadMap(data map[string]interface{}) (*Assistant, error) {
 		}
 	}
 
+	// schema
+	if schema, has := data["schema"]; has && schema != nil {
+		switch vv := schema.(type) {
+		case *ResponseSchema:
+			assistant.Schema = vv
+
+		default:
+			raw, err := jsoniter.Marshal(schema)
+			if err != nil {
+				return nil, fmt.Errorf("schema format error %s", err.Error())
+			}
+
+			var schema ResponseSchema
+			err = jsoniter.Unmarshal(raw, &schema)
+			if err != nil {
+				return nil, fmt.Errorf("schema format error %s", err.Error())
+			}
+			assistant.Schema = &schema
+		}
+
+		if assistant.Schema.Schema == nil {
+			return nil, fmt.Errorf("schema.schema is required")
+		}
+
+		if err := assistant.Schema.Compile(); err != nil {
+			return nil, err
+		}
+
+		if assistant.Schema.Name == "" {
+			assistant.Schema.Name = strings.ReplaceAll(assistant.ID, ".", "_")
+		}
+	}
+
 	// script
 	if data["script"] != nil {
 		switch v := data["script"].(type) {
@@ -685,3 +735,29 @@ func loadTools(file string) (*ToolCalls, int64, error) {
 
 	return &tools, ts.UnixNano(), nil
 }
+
+func loadSchema(file string) (*ResponseSchema, int64, error) {
+
+	app, err := fs.Get("app")
+	if err != nil {
+		return nil, 0, err
+	}
+
+	content, err := app.ReadFile(file)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	ts, err := app.ModTime(file)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var schema ResponseSchema
+	err = application.Parse(file, content, &schema)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return &schema, ts.UnixNano(), nil
+}
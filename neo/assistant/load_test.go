@@ -389,6 +389,8 @@ func (m *mockStore) SaveAssistant(assistant map[string]interface{}) (interface{}
 func (m *mockStore) SaveHistory(sid string, messages []map[string]interface{}, cid string, context map[string]interface{}) error {
 	return nil
 }
+func (m *mockStore) GetBranches(sid string, cid string) ([]store.Branch, error)   { return nil, nil }
+func (m *mockStore) SwitchBranch(sid string, cid string, messageID string) error  { return nil }
 func (m *mockStore) UpdateChatTitle(sid string, cid string, title string) error   { return nil }
 func (m *mockStore) DeleteAssistants(filter store.AssistantFilter) (int64, error) { return 0, nil }
 func (m *mockStore) GetAssistantTags() ([]string, error)                          { return []string{}, nil }
@@ -0,0 +1,316 @@
+package assistant
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/yaoapp/gou/process"
+	"github.com/yaoapp/kun/log"
+	chatctx "github.com/yaoapp/yao/neo/context"
+	chatMessage "github.com/yaoapp/yao/neo/message"
+	api "github.com/yaoapp/yao/openai"
+)
+
+// toolLoop returns the tool loop limits of the assistant
+func (ast *Assistant) toolLoop() ToolLoop {
+	loop := ToolLoop{MaxIterations: 5, Timeout: 30}
+	if ast.Tools == nil || ast.Tools.Loop == nil {
+		return loop
+	}
+
+	if ast.Tools.Loop.MaxIterations > 0 {
+		loop.MaxIterations = ast.Tools.Loop.MaxIterations
+	}
+
+	if ast.Tools.Loop.Timeout > 0 {
+		loop.Timeout = ast.Tools.Loop.Timeout
+	}
+	return loop
+}
+
+// boundToolCalls returns the tool calls of the response when all of them are bound to Yao processes
+// The unbound tool calls are left to the Done hook
+func (ast *Assistant) boundToolCalls(ctx chatctx.Context, native []api.ToolCall, contents *chatMessage.Contents) []ToolCall {
+	if ast.Tools == nil || len(ast.Tools.Process) == 0 {
+		return nil
+	}
+
+	// The maximum iterations reached
+	if int(ctx.ToolTimes) >= ast.toolLoop().MaxIterations {
+		log.Warn("[Neo] %s the tool loop reached the maximum iterations %d", ast.ID, ctx.ToolTimes)
+		return nil
+	}
+
+	calls := []ToolCall{}
+
+	// Native tool_calls
+	for _, tool := range native {
+		call := ToolCall{ID: tool.ID, Function: tool.Function.Name, Arguments: map[string]interface{}{}}
+		if strings.TrimSpace(tool.Function.Arguments) != "" {
+			err := ParseJSON(tool.Function.Arguments, &call.Arguments)
+			if err != nil {
+				call.Error = fmt.Sprintf("Can not parse the arguments: %s", err.Error())
+			}
+		}
+		calls = append(calls, call)
+	}
+
+	// <tool> tags
+	if len(calls) == 0 && contents != nil {
+		for _, data := range contents.Data {
+			if data.Type != "tool" || data.Props == nil {
+				continue
+			}
+
+			if executed, _ := data.Props["executed"].(bool); executed {
+				continue
+			}
+
+			props := toolProps(data)
+			call := ToolCall{Arguments: map[string]interface{}{}}
+			call.ID, _ = props["id"].(string)
+			call.Function, _ = props["function"].(string)
+			if args, ok := props["arguments"].(map[string]interface{}); ok {
+				call.Arguments = args
+			}
+			if err, ok := props["error"].(string); ok {
+				call.Error = err
+			}
+			calls = append(calls, call)
+		}
+	}
+
+	if len(calls) == 0 {
+		return nil
+	}
+
+	for _, call := range calls {
+		if _, has := ast.Tools.Process[call.Function]; !has {
+			return nil
+		}
+	}
+	return calls
+}
+
+// runTools execute the tool calls concurrently, feed the results back and continue the chat
+func (ast *Assistant) runTools(
+	c *gin.Context,
+	ctx chatctx.Context,
+	messages []chatMessage.Message,
+	options map[string]interface{},
+	clientBreak chan bool,
+	contents *chatMessage.Contents,
+	calls []ToolCall,
+	cb interface{},
+) (interface{}, error) {
+
+	ctx.ToolTimes = ctx.ToolTimes + 1
+	loop := ast.toolLoop()
+	timeout := time.Duration(loop.Timeout) * time.Second
+
+	var mutex sync.Mutex
+	progress := func(text string) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		msg := chatMessage.New().Map(map[string]interface{}{
+			"new":   true,
+			"role":  "assistant",
+			"type":  "loading",
+			"props": map[string]interface{}{"placeholder": text},
+		})
+		msg.Retry = ctx.Retry
+		msg.Silent = ctx.Silent
+		msg.Assistant(ast.ID, ast.Name, ast.Avatar).Callback(cb).Write(c.Writer)
+	}
+
+	var wg sync.WaitGroup
+	for i := range calls {
+		if calls[i].Error != "" {
+			continue
+		}
+
+		wg.Add(1)
+		progress(fmt.Sprintf("Calling %s", calls[i].Function))
+		go func(call *ToolCall) {
+			defer wg.Done()
+			ast.callTool(c.Request.Context(), ctx, call, timeout)
+			if call.Error != "" {
+				progress(fmt.Sprintf("%s failed: %s", call.Function, call.Error))
+				return
+			}
+			progress(fmt.Sprintf("%s done", call.Function))
+		}(&calls[i])
+	}
+	wg.Wait()
+
+	// The tool calls are executed, not handled by the Done hook again
+	executeToolContents(contents, calls)
+
+	next, err := toolMessages(calls)
+	if err != nil {
+		return nil, err
+	}
+
+	input := make([]chatMessage.Message, 0, len(messages)+len(next))
+	input = append(input, messages...)
+	input = append(input, next...)
+	return ast.streamChat(c, ctx, input, options, clientBreak, contents, cb)
+}
+
+// callTool execute the process bound to the tool call
+// The process runs with the timeout context. The call does not wait for the process after the timeout,
+// the process is released after it returns.
+func (ast *Assistant) callTool(parent context.Context, ctx chatctx.Context, call *ToolCall, timeout time.Duration) {
+	name := ast.Tools.Process[call.Function]
+	timeoutCtx, cancel := context.WithTimeout(parent, timeout)
+	defer cancel()
+
+	p, err := process.Of(name, call.Arguments)
+	if err != nil {
+		call.Error = err.Error()
+		return
+	}
+
+	type result struct {
+		value interface{}
+		err   error
+	}
+
+	done := make(chan result, 1)
+	go func() {
+		defer p.Release()
+		err := p.WithContext(timeoutCtx).WithSID(ctx.Sid).Execute()
+		if err != nil {
+			done <- result{err: err}
+			return
+		}
+		done <- result{value: p.Value()}
+	}()
+
+	select {
+	case res := <-done:
+		if res.err != nil {
+			call.Error = res.err.Error()
+			return
+		}
+		call.Result = res.value
+
+	case <-timeoutCtx.Done():
+		if timeoutCtx.Err() == context.DeadlineExceeded {
+			call.Error = fmt.Sprintf("%s timeout after %s", name, timeout)
+			return
+		}
+		call.Error = fmt.Sprintf("%s %s", name, timeoutCtx.Err().Error())
+	}
+}
+
+// executeToolContents mark the <tool> contents executed, and keep the results with them (saved to the history)
+// The calls are matched by id, the contents without a known id take the unmatched calls in order, see boundToolCalls
+func executeToolContents(contents *chatMessage.Contents, calls []ToolCall) {
+	used := make([]bool, len(calls))
+	next := func(id string) *ToolCall {
+		for i := range calls {
+			if !used[i] && id != "" && calls[i].ID == id {
+				used[i] = true
+				return &calls[i]
+			}
+		}
+		for i := range calls {
+			if !used[i] {
+				used[i] = true
+				return &calls[i]
+			}
+		}
+		return nil
+	}
+
+	for i := range contents.Data {
+		if contents.Data[i].Type != "tool" {
+			continue
+		}
+		if contents.Data[i].Props == nil {
+			contents.Data[i].Props = map[string]interface{}{}
+		}
+
+		props := contents.Data[i].Props
+		if executed, _ := props["executed"].(bool); executed {
+			continue
+		}
+		props["executed"] = true
+
+		id, _ := toolProps(contents.Data[i])["id"].(string)
+		call := next(id)
+		if call == nil {
+			continue
+		}
+
+		if call.Error != "" {
+			props["error"] = call.Error
+			continue
+		}
+		props["result"] = call.Result
+	}
+}
+
+// toolMessages returns the assistant message of the tool calls, followed by a tool message (role = tool) of each result
+func toolMessages(calls []ToolCall) ([]chatMessage.Message, error) {
+	native := []api.ToolCall{}
+	results := []chatMessage.Message{}
+	for i, call := range calls {
+		id := call.ID
+		if id == "" {
+			id = fmt.Sprintf("call_%d", i)
+		}
+
+		args, err := jsoniter.MarshalToString(call.Arguments)
+		if err != nil {
+			return nil, err
+		}
+		native = append(native, api.ToolCall{Index: i, ID: id, Type: "function", Function: api.Function{Name: call.Function, Arguments: args}})
+
+		result := map[string]interface{}{"result": call.Result}
+		if call.Error != "" {
+			result = map[string]interface{}{"error": call.Error}
+		}
+
+		raw, err := jsoniter.MarshalToString(result)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, chatMessage.Message{Role: "tool", Type: "text", ToolCallID: id, Text: raw})
+	}
+
+	messages := []chatMessage.Message{{Role: "assistant", Type: "text", ToolCalls: native}}
+	return append(messages, results...), nil
+}
+
+// appendToolCalls merge the native tool_calls deltas by index
+func appendToolCalls(calls []api.ToolCall, deltas []api.ToolCall) []api.ToolCall {
+	for _, delta := range deltas {
+		found := false
+		for i := range calls {
+			if calls[i].Index != delta.Index {
+				continue
+			}
+			if delta.ID != "" {
+				calls[i].ID = delta.ID
+			}
+			if delta.Function.Name != "" {
+				calls[i].Function.Name = delta.Function.Name
+			}
+			calls[i].Function.Arguments += delta.Function.Arguments
+			found = true
+			break
+		}
+
+		if !found {
+			calls = append(calls, delta)
+		}
+	}
+	return calls
+}
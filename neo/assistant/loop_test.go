@@ -0,0 +1,150 @@
+package assistant
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yaoapp/gou/process"
+	chatctx "github.com/yaoapp/yao/neo/context"
+	chatMessage "github.com/yaoapp/yao/neo/message"
+	api "github.com/yaoapp/yao/openai"
+)
+
+func TestAppendToolCalls(t *testing.T) {
+	calls := []api.ToolCall{}
+	deltas := [][]api.ToolCall{
+		{{Index: 0, ID: "call_1", Function: api.Function{Name: "weather", Arguments: `{"city":`}}},
+		{{Index: 1, ID: "call_2", Function: api.Function{Name: "time", Arguments: `{}`}}},
+		{{Index: 0, Function: api.Function{Arguments: `"Paris"}`}}},
+	}
+	for _, delta := range deltas {
+		calls = appendToolCalls(calls, delta)
+	}
+
+	assert.Equal(t, 2, len(calls))
+	assert.Equal(t, "call_1", calls[0].ID)
+	assert.Equal(t, `{"city":"Paris"}`, calls[0].Function.Arguments)
+	assert.Equal(t, "time", calls[1].Function.Name)
+}
+
+func TestBoundToolCalls(t *testing.T) {
+	ast := &Assistant{ID: "test", Tools: &ToolCalls{
+		Process: map[string]string{"weather": "scripts.weather.Get", "time": "utils.now.Timestamp"},
+		Loop:    &ToolLoop{MaxIterations: 2},
+	}}
+
+	native := []api.ToolCall{
+		{Index: 0, ID: "call_1", Function: api.Function{Name: "weather", Arguments: `{"city":"Paris"}`}},
+		{Index: 1, ID: "call_2", Function: api.Function{Name: "time"}},
+	}
+
+	calls := ast.boundToolCalls(chatctx.Context{}, native, nil)
+	assert.Equal(t, 2, len(calls))
+	assert.Equal(t, map[string]interface{}{"city": "Paris"}, calls[0].Arguments)
+	assert.Equal(t, map[string]interface{}{}, calls[1].Arguments)
+
+	// The maximum iterations reached
+	assert.Nil(t, ast.boundToolCalls(chatctx.Context{ToolTimes: 2}, native, nil))
+
+	// Unbound tool calls are handled by the Done hook
+	native = append(native, api.ToolCall{Index: 2, ID: "call_3", Function: api.Function{Name: "unknown"}})
+	assert.Nil(t, ast.boundToolCalls(chatctx.Context{}, native, nil))
+
+	// <tool> tags
+	contents := chatMessage.NewContents()
+	contents.Data = []chatMessage.Data{
+		{Type: "text", Props: map[string]interface{}{"text": "Let me check"}},
+		{Type: "tool", Props: map[string]interface{}{"text": "<tool>\n{\"function\":\"weather\",\"arguments\":{\"city\":\"Tokyo\"}}\n</tool>"}},
+		{Type: "tool", Props: map[string]interface{}{"text": "<tool>\n{\"function\":\"time\"}\n</tool>", "executed": true}},
+	}
+	calls = ast.boundToolCalls(chatctx.Context{}, nil, contents)
+	assert.Equal(t, 1, len(calls))
+	assert.Equal(t, "weather", calls[0].Function)
+	assert.Equal(t, "Tokyo", calls[0].Arguments["city"])
+}
+
+func TestExecuteToolContents(t *testing.T) {
+	contents := chatMessage.NewContents()
+	contents.Data = []chatMessage.Data{
+		{Type: "tool", Props: map[string]interface{}{"text": "<tool>\n{\"function\":\"time\"}\n</tool>", "executed": true, "result": "12:00"}},
+		{Type: "text", Props: map[string]interface{}{"text": "Let me check"}},
+		{Type: "tool", Props: map[string]interface{}{"text": "<tool>\n{\"function\":\"weather\"}\n</tool>"}},
+		{Type: "tool", Props: map[string]interface{}{"text": "<tool>\n{\"function\":\"stock\"}\n</tool>"}},
+	}
+
+	executeToolContents(contents, []ToolCall{{Function: "weather", Result: "Sunny"}, {Function: "stock", Error: "timeout"}})
+	assert.Equal(t, "12:00", contents.Data[0].Props["result"])
+	assert.Equal(t, "Sunny", contents.Data[2].Props["result"])
+	assert.Equal(t, true, contents.Data[2].Props["executed"])
+	assert.Equal(t, "timeout", contents.Data[3].Props["error"])
+	assert.Nil(t, contents.Data[3].Props["result"])
+
+	// The tool calls are matched by id
+	contents.Data = []chatMessage.Data{
+		{Type: "tool", Props: map[string]interface{}{"text": "<tool>\n{\"id\":\"call_2\",\"function\":\"weather\"}\n</tool>"}},
+		{Type: "tool", Props: map[string]interface{}{"text": "<tool>\n{\"id\":\"call_1\",\"function\":\"weather\"}\n</tool>"}},
+	}
+	executeToolContents(contents, []ToolCall{{ID: "call_1", Function: "weather", Result: "Sunny"}, {ID: "call_2", Function: "weather", Result: "Rainy"}})
+	assert.Equal(t, "Rainy", contents.Data[0].Props["result"])
+	assert.Equal(t, "Sunny", contents.Data[1].Props["result"])
+}
+
+func TestToolMessages(t *testing.T) {
+	messages, err := toolMessages([]ToolCall{
+		{ID: "call_1", Function: "weather", Arguments: map[string]interface{}{"city": "Paris"}, Result: "Sunny"},
+		{ID: "call_2", Function: "time", Error: "timeout"},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(messages))
+	assert.Equal(t, "assistant", messages[0].Role)
+	if assert.Equal(t, 2, len(messages[0].ToolCalls)) {
+		assert.Equal(t, "call_1", messages[0].ToolCalls[0].ID)
+		assert.Equal(t, "weather", messages[0].ToolCalls[0].Function.Name)
+		assert.Equal(t, `{"city":"Paris"}`, messages[0].ToolCalls[0].Function.Arguments)
+	}
+
+	assert.Equal(t, "tool", messages[1].Role)
+	assert.Equal(t, "call_1", messages[1].ToolCallID)
+	assert.Contains(t, messages[1].Text, `"result":"Sunny"`)
+	assert.Equal(t, "tool", messages[2].Role)
+	assert.Equal(t, "call_2", messages[2].ToolCallID)
+	assert.Contains(t, messages[2].Text, `"error":"timeout"`)
+}
+
+func TestToolLoop(t *testing.T) {
+	ast := &Assistant{}
+	assert.Equal(t, ToolLoop{MaxIterations: 5, Timeout: 30}, ast.toolLoop())
+
+	ast.Tools = &ToolCalls{Loop: &ToolLoop{Timeout: 5}}
+	assert.Equal(t, ToolLoop{MaxIterations: 5, Timeout: 5}, ast.toolLoop())
+}
+
+func TestCallToolTimeout(t *testing.T) {
+	release := make(chan bool)
+	defer close(release)
+
+	// The process does not honour the context
+	process.Register("unit.tool.Hang", func(p *process.Process) interface{} {
+		<-release
+		return "done"
+	})
+	process.Register("unit.tool.Echo", func(p *process.Process) interface{} {
+		return p.Args[0]
+	})
+
+	ast := &Assistant{Tools: &ToolCalls{Process: map[string]string{"hang": "unit.tool.Hang", "echo": "unit.tool.Echo"}}}
+	call := &ToolCall{Function: "hang", Arguments: map[string]interface{}{}}
+
+	start := time.Now()
+	ast.callTool(context.Background(), chatctx.Context{}, call, 100*time.Millisecond)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Contains(t, call.Error, "timeout")
+	assert.Nil(t, call.Result)
+
+	call = &ToolCall{Function: "echo", Arguments: map[string]interface{}{"q": "yao"}}
+	ast.callTool(context.Background(), chatctx.Context{}, call, time.Second)
+	assert.Empty(t, call.Error)
+	assert.Equal(t, map[string]interface{}{"q": "yao"}, call.Result)
+}
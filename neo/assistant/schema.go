@@ -0,0 +1,217 @@
+package assistant
+
+import (
+	"fmt"
+	"strings"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	chatMessage "github.com/yaoapp/yao/neo/message"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// schemaURL the URL of the response schema resource, the local references are resolved against it
+const schemaURL = "mem:///response.schema.json"
+
+// schemaPrinter the printer of the validation errors
+var schemaPrinter = message.NewPrinter(language.English)
+
+// schemaRetryMark the heading of the errors in the retry prompt
+const schemaRetryMark = "## Response Schema Errors"
+
+// SchemaError the response does not match the response schema
+type SchemaError struct {
+	Output string   // The raw output of the model
+	Errors []string // The validation errors
+}
+
+// Error returns the error message
+func (err *SchemaError) Error() string {
+	return fmt.Sprintf("the response does not match the schema: %s", strings.Join(err.Errors, "; "))
+}
+
+// retries returns the maximum retries of the response schema
+func (schema *ResponseSchema) retries() int {
+	if schema.Retries > 0 {
+		return schema.Retries
+	}
+	return 3
+}
+
+// responseFormat returns the json_schema response_format option of the connector
+func (schema *ResponseSchema) responseFormat() map[string]interface{} {
+	format := map[string]interface{}{
+		"name":   schema.Name,
+		"schema": schema.Schema,
+		"strict": schema.Strict,
+	}
+	if schema.Description != "" {
+		format["description"] = schema.Description
+	}
+	return map[string]interface{}{"type": "json_schema", "json_schema": format}
+}
+
+// prompts returns the system prompts of the response schema for the connectors without response_format
+func (schema *ResponseSchema) prompts() []chatMessage.Message {
+	raw, _ := jsoniter.MarshalToString(schema.Schema)
+	content := "## Response Schema\n" +
+		"Reply with a single JSON value that matches the JSON schema below.\n" +
+		"Do not add any explanation, comment or markdown code fence.\n"
+	if schema.Description != "" {
+		content += fmt.Sprintf("Description: %s\n", schema.Description)
+	}
+	content += raw
+
+	return []chatMessage.Message{
+		*chatMessage.New().Map(map[string]interface{}{"role": "system", "name": "RESPONSE_SCHEMA", "text": content}),
+	}
+}
+
+// Parse parses the output of the model, repairs the malformed JSON and validates it against the schema
+func (schema *ResponseSchema) Parse(output string) (interface{}, error) {
+	text := schemaText(output)
+	if text == "" {
+		return nil, &SchemaError{Output: output, Errors: []string{"the response is empty"}}
+	}
+
+	var value interface{}
+	if err := ParseJSON(text, &value); err != nil {
+		return nil, &SchemaError{Output: output, Errors: []string{fmt.Sprintf("the response is not a valid JSON, %s", err.Error())}}
+	}
+
+	compiled := schema.compiled
+	if compiled == nil {
+		var err error
+		compiled, err = compileSchema(schema.Schema)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := compiled.Validate(value)
+	if err == nil {
+		return value, nil
+	}
+
+	verr, ok := err.(*jsonschema.ValidationError)
+	if !ok {
+		return nil, &SchemaError{Output: output, Errors: []string{err.Error()}}
+	}
+	return nil, &SchemaError{Output: output, Errors: schemaErrors(verr)}
+}
+
+// Compile compiles the JSON schema, the compiled schema is used to validate the responses
+func (schema *ResponseSchema) Compile() error {
+	compiled, err := compileSchema(schema.Schema)
+	if err != nil {
+		return err
+	}
+	schema.compiled = compiled
+	return nil
+}
+
+// output returns the text output of the contents
+func (schema *ResponseSchema) output(contents *chatMessage.Contents) string {
+	text := []string{}
+	for _, data := range contents.Data {
+		if data.Type == "text" {
+			text = append(text, string(data.Bytes))
+		}
+	}
+	return strings.Join(text, "")
+}
+
+// data replaces the text contents with the structured output, and returns the message of it
+func (schema *ResponseSchema) data(contents *chatMessage.Contents, value interface{}) *chatMessage.Message {
+	data := []chatMessage.Data{}
+	for _, item := range contents.Data {
+		if item.Type == "text" {
+			continue
+		}
+		data = append(data, item)
+	}
+	contents.Data = data
+	contents.Current = len(data) - 1
+
+	props := map[string]interface{}{"name": schema.Name, "data": value}
+	contents.NewType("json", props)
+	return chatMessage.New().Map(map[string]interface{}{"type": "json", "props": props, "new": true})
+}
+
+// Prompt returns the retry prompt asking the model to fix the response, it replaces the last user message
+// The request of the user is kept, and the errors of the previous retry are replaced.
+func (err *SchemaError) Prompt(messages []chatMessage.Message) string {
+	request := ""
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" {
+			request = messages[i].Text
+			break
+		}
+	}
+
+	if index := strings.Index(request, schemaRetryMark); index >= 0 {
+		request = strings.TrimSpace(request[:index])
+	}
+
+	prompt := request + "\n\n" + schemaRetryMark + "\n"
+	if strings.TrimSpace(err.Output) != "" {
+		prompt += "The previous response does not match the response schema:\n" + err.Output + "\n"
+	}
+	prompt += "Errors:\n  - " + strings.Join(err.Errors, "\n  - ") + "\n" +
+		"Reply again with a single JSON value that matches the schema, without any other text."
+	return prompt
+}
+
+// schemaText returns the JSON text of the output, the markdown code fence and the surrounding text are removed
+func schemaText(output string) string {
+	text := strings.TrimSpace(output)
+	if start := strings.Index(text, "```"); start >= 0 {
+		body := text[start+3:]
+		if nl := strings.Index(body, "\n"); nl >= 0 {
+			body = body[nl+1:]
+		}
+		if end := strings.Index(body, "```"); end >= 0 {
+			body = body[:end]
+		}
+		text = strings.TrimSpace(body)
+	}
+
+	start := strings.IndexAny(text, "{[")
+	if start > 0 {
+		text = text[start:]
+	}
+
+	if end := strings.LastIndexAny(text, "}]"); end >= 0 && end < len(text)-1 {
+		text = text[:end+1]
+	}
+	return text
+}
+
+// compileSchema compiles the JSON schema, the draft 2020-12 is used if the $schema is not set
+func compileSchema(schema map[string]interface{}) (*jsonschema.Schema, error) {
+	compiler := jsonschema.NewCompiler()
+	if err := compiler.AddResource(schemaURL, schema); err != nil {
+		return nil, fmt.Errorf("the response schema is invalid, %s", err.Error())
+	}
+
+	compiled, err := compiler.Compile(schemaURL)
+	if err != nil {
+		return nil, fmt.Errorf("the response schema is invalid, %s", err.Error())
+	}
+	return compiled, nil
+}
+
+// schemaErrors returns the leaf errors of the validation error, e.g. $.age: got number, want integer
+func schemaErrors(err *jsonschema.ValidationError) []string {
+	if len(err.Causes) == 0 {
+		path := strings.Join(append([]string{"$"}, err.InstanceLocation...), ".")
+		return []string{fmt.Sprintf("%s: %s", path, err.ErrorKind.LocalizedString(schemaPrinter))}
+	}
+
+	errs := []string{}
+	for _, cause := range err.Causes {
+		errs = append(errs, schemaErrors(cause)...)
+	}
+	return errs
+}
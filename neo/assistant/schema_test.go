@@ -0,0 +1,166 @@
+package assistant
+
+import (
+	"strings"
+	"testing"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/assert"
+	chatMessage "github.com/yaoapp/yao/neo/message"
+)
+
+func TestSchemaParse(t *testing.T) {
+	schema := prepareSchema(t)
+
+	// Markdown code fence and surrounding text
+	data, err := schema.Parse("Here is the form:\n```json\n{\"name\": \"Alice\", \"age\": 30, \"tags\": [\"a\"]}\n```\nDone.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "Alice", data.(map[string]interface{})["name"])
+
+	// Malformed JSON is repaired
+	data, err = schema.Parse(`{"name": "Bob", "age": 20, "tags": ["a", "b"],}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, float64(20), data.(map[string]interface{})["age"])
+
+	// Schema errors
+	_, err = schema.Parse(`{"name": "", "age": 1.5, "role": "root", "extra": true, "tags": ["a", "a"]}`)
+	assert.IsType(t, &SchemaError{}, err)
+	errs := err.(*SchemaError).Errors
+	assert.Contains(t, errs, "$.age: got number, want integer")
+	assert.Contains(t, errs, "$: additional properties 'extra' not allowed")
+	assert.Contains(t, errs, "$.name: minLength: got 0, want 1")
+	assert.Contains(t, errs, "$.role: value must be one of 'admin', 'user'")
+	assert.Contains(t, errs, "$.tags: items at 0 and 1 are equal")
+
+	_, err = schema.Parse(`{"age": 30}`)
+	assert.Contains(t, err.(*SchemaError).Errors, "$: missing property 'name'")
+
+	_, err = schema.Parse("I can not fill the form")
+	assert.IsType(t, &SchemaError{}, err)
+}
+
+func TestSchemaValidate(t *testing.T) {
+	var root map[string]interface{}
+	err := jsoniter.UnmarshalFromString(`{
+		"type": "object",
+		"$defs": {"point": {"type": "object", "properties": {"x": {"type": "number"}}, "required": ["x"]}},
+		"properties": {
+			"start": {"$ref": "#/$defs/point"},
+			"value": {"anyOf": [{"type": "string"}, {"type": "null"}]},
+			"kind": {"oneOf": [{"const": "a"}, {"const": "b"}]},
+			"score": {"type": ["number", "null"], "minimum": 0, "exclusiveMaximum": 100},
+			"tags": {"type": "array", "items": {"type": "string"}}
+		}
+	}`, &root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	schema := &ResponseSchema{Name: "test", Schema: root}
+	err = schema.Compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errors := func(raw string) []string {
+		_, err := schema.Parse(raw)
+		if err == nil {
+			return nil
+		}
+		return err.(*SchemaError).Errors
+	}
+
+	assert.Empty(t, errors(`{"start": {"x": 1}, "value": null, "kind": "a", "score": 99.5}`))
+	assert.Empty(t, errors(`{"score": null}`))
+	assert.Equal(t, []string{"$.start: missing property 'x'"}, errors(`{"start": {}}`))
+	assert.Equal(t, []string{"$.value: got number, want string", "$.value: got number, want null"}, errors(`{"value": 1}`))
+	assert.Equal(t, []string{"$.kind: value must be 'a'", "$.kind: value must be 'b'"}, errors(`{"kind": "c"}`))
+	assert.Equal(t, []string{"$.score: exclusiveMaximum: got 100, want 100"}, errors(`{"score": 100}`))
+	assert.Equal(t, []string{"$.tags.1: got number, want string"}, errors(`{"tags": ["a", 1]}`))
+	assert.Equal(t, []string{"$: got array, want object"}, errors(`[]`))
+
+	// The invalid schema
+	err = (&ResponseSchema{Name: "test", Schema: map[string]interface{}{"type": "unknown"}}).Compile()
+	assert.Contains(t, err.Error(), "the response schema is invalid")
+}
+
+func TestSchemaData(t *testing.T) {
+	schema := prepareSchema(t)
+	contents := chatMessage.NewContents()
+	contents.NewType("think", map[string]interface{}{"text": "thinking"})
+	contents.NewText([]byte(`{"name": `))
+	contents.AppendText([]byte(`"Alice"}`))
+
+	assert.Equal(t, `{"name": "Alice"}`, schema.output(contents))
+
+	msg := schema.data(contents, map[string]interface{}{"name": "Alice"})
+	assert.Equal(t, "json", msg.Type)
+	assert.Equal(t, 2, len(contents.Data))
+	assert.Equal(t, 1, contents.Current)
+	assert.Equal(t, "json", contents.Data[1].Type)
+	assert.Equal(t, "form", contents.Data[1].Props["name"])
+	assert.Equal(t, map[string]interface{}{"name": "Alice"}, contents.Data[1].Props["data"])
+}
+
+func TestSchemaPrompt(t *testing.T) {
+	messages := []chatMessage.Message{
+		{Role: "system", Text: "You are a form filler"},
+		{Role: "user", Text: "Fill the form for Alice"},
+	}
+
+	prompt := (&SchemaError{Output: `{"age": 1}`, Errors: []string{"$: missing property 'name'"}}).Prompt(messages)
+	assert.True(t, strings.HasPrefix(prompt, "Fill the form for Alice\n\n"+schemaRetryMark))
+	assert.Contains(t, prompt, `{"age": 1}`)
+	assert.Contains(t, prompt, "$: missing property 'name'")
+
+	// The errors of the previous retry are replaced
+	messages[1].Text = prompt
+	prompt = (&SchemaError{Output: "", Errors: []string{"the response is empty"}}).Prompt(messages)
+	assert.Equal(t, 1, strings.Count(prompt, schemaRetryMark))
+	assert.Contains(t, prompt, "the response is empty")
+	assert.NotContains(t, prompt, "missing property")
+	assert.True(t, strings.HasPrefix(prompt, "Fill the form for Alice\n\n"))
+}
+
+func TestSchemaOptions(t *testing.T) {
+	defer SetConnectorSettings(map[string]ConnectorSetting{})
+
+	ast := &Assistant{ID: "test", Connector: "gpt-4o", Schema: prepareSchema(t)}
+	SetConnectorSettings(map[string]ConnectorSetting{"gpt-4o": {ResponseFormat: true}})
+	options := ast.withOptions(nil)
+	format := options["response_format"].(map[string]interface{})
+	assert.Equal(t, "json_schema", format["type"])
+	assert.Equal(t, "form", format["json_schema"].(map[string]interface{})["name"])
+	assert.Empty(t, ast.withPrompts([]chatMessage.Message{}))
+
+	SetConnectorSettings(map[string]ConnectorSetting{})
+	options = ast.withOptions(nil)
+	assert.Nil(t, options["response_format"])
+	prompts := ast.withPrompts([]chatMessage.Message{})
+	assert.Equal(t, 1, len(prompts))
+	assert.Equal(t, "RESPONSE_SCHEMA", prompts[0].Name)
+	assert.Contains(t, prompts[0].Text, `"required":["name"]`)
+}
+
+func prepareSchema(t *testing.T) *ResponseSchema {
+	var schema map[string]interface{}
+	err := jsoniter.UnmarshalFromString(`{
+		"type": "object",
+		"properties": {
+			"name": {"type": "string", "minLength": 1},
+			"age": {"type": "integer", "minimum": 0},
+			"role": {"type": "string", "enum": ["admin", "user"]},
+			"tags": {"type": "array", "items": {"type": "string"}, "uniqueItems": true}
+		},
+		"required": ["name"],
+		"additionalProperties": false
+	}`, &schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &ResponseSchema{Name: "form", Schema: schema}
+}
@@ -6,6 +6,7 @@ import (
 	"mime/multipart"
 
 	"github.com/gin-gonic/gin"
+	"github.com/santhosh-tekuri/jsonschema/v6"
 	"github.com/yaoapp/gou/rag/driver"
 	v8 "github.com/yaoapp/gou/runtime/v8"
 	chatctx "github.com/yaoapp/yao/neo/context"
@@ -122,6 +123,7 @@ type Assistant struct {
 	Options     map[string]interface{}   `json:"options,omitempty"`     // AI Options
 	Prompts     []Prompt                 `json:"prompts,omitempty"`     // AI Prompts
 	Tools       *ToolCalls               `json:"tools,omitempty"`       // Assistant Tools
+	Schema      *ResponseSchema          `json:"schema,omitempty"`      // Assistant Response Schema
 	Flows       []map[string]interface{} `json:"flows,omitempty"`       // Assistant Flows
 	Placeholder *Placeholder             `json:"placeholder,omitempty"` // Assistant Placeholder
 	Script      *v8.Script               `json:"-" yaml:"-"`            // Assistant Script
@@ -135,14 +137,42 @@ type Assistant struct {
 
 // ToolCalls the tool calls
 type ToolCalls struct {
-	Tools   []Tool   `json:"tools,omitempty"`
-	Prompts []Prompt `json:"prompts,omitempty"`
+	Tools   []Tool            `json:"tools,omitempty"`
+	Prompts []Prompt          `json:"prompts,omitempty"`
+	Process map[string]string `json:"process,omitempty"` // The Yao process bindings, function name => process name
+	Loop    *ToolLoop         `json:"loop,omitempty"`    // The tool loop limits
+}
+
+// ToolLoop the tool loop limits
+type ToolLoop struct {
+	MaxIterations int `json:"max_iterations,omitempty"` // The maximum iterations of the loop, default is 5
+	Timeout       int `json:"timeout,omitempty"`        // The timeout of each tool call in seconds, default is 30
+}
+
+// ToolCall a tool call of the assistant response
+type ToolCall struct {
+	ID        string                 `json:"id,omitempty"`
+	Function  string                 `json:"function"`
+	Arguments map[string]interface{} `json:"arguments,omitempty"`
+	Result    interface{}            `json:"result,omitempty"`
+	Error     string                 `json:"error,omitempty"`
+}
+
+// ResponseSchema the JSON schema of the assistant response (structured output)
+type ResponseSchema struct {
+	Name        string                 `json:"name"`                  // The name of the schema
+	Description string                 `json:"description,omitempty"` // The description of the schema
+	Schema      map[string]interface{} `json:"schema"`                // The JSON schema of the response
+	Strict      bool                   `json:"strict,omitempty"`      // Enable the strict mode of the connector response_format
+	Retries     int                    `json:"retries,omitempty"`     // The maximum retries when the response is invalid, default is 3
+	compiled    *jsonschema.Schema     `json:"-"`                     // The compiled JSON schema
 }
 
 // ConnectorSetting the connector setting
 type ConnectorSetting struct {
-	Vision bool `json:"vision,omitempty" yaml:"vision,omitempty"`
-	Tools  bool `json:"tools,omitempty" yaml:"tools,omitempty"`
+	Vision         bool `json:"vision,omitempty" yaml:"vision,omitempty"`
+	Tools          bool `json:"tools,omitempty" yaml:"tools,omitempty"`
+	ResponseFormat bool `json:"response_format,omitempty" yaml:"response_format,omitempty"` // Supports the json_schema response_format
 }
 
 // Placeholder the assistant placeholder
@@ -159,12 +189,22 @@ var VisionCapableModels = map[string]bool{
 	"gpt-4v":               true, // Alias for gpt-4-vision-preview
 
 	// Anthropic Models
-	"claude-3-opus":   true, // Most capable Claude model
-	"claude-3-sonnet": true, // Balanced Claude model
-	"claude-3-haiku":  true, // Fast and efficient Claude model
+	"claude-3-opus":            true, // Most capable Claude model
+	"claude-3-sonnet":          true, // Balanced Claude model
+	"claude-3-haiku":           true, // Fast and efficient Claude model
+	"claude-3-5-sonnet-latest": true, // Claude 3.5 Sonnet, native Anthropic connector
+	"claude-3-5-haiku-latest":  true, // Claude 3.5 Haiku, native Anthropic connector
+	"claude-3-7-sonnet-latest": true, // Claude 3.7 Sonnet, native Anthropic connector
+	"claude-sonnet-4-0":        true, // Claude Sonnet 4, native Anthropic connector
+	"claude-opus-4-0":          true, // Claude Opus 4, native Anthropic connector
 
 	// Google Models
 	"gemini-pro-vision": true,
+	"gemini-1.5-pro":    true, // Gemini 1.5 Pro, native Gemini connector
+	"gemini-1.5-flash":  true, // Gemini 1.5 Flash, native Gemini connector
+	"gemini-2.0-flash":  true, // Gemini 2.0 Flash, native Gemini connector
+	"gemini-2.5-pro":    true, // Gemini 2.5 Pro, native Gemini connector
+	"gemini-2.5-flash":  true, // Gemini 2.5 Flash, native Gemini connector
 
 	// Open Source Models
 	"llava-13b": true,
@@ -195,3 +235,29 @@ type FileResponse struct {
 	ContentType string
 	Extension   string
 }
+
+// History strategies
+const (
+	// HistoryFull send the full history to the connector (default)
+	HistoryFull = "full"
+	// HistoryWindow keep the latest messages that fit the token budget
+	HistoryWindow = "window"
+	// HistorySummary summarize the trimmed messages and persist the summary
+	HistorySummary = "summary"
+)
+
+// HistorySetting the conversation history strategy
+type HistorySetting struct {
+	Strategy  string          `json:"strategy,omitempty" yaml:"strategy,omitempty"`     // full, window or summary, default is full
+	MaxTokens int             `json:"max_tokens,omitempty" yaml:"max_tokens,omitempty"` // The token budget of the request, default is the context window of the model
+	Reserved  int             `json:"reserved,omitempty" yaml:"reserved,omitempty"`     // The tokens reserved for the completion, default is 1/4 of the budget
+	PinSystem *bool           `json:"pin_system,omitempty" yaml:"pin_system,omitempty"` // Whether to keep the system messages of the history, default is true
+	Summary   *SummarySetting `json:"summary,omitempty" yaml:"summary,omitempty"`       // The summary setting
+}
+
+// SummarySetting the rolling summary setting
+type SummarySetting struct {
+	Connector string `json:"connector,omitempty" yaml:"connector,omitempty"`   // The connector for the summary, default is the assistant connector
+	Prompt    string `json:"prompt,omitempty" yaml:"prompt,omitempty"`         // The summary prompt
+	MaxTokens int    `json:"max_tokens,omitempty" yaml:"max_tokens,omitempty"` // The max tokens of the summary
+}
@@ -14,6 +14,7 @@ type Context struct {
 	context.Context
 	Sid         string                 `json:"sid" yaml:"-"`           // Session ID
 	ChatID      string                 `json:"chat_id,omitempty"`      // Chat ID, use to select chat
+	ParentID    string                 `json:"parent_id,omitempty"`    // Parent message ID, use to branch the chat (edit or regenerate)
 	AssistantID string                 `json:"assistant_id,omitempty"` // Assistant ID, use to select assistant
 	Stack       string                 `json:"stack,omitempty"`        // will be removed in the future
 	Path        string                 `json:"pathname,omitempty"`     // wiil be rename to path
@@ -25,6 +26,7 @@ type Context struct {
 	Silent      bool                   `json:"silent,omitempty"`      // Silent mode
 	Retry       bool                   `json:"retry,omitempty"`       // Retry mode
 	RetryTimes  uint8                  `json:"retry_times,omitempty"` // Retry times
+	ToolTimes   uint8                  `json:"tool_times,omitempty"`  // Tool loop iterations
 	Upload      *FileUpload            `json:"upload,omitempty"`
 	Version     bool                   `json:"version,omitempty"` // Version support
 	RAG         bool                   `json:"rag,omitempty"`     // RAG support
@@ -115,6 +117,9 @@ func (ctx *Context) Map() map[string]interface{} {
 	if ctx.ChatID != "" {
 		data["chat_id"] = ctx.ChatID
 	}
+	if ctx.ParentID != "" {
+		data["parent_id"] = ctx.ParentID
+	}
 	if ctx.AssistantID != "" {
 		data["assistant_id"] = ctx.AssistantID
 	}
@@ -105,12 +105,12 @@ func initStore() error {
 		return err
 
 	} else if conn.Is(connector.REDIS) {
-		Neo.Store = store.NewRedis()
-		return nil
+		Neo.Store, err = store.NewRedis(Neo.StoreSetting)
+		return err
 
 	} else if conn.Is(connector.MONGO) {
-		Neo.Store = store.NewMongo()
-		return nil
+		Neo.Store, err = store.NewMongo(Neo.StoreSetting)
+		return err
 	}
 
 	return fmt.Errorf("%s store connector %s not support", Neo.ID, Neo.StoreSetting.Connector)
@@ -167,6 +167,9 @@ func initAssistant() error {
 	// Default Connector
 	assistant.SetConnector(Neo.Connector)
 
+	// History Strategy
+	assistant.SetHistory(Neo.History)
+
 	// Load Built-in Assistants
 	err := assistant.LoadBuiltIn()
 	if err != nil {
@@ -44,6 +44,8 @@ type Message struct {
 	IsTool          bool                   `json:"-"`                          // is tool for the message for native tool_calls
 	IsBeginTool     bool                   `json:"-"`                          // is new tool for the message for native tool_calls
 	IsEndTool       bool                   `json:"-"`                          // is end tool for the message for native tool_calls
+	ToolCalls       []openai.ToolCall      `json:"-"`                          // the tool_calls deltas for native tool_calls
+	ToolCallID      string                 `json:"-"`                          // the tool call id of the tool result message (role = tool)
 	Result          any                    `json:"result,omitempty"`           // result for the message
 	Begin           int64                  `json:"begin,omitempty"`            // begin at for the message // timestamp
 	End             int64                  `json:"end,omitempty"`              // end at for the message // timestamp
@@ -233,6 +235,7 @@ func NewOpenAI(data []byte, isThinking bool) *Message {
 		// Tool calls
 		if len(chunk.Choices[0].Delta.ToolCalls) > 0 || chunk.Choices[0].FinishReason == "tool_calls" {
 			msg.Type = "tool_calls_native"
+			msg.ToolCalls = chunk.Choices[0].Delta.ToolCalls
 			text := ""
 			if len(chunk.Choices[0].Delta.ToolCalls) > 0 {
 				id := chunk.Choices[0].Delta.ToolCalls[0].ID
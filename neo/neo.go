@@ -10,6 +10,7 @@ import (
 	"github.com/yaoapp/yao/neo/assistant"
 	chatctx "github.com/yaoapp/yao/neo/context"
 	"github.com/yaoapp/yao/neo/message"
+	"github.com/yaoapp/yao/neo/transcript"
 )
 
 // Answer reply the message
@@ -336,3 +337,24 @@ func (neo *DSL) Download(ctx chatctx.Context, c *gin.Context) (*assistant.FileRe
 	// Download file using the assistant
 	return ast.Download(ctx.Context, fileID)
 }
+
+// ExportChat exports the chat as a transcript in the format (md, json, html)
+func (neo *DSL) ExportChat(sid string, chatID string, format string) ([]byte, error) {
+	if neo.Store == nil {
+		return nil, fmt.Errorf("neo store is not initialized")
+	}
+
+	t, err := transcript.Export(neo.Store, sid, chatID)
+	if err != nil {
+		return nil, err
+	}
+	return t.Render(format)
+}
+
+// ImportChat imports the transcript as a new chat, returns the chat id
+func (neo *DSL) ImportChat(sid string, t *transcript.Transcript, chatID string) (string, error) {
+	if neo.Store == nil {
+		return "", fmt.Errorf("neo store is not initialized")
+	}
+	return transcript.Import(neo.Store, sid, t, chatID)
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/yaoapp/kun/exception"
 	"github.com/yaoapp/yao/neo/message"
 	"github.com/yaoapp/yao/neo/store"
+	"github.com/yaoapp/yao/neo/transcript"
 )
 
 // GetNeo returns the Neo instance
@@ -32,6 +33,8 @@ func init() {
 		"assistant.search": processAssistantSearch,
 		"assistant.find":   processAssistantFind,
 		"assistant.match":  processAssistantMatch, // Match assistant by content and params
+		"chat.export":      processChatExport,
+		"chat.import":      processChatImport,
 	})
 }
 
@@ -430,3 +433,83 @@ func processAssistantFind(process *process.Process) interface{} {
 
 	return res.Data[0]
 }
+
+// processChatExport process the chat export request
+// Args: chat_id, format (md, json, html, default json)
+// Returns the transcript object for json, the text for md and html
+func processChatExport(process *process.Process) interface{} {
+	process.ValidateArgNums(1)
+	chatID := process.ArgsString(0)
+	format := transcript.FormatJSON
+	if process.NumOfArgs() > 1 {
+		format = process.ArgsString(1)
+	}
+
+	if process.Sid == "" {
+		exception.New("sid is required", 400).Throw()
+	}
+
+	neo := GetNeo()
+	if neo.Store == nil {
+		exception.New("Neo store is not initialized", 500).Throw()
+	}
+
+	data, err := neo.ExportChat(process.Sid, chatID, format)
+	if err != nil {
+		exception.New("Failed to export chat: %s", 500, err.Error()).Throw()
+	}
+
+	if _, ext := transcript.ContentType(format); ext != transcript.FormatJSON {
+		return string(data)
+	}
+
+	var res map[string]interface{}
+	err = json.Unmarshal(data, &res)
+	if err != nil {
+		exception.New("Failed to export chat: %s", 500, err.Error()).Throw()
+	}
+	return res
+}
+
+// processChatImport process the chat import request
+// Args: transcript (the exported JSON object or string, the md and html transcripts can not be imported), chat_id (optional)
+// Returns the chat id
+func processChatImport(process *process.Process) interface{} {
+	process.ValidateArgNums(1)
+	chatID := ""
+	if process.NumOfArgs() > 1 {
+		chatID = process.ArgsString(1)
+	}
+
+	if process.Sid == "" {
+		exception.New("sid is required", 400).Throw()
+	}
+
+	neo := GetNeo()
+	if neo.Store == nil {
+		exception.New("Neo store is not initialized", 500).Throw()
+	}
+
+	var data []byte
+	switch v := process.Args[0].(type) {
+	case string:
+		data = []byte(v)
+	default:
+		raw, err := json.Marshal(v)
+		if err != nil {
+			exception.New("Invalid transcript: %s", 400, err.Error()).Throw()
+		}
+		data = raw
+	}
+
+	t, err := transcript.Parse(data)
+	if err != nil {
+		exception.New("Invalid transcript: %s", 400, err.Error()).Throw()
+	}
+
+	id, err := neo.ImportChat(process.Sid, t, chatID)
+	if err != nil {
+		exception.New("Failed to import chat: %s", 500, err.Error()).Throw()
+	}
+	return id
+}
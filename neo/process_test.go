@@ -511,3 +511,56 @@ func TestProcessAssistantValidation(t *testing.T) {
 	}
 	assert.Equal(t, int64(0), total)
 }
+
+func TestProcessChatExportImport(t *testing.T) {
+	prepare(t)
+	defer test.Clean()
+
+	sid := "sid-process-export"
+	neo := GetNeo()
+	err := neo.Store.SaveHistory(sid, []map[string]interface{}{
+		{"role": "user", "content": "Hello", "name": sid},
+		{"role": "assistant", "content": `[{"type":"text","text":"Hi there"}]`, "name": "neo"},
+	}, "chat-process-export", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer neo.Store.DeleteAllChats(sid)
+
+	p, err := process.Of("neo.chat.export", "chat-process-export")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := p.WithSID(sid).Exec()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exported, ok := output.(map[string]interface{})
+	assert.True(t, ok)
+	assert.Equal(t, "chat-process-export", exported["chat_id"])
+	assert.Equal(t, 2, len(exported["history"].([]interface{})))
+
+	p, err = process.Of("neo.chat.import", exported, "chat-process-import")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output, err = p.WithSID(sid).Exec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "chat-process-import", output)
+
+	p, err = process.Of("neo.chat.export", "chat-process-import", "md")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output, err = p.WithSID(sid).Exec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Contains(t, output, "Hi there")
+}
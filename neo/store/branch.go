@@ -0,0 +1,334 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/google/uuid"
+)
+
+// BranchRoot the parent id to start a new branch from the beginning of the chat
+const BranchRoot = "root"
+
+// ErrNotFound the chat or the message is not found
+var ErrNotFound = errors.New("not found")
+
+// branchPreviewSize the maximum runes of the branch preview
+const branchPreviewSize = 100
+
+// chainHistory assign the message id and the parent id to the history records
+// The first message is the child of context.parent_id if given (BranchRoot starts from the beginning),
+// otherwise the child of the head message. Returns the new head, the silent messages do not move the head.
+func chainHistory(values []map[string]interface{}, context map[string]interface{}, head string, silent bool) string {
+	parent := head
+	if context != nil {
+		if id, ok := context["parent_id"].(string); ok && id != "" {
+			parent = id
+		}
+	}
+	if parent == BranchRoot {
+		parent = ""
+	}
+
+	// The given ids are kept (import), BranchRoot starts from the beginning
+	for _, value := range values {
+		id := toString(value["message_id"])
+		if id == "" {
+			id = uuid.New().String()
+		}
+		value["message_id"] = id
+
+		switch given := toString(value["parent_id"]); given {
+		case "":
+			value["parent_id"] = nil
+			if parent != "" {
+				value["parent_id"] = parent
+			}
+		case BranchRoot:
+			value["parent_id"] = nil
+		}
+		parent = id
+	}
+
+	if silent || len(values) == 0 {
+		return head
+	}
+	return parent
+}
+
+// selectHistory select the branch of the history (oldest first), then apply the filter
+// The branch ends at filter.Branch if given, otherwise at the head message.
+func selectHistory(history []map[string]interface{}, head string, filter ChatFilter, maxSize int) ([]map[string]interface{}, error) {
+	if filter.Branch != "" {
+		if filter.Branch != BranchRoot && findMessage(history, filter.Branch) == nil {
+			return nil, fmt.Errorf("message %s %w", filter.Branch, ErrNotFound)
+		}
+		head = filter.Branch
+	}
+	return filterHistory(BranchHistory(history, head), filter, maxSize), nil
+}
+
+// BranchHistory returns the messages on the branch ending at the head message (oldest first)
+// The messages saved without message_id are always on the branch,
+// the silent messages are on the branch when their parent is on the branch.
+func BranchHistory(history []map[string]interface{}, head string) []map[string]interface{} {
+	messages := map[string]map[string]interface{}{}
+	for _, message := range history {
+		if id := toString(message["message_id"]); id != "" {
+			messages[id] = message
+		}
+	}
+
+	if len(messages) == 0 {
+		return history
+	}
+
+	included := map[string]bool{"": true}
+	id := branchHead(history, messages, head)
+	for id != "" && !included[id] {
+		message, has := messages[id]
+		if !has {
+			break
+		}
+		included[id] = true
+		id = toString(message["parent_id"])
+	}
+
+	res := []map[string]interface{}{}
+	for _, message := range history {
+		id := toString(message["message_id"])
+		switch {
+		case id == "" || included[id]:
+			res = append(res, message)
+
+		case toBool(message["silent"]) && included[toString(message["parent_id"])]:
+			included[id] = true
+			res = append(res, message)
+		}
+	}
+	return res
+}
+
+// branchHead returns the head message id of the history
+// Falls back to the latest message if the head is not given or has been removed (TTL, MaxSize)
+func branchHead(history []map[string]interface{}, messages map[string]map[string]interface{}, head string) string {
+	if head == BranchRoot {
+		return ""
+	}
+
+	if _, has := messages[head]; has {
+		return head
+	}
+
+	for i := len(history) - 1; i >= 0; i-- {
+		if id := toString(history[i]["message_id"]); id != "" && !toBool(history[i]["silent"]) {
+			return id
+		}
+	}
+	return ""
+}
+
+// historyBranches returns the branches of the history, the oldest first
+func historyBranches(history []map[string]interface{}, head string) []Branch {
+	branches, previews := branchTree(history, head)
+	for i, message := range previews {
+		branches[i].Preview = branchPreview(message)
+	}
+	return branches
+}
+
+// branchTree returns the branches of the history (oldest first) and the preview message of each branch (nil if no user message).
+// The children are always saved after the parent, so the sizes and the previews are counted in one pass.
+func branchTree(history []map[string]interface{}, head string) ([]Branch, []map[string]interface{}) {
+	messages := map[string]map[string]interface{}{}
+	parents := map[string]bool{}
+
+	// The messages saved without message_id are on all the branches
+	base := 0
+	var basePreview map[string]interface{}
+	for _, message := range history {
+		if toBool(message["silent"]) {
+			continue
+		}
+
+		id := toString(message["message_id"])
+		if id == "" {
+			base++
+			if message["role"] == "user" {
+				basePreview = message
+			}
+			continue
+		}
+		messages[id] = message
+		parents[toString(message["parent_id"])] = true
+	}
+
+	sizes := map[string]int{}
+	previews := map[string]map[string]interface{}{}
+	head = branchHead(history, messages, head)
+	branches := []Branch{}
+	leaves := []map[string]interface{}{}
+	for _, message := range history {
+		id := toString(message["message_id"])
+		if _, has := messages[id]; !has {
+			continue
+		}
+
+		parent := toString(message["parent_id"])
+		size, preview := base, basePreview
+		if _, has := sizes[parent]; has {
+			size, preview = sizes[parent], previews[parent]
+		}
+		if message["role"] == "user" {
+			preview = message
+		}
+		sizes[id], previews[id] = size+1, preview
+
+		if !parents[id] {
+			branches = append(branches, Branch{ID: id, Active: id == head, CreatedAt: message["created_at"], Size: size + 1})
+			leaves = append(leaves, preview)
+		}
+	}
+	return branches, leaves
+}
+
+// branchPreview the preview of the branch, the content of the message is truncated
+func branchPreview(message map[string]interface{}) string {
+	if message == nil {
+		return ""
+	}
+
+	preview := toString(message["content"])
+	if runes := []rune(preview); len(runes) > branchPreviewSize {
+		return string(runes[:branchPreviewSize]) + "..."
+	}
+	return preview
+}
+
+// branchWalker collects the messages on the branch ending at the head message, the messages are added newest first.
+// The messages saved without message_id are always on the branch, the silent messages are on the branch when their parent is on the branch.
+type branchWalker struct {
+	next     string                  // the next message on the branch, the parent of the last collected message
+	latest   bool                    // the head is not given, the latest message is the head
+	included map[string]bool         // the collected message ids
+	pending  map[string][]branchItem // the silent messages waiting for their parent (by parent id)
+	items    []branchItem            // the collected messages
+	seq      int                     // the sequence of the added messages
+}
+
+type branchItem struct {
+	seq     int
+	message map[string]interface{}
+}
+
+// newBranchWalker create a walker from the head message. BranchRoot collects the messages without message_id only,
+// an empty head starts from the latest message.
+func newBranchWalker(head string) *branchWalker {
+	walker := &branchWalker{
+		included: map[string]bool{"": true},
+		pending:  map[string][]branchItem{},
+		items:    []branchItem{},
+	}
+
+	switch head {
+	case BranchRoot:
+	case "":
+		walker.latest = true
+	default:
+		walker.next = head
+	}
+	return walker
+}
+
+// add the message older than the added messages
+func (walker *branchWalker) add(message map[string]interface{}) {
+	walker.seq++
+	item := branchItem{seq: walker.seq, message: message}
+	id := toString(message["message_id"])
+	parent := toString(message["parent_id"])
+	silent := toBool(message["silent"])
+
+	switch {
+	case id == "":
+		walker.items = append(walker.items, item)
+
+	case silent && walker.included[parent]:
+		walker.include(id, item)
+
+	case silent:
+		walker.pending[parent] = append(walker.pending[parent], item)
+
+	case walker.latest || id == walker.next:
+		walker.latest = false
+		walker.next = parent
+		walker.include(id, item)
+	}
+}
+
+// include collect the message and the silent messages waiting for it
+func (walker *branchWalker) include(id string, item branchItem) {
+	walker.included[id] = true
+	walker.items = append(walker.items, item)
+	children := walker.pending[id]
+	delete(walker.pending, id)
+	for _, child := range children {
+		walker.include(toString(child.message["message_id"]), child)
+	}
+}
+
+// count the number of the collected messages, the silent messages are counted if includeSilent is true
+func (walker *branchWalker) count(includeSilent bool) int {
+	if includeSilent {
+		return len(walker.items)
+	}
+
+	count := 0
+	for _, item := range walker.items {
+		if !toBool(item.message["silent"]) {
+			count++
+		}
+	}
+	return count
+}
+
+// history the collected messages, oldest first
+func (walker *branchWalker) history() []map[string]interface{} {
+	sort.Slice(walker.items, func(i, j int) bool { return walker.items[i].seq > walker.items[j].seq })
+	history := make([]map[string]interface{}, 0, len(walker.items))
+	for _, item := range walker.items {
+		history = append(history, item.message)
+	}
+	return history
+}
+
+// branchLeaf returns the latest leaf message descending from the message (the message itself if it is a leaf)
+func branchLeaf(history []map[string]interface{}, messageID string) (string, error) {
+	message := findMessage(history, messageID)
+	if message == nil || toBool(message["silent"]) {
+		return "", fmt.Errorf("message %s %w", messageID, ErrNotFound)
+	}
+
+	// The children are always saved after the parent
+	leaf := messageID
+	descendants := map[string]bool{messageID: true}
+	for _, message := range history {
+		id := toString(message["message_id"])
+		if id == "" || toBool(message["silent"]) || !descendants[toString(message["parent_id"])] {
+			continue
+		}
+		descendants[id] = true
+		leaf = id
+	}
+	return leaf, nil
+}
+
+// findMessage returns the message of the history by message id, nil if not found
+func findMessage(history []map[string]interface{}, messageID string) map[string]interface{} {
+	for _, message := range history {
+		if toString(message["message_id"]) == messageID {
+			return message
+		}
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package store
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChainHistory(t *testing.T) {
+	values := []map[string]interface{}{{"content": "1"}, {"content": "2"}}
+	head := chainHistory(values, nil, "m0", false)
+	assert.Equal(t, "m0", values[0]["parent_id"])
+	assert.Equal(t, values[0]["message_id"], values[1]["parent_id"])
+	assert.Equal(t, values[1]["message_id"], head)
+
+	// The silent messages do not move the head
+	values = []map[string]interface{}{{"content": "summary"}}
+	assert.Equal(t, "m0", chainHistory(values, map[string]interface{}{"silent": true}, "m0", true))
+	assert.Equal(t, "m0", values[0]["parent_id"])
+
+	// Start from the given parent or the beginning
+	values = []map[string]interface{}{{"content": "edited"}}
+	chainHistory(values, map[string]interface{}{"parent_id": "m1"}, "m0", false)
+	assert.Equal(t, "m1", values[0]["parent_id"])
+
+	values = []map[string]interface{}{{"content": "new"}}
+	chainHistory(values, map[string]interface{}{"parent_id": BranchRoot}, "m0", false)
+	assert.Nil(t, values[0]["parent_id"])
+
+	// The given ids are kept
+	values = []map[string]interface{}{
+		{"content": "1", "message_id": "i1", "parent_id": BranchRoot},
+		{"content": "2", "message_id": "i2", "parent_id": "i1"},
+		{"content": "3"},
+	}
+	assert.Equal(t, values[2]["message_id"], chainHistory(values, nil, "m0", false))
+	assert.Nil(t, values[0]["parent_id"])
+	assert.Equal(t, "i1", values[1]["parent_id"])
+	assert.Equal(t, "i2", values[2]["parent_id"])
+}
+
+func TestBranchHistory(t *testing.T) {
+	history := []map[string]interface{}{
+		{"content": "legacy"},
+		{"content": "1", "message_id": "m1", "parent_id": nil},
+		{"content": "2", "message_id": "m2", "parent_id": "m1"},
+		{"content": "2'", "message_id": "m3", "parent_id": "m1"},
+		{"content": "summary", "message_id": "s1", "parent_id": "m2", "silent": true},
+		{"content": "3", "message_id": "m4", "parent_id": "m2"},
+	}
+
+	contents := func(history []map[string]interface{}) []interface{} {
+		res := []interface{}{}
+		for _, message := range history {
+			res = append(res, message["content"])
+		}
+		return res
+	}
+
+	// The messages without message_id are always on the branch
+	assert.Equal(t, []interface{}{"legacy", "1", "2", "summary", "3"}, contents(BranchHistory(history, "m4")))
+	assert.Equal(t, []interface{}{"legacy", "1", "2'"}, contents(BranchHistory(history, "m3")))
+
+	// Falls back to the latest message
+	assert.Equal(t, []interface{}{"legacy", "1", "2", "summary", "3"}, contents(BranchHistory(history, "removed")))
+	assert.Equal(t, []interface{}{"legacy"}, contents(BranchHistory(history, BranchRoot)))
+	assert.Equal(t, 1, len(BranchHistory(history[:1], "m4")))
+
+	// The walker collects the same messages from the head to the root
+	for _, head := range []string{"m4", "m3", "m2", BranchRoot, ""} {
+		walker := newBranchWalker(head)
+		for i := len(history) - 1; i >= 0; i-- {
+			walker.add(history[i])
+		}
+		assert.Equal(t, contents(BranchHistory(history, head)), contents(walker.history()), head)
+	}
+
+	leaf, err := branchLeaf(history, "m1")
+	assert.Nil(t, err)
+	assert.Equal(t, "m4", leaf)
+
+	leaf, err = branchLeaf(history, "m3")
+	assert.Nil(t, err)
+	assert.Equal(t, "m3", leaf)
+
+	_, err = branchLeaf(history, "s1")
+	assert.NotNil(t, err)
+
+	branches := historyBranches(history, "m3")
+	assert.Equal(t, 2, len(branches))
+	assert.Equal(t, Branch{ID: "m3", Active: true, Size: 3}, branches[0])
+	assert.Equal(t, "m4", branches[1].ID)
+	assert.Equal(t, 4, branches[1].Size)
+
+	history[3]["role"] = "user"
+	history[3]["content"] = strings.Repeat("a", 200)
+	branches = historyBranches(history, "m3")
+	assert.Equal(t, strings.Repeat("a", branchPreviewSize)+"...", branches[0].Preview)
+}
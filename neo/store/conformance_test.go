@@ -0,0 +1,58 @@
+package store_test
+
+import (
+	"testing"
+
+	"github.com/yaoapp/yao/config"
+	"github.com/yaoapp/yao/neo/store"
+	"github.com/yaoapp/yao/neo/store/storetest"
+	"github.com/yaoapp/yao/test"
+)
+
+func TestMemoryConformance(t *testing.T) {
+	test.Prepare(t, config.Conf)
+	defer test.Clean()
+
+	storetest.Run(t, func(t *testing.T, setting store.Setting) store.Store {
+		s, err := store.NewMemory(setting)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	})
+}
+
+func TestXunConformance(t *testing.T) {
+	test.Prepare(t, config.Conf)
+	defer test.Clean()
+
+	for _, connector := range []string{"default", "mysql"} {
+		t.Run(connector, func(t *testing.T) {
+			storetest.Run(t, factory(connector, store.NewXun))
+		})
+	}
+}
+
+func TestRedisConformance(t *testing.T) {
+	test.Prepare(t, config.Conf)
+	defer test.Clean()
+	storetest.Run(t, factory("redis", store.NewRedis))
+}
+
+func TestMongoConformance(t *testing.T) {
+	test.Prepare(t, config.Conf)
+	defer test.Clean()
+	storetest.Run(t, factory("mongo", store.NewMongo))
+}
+
+func factory(connector string, create func(store.Setting) (store.Store, error)) storetest.Factory {
+	return func(t *testing.T, setting store.Setting) store.Store {
+		setting.Connector = connector
+		setting.Prefix = "__unit_test_conformance_"
+		s, err := create(setting)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+}
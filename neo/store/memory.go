@@ -0,0 +1,435 @@
+package store
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Memory represents an in-memory conversation storage
+// It is the reference implementation of the Store interface, the data is lost when the process exits.
+// Useful for unit tests and for the applications which do not need to persist the conversations.
+type Memory struct {
+	setting    Setting
+	mutex      sync.RWMutex
+	chats      map[string]map[string]map[string]interface{}   // user id => chat id => chat
+	histories  map[string]map[string][]map[string]interface{} // user id => chat id => history (oldest first)
+	assistants map[string]map[string]interface{}              // assistant id => assistant
+}
+
+// NewMemory create a new memory store
+func NewMemory(setting Setting) (Store, error) {
+	return &Memory{
+		setting:    setting,
+		chats:      map[string]map[string]map[string]interface{}{},
+		histories:  map[string]map[string][]map[string]interface{}{},
+		assistants: map[string]map[string]interface{}{},
+	}, nil
+}
+
+// UpdateChatTitle updates chat title
+func (mem *Memory) UpdateChatTitle(sid string, cid string, title string) error {
+	userID, err := getUserID(mem.setting, sid)
+	if err != nil {
+		return err
+	}
+
+	mem.mutex.Lock()
+	defer mem.mutex.Unlock()
+
+	if chat, has := mem.chats[userID][cid]; has {
+		chat["title"] = title
+		chat["updated_at"] = time.Now()
+	}
+	return nil
+}
+
+// GetChats retrieves a list of chats
+func (mem *Memory) GetChats(sid string, filter ChatFilter) (*ChatGroupResponse, error) {
+	// Default behavior: exclude silent chats
+	if filter.Silent == nil {
+		silentFalse := false
+		filter.Silent = &silentFalse
+	}
+
+	userID, err := getUserID(mem.setting, sid)
+	if err != nil {
+		return nil, err
+	}
+
+	// Set default values
+	if filter.Page <= 0 {
+		filter.Page = 1
+	}
+	if filter.PageSize <= 0 {
+		filter.PageSize = 20
+	}
+	if filter.Order == "" {
+		filter.Order = "desc"
+	}
+
+	mem.mutex.RLock()
+	defer mem.mutex.RUnlock()
+
+	matched := []map[string]interface{}{}
+	for _, chat := range mem.chats[userID] {
+		if matchChat(chat, filter) {
+			matched = append(matched, copyMap(chat))
+		}
+	}
+	sortChats(matched, filter.Order)
+
+	total := int64(len(matched))
+	offset := (filter.Page - 1) * filter.PageSize
+	page := []map[string]interface{}{}
+	for i := offset; i < len(matched) && i < offset+filter.PageSize; i++ {
+		page = append(page, matched[i])
+	}
+	mem.withAssistants(page)
+
+	return &ChatGroupResponse{
+		Groups:   groupChats(page),
+		Page:     filter.Page,
+		PageSize: filter.PageSize,
+		Total:    total,
+		LastPage: lastPage(total, filter.PageSize),
+	}, nil
+}
+
+// withAssistants add the assistant name and avatar to the chats (the lock must be held)
+func (mem *Memory) withAssistants(chats []map[string]interface{}) {
+	for _, chat := range chats {
+		if id, ok := chat["assistant_id"].(string); ok && id != "" {
+			if assistant, has := mem.assistants[id]; has {
+				chat["assistant_name"] = assistant["name"]
+				chat["assistant_avatar"] = assistant["avatar"]
+			}
+		}
+	}
+}
+
+// GetChat retrieves a single chat's information
+func (mem *Memory) GetChat(sid string, cid string) (*ChatInfo, error) {
+	return mem.GetChatWithFilter(sid, cid, ChatFilter{})
+}
+
+// GetChatWithFilter retrieves a single chat's information with filter options
+func (mem *Memory) GetChatWithFilter(sid string, cid string, filter ChatFilter) (*ChatInfo, error) {
+	userID, err := getUserID(mem.setting, sid)
+	if err != nil {
+		return nil, err
+	}
+
+	mem.mutex.RLock()
+	row, has := mem.chats[userID][cid]
+	if !has {
+		mem.mutex.RUnlock()
+		return nil, nil
+	}
+
+	chat := map[string]interface{}{
+		"chat_id":      row["chat_id"],
+		"title":        row["title"],
+		"assistant_id": row["assistant_id"],
+	}
+	mem.withAssistants([]map[string]interface{}{chat})
+	mem.mutex.RUnlock()
+
+	history, err := mem.GetHistoryWithFilter(sid, cid, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ChatInfo{
+		Chat:    chat,
+		History: history,
+	}, nil
+}
+
+// GetHistory retrieves chat history
+func (mem *Memory) GetHistory(sid string, cid string) ([]map[string]interface{}, error) {
+	return mem.GetHistoryWithFilter(sid, cid, ChatFilter{})
+}
+
+// GetHistoryWithFilter retrieves chat history with filter options
+func (mem *Memory) GetHistoryWithFilter(sid string, cid string, filter ChatFilter) ([]map[string]interface{}, error) {
+	userID, err := getUserID(mem.setting, sid)
+	if err != nil {
+		return nil, err
+	}
+
+	mem.mutex.RLock()
+	defer mem.mutex.RUnlock()
+	return selectHistory(mem.loadHistory(userID, cid), mem.head(userID, cid), filter, mem.setting.MaxSize)
+}
+
+// GetBranches retrieves the branches of the chat
+func (mem *Memory) GetBranches(sid string, cid string) ([]Branch, error) {
+	userID, err := getUserID(mem.setting, sid)
+	if err != nil {
+		return nil, err
+	}
+
+	mem.mutex.RLock()
+	defer mem.mutex.RUnlock()
+	return historyBranches(mem.loadHistory(userID, cid), mem.head(userID, cid)), nil
+}
+
+// SwitchBranch switches the active branch of the chat
+func (mem *Memory) SwitchBranch(sid string, cid string, messageID string) error {
+	userID, err := getUserID(mem.setting, sid)
+	if err != nil {
+		return err
+	}
+
+	mem.mutex.Lock()
+	defer mem.mutex.Unlock()
+
+	chat, has := mem.chats[userID][cid]
+	if !has {
+		return fmt.Errorf("chat %s %w", cid, ErrNotFound)
+	}
+
+	head, err := branchLeaf(mem.loadHistory(userID, cid), messageID)
+	if err != nil {
+		return err
+	}
+	chat["head_id"] = head
+	return nil
+}
+
+// loadHistory returns the unexpired history of the chat, oldest first (the lock must be held)
+func (mem *Memory) loadHistory(userID string, cid string) []map[string]interface{} {
+	now := time.Now()
+	history := []map[string]interface{}{}
+	for _, message := range mem.histories[userID][cid] {
+		if expiredAt, ok := message["expired_at"].(time.Time); ok && !expiredAt.After(now) {
+			continue
+		}
+
+		value := copyMap(message)
+		delete(value, "expired_at")
+		history = append(history, value)
+	}
+	return history
+}
+
+// head returns the head message id of the chat (the lock must be held)
+func (mem *Memory) head(userID string, cid string) string {
+	if chat, has := mem.chats[userID][cid]; has {
+		return toString(chat["head_id"])
+	}
+	return ""
+}
+
+// SaveHistory saves chat history
+func (mem *Memory) SaveHistory(sid string, messages []map[string]interface{}, cid string, context map[string]interface{}) error {
+	if cid == "" {
+		cid = uuid.New().String() // Generate a new UUID if cid is empty
+	}
+
+	userID, err := getUserID(mem.setting, sid)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	silent := getSilent(context)
+	values, err := newHistoryMessages(userID, cid, messages, context, silent, now)
+	if err != nil {
+		return err
+	}
+
+	mem.mutex.Lock()
+	defer mem.mutex.Unlock()
+
+	if _, has := mem.chats[userID]; !has {
+		mem.chats[userID] = map[string]map[string]interface{}{}
+		mem.histories[userID] = map[string][]map[string]interface{}{}
+	}
+
+	chat, has := mem.chats[userID][cid]
+	if !has {
+		chat = map[string]interface{}{
+			"chat_id":    cid,
+			"title":      nil,
+			"created_at": now,
+		}
+		mem.chats[userID][cid] = chat
+	}
+	chat["assistant_id"] = getContextAssistantID(context)
+	chat["silent"] = silent
+	chat["updated_at"] = now
+	chat["head_id"] = chainHistory(values, context, toString(chat["head_id"]), silent)
+
+	var expiredAt interface{} = nil
+	if mem.setting.TTL > 0 {
+		expiredAt = now.Add(time.Duration(mem.setting.TTL) * time.Second)
+	}
+
+	history := mem.histories[userID][cid]
+	for _, value := range values {
+		value["expired_at"] = expiredAt
+		history = append(history, value)
+	}
+
+	// Cap the history length
+	if mem.setting.MaxSize > 0 && len(history) > mem.setting.MaxSize {
+		history = append([]map[string]interface{}{}, history[len(history)-mem.setting.MaxSize:]...)
+	}
+	mem.histories[userID][cid] = history
+	return nil
+}
+
+// DeleteChat deletes a single chat
+func (mem *Memory) DeleteChat(sid string, cid string) error {
+	userID, err := getUserID(mem.setting, sid)
+	if err != nil {
+		return err
+	}
+
+	mem.mutex.Lock()
+	defer mem.mutex.Unlock()
+	delete(mem.chats[userID], cid)
+	delete(mem.histories[userID], cid)
+	return nil
+}
+
+// DeleteAllChats deletes all chats
+func (mem *Memory) DeleteAllChats(sid string) error {
+	userID, err := getUserID(mem.setting, sid)
+	if err != nil {
+		return err
+	}
+
+	mem.mutex.Lock()
+	defer mem.mutex.Unlock()
+	delete(mem.chats, userID)
+	delete(mem.histories, userID)
+	return nil
+}
+
+// SaveAssistant saves assistant information
+func (mem *Memory) SaveAssistant(assistant map[string]interface{}) (interface{}, error) {
+	err := validateAssistant(assistant)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create a copy of the assistant map to avoid modifying the original
+	assistantCopy := copyAssistant(assistant)
+	err = normalizeJSONFields(assistantCopy, assistantJSONFields)
+	if err != nil {
+		return nil, err
+	}
+
+	mem.mutex.Lock()
+	defer mem.mutex.Unlock()
+
+	// Generate assistant_id if not provided
+	if _, ok := assistantCopy["assistant_id"]; !ok {
+		assistantCopy["assistant_id"], err = mem.generateAssistantID()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Update or insert
+	id := fmt.Sprintf("%v", assistantCopy["assistant_id"])
+	if exists, has := mem.assistants[id]; has {
+		for key, value := range assistantCopy {
+			exists[key] = value
+		}
+		exists["updated_at"] = time.Now()
+		return assistantCopy["assistant_id"], nil
+	}
+
+	data := copyMap(assistantCopy)
+	defaultAssistant(data)
+	data["created_at"] = time.Now()
+	data["updated_at"] = nil
+	mem.assistants[id] = data
+	return assistantCopy["assistant_id"], nil
+}
+
+// DeleteAssistant deletes an assistant
+func (mem *Memory) DeleteAssistant(assistantID string) error {
+	mem.mutex.Lock()
+	defer mem.mutex.Unlock()
+
+	if _, has := mem.assistants[assistantID]; !has {
+		return fmt.Errorf("assistant %s not found", assistantID)
+	}
+	delete(mem.assistants, assistantID)
+	return nil
+}
+
+// GetAssistants retrieves a list of assistants
+func (mem *Memory) GetAssistants(filter AssistantFilter) (*AssistantResponse, error) {
+	mem.mutex.RLock()
+	defer mem.mutex.RUnlock()
+
+	matched := []map[string]interface{}{}
+	for _, assistant := range mem.assistants {
+		if matchAssistant(assistant, filter) {
+			matched = append(matched, copyMap(assistant))
+		}
+	}
+	return paginateAssistants(matched, filter), nil
+}
+
+// GetAssistant retrieves a single assistant by ID
+func (mem *Memory) GetAssistant(assistantID string) (map[string]interface{}, error) {
+	mem.mutex.RLock()
+	defer mem.mutex.RUnlock()
+
+	assistant, has := mem.assistants[assistantID]
+	if !has {
+		return nil, fmt.Errorf("assistant %s not found", assistantID)
+	}
+	return copyMap(assistant), nil
+}
+
+// DeleteAssistants deletes assistants based on filter conditions
+func (mem *Memory) DeleteAssistants(filter AssistantFilter) (int64, error) {
+	mem.mutex.Lock()
+	defer mem.mutex.Unlock()
+
+	var count int64 = 0
+	for id, assistant := range mem.assistants {
+		if matchAssistant(assistant, filter) {
+			delete(mem.assistants, id)
+			count++
+		}
+	}
+	return count, nil
+}
+
+// GetAssistantTags retrieves all unique tags from assistants
+func (mem *Memory) GetAssistantTags() ([]string, error) {
+	mem.mutex.RLock()
+	defer mem.mutex.RUnlock()
+
+	assistants := make([]map[string]interface{}, 0, len(mem.assistants))
+	for _, assistant := range mem.assistants {
+		assistants = append(assistants, assistant)
+	}
+	return assistantTags(assistants), nil
+}
+
+// generateAssistantID generates a random-looking 6-digit ID (the lock must be held)
+func (mem *Memory) generateAssistantID() (string, error) {
+	maxAttempts := 10 // Maximum number of attempts to generate a unique ID
+	for i := 0; i < maxAttempts; i++ {
+		timestamp := time.Now().UnixNano()
+		random := (timestamp ^ (timestamp >> 12)) % 1000000
+		hash := fmt.Sprintf("%06d", random)
+		if _, has := mem.assistants[hash]; !has {
+			return hash, nil
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return "", fmt.Errorf("failed to generate unique ID after %d attempts", maxAttempts)
+}
@@ -1,84 +1,754 @@
 package store
 
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yaoapp/gou/connector"
+	mongoConnector "github.com/yaoapp/gou/connector/mongo"
+	"github.com/yaoapp/kun/log"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
 // Mongo represents a MongoDB-based conversation storage
-type Mongo struct{}
+// The documents have the same fields as the xun tables, the JSON fields are stored as documents,
+// and the expired history is removed by a TTL index on expired_at.
+type Mongo struct {
+	history   *mongo.Collection
+	chat      *mongo.Collection
+	assistant *mongo.Collection
+	setting   Setting
+}
 
 // NewMongo create a new mongo store
-func NewMongo() Store {
-	return &Mongo{}
+func NewMongo(setting Setting) (Store, error) {
+	conn, err := connector.Select(setting.Connector)
+	if err != nil {
+		return nil, err
+	}
+
+	mconn, ok := conn.(*mongoConnector.Connector)
+	if !ok || mconn.Database == nil {
+		return nil, fmt.Errorf("the connector %s is not a mongo connector", setting.Connector)
+	}
+
+	m := &Mongo{
+		history:   mconn.Database.Collection(setting.Prefix + "history"),
+		chat:      mconn.Database.Collection(setting.Prefix + "chat"),
+		assistant: mconn.Database.Collection(setting.Prefix + "assistant"),
+		setting:   setting,
+	}
+
+	err = m.initialize()
+	if err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// initialize create the indexes
+func (m *Mongo) initialize() error {
+	ctx := context.Background()
+	_, err := m.history.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "sid", Value: 1}, {Key: "cid", Value: 1}}},
+		{Keys: bson.D{{Key: "expired_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = m.chat.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "chat_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "sid", Value: 1}, {Key: "updated_at", Value: -1}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = m.assistant.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "assistant_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "sort", Value: 1}, {Key: "updated_at", Value: -1}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Trace("Initialize the conversation collections: %s", m.setting.Prefix)
+	return nil
+}
+
+// UpdateChatTitle updates chat title
+func (m *Mongo) UpdateChatTitle(sid string, cid string, title string) error {
+	userID, err := getUserID(m.setting, sid)
+	if err != nil {
+		return err
+	}
+
+	_, err = m.chat.UpdateOne(context.Background(),
+		bson.M{"sid": userID, "chat_id": cid},
+		bson.M{"$set": bson.M{"title": title, "updated_at": time.Now()}},
+	)
+	return err
 }
 
 // GetChats retrieves a list of chats
 func (m *Mongo) GetChats(sid string, filter ChatFilter) (*ChatGroupResponse, error) {
-	return &ChatGroupResponse{}, nil
+	// Default behavior: exclude silent chats
+	if filter.Silent == nil {
+		silentFalse := false
+		filter.Silent = &silentFalse
+	}
+
+	userID, err := getUserID(m.setting, sid)
+	if err != nil {
+		return nil, err
+	}
+
+	// Set default values
+	if filter.Page <= 0 {
+		filter.Page = 1
+	}
+	if filter.PageSize <= 0 {
+		filter.PageSize = 20
+	}
+	if filter.Order == "" {
+		filter.Order = "desc"
+	}
+
+	query := bson.M{"sid": userID}
+	if !*filter.Silent {
+		query["silent"] = bson.M{"$ne": true}
+	}
+	if filter.Keywords != "" {
+		query["title"] = keywordsRegex(filter.Keywords)
+	}
+
+	ctx := context.Background()
+	total, err := m.chat.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	order := -1
+	if filter.Order == "asc" {
+		order = 1
+	}
+
+	opts := options.Find().
+		SetProjection(bson.M{"_id": 0, "chat_id": 1, "title": 1, "assistant_id": 1, "silent": 1, "created_at": 1, "updated_at": 1}).
+		SetSort(bson.D{{Key: "updated_at", Value: order}}).
+		SetSkip(int64((filter.Page - 1) * filter.PageSize)).
+		SetLimit(int64(filter.PageSize))
+
+	chats, err := m.find(ctx, m.chat, query, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Add assistant details
+	err = m.withAssistants(ctx, chats)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ChatGroupResponse{
+		Groups:   groupChats(chats),
+		Page:     filter.Page,
+		PageSize: filter.PageSize,
+		Total:    total,
+		LastPage: lastPage(total, filter.PageSize),
+	}, nil
+}
+
+// withAssistants add the assistant name and avatar to the chats
+func (m *Mongo) withAssistants(ctx context.Context, chats []map[string]interface{}) error {
+	ids := []interface{}{}
+	for _, chat := range chats {
+		if id, ok := chat["assistant_id"].(string); ok && id != "" {
+			ids = append(ids, id)
+		}
+	}
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	assistants, err := m.find(ctx, m.assistant,
+		bson.M{"assistant_id": bson.M{"$in": ids}},
+		options.Find().SetProjection(bson.M{"_id": 0, "assistant_id": 1, "name": 1, "avatar": 1}),
+	)
+	if err != nil {
+		return err
+	}
+
+	assistantMap := map[string]map[string]interface{}{}
+	for _, assistant := range assistants {
+		assistantMap[fmt.Sprintf("%v", assistant["assistant_id"])] = assistant
+	}
+
+	for _, chat := range chats {
+		if id, ok := chat["assistant_id"].(string); ok && id != "" {
+			if assistant, has := assistantMap[id]; has {
+				chat["assistant_name"] = assistant["name"]
+				chat["assistant_avatar"] = assistant["avatar"]
+			}
+		}
+	}
+	return nil
 }
 
 // GetChat retrieves a single chat's information
 func (m *Mongo) GetChat(sid string, cid string) (*ChatInfo, error) {
-	return &ChatInfo{}, nil
+	return m.GetChatWithFilter(sid, cid, ChatFilter{})
 }
 
 // GetChatWithFilter retrieves a single chat's information with filter options
 func (m *Mongo) GetChatWithFilter(sid string, cid string, filter ChatFilter) (*ChatInfo, error) {
-	return &ChatInfo{}, nil
+	userID, err := getUserID(m.setting, sid)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	row, err := m.findOne(ctx, m.chat, bson.M{"sid": userID, "chat_id": cid})
+	if err != nil {
+		return nil, err
+	}
+
+	// Return nil if the chat does not exist
+	if row == nil {
+		return nil, nil
+	}
+
+	chat := map[string]interface{}{
+		"chat_id":      row["chat_id"],
+		"title":        row["title"],
+		"assistant_id": row["assistant_id"],
+	}
+
+	err = m.withAssistants(ctx, []map[string]interface{}{chat})
+	if err != nil {
+		return nil, err
+	}
+
+	history, err := m.GetHistoryWithFilter(sid, cid, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ChatInfo{
+		Chat:    chat,
+		History: history,
+	}, nil
 }
 
 // GetHistory retrieves chat history
 func (m *Mongo) GetHistory(sid string, cid string) ([]map[string]interface{}, error) {
-	return []map[string]interface{}{}, nil
+	return m.GetHistoryWithFilter(sid, cid, ChatFilter{})
 }
 
 // GetHistoryWithFilter retrieves chat history with filter options
 func (m *Mongo) GetHistoryWithFilter(sid string, cid string, filter ChatFilter) ([]map[string]interface{}, error) {
-	return []map[string]interface{}{}, nil
+	userID, err := getUserID(m.setting, sid)
+	if err != nil {
+		return nil, err
+	}
+
+	history, head, err := m.loadHistory(context.Background(), userID, cid)
+	if err != nil {
+		return nil, err
+	}
+	return selectHistory(history, head, filter, m.setting.MaxSize)
 }
 
-// SaveHistory saves chat history
-func (m *Mongo) SaveHistory(sid string, messages []map[string]interface{}, cid string, context map[string]interface{}) error {
+// GetBranches retrieves the branches of the chat
+func (m *Mongo) GetBranches(sid string, cid string) ([]Branch, error) {
+	userID, err := getUserID(m.setting, sid)
+	if err != nil {
+		return nil, err
+	}
+
+	history, head, err := m.loadHistory(context.Background(), userID, cid)
+	if err != nil {
+		return nil, err
+	}
+	return historyBranches(history, head), nil
+}
+
+// SwitchBranch switches the active branch of the chat
+func (m *Mongo) SwitchBranch(sid string, cid string, messageID string) error {
+	userID, err := getUserID(m.setting, sid)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	history, _, err := m.loadHistory(ctx, userID, cid)
+	if err != nil {
+		return err
+	}
+
+	head, err := branchLeaf(history, messageID)
+	if err != nil {
+		return err
+	}
+
+	res, err := m.chat.UpdateOne(ctx, bson.M{"sid": userID, "chat_id": cid}, bson.M{"$set": bson.M{"head_id": head}})
+	if err != nil {
+		return err
+	}
+
+	if res.MatchedCount == 0 {
+		return fmt.Errorf("chat %s %w", cid, ErrNotFound)
+	}
 	return nil
 }
 
+// loadHistory returns the history of the chat (oldest first) and the head message id
+func (m *Mongo) loadHistory(ctx context.Context, userID string, cid string) ([]map[string]interface{}, string, error) {
+	query := bson.M{"sid": userID, "cid": cid}
+
+	// The TTL monitor runs periodically, filter the expired messages explicitly
+	if m.setting.TTL > 0 {
+		query["expired_at"] = bson.M{"$gt": time.Now()}
+	}
+
+	opts := options.Find().
+		SetProjection(bson.M{"_id": 0, "sid": 0, "cid": 0, "expired_at": 0}).
+		SetSort(bson.D{{Key: "_id", Value: 1}})
+
+	history, err := m.find(ctx, m.history, query, opts)
+	if err != nil {
+		return nil, "", err
+	}
+
+	chat, err := m.findOne(ctx, m.chat, bson.M{"sid": userID, "chat_id": cid})
+	if err != nil || chat == nil {
+		return history, "", err
+	}
+	return history, toString(chat["head_id"]), nil
+}
+
+// SaveHistory saves chat history
+func (m *Mongo) SaveHistory(sid string, messages []map[string]interface{}, cid string, chatContext map[string]interface{}) error {
+	if cid == "" {
+		cid = uuid.New().String() // Generate a new UUID if cid is empty
+	}
+
+	userID, err := getUserID(m.setting, sid)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	silent := getSilent(chatContext)
+	values, err := newHistoryMessages(userID, cid, messages, chatContext, silent, now)
+	if err != nil {
+		return err
+	}
+
+	// Chain the messages to the head of the active branch
+	ctx := context.Background()
+	chat, err := m.findOne(ctx, m.chat, bson.M{"sid": userID, "chat_id": cid})
+	if err != nil {
+		return err
+	}
+
+	head := ""
+	if chat != nil {
+		head = toString(chat["head_id"])
+	}
+
+	// Ensure the chat exists, and update assistant_id, silent, head_id and updated_at
+	_, err = m.chat.UpdateOne(ctx,
+		bson.M{"chat_id": cid, "sid": userID},
+		bson.M{
+			"$set": bson.M{
+				"assistant_id": getContextAssistantID(chatContext),
+				"silent":       silent,
+				"head_id":      chainHistory(values, chatContext, head, silent),
+				"updated_at":   now,
+			},
+			"$setOnInsert": bson.M{"title": nil, "created_at": now},
+		},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		return err
+	}
+
+	if len(values) == 0 {
+		return nil
+	}
+
+	var expiredAt interface{} = nil
+	if m.setting.TTL > 0 {
+		expiredAt = now.Add(time.Duration(m.setting.TTL) * time.Second)
+	}
+
+	docs := make([]interface{}, 0, len(values))
+	for _, value := range values {
+		value["sid"] = userID
+		value["cid"] = cid
+		value["expired_at"] = expiredAt
+		docs = append(docs, value)
+	}
+
+	_, err = m.history.InsertMany(ctx, docs, options.InsertMany().SetOrdered(true))
+	return err
+}
+
 // DeleteChat deletes a single chat
 func (m *Mongo) DeleteChat(sid string, cid string) error {
-	return nil
+	userID, err := getUserID(m.setting, sid)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	_, err = m.history.DeleteMany(ctx, bson.M{"sid": userID, "cid": cid})
+	if err != nil {
+		return err
+	}
+
+	_, err = m.chat.DeleteOne(ctx, bson.M{"sid": userID, "chat_id": cid})
+	return err
 }
 
 // DeleteAllChats deletes all chats
 func (m *Mongo) DeleteAllChats(sid string) error {
-	return nil
-}
+	userID, err := getUserID(m.setting, sid)
+	if err != nil {
+		return err
+	}
 
-// UpdateChatTitle updates chat title
-func (m *Mongo) UpdateChatTitle(sid string, cid string, title string) error {
-	return nil
+	ctx := context.Background()
+	_, err = m.history.DeleteMany(ctx, bson.M{"sid": userID})
+	if err != nil {
+		return err
+	}
+
+	_, err = m.chat.DeleteMany(ctx, bson.M{"sid": userID})
+	return err
 }
 
 // SaveAssistant saves assistant information
 func (m *Mongo) SaveAssistant(assistant map[string]interface{}) (interface{}, error) {
-	return assistant["assistant_id"], nil
+	err := validateAssistant(assistant)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create a copy of the assistant map to avoid modifying the original
+	assistantCopy := copyAssistant(assistant)
+
+	// Generate assistant_id if not provided
+	if _, ok := assistantCopy["assistant_id"]; !ok {
+		assistantCopy["assistant_id"], err = m.GenerateAssistantID()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ctx := context.Background()
+	query := bson.M{"assistant_id": assistantCopy["assistant_id"]}
+	exists, err := m.assistant.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	// Update or insert
+	if exists > 0 {
+		set := bson.M{}
+		for key, value := range assistantCopy {
+			set[key] = value
+		}
+		set["updated_at"] = time.Now()
+
+		_, err = m.assistant.UpdateOne(ctx, query, bson.M{"$set": set})
+		if err != nil {
+			return nil, err
+		}
+		return assistantCopy["assistant_id"], nil
+	}
+
+	doc := bson.M{}
+	for key, value := range assistantCopy {
+		doc[key] = value
+	}
+	defaultAssistant(doc)
+	doc["created_at"] = time.Now()
+	doc["updated_at"] = nil
+
+	_, err = m.assistant.InsertOne(ctx, doc)
+	if err != nil {
+		return nil, err
+	}
+	return assistantCopy["assistant_id"], nil
 }
 
 // DeleteAssistant deletes an assistant
 func (m *Mongo) DeleteAssistant(assistantID string) error {
+	res, err := m.assistant.DeleteOne(context.Background(), bson.M{"assistant_id": assistantID})
+	if err != nil {
+		return err
+	}
+
+	if res.DeletedCount == 0 {
+		return fmt.Errorf("assistant %s not found", assistantID)
+	}
 	return nil
 }
 
 // GetAssistants retrieves a list of assistants
 func (m *Mongo) GetAssistants(filter AssistantFilter) (*AssistantResponse, error) {
-	return &AssistantResponse{}, nil
+	// Set defaults for pagination
+	if filter.PageSize <= 0 {
+		filter.PageSize = 20
+	}
+	if filter.Page <= 0 {
+		filter.Page = 1
+	}
+
+	ctx := context.Background()
+	query := assistantQuery(filter)
+	total, err := m.assistant.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	projection := bson.M{"_id": 0}
+	if len(filter.Select) > 0 {
+		for _, field := range filter.Select {
+			projection[field] = 1
+		}
+	}
+
+	opts := options.Find().
+		SetProjection(projection).
+		SetSort(bson.D{{Key: "sort", Value: 1}, {Key: "updated_at", Value: -1}}).
+		SetSkip(int64((filter.Page - 1) * filter.PageSize)).
+		SetLimit(int64(filter.PageSize))
+
+	data, err := m.find(ctx, m.assistant, query, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return assistantResponse(data, total, filter), nil
 }
 
 // GetAssistant retrieves a single assistant by ID
 func (m *Mongo) GetAssistant(assistantID string) (map[string]interface{}, error) {
-	return map[string]interface{}{}, nil
+	data, err := m.findOne(context.Background(), m.assistant, bson.M{"assistant_id": assistantID})
+	if err != nil {
+		return nil, err
+	}
+
+	if data == nil {
+		return nil, fmt.Errorf("assistant %s not found", assistantID)
+	}
+
+	return data, nil
 }
 
-// DeleteAssistants deletes assistants based on filter conditions (not implemented)
-func (mongo *Mongo) DeleteAssistants(filter AssistantFilter) (int64, error) {
-	return 0, nil
+// DeleteAssistants deletes assistants based on filter conditions
+func (m *Mongo) DeleteAssistants(filter AssistantFilter) (int64, error) {
+	res, err := m.assistant.DeleteMany(context.Background(), assistantQuery(filter))
+	if err != nil {
+		return 0, err
+	}
+	return res.DeletedCount, nil
 }
 
 // GetAssistantTags retrieves all unique tags from assistants
-func (conv *Mongo) GetAssistantTags() ([]string, error) {
-	return []string{}, nil
+func (m *Mongo) GetAssistantTags() ([]string, error) {
+	ctx := context.Background()
+	cursor, err := m.assistant.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"type": "assistant"}}},
+		{{Key: "$unwind", Value: "$tags"}},
+		{{Key: "$group", Value: bson.M{"_id": "$tags"}}},
+		{{Key: "$sort", Value: bson.M{"_id": 1}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	tags := []string{}
+	for cursor.Next(ctx) {
+		var row struct {
+			Tag interface{} `bson:"_id"`
+		}
+		if err := cursor.Decode(&row); err != nil {
+			return nil, err
+		}
+		if tag, ok := row.Tag.(string); ok && tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags, cursor.Err()
+}
+
+// GenerateAssistantID generates a random-looking 6-digit ID
+func (m *Mongo) GenerateAssistantID() (string, error) {
+	maxAttempts := 10 // Maximum number of attempts to generate a unique ID
+	for i := 0; i < maxAttempts; i++ {
+		timestamp := time.Now().UnixNano()
+		random := (timestamp ^ (timestamp >> 12)) % 1000000
+		hash := fmt.Sprintf("%06d", random)
+
+		n, err := m.assistant.CountDocuments(context.Background(), bson.M{"assistant_id": hash})
+		if err != nil {
+			return "", err
+		}
+
+		if n == 0 {
+			return hash, nil
+		}
+
+		// If ID exists, wait a bit and try again
+		time.Sleep(time.Millisecond)
+	}
+
+	return "", fmt.Errorf("failed to generate unique ID after %d attempts", maxAttempts)
+}
+
+// find query the documents and convert them to maps
+func (m *Mongo) find(ctx context.Context, coll *mongo.Collection, query interface{}, opts ...*options.FindOptions) ([]map[string]interface{}, error) {
+	cursor, err := coll.Find(ctx, query, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	res := []map[string]interface{}{}
+	for cursor.Next(ctx) {
+		var doc bson.M
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, err
+		}
+		res = append(res, mongoDocument(doc))
+	}
+	return res, cursor.Err()
+}
+
+// findOne query a document, returns nil if not found
+func (m *Mongo) findOne(ctx context.Context, coll *mongo.Collection, query interface{}) (map[string]interface{}, error) {
+	var doc bson.M
+	err := coll.FindOne(ctx, query, options.FindOne().SetProjection(bson.M{"_id": 0})).Decode(&doc)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return mongoDocument(doc), nil
+}
+
+// assistantQuery convert the assistant filter to the mongo query
+func assistantQuery(filter AssistantFilter) bson.M {
+	query := bson.M{}
+	if len(filter.Tags) > 0 {
+		query["tags"] = bson.M{"$in": filter.Tags}
+	}
+
+	if filter.Keywords != "" {
+		query["$or"] = bson.A{
+			bson.M{"name": keywordsRegex(filter.Keywords)},
+			bson.M{"description": keywordsRegex(filter.Keywords)},
+		}
+	}
+
+	if filter.Type != "" {
+		query["type"] = filter.Type
+	}
+
+	if filter.Connector != "" {
+		query["connector"] = filter.Connector
+	}
+
+	id := bson.M{}
+	if filter.AssistantID != "" {
+		id["$eq"] = filter.AssistantID
+	}
+	if len(filter.AssistantIDs) > 0 {
+		id["$in"] = filter.AssistantIDs
+	}
+	if len(id) > 0 {
+		query["assistant_id"] = id
+	}
+
+	if filter.Mentionable != nil {
+		query["mentionable"] = *filter.Mentionable
+	}
+
+	if filter.Automated != nil {
+		query["automated"] = *filter.Automated
+	}
+
+	if filter.BuiltIn != nil {
+		query["built_in"] = *filter.BuiltIn
+	}
+
+	return query
+}
+
+// keywordsRegex the case-insensitive regex of the keywords, the same as SQL LIKE %keywords%
+func keywordsRegex(keywords string) primitive.Regex {
+	return primitive.Regex{Pattern: regexp.QuoteMeta(keywords), Options: "i"}
+}
+
+// mongoDocument convert the bson document to the plain map
+func mongoDocument(doc bson.M) map[string]interface{} {
+	res := map[string]interface{}{}
+	for key, value := range doc {
+		if key == "_id" {
+			continue
+		}
+		res[key] = mongoValue(value)
+	}
+	return res
+}
+
+func mongoValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case bson.M:
+		res := map[string]interface{}{}
+		for key, item := range v {
+			res[key] = mongoValue(item)
+		}
+		return res
+
+	case bson.D:
+		res := map[string]interface{}{}
+		for _, item := range v {
+			res[item.Key] = mongoValue(item.Value)
+		}
+		return res
+
+	case bson.A:
+		res := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			res = append(res, mongoValue(item))
+		}
+		return res
+
+	case primitive.DateTime:
+		return v.Time()
+
+	case int32:
+		return int(v)
+
+	case int64:
+		return int(v)
+	}
+	return value
 }
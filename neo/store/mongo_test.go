@@ -0,0 +1,76 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yaoapp/yao/config"
+	"github.com/yaoapp/yao/test"
+)
+
+func TestNewMongo(t *testing.T) {
+	test.Prepare(t, config.Conf)
+	defer test.Clean()
+
+	store := prepareMongo(t, Setting{})
+	defer cleanMongo(store)
+
+	indexes, err := store.history.Indexes().List(context.Background())
+	assert.Nil(t, err)
+	assert.True(t, indexes.Next(context.Background()))
+
+	_, err = NewMongo(Setting{Connector: "redis", Prefix: "__unit_test_conversation_"})
+	assert.NotNil(t, err)
+}
+
+func TestMongoExpiredHistory(t *testing.T) {
+	test.Prepare(t, config.Conf)
+	defer test.Clean()
+
+	store := prepareMongo(t, Setting{TTL: 60})
+	defer cleanMongo(store)
+
+	sid := "test_user"
+	cid := "test_chat"
+	err := store.SaveHistory(sid, []map[string]interface{}{{"role": "user", "content": "hello"}}, cid, nil)
+	assert.Nil(t, err)
+
+	ctx := context.Background()
+	row := map[string]interface{}{}
+	err = store.history.FindOne(ctx, map[string]interface{}{"sid": sid, "cid": cid}).Decode(&row)
+	assert.Nil(t, err)
+	assert.NotNil(t, row["expired_at"])
+
+	// The TTL monitor runs periodically, the expired messages are filtered on read
+	_, err = store.history.UpdateMany(ctx,
+		map[string]interface{}{"sid": sid, "cid": cid},
+		map[string]interface{}{"$set": map[string]interface{}{"expired_at": time.Now().Add(-time.Minute)}},
+	)
+	assert.Nil(t, err)
+
+	history, err := store.GetHistory(sid, cid)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(history))
+}
+
+func prepareMongo(t *testing.T, setting Setting) *Mongo {
+	setting.Connector = "mongo"
+	setting.Prefix = "__unit_test_conversation_"
+	store, err := NewMongo(setting)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := store.(*Mongo)
+	cleanMongo(m)
+	return m
+}
+
+func cleanMongo(m *Mongo) {
+	ctx := context.Background()
+	m.history.DeleteMany(ctx, map[string]interface{}{})
+	m.chat.DeleteMany(ctx, map[string]interface{}{})
+	m.assistant.DeleteMany(ctx, map[string]interface{}{})
+}
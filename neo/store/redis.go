@@ -1,84 +1,842 @@
 package store
 
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/yaoapp/gou/connector"
+	redisConnector "github.com/yaoapp/gou/connector/redis"
+)
+
 // Redis represents a Redis-based conversation storage
-type Redis struct{}
+//
+// Keys (prefixed with Setting.Prefix):
+//   - chat:<uid>:<cid>     the chat information (JSON), head_id is the last message of the active branch
+//   - chats:<uid>          the chat ids of the user, scored by updated_at (ZSET)
+//   - visible:<uid>        the chat ids of the user without the silent chats, scored by updated_at (ZSET)
+//   - history:<uid>:<cid>  the chat history, oldest first (LIST of JSON), capped by Setting.MaxSize
+//   - assistant:<id>       the assistant information (JSON)
+//   - assistants           the assistant ids (SET)
+//
+// The chat, the chat index and the history keys expire after Setting.TTL seconds
+// since the last message was saved.
+type Redis struct {
+	rdb     *redis.Client
+	setting Setting
+}
 
 // NewRedis create a new redis store
-func NewRedis() Store {
-	return &Redis{}
+func NewRedis(setting Setting) (Store, error) {
+	conn, err := connector.Select(setting.Connector)
+	if err != nil {
+		return nil, err
+	}
+
+	rconn, ok := conn.(*redisConnector.Connector)
+	if !ok || rconn.Rdb == nil {
+		return nil, fmt.Errorf("the connector %s is not a redis connector", setting.Connector)
+	}
+
+	return &Redis{rdb: rconn.Rdb, setting: setting}, nil
+}
+
+func (r *Redis) key(parts ...string) string {
+	key := r.setting.Prefix
+	for i, part := range parts {
+		if i > 0 {
+			key += ":"
+		}
+		key += part
+	}
+	return key
+}
+
+func (r *Redis) chatKey(userID string, cid string) string {
+	return r.key("chat", userID, cid)
+}
+
+func (r *Redis) chatsKey(userID string) string {
+	return r.key("chats", userID)
+}
+
+func (r *Redis) visibleKey(userID string) string {
+	return r.key("visible", userID)
+}
+
+func (r *Redis) historyKey(userID string, cid string) string {
+	return r.key("history", userID, cid)
+}
+
+func (r *Redis) assistantKey(assistantID string) string {
+	return r.key("assistant", assistantID)
+}
+
+func (r *Redis) assistantsKey() string {
+	return r.key("assistants")
+}
+
+func (r *Redis) ttl() time.Duration {
+	return time.Duration(r.setting.TTL) * time.Second
+}
+
+// getJSON get the JSON value of the key, returns nil if the key does not exist
+func (r *Redis) getJSON(ctx context.Context, key string) (map[string]interface{}, error) {
+	raw, err := r.rdb.Get(ctx, key).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var data map[string]interface{}
+	err = jsoniter.UnmarshalFromString(raw, &data)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// mgetJSON get the JSON values of the keys, the missing keys are skipped
+func (r *Redis) mgetJSON(ctx context.Context, keys []string) ([]map[string]interface{}, error) {
+	res := []map[string]interface{}{}
+	if len(keys) == 0 {
+		return res, nil
+	}
+
+	values, err := r.rdb.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, value := range values {
+		raw, ok := value.(string)
+		if !ok {
+			continue
+		}
+
+		var data map[string]interface{}
+		err = jsoniter.UnmarshalFromString(raw, &data)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, data)
+	}
+	return res, nil
+}
+
+// UpdateChatTitle updates chat title
+func (r *Redis) UpdateChatTitle(sid string, cid string, title string) error {
+	userID, err := getUserID(r.setting, sid)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	chat, err := r.getJSON(ctx, r.chatKey(userID, cid))
+	if err != nil || chat == nil {
+		return err
+	}
+
+	now := time.Now()
+	chat["title"] = title
+	chat["updated_at"] = now
+	raw, err := jsoniter.MarshalToString(chat)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, r.chatKey(userID, cid), raw, redis.KeepTTL)
+		pipe.ZAdd(ctx, r.chatsKey(userID), &redis.Z{Score: float64(now.UnixNano()), Member: cid})
+		if !toBool(chat["silent"]) {
+			pipe.ZAdd(ctx, r.visibleKey(userID), &redis.Z{Score: float64(now.UnixNano()), Member: cid})
+		}
+		return nil
+	})
+	return err
 }
 
 // GetChats retrieves a list of chats
+// The page is read from the chat index sorted by updated_at. The keywords search scans all the chats
+// of the user, the chats are loaded, matched with the titles and paginated in memory.
 func (r *Redis) GetChats(sid string, filter ChatFilter) (*ChatGroupResponse, error) {
-	return &ChatGroupResponse{}, nil
+	// Default behavior: exclude silent chats
+	if filter.Silent == nil {
+		silentFalse := false
+		filter.Silent = &silentFalse
+	}
+
+	userID, err := getUserID(r.setting, sid)
+	if err != nil {
+		return nil, err
+	}
+
+	// Set default values
+	if filter.Page <= 0 {
+		filter.Page = 1
+	}
+	if filter.PageSize <= 0 {
+		filter.PageSize = 20
+	}
+	if filter.Order == "" {
+		filter.Order = "desc"
+	}
+
+	ctx := context.Background()
+	var page []map[string]interface{}
+	var total int64
+	if filter.Keywords != "" {
+		page, total, err = r.searchChats(ctx, userID, filter)
+	} else {
+		page, total, err = r.pageChats(ctx, userID, filter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Add assistant details
+	err = r.withAssistants(ctx, page)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ChatGroupResponse{
+		Groups:   groupChats(page),
+		Page:     filter.Page,
+		PageSize: filter.PageSize,
+		Total:    total,
+		LastPage: lastPage(total, filter.PageSize),
+	}, nil
+}
+
+// pageChats read the page of the chats from the chat index, the silent chats are excluded by the visible index
+func (r *Redis) pageChats(ctx context.Context, userID string, filter ChatFilter) ([]map[string]interface{}, int64, error) {
+	key := r.chatsKey(userID)
+	if !*filter.Silent {
+		key = r.visibleKey(userID)
+		err := r.indexVisible(ctx, userID)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+
+	start := int64((filter.Page - 1) * filter.PageSize)
+	stop := start + int64(filter.PageSize) - 1
+	for retry := 0; ; retry++ {
+		total, err := r.rdb.ZCard(ctx, key).Result()
+		if err != nil {
+			return nil, 0, err
+		}
+
+		var cids []string
+		if strings.ToLower(filter.Order) == "asc" {
+			cids, err = r.rdb.ZRange(ctx, key, start, stop).Result()
+		} else {
+			cids, err = r.rdb.ZRevRange(ctx, key, start, stop).Result()
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+
+		keys := make([]string, 0, len(cids))
+		for _, cid := range cids {
+			keys = append(keys, r.chatKey(userID, cid))
+		}
+
+		chats, err := r.mgetJSON(ctx, keys)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		if len(chats) == len(cids) || retry >= 2 {
+			return chats, total, nil
+		}
+
+		// Remove the expired chats from the index and read the page again
+		r.cleanChats(ctx, userID, cids, chats)
+	}
+}
+
+// indexVisible build the visible index of the chats saved without it
+func (r *Redis) indexVisible(ctx context.Context, userID string) error {
+	key := r.visibleKey(userID)
+	exists, err := r.rdb.Exists(ctx, key).Result()
+	if err != nil || exists > 0 {
+		return err
+	}
+
+	items, err := r.rdb.ZRangeWithScores(ctx, r.chatsKey(userID), 0, -1).Result()
+	if err != nil || len(items) == 0 {
+		return err
+	}
+
+	scores := map[string]float64{}
+	keys := make([]string, 0, len(items))
+	for _, item := range items {
+		cid := fmt.Sprintf("%v", item.Member)
+		scores[cid] = item.Score
+		keys = append(keys, r.chatKey(userID, cid))
+	}
+
+	chats, err := r.mgetJSON(ctx, keys)
+	if err != nil {
+		return err
+	}
+
+	members := []*redis.Z{}
+	for _, chat := range chats {
+		if toBool(chat["silent"]) {
+			continue
+		}
+		cid := fmt.Sprintf("%v", chat["chat_id"])
+		members = append(members, &redis.Z{Score: scores[cid], Member: cid})
+	}
+
+	if len(members) == 0 {
+		return nil
+	}
+
+	_, err = r.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZAdd(ctx, key, members...)
+		if r.setting.TTL > 0 {
+			pipe.Expire(ctx, key, r.ttl())
+		}
+		return nil
+	})
+	return err
+}
+
+// searchChats match the titles of all the chats of the user with the keywords, and paginate the matched chats
+func (r *Redis) searchChats(ctx context.Context, userID string, filter ChatFilter) ([]map[string]interface{}, int64, error) {
+	cids, err := r.rdb.ZRange(ctx, r.chatsKey(userID), 0, -1).Result()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	keys := make([]string, 0, len(cids))
+	for _, cid := range cids {
+		keys = append(keys, r.chatKey(userID, cid))
+	}
+
+	chats, err := r.mgetJSON(ctx, keys)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Remove the expired chats from the index
+	if len(chats) < len(cids) {
+		r.cleanChats(ctx, userID, cids, chats)
+	}
+
+	matched := []map[string]interface{}{}
+	for _, chat := range chats {
+		if matchChat(chat, filter) {
+			matched = append(matched, chat)
+		}
+	}
+	sortChats(matched, filter.Order)
+
+	offset := (filter.Page - 1) * filter.PageSize
+	page := []map[string]interface{}{}
+	for i := offset; i < len(matched) && i < offset+filter.PageSize; i++ {
+		page = append(page, matched[i])
+	}
+	return page, int64(len(matched)), nil
+}
+
+// cleanChats remove the chat ids which chat information is expired from the index
+func (r *Redis) cleanChats(ctx context.Context, userID string, cids []string, chats []map[string]interface{}) {
+	exists := map[string]bool{}
+	for _, chat := range chats {
+		exists[fmt.Sprintf("%v", chat["chat_id"])] = true
+	}
+
+	members := []interface{}{}
+	for _, cid := range cids {
+		if !exists[cid] {
+			members = append(members, cid)
+		}
+	}
+
+	if len(members) > 0 {
+		r.rdb.ZRem(ctx, r.chatsKey(userID), members...)
+		r.rdb.ZRem(ctx, r.visibleKey(userID), members...)
+	}
+}
+
+// withAssistants add the assistant name and avatar to the chats
+func (r *Redis) withAssistants(ctx context.Context, chats []map[string]interface{}) error {
+	keys := []string{}
+	for _, chat := range chats {
+		if id, ok := chat["assistant_id"].(string); ok && id != "" {
+			keys = append(keys, r.assistantKey(id))
+		}
+	}
+
+	assistants, err := r.mgetJSON(ctx, keys)
+	if err != nil {
+		return err
+	}
+
+	assistantMap := map[string]map[string]interface{}{}
+	for _, assistant := range assistants {
+		assistantMap[fmt.Sprintf("%v", assistant["assistant_id"])] = assistant
+	}
+
+	for _, chat := range chats {
+		if id, ok := chat["assistant_id"].(string); ok && id != "" {
+			if assistant, has := assistantMap[id]; has {
+				chat["assistant_name"] = assistant["name"]
+				chat["assistant_avatar"] = assistant["avatar"]
+			}
+		}
+	}
+	return nil
 }
 
 // GetChat retrieves a single chat's information
 func (r *Redis) GetChat(sid string, cid string) (*ChatInfo, error) {
-	return &ChatInfo{}, nil
+	return r.GetChatWithFilter(sid, cid, ChatFilter{})
 }
 
 // GetChatWithFilter retrieves a single chat's information with filter options
 func (r *Redis) GetChatWithFilter(sid string, cid string, filter ChatFilter) (*ChatInfo, error) {
-	return &ChatInfo{}, nil
+	userID, err := getUserID(r.setting, sid)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	row, err := r.getJSON(ctx, r.chatKey(userID, cid))
+	if err != nil {
+		return nil, err
+	}
+
+	// Return nil if the chat does not exist
+	if row == nil {
+		return nil, nil
+	}
+
+	chat := map[string]interface{}{
+		"chat_id":      row["chat_id"],
+		"title":        row["title"],
+		"assistant_id": row["assistant_id"],
+	}
+
+	err = r.withAssistants(ctx, []map[string]interface{}{chat})
+	if err != nil {
+		return nil, err
+	}
+
+	history, err := r.GetHistoryWithFilter(sid, cid, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ChatInfo{
+		Chat:    chat,
+		History: history,
+	}, nil
 }
 
 // GetHistory retrieves chat history
 func (r *Redis) GetHistory(sid string, cid string) ([]map[string]interface{}, error) {
-	return []map[string]interface{}{}, nil
+	return r.GetHistoryWithFilter(sid, cid, ChatFilter{})
 }
 
 // GetHistoryWithFilter retrieves chat history with filter options
 func (r *Redis) GetHistoryWithFilter(sid string, cid string, filter ChatFilter) ([]map[string]interface{}, error) {
-	return []map[string]interface{}{}, nil
+	userID, err := getUserID(r.setting, sid)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	history, head, err := r.loadHistory(ctx, userID, cid)
+	if err != nil {
+		return nil, err
+	}
+	return selectHistory(history, head, filter, r.setting.MaxSize)
+}
+
+// GetBranches retrieves the branches of the chat
+func (r *Redis) GetBranches(sid string, cid string) ([]Branch, error) {
+	userID, err := getUserID(r.setting, sid)
+	if err != nil {
+		return nil, err
+	}
+
+	history, head, err := r.loadHistory(context.Background(), userID, cid)
+	if err != nil {
+		return nil, err
+	}
+	return historyBranches(history, head), nil
+}
+
+// SwitchBranch switches the active branch of the chat
+func (r *Redis) SwitchBranch(sid string, cid string, messageID string) error {
+	userID, err := getUserID(r.setting, sid)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	chat, err := r.getJSON(ctx, r.chatKey(userID, cid))
+	if err != nil {
+		return err
+	}
+
+	if chat == nil {
+		return fmt.Errorf("chat %s %w", cid, ErrNotFound)
+	}
+
+	history, _, err := r.loadHistory(ctx, userID, cid)
+	if err != nil {
+		return err
+	}
+
+	chat["head_id"], err = branchLeaf(history, messageID)
+	if err != nil {
+		return err
+	}
+
+	raw, err := jsoniter.MarshalToString(chat)
+	if err != nil {
+		return err
+	}
+	return r.rdb.Set(ctx, r.chatKey(userID, cid), raw, redis.KeepTTL).Err()
+}
+
+// loadHistory returns the history of the chat (oldest first) and the head message id
+func (r *Redis) loadHistory(ctx context.Context, userID string, cid string) ([]map[string]interface{}, string, error) {
+	rows, err := r.rdb.LRange(ctx, r.historyKey(userID, cid), 0, -1).Result()
+	if err != nil {
+		return nil, "", err
+	}
+
+	history := make([]map[string]interface{}, 0, len(rows))
+	for _, row := range rows {
+		var message map[string]interface{}
+		err = jsoniter.UnmarshalFromString(row, &message)
+		if err != nil {
+			return nil, "", err
+		}
+		history = append(history, message)
+	}
+
+	chat, err := r.getJSON(ctx, r.chatKey(userID, cid))
+	if err != nil || chat == nil {
+		return history, "", err
+	}
+	return history, toString(chat["head_id"]), nil
 }
 
 // SaveHistory saves chat history
 func (r *Redis) SaveHistory(sid string, messages []map[string]interface{}, cid string, context map[string]interface{}) error {
-	return nil
+	if cid == "" {
+		cid = uuid.New().String() // Generate a new UUID if cid is empty
+	}
+
+	userID, err := getUserID(r.setting, sid)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	silent := getSilent(context)
+	values, err := newHistoryMessages(userID, cid, messages, context, silent, now)
+	if err != nil {
+		return err
+	}
+
+	ctx := r.rdb.Context()
+	chatKey := r.chatKey(userID, cid)
+	chat, err := r.getJSON(ctx, chatKey)
+	if err != nil {
+		return err
+	}
+
+	if chat == nil {
+		chat = map[string]interface{}{
+			"chat_id":    cid,
+			"title":      nil,
+			"created_at": now,
+		}
+	}
+	chat["assistant_id"] = getContextAssistantID(context)
+	chat["silent"] = silent
+	chat["updated_at"] = now
+	chat["head_id"] = chainHistory(values, context, toString(chat["head_id"]), silent)
+
+	chatRaw, err := jsoniter.MarshalToString(chat)
+	if err != nil {
+		return err
+	}
+
+	rows := make([]interface{}, 0, len(values))
+	for _, value := range values {
+		raw, err := jsoniter.MarshalToString(value)
+		if err != nil {
+			return err
+		}
+		rows = append(rows, raw)
+	}
+
+	historyKey := r.historyKey(userID, cid)
+	chatsKey := r.chatsKey(userID)
+	visibleKey := r.visibleKey(userID)
+	_, err = r.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, chatKey, chatRaw, r.ttl())
+		pipe.ZAdd(ctx, chatsKey, &redis.Z{Score: float64(now.UnixNano()), Member: cid})
+		if silent {
+			pipe.ZRem(ctx, visibleKey, cid)
+		} else {
+			pipe.ZAdd(ctx, visibleKey, &redis.Z{Score: float64(now.UnixNano()), Member: cid})
+		}
+		if len(rows) > 0 {
+			pipe.RPush(ctx, historyKey, rows...)
+		}
+
+		// Cap the history length
+		if r.setting.MaxSize > 0 {
+			pipe.LTrim(ctx, historyKey, int64(-r.setting.MaxSize), -1)
+		}
+
+		if r.setting.TTL > 0 {
+			pipe.Expire(ctx, historyKey, r.ttl())
+			pipe.Expire(ctx, chatsKey, r.ttl())
+			pipe.Expire(ctx, visibleKey, r.ttl())
+		}
+		return nil
+	})
+	return err
 }
 
 // DeleteChat deletes a single chat
 func (r *Redis) DeleteChat(sid string, cid string) error {
-	return nil
+	userID, err := getUserID(r.setting, sid)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	_, err = r.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, r.historyKey(userID, cid), r.chatKey(userID, cid))
+		pipe.ZRem(ctx, r.chatsKey(userID), cid)
+		pipe.ZRem(ctx, r.visibleKey(userID), cid)
+		return nil
+	})
+	return err
 }
 
 // DeleteAllChats deletes all chats
 func (r *Redis) DeleteAllChats(sid string) error {
-	return nil
-}
+	userID, err := getUserID(r.setting, sid)
+	if err != nil {
+		return err
+	}
 
-// UpdateChatTitle updates chat title
-func (r *Redis) UpdateChatTitle(sid string, cid string, title string) error {
-	return nil
+	ctx := context.Background()
+	cids, err := r.rdb.ZRange(ctx, r.chatsKey(userID), 0, -1).Result()
+	if err != nil {
+		return err
+	}
+
+	keys := []string{r.chatsKey(userID), r.visibleKey(userID)}
+	for _, cid := range cids {
+		keys = append(keys, r.historyKey(userID, cid), r.chatKey(userID, cid))
+	}
+	return r.rdb.Del(ctx, keys...).Err()
 }
 
 // SaveAssistant saves assistant information
 func (r *Redis) SaveAssistant(assistant map[string]interface{}) (interface{}, error) {
-	return assistant["assistant_id"], nil
+	err := validateAssistant(assistant)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create a copy of the assistant map to avoid modifying the original
+	assistantCopy := copyAssistant(assistant)
+
+	// Generate assistant_id if not provided
+	if _, ok := assistantCopy["assistant_id"]; !ok {
+		assistantCopy["assistant_id"], err = r.GenerateAssistantID()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ctx := context.Background()
+	id := fmt.Sprintf("%v", assistantCopy["assistant_id"])
+	exists, err := r.getJSON(ctx, r.assistantKey(id))
+	if err != nil {
+		return nil, err
+	}
+
+	// Update or insert
+	data := assistantCopy
+	if exists != nil {
+		for key, value := range assistantCopy {
+			exists[key] = value
+		}
+		exists["updated_at"] = time.Now()
+		data = exists
+	} else {
+		defaultAssistant(data)
+		data["created_at"] = time.Now()
+		data["updated_at"] = nil
+	}
+
+	raw, err := jsoniter.MarshalToString(data)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = r.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, r.assistantKey(id), raw, 0)
+		pipe.SAdd(ctx, r.assistantsKey(), id)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return assistantCopy["assistant_id"], nil
 }
 
 // DeleteAssistant deletes an assistant
 func (r *Redis) DeleteAssistant(assistantID string) error {
-	return nil
+	ctx := context.Background()
+	n, err := r.rdb.Exists(ctx, r.assistantKey(assistantID)).Result()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return fmt.Errorf("assistant %s not found", assistantID)
+	}
+
+	_, err = r.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, r.assistantKey(assistantID))
+		pipe.SRem(ctx, r.assistantsKey(), assistantID)
+		return nil
+	})
+	return err
+}
+
+// allAssistants get all the assistants
+func (r *Redis) allAssistants(ctx context.Context) ([]map[string]interface{}, error) {
+	ids, err := r.rdb.SMembers(ctx, r.assistantsKey()).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(ids))
+	for _, id := range ids {
+		keys = append(keys, r.assistantKey(id))
+	}
+	return r.mgetJSON(ctx, keys)
 }
 
 // GetAssistants retrieves a list of assistants
 func (r *Redis) GetAssistants(filter AssistantFilter) (*AssistantResponse, error) {
-	return &AssistantResponse{}, nil
+	assistants, err := r.allAssistants(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	matched := []map[string]interface{}{}
+	for _, assistant := range assistants {
+		if matchAssistant(assistant, filter) {
+			matched = append(matched, assistant)
+		}
+	}
+
+	return paginateAssistants(matched, filter), nil
 }
 
 // GetAssistant retrieves a single assistant by ID
 func (r *Redis) GetAssistant(assistantID string) (map[string]interface{}, error) {
-	return map[string]interface{}{}, nil
+	data, err := r.getJSON(context.Background(), r.assistantKey(assistantID))
+	if err != nil {
+		return nil, err
+	}
+
+	if data == nil {
+		return nil, fmt.Errorf("assistant %s not found", assistantID)
+	}
+
+	return data, nil
 }
 
-// DeleteAssistants deletes assistants based on filter conditions (not implemented)
-func (redis *Redis) DeleteAssistants(filter AssistantFilter) (int64, error) {
-	return 0, nil
+// DeleteAssistants deletes assistants based on filter conditions
+func (r *Redis) DeleteAssistants(filter AssistantFilter) (int64, error) {
+	ctx := context.Background()
+	assistants, err := r.allAssistants(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	keys := []string{}
+	ids := []interface{}{}
+	for _, assistant := range assistants {
+		if matchAssistant(assistant, filter) {
+			id := fmt.Sprintf("%v", assistant["assistant_id"])
+			keys = append(keys, r.assistantKey(id))
+			ids = append(ids, id)
+		}
+	}
+
+	if len(keys) == 0 {
+		return 0, nil
+	}
+
+	_, err = r.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, keys...)
+		pipe.SRem(ctx, r.assistantsKey(), ids...)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(keys)), nil
 }
 
 // GetAssistantTags retrieves all unique tags from assistants
-func (conv *Redis) GetAssistantTags() ([]string, error) {
-	return []string{}, nil
+func (r *Redis) GetAssistantTags() ([]string, error) {
+	assistants, err := r.allAssistants(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return assistantTags(assistants), nil
+}
+
+// GenerateAssistantID generates a random-looking 6-digit ID
+func (r *Redis) GenerateAssistantID() (string, error) {
+	maxAttempts := 10 // Maximum number of attempts to generate a unique ID
+	for i := 0; i < maxAttempts; i++ {
+		timestamp := time.Now().UnixNano()
+		random := (timestamp ^ (timestamp >> 12)) % 1000000
+		hash := fmt.Sprintf("%06d", random)
+
+		n, err := r.rdb.Exists(context.Background(), r.assistantKey(hash)).Result()
+		if err != nil {
+			return "", err
+		}
+
+		if n == 0 {
+			return hash, nil
+		}
+
+		// If ID exists, wait a bit and try again
+		time.Sleep(time.Millisecond)
+	}
+
+	return "", fmt.Errorf("failed to generate unique ID after %d attempts", maxAttempts)
 }
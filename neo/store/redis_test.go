@@ -0,0 +1,151 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yaoapp/yao/config"
+	"github.com/yaoapp/yao/test"
+)
+
+func TestNewRedis(t *testing.T) {
+	test.Prepare(t, config.Conf)
+	defer test.Clean()
+
+	store := prepareRedis(t, Setting{})
+	defer cleanRedis(store)
+
+	assert.NotNil(t, store.rdb)
+
+	_, err := NewRedis(Setting{Connector: "mysql", Prefix: "__unit_test_conversation_"})
+	assert.NotNil(t, err)
+}
+
+func TestRedisKeys(t *testing.T) {
+	test.Prepare(t, config.Conf)
+	defer test.Clean()
+
+	store := prepareRedis(t, Setting{MaxSize: 3, TTL: 60})
+	defer cleanRedis(store)
+
+	sid := "test_user"
+	cid := "test_chat"
+	for i := 0; i < 5; i++ {
+		messages := []map[string]interface{}{
+			{"role": "user", "content": fmt.Sprintf("message %d", i)},
+		}
+		err := store.SaveHistory(sid, messages, cid, nil)
+		assert.Nil(t, err)
+	}
+
+	ctx := context.Background()
+
+	// The history list is capped by MaxSize
+	length, err := store.rdb.LLen(ctx, store.historyKey(sid, cid)).Result()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), length)
+
+	// The chat keys expire after TTL
+	for _, key := range []string{store.historyKey(sid, cid), store.chatKey(sid, cid), store.chatsKey(sid)} {
+		ttl, err := store.rdb.TTL(ctx, key).Result()
+		assert.Nil(t, err)
+		assert.Greater(t, ttl, time.Duration(0), key)
+	}
+
+	// The title update keeps the TTL
+	err = store.UpdateChatTitle(sid, cid, "Title")
+	assert.Nil(t, err)
+	ttl, err := store.rdb.TTL(ctx, store.chatKey(sid, cid)).Result()
+	assert.Nil(t, err)
+	assert.Greater(t, ttl, time.Duration(0))
+
+	// The expired chats are removed from the index
+	err = store.rdb.Del(ctx, store.chatKey(sid, cid)).Err()
+	assert.Nil(t, err)
+	_, err = store.GetChats(sid, ChatFilter{})
+	assert.Nil(t, err)
+	n, err := store.rdb.ZCard(ctx, store.chatsKey(sid)).Result()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), n)
+}
+
+func TestRedisChats(t *testing.T) {
+	test.Prepare(t, config.Conf)
+	defer test.Clean()
+
+	store := prepareRedis(t, Setting{})
+	defer cleanRedis(store)
+
+	sid := "test_user"
+	for i := 0; i < 5; i++ {
+		var context map[string]interface{}
+		if i == 4 {
+			context = map[string]interface{}{"silent": true}
+		}
+		messages := []map[string]interface{}{{"role": "user", "content": fmt.Sprintf("message %d", i)}}
+		err := store.SaveHistory(sid, messages, fmt.Sprintf("chat_%d", i), context)
+		assert.Nil(t, err)
+	}
+
+	ctx := context.Background()
+	n, err := store.rdb.ZCard(ctx, store.visibleKey(sid)).Result()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(4), n)
+
+	// The page is read from the index, the latest first
+	res, err := store.GetChats(sid, ChatFilter{Page: 1, PageSize: 3})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(4), res.Total)
+	assert.Equal(t, 2, res.LastPage)
+	ids := []interface{}{}
+	for _, group := range res.Groups {
+		for _, chat := range group.Chats {
+			ids = append(ids, chat["chat_id"])
+		}
+	}
+	assert.Equal(t, []interface{}{"chat_3", "chat_2", "chat_1"}, ids)
+
+	// The visible index is built for the chats saved without it
+	err = store.rdb.Del(ctx, store.visibleKey(sid)).Err()
+	assert.Nil(t, err)
+	res, err = store.GetChats(sid, ChatFilter{})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(4), res.Total)
+
+	silentTrue := true
+	res, err = store.GetChats(sid, ChatFilter{Silent: &silentTrue})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(5), res.Total)
+
+	// The keywords search scans all the chats
+	err = store.UpdateChatTitle(sid, "chat_2", "Redis Title")
+	assert.Nil(t, err)
+	res, err = store.GetChats(sid, ChatFilter{Keywords: "redis"})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), res.Total)
+}
+
+func prepareRedis(t *testing.T, setting Setting) *Redis {
+	setting.Connector = "redis"
+	setting.Prefix = "__unit_test_conversation_"
+	store, err := NewRedis(setting)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := store.(*Redis)
+	cleanRedis(r)
+	return r
+}
+
+func cleanRedis(r *Redis) {
+	ctx := context.Background()
+	keys, err := r.rdb.Keys(ctx, r.setting.Prefix+"*").Result()
+	if err != nil || len(keys) == 0 {
+		return
+	}
+	r.rdb.Del(ctx, keys...)
+}
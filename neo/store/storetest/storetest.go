@@ -0,0 +1,786 @@
+// Package storetest provides the conformance test suite of the neo store.Store implementations.
+//
+// Usage:
+//
+//	func TestMyStore(t *testing.T) {
+//		storetest.Run(t, func(t *testing.T, setting store.Setting) store.Store {
+//			s, err := NewMyStore(setting)
+//			if err != nil {
+//				t.Fatal(err)
+//			}
+//			return s
+//		})
+//	}
+//
+// The suite resets the data it uses (the chats of the test users and all the assistants)
+// before each case, so the factory should return a store with a dedicated prefix or database.
+package storetest
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yaoapp/yao/neo/store"
+)
+
+// Factory create the store to test with the given setting.
+// The setting only carries MaxSize and TTL, the factory fills the connector, prefix, etc.
+type Factory func(t *testing.T, setting store.Setting) store.Store
+
+// Test users
+const (
+	UserA = "__storetest_user_a"
+	UserB = "__storetest_user_b"
+)
+
+var labels = map[string]bool{"Today": true, "Yesterday": true, "This Week": true, "Last Week": true, "Even Earlier": true}
+
+// Run run the conformance test suite
+func Run(t *testing.T, factory Factory) {
+	cases := []struct {
+		name string
+		fn   func(t *testing.T, factory Factory)
+	}{
+		{"History", testHistory},
+		{"HistoryPagination", testHistoryPagination},
+		{"HistoryMaxSize", testHistoryMaxSize},
+		{"HistorySilent", testHistorySilent},
+		{"HistoryTTL", testHistoryTTL},
+		{"Branches", testBranches},
+		{"Chats", testChats},
+		{"ChatsPagination", testChatsPagination},
+		{"ChatsOrder", testChatsOrder},
+		{"ChatsSilent", testChatsSilent},
+		{"ChatTitle", testChatTitle},
+		{"DeleteChats", testDeleteChats},
+		{"AssistantCRUD", testAssistantCRUD},
+		{"AssistantFilters", testAssistantFilters},
+		{"AssistantPagination", testAssistantPagination},
+		{"AssistantTags", testAssistantTags},
+		{"DeleteAssistants", testDeleteAssistants},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) { c.fn(t, factory) })
+	}
+}
+
+// open create the store and reset the test data
+func open(t *testing.T, factory Factory, setting store.Setting) store.Store {
+	s := factory(t, setting)
+	reset(t, s)
+	t.Cleanup(func() { reset(t, s) })
+	return s
+}
+
+func reset(t *testing.T, s store.Store) {
+	for _, sid := range []string{UserA, UserB} {
+		if err := s.DeleteAllChats(sid); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := s.DeleteAssistants(store.AssistantFilter{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func messages(contents ...string) []map[string]interface{} {
+	res := []map[string]interface{}{}
+	for i, content := range contents {
+		role := "user"
+		if i%2 == 1 {
+			role = "assistant"
+		}
+		res = append(res, map[string]interface{}{"role": role, "name": role + "1", "content": content})
+	}
+	return res
+}
+
+func contents(history []map[string]interface{}) []string {
+	res := []string{}
+	for _, message := range history {
+		res = append(res, fmt.Sprintf("%v", message["content"]))
+	}
+	return res
+}
+
+func total(res *store.ChatGroupResponse) int {
+	n := 0
+	for _, group := range res.Groups {
+		n += len(group.Chats)
+	}
+	return n
+}
+
+func chatIDs(res *store.ChatGroupResponse) []string {
+	ids := []string{}
+	for _, group := range res.Groups {
+		for _, chat := range group.Chats {
+			ids = append(ids, fmt.Sprintf("%v", chat["chat_id"]))
+		}
+	}
+	return ids
+}
+
+func toBool(v interface{}) bool {
+	switch value := v.(type) {
+	case bool:
+		return value
+	case int:
+		return value != 0
+	case int32:
+		return value != 0
+	case int64:
+		return value != 0
+	case float64:
+		return value != 0
+	case string:
+		return value == "true" || value == "1"
+	}
+	return false
+}
+
+func testHistory(t *testing.T, factory Factory) {
+	s := open(t, factory, store.Setting{})
+
+	err := s.SaveHistory(UserA, messages("hello", "Hi! How can I help you?"), "chat_1", map[string]interface{}{"assistant_id": "assistant-1"})
+	assert.Nil(t, err)
+
+	err = s.SaveHistory(UserA, messages("bye"), "chat_1", map[string]interface{}{"assistant_id": "assistant-1"})
+	assert.Nil(t, err)
+
+	history, err := s.GetHistory(UserA, "chat_1")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"hello", "Hi! How can I help you?", "bye"}, contents(history))
+	assert.Equal(t, "user", history[0]["role"])
+	assert.Equal(t, "user1", history[0]["name"])
+	assert.Equal(t, "assistant", history[1]["role"])
+
+	// The history belongs to the user
+	history, err = s.GetHistory(UserB, "chat_1")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(history))
+
+	// Unknown chat
+	history, err = s.GetHistory(UserA, "chat_not_exists")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(history))
+
+	// Invalid messages
+	err = s.SaveHistory(UserA, []map[string]interface{}{{"role": 1, "content": "hello"}}, "chat_1", nil)
+	assert.NotNil(t, err)
+	err = s.SaveHistory(UserA, []map[string]interface{}{{"role": "user", "content": 1}}, "chat_1", nil)
+	assert.NotNil(t, err)
+}
+
+func testHistoryPagination(t *testing.T, factory Factory) {
+	s := open(t, factory, store.Setting{})
+
+	for i := 0; i < 5; i++ {
+		err := s.SaveHistory(UserA, messages(fmt.Sprintf("message %d", i)), "chat_1", nil)
+		assert.Nil(t, err)
+	}
+
+	// Pages are counted from the latest message, each page is in chronological order
+	history, err := s.GetHistoryWithFilter(UserA, "chat_1", store.ChatFilter{Page: 1, PageSize: 2})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"message 3", "message 4"}, contents(history))
+
+	history, err = s.GetHistoryWithFilter(UserA, "chat_1", store.ChatFilter{Page: 3, PageSize: 2})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"message 0"}, contents(history))
+
+	history, err = s.GetHistoryWithFilter(UserA, "chat_1", store.ChatFilter{Page: 4, PageSize: 2})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(history))
+}
+
+func testHistoryMaxSize(t *testing.T, factory Factory) {
+	s := open(t, factory, store.Setting{MaxSize: 3})
+
+	for i := 0; i < 5; i++ {
+		err := s.SaveHistory(UserA, messages(fmt.Sprintf("message %d", i)), "chat_1", nil)
+		assert.Nil(t, err)
+	}
+
+	history, err := s.GetHistory(UserA, "chat_1")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"message 2", "message 3", "message 4"}, contents(history))
+}
+
+func testHistorySilent(t *testing.T, factory Factory) {
+	s := open(t, factory, store.Setting{})
+
+	err := s.SaveHistory(UserA, messages("hello", "hi"), "chat_1", map[string]interface{}{"assistant_id": "assistant-1"})
+	assert.Nil(t, err)
+
+	err = s.SaveHistory(UserA, messages("silent message", "silent response"), "chat_1", map[string]interface{}{"assistant_id": "assistant-1", "silent": true})
+	assert.Nil(t, err)
+
+	// Silent messages are excluded by default
+	history, err := s.GetHistory(UserA, "chat_1")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"hello", "hi"}, contents(history))
+	for _, message := range history {
+		assert.False(t, toBool(message["silent"]))
+	}
+
+	silentFalse := false
+	history, err = s.GetHistoryWithFilter(UserA, "chat_1", store.ChatFilter{Silent: &silentFalse})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(history))
+
+	silentTrue := true
+	history, err = s.GetHistoryWithFilter(UserA, "chat_1", store.ChatFilter{Silent: &silentTrue})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"hello", "hi", "silent message", "silent response"}, contents(history))
+	assert.True(t, toBool(history[3]["silent"]))
+
+	chat, err := s.GetChatWithFilter(UserA, "chat_1", store.ChatFilter{Silent: &silentTrue})
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(chat.History))
+
+	chat, err = s.GetChat(UserA, "chat_1")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(chat.History))
+}
+
+func testHistoryTTL(t *testing.T, factory Factory) {
+	s := open(t, factory, store.Setting{TTL: 1})
+
+	err := s.SaveHistory(UserA, messages("hello"), "chat_1", nil)
+	assert.Nil(t, err)
+
+	history, err := s.GetHistory(UserA, "chat_1")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(history))
+
+	time.Sleep(2 * time.Second)
+
+	history, err = s.GetHistory(UserA, "chat_1")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(history))
+}
+
+func testBranches(t *testing.T, factory Factory) {
+	s := open(t, factory, store.Setting{})
+
+	err := s.SaveHistory(UserA, messages("hello", "hi"), "chat_1", nil)
+	assert.Nil(t, err)
+	err = s.SaveHistory(UserA, messages("how are you", "fine"), "chat_1", nil)
+	assert.Nil(t, err)
+
+	original, err := s.GetHistory(UserA, "chat_1")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"hello", "hi", "how are you", "fine"}, contents(original))
+	for i := 1; i < len(original); i++ {
+		assert.NotEmpty(t, original[i]["message_id"])
+		assert.Equal(t, original[i-1]["message_id"], original[i]["parent_id"])
+	}
+
+	// Edit the second user message, the new messages are a branch of the same parent
+	err = s.SaveHistory(UserA, messages("how old are you", "I am new"), "chat_1", map[string]interface{}{"parent_id": original[1]["message_id"]})
+	assert.Nil(t, err)
+
+	history, err := s.GetHistory(UserA, "chat_1")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"hello", "hi", "how old are you", "I am new"}, contents(history))
+
+	branches, err := s.GetBranches(UserA, "chat_1")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(branches))
+	assert.Equal(t, original[3]["message_id"], branches[0].ID)
+	assert.False(t, branches[0].Active)
+	assert.Equal(t, "how are you", branches[0].Preview)
+	assert.Equal(t, history[3]["message_id"], branches[1].ID)
+	assert.True(t, branches[1].Active)
+	assert.Equal(t, 4, branches[1].Size)
+
+	// The silent messages belong to the branch of their parent
+	err = s.SaveHistory(UserA, messages("silent"), "chat_1", map[string]interface{}{"silent": true})
+	assert.Nil(t, err)
+
+	silentTrue := true
+	history, err = s.GetHistoryWithFilter(UserA, "chat_1", store.ChatFilter{Silent: &silentTrue})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"hello", "hi", "how old are you", "I am new", "silent"}, contents(history))
+
+	// Switch to the original branch by any message on it
+	err = s.SwitchBranch(UserA, "chat_1", fmt.Sprintf("%v", original[2]["message_id"]))
+	assert.Nil(t, err)
+
+	history, err = s.GetHistoryWithFilter(UserA, "chat_1", store.ChatFilter{Silent: &silentTrue})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"hello", "hi", "how are you", "fine"}, contents(history))
+
+	// Continue the active branch
+	err = s.SaveHistory(UserA, messages("bye"), "chat_1", nil)
+	assert.Nil(t, err)
+	history, err = s.GetHistory(UserA, "chat_1")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"hello", "hi", "how are you", "fine", "bye"}, contents(history))
+
+	// The branch ends at the given message
+	history, err = s.GetHistoryWithFilter(UserA, "chat_1", store.ChatFilter{Branch: fmt.Sprintf("%v", original[1]["message_id"])})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"hello", "hi"}, contents(history))
+
+	history, err = s.GetHistoryWithFilter(UserA, "chat_1", store.ChatFilter{Branch: store.BranchRoot})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(history))
+
+	_, err = s.GetHistoryWithFilter(UserA, "chat_1", store.ChatFilter{Branch: "message_not_exists"})
+	assert.ErrorIs(t, err, store.ErrNotFound)
+
+	// Start over from the beginning
+	err = s.SaveHistory(UserA, messages("new topic", "sure"), "chat_1", map[string]interface{}{"parent_id": store.BranchRoot})
+	assert.Nil(t, err)
+
+	history, err = s.GetHistory(UserA, "chat_1")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"new topic", "sure"}, contents(history))
+
+	branches, err = s.GetBranches(UserA, "chat_1")
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(branches))
+	assert.True(t, branches[2].Active)
+
+	err = s.SwitchBranch(UserA, "chat_1", "message_not_exists")
+	assert.ErrorIs(t, err, store.ErrNotFound)
+
+	err = s.SwitchBranch(UserA, "chat_not_exists", fmt.Sprintf("%v", original[2]["message_id"]))
+	assert.ErrorIs(t, err, store.ErrNotFound)
+
+	// The branches belong to the user
+	branches, err = s.GetBranches(UserB, "chat_1")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(branches))
+}
+
+func testChats(t *testing.T, factory Factory) {
+	s := open(t, factory, store.Setting{})
+
+	_, err := s.SaveAssistant(map[string]interface{}{
+		"assistant_id": "assistant-1",
+		"name":         "Assistant 1",
+		"avatar":       "avatar1.png",
+		"type":         "assistant",
+		"connector":    "test",
+	})
+	assert.Nil(t, err)
+
+	for i := 0; i < 3; i++ {
+		var context map[string]interface{}
+		if i%2 == 0 {
+			context = map[string]interface{}{"assistant_id": "assistant-1"}
+		}
+		err = s.SaveHistory(UserA, messages("hello"), fmt.Sprintf("chat_%d", i), context)
+		assert.Nil(t, err)
+		err = s.UpdateChatTitle(UserA, fmt.Sprintf("chat_%d", i), fmt.Sprintf("Chat Title %d", i))
+		assert.Nil(t, err)
+	}
+
+	err = s.SaveHistory(UserB, messages("hello"), "chat_b", nil)
+	assert.Nil(t, err)
+
+	res, err := s.GetChats(UserA, store.ChatFilter{})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), res.Total)
+	assert.Equal(t, 3, total(res))
+	assert.Equal(t, 1, res.Page)
+	assert.Equal(t, 20, res.PageSize)
+	assert.Equal(t, 1, res.LastPage)
+	assert.ElementsMatch(t, []string{"chat_0", "chat_1", "chat_2"}, chatIDs(res))
+
+	// Grouped by date with the assistant details
+	for _, group := range res.Groups {
+		assert.True(t, labels[group.Label], group.Label)
+		for _, chat := range group.Chats {
+			if chat["chat_id"] == "chat_1" {
+				assert.Nil(t, chat["assistant_name"])
+				continue
+			}
+			assert.Equal(t, "assistant-1", chat["assistant_id"])
+			assert.Equal(t, "Assistant 1", chat["assistant_name"])
+			assert.Equal(t, "avatar1.png", chat["assistant_avatar"])
+		}
+	}
+
+	// Keywords
+	res, err = s.GetChats(UserA, store.ChatFilter{Keywords: "Title 2"})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), res.Total)
+	assert.Equal(t, []string{"chat_2"}, chatIDs(res))
+
+	res, err = s.GetChats(UserA, store.ChatFilter{Keywords: "not exists"})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), res.Total)
+	assert.Equal(t, 0, len(res.Groups))
+
+	// Chat details
+	chat, err := s.GetChat(UserA, "chat_0")
+	assert.Nil(t, err)
+	assert.Equal(t, "chat_0", chat.Chat["chat_id"])
+	assert.Equal(t, "Chat Title 0", chat.Chat["title"])
+	assert.Equal(t, "assistant-1", chat.Chat["assistant_id"])
+	assert.Equal(t, "Assistant 1", chat.Chat["assistant_name"])
+	assert.Equal(t, 1, len(chat.History))
+
+	chat, err = s.GetChat(UserA, "chat_not_exists")
+	assert.Nil(t, err)
+	assert.Nil(t, chat)
+
+	chat, err = s.GetChat(UserA, "chat_b")
+	assert.Nil(t, err)
+	assert.Nil(t, chat)
+}
+
+func testChatsPagination(t *testing.T, factory Factory) {
+	s := open(t, factory, store.Setting{})
+
+	for i := 0; i < 5; i++ {
+		err := s.SaveHistory(UserA, messages("hello"), fmt.Sprintf("chat_%d", i), nil)
+		assert.Nil(t, err)
+	}
+
+	ids := []string{}
+	for page := 1; page <= 3; page++ {
+		res, err := s.GetChats(UserA, store.ChatFilter{Page: page, PageSize: 2})
+		assert.Nil(t, err)
+		assert.Equal(t, int64(5), res.Total)
+		assert.Equal(t, page, res.Page)
+		assert.Equal(t, 2, res.PageSize)
+		assert.Equal(t, 3, res.LastPage)
+		ids = append(ids, chatIDs(res)...)
+	}
+	assert.ElementsMatch(t, []string{"chat_0", "chat_1", "chat_2", "chat_3", "chat_4"}, ids)
+
+	res, err := s.GetChats(UserA, store.ChatFilter{Page: 4, PageSize: 2})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, total(res))
+
+	// Empty result
+	res, err = s.GetChats(UserB, store.ChatFilter{})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), res.Total)
+	assert.Equal(t, 1, res.LastPage)
+}
+
+func testChatsOrder(t *testing.T, factory Factory) {
+	s := open(t, factory, store.Setting{})
+
+	err := s.SaveHistory(UserA, messages("hello"), "chat_old", nil)
+	assert.Nil(t, err)
+
+	// Some stores keep the datetime in seconds
+	time.Sleep(1100 * time.Millisecond)
+
+	err = s.SaveHistory(UserA, messages("hello"), "chat_new", nil)
+	assert.Nil(t, err)
+
+	res, err := s.GetChats(UserA, store.ChatFilter{})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"chat_new", "chat_old"}, chatIDs(res))
+
+	res, err = s.GetChats(UserA, store.ChatFilter{Order: "asc"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"chat_old", "chat_new"}, chatIDs(res))
+}
+
+func testChatsSilent(t *testing.T, factory Factory) {
+	s := open(t, factory, store.Setting{})
+
+	err := s.SaveHistory(UserA, messages("hello"), "chat_normal", nil)
+	assert.Nil(t, err)
+	err = s.SaveHistory(UserA, messages("hello"), "chat_silent", map[string]interface{}{"silent": true})
+	assert.Nil(t, err)
+
+	res, err := s.GetChats(UserA, store.ChatFilter{})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), res.Total)
+	assert.Equal(t, []string{"chat_normal"}, chatIDs(res))
+
+	silentFalse := false
+	res, err = s.GetChats(UserA, store.ChatFilter{Silent: &silentFalse})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), res.Total)
+
+	silentTrue := true
+	res, err = s.GetChats(UserA, store.ChatFilter{Silent: &silentTrue})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), res.Total)
+	assert.ElementsMatch(t, []string{"chat_normal", "chat_silent"}, chatIDs(res))
+}
+
+func testChatTitle(t *testing.T, factory Factory) {
+	s := open(t, factory, store.Setting{})
+
+	err := s.SaveHistory(UserA, messages("hello"), "chat_1", nil)
+	assert.Nil(t, err)
+
+	chat, err := s.GetChat(UserA, "chat_1")
+	assert.Nil(t, err)
+	assert.Nil(t, chat.Chat["title"])
+
+	err = s.UpdateChatTitle(UserA, "chat_1", "First Title")
+	assert.Nil(t, err)
+	err = s.UpdateChatTitle(UserA, "chat_1", "Second Title")
+	assert.Nil(t, err)
+
+	chat, err = s.GetChat(UserA, "chat_1")
+	assert.Nil(t, err)
+	assert.Equal(t, "Second Title", chat.Chat["title"])
+
+	// Other users can not update the title
+	err = s.UpdateChatTitle(UserB, "chat_1", "Other Title")
+	assert.Nil(t, err)
+	chat, err = s.GetChat(UserA, "chat_1")
+	assert.Nil(t, err)
+	assert.Equal(t, "Second Title", chat.Chat["title"])
+
+	// Unknown chat
+	err = s.UpdateChatTitle(UserA, "chat_not_exists", "Title")
+	assert.Nil(t, err)
+}
+
+func testDeleteChats(t *testing.T, factory Factory) {
+	s := open(t, factory, store.Setting{})
+
+	for i := 0; i < 3; i++ {
+		err := s.SaveHistory(UserA, messages("hello"), fmt.Sprintf("chat_%d", i), nil)
+		assert.Nil(t, err)
+	}
+	err := s.SaveHistory(UserB, messages("hello"), "chat_b", nil)
+	assert.Nil(t, err)
+
+	err = s.DeleteChat(UserA, "chat_0")
+	assert.Nil(t, err)
+
+	chat, err := s.GetChat(UserA, "chat_0")
+	assert.Nil(t, err)
+	assert.Nil(t, chat)
+
+	history, err := s.GetHistory(UserA, "chat_0")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(history))
+
+	res, err := s.GetChats(UserA, store.ChatFilter{})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), res.Total)
+
+	err = s.DeleteAllChats(UserA)
+	assert.Nil(t, err)
+
+	res, err = s.GetChats(UserA, store.ChatFilter{})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), res.Total)
+
+	// The other users' chats are kept
+	res, err = s.GetChats(UserB, store.ChatFilter{})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), res.Total)
+}
+
+func testAssistantCRUD(t *testing.T, factory Factory) {
+	s := open(t, factory, store.Setting{})
+
+	// Required fields
+	_, err := s.SaveAssistant(map[string]interface{}{"type": "assistant", "connector": "test"})
+	assert.NotNil(t, err)
+	_, err = s.SaveAssistant(map[string]interface{}{"name": "", "type": "assistant", "connector": "test"})
+	assert.NotNil(t, err)
+
+	// Generated ID
+	assistant := map[string]interface{}{
+		"name":        "Test Assistant",
+		"type":        "assistant",
+		"connector":   "test",
+		"description": "Test Description",
+		"tags":        []string{"tag1", "tag2"},
+		"options":     map[string]interface{}{"temperature": 0.5},
+		"prompts":     `[{"role":"system","content":"You are a helpful assistant"}]`,
+	}
+	id, err := s.SaveAssistant(assistant)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, id)
+	assert.Nil(t, assistant["assistant_id"], "the input should not be modified")
+
+	// JSON fields are parsed
+	data, err := s.GetAssistant(fmt.Sprintf("%v", id))
+	assert.Nil(t, err)
+	assert.Equal(t, "Test Assistant", data["name"])
+	assert.Equal(t, "Test Description", data["description"])
+	assert.Equal(t, []interface{}{"tag1", "tag2"}, data["tags"])
+	assert.Equal(t, map[string]interface{}{"temperature": 0.5}, data["options"])
+	assert.Equal(t, []interface{}{map[string]interface{}{"role": "system", "content": "You are a helpful assistant"}}, data["prompts"])
+	assert.True(t, toBool(data["mentionable"]))
+	assert.True(t, toBool(data["automated"]))
+	assert.False(t, toBool(data["built_in"]))
+
+	// Update
+	_, err = s.SaveAssistant(map[string]interface{}{
+		"assistant_id": id,
+		"name":         "Updated Assistant",
+		"type":         "assistant",
+		"connector":    "test",
+		"mentionable":  false,
+	})
+	assert.Nil(t, err)
+
+	data, err = s.GetAssistant(fmt.Sprintf("%v", id))
+	assert.Nil(t, err)
+	assert.Equal(t, "Updated Assistant", data["name"])
+	assert.Equal(t, "Test Description", data["description"])
+	assert.False(t, toBool(data["mentionable"]))
+
+	// Delete
+	err = s.DeleteAssistant(fmt.Sprintf("%v", id))
+	assert.Nil(t, err)
+
+	_, err = s.GetAssistant(fmt.Sprintf("%v", id))
+	assert.NotNil(t, err)
+
+	err = s.DeleteAssistant(fmt.Sprintf("%v", id))
+	assert.NotNil(t, err)
+}
+
+// saveAssistants save the assistants for the filter tests
+func saveAssistants(t *testing.T, s store.Store) {
+	assistants := []map[string]interface{}{
+		{"assistant_id": "assistant-1", "name": "Writer", "description": "Write articles", "type": "assistant", "connector": "openai", "tags": []string{"writing", "general"}, "sort": 1, "mentionable": true, "automated": true, "built_in": true},
+		{"assistant_id": "assistant-2", "name": "Coder", "description": "Write code", "type": "assistant", "connector": "openai", "tags": []string{"coding"}, "sort": 2, "mentionable": false, "automated": true, "built_in": false},
+		{"assistant_id": "assistant-3", "name": "Translator", "description": "Translate text", "type": "assistant", "connector": "moapi", "tags": []string{"general"}, "sort": 3, "mentionable": true, "automated": false, "built_in": false},
+		{"assistant_id": "bot-1", "name": "Bot", "description": "A bot", "type": "bot", "connector": "moapi", "tags": []string{"bot"}, "sort": 4, "mentionable": false, "automated": false, "built_in": true},
+	}
+
+	for _, assistant := range assistants {
+		_, err := s.SaveAssistant(assistant)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func assistantIDs(res *store.AssistantResponse) []string {
+	ids := []string{}
+	for _, assistant := range res.Data {
+		ids = append(ids, fmt.Sprintf("%v", assistant["assistant_id"]))
+	}
+	return ids
+}
+
+func testAssistantFilters(t *testing.T, factory Factory) {
+	s := open(t, factory, store.Setting{})
+	saveAssistants(t, s)
+
+	yes := true
+	no := false
+	cases := []struct {
+		name   string
+		filter store.AssistantFilter
+		ids    []string
+	}{
+		{"All", store.AssistantFilter{}, []string{"assistant-1", "assistant-2", "assistant-3", "bot-1"}},
+		{"Tags", store.AssistantFilter{Tags: []string{"general"}}, []string{"assistant-1", "assistant-3"}},
+		{"AnyTags", store.AssistantFilter{Tags: []string{"coding", "bot"}}, []string{"assistant-2", "bot-1"}},
+		{"KeywordsName", store.AssistantFilter{Keywords: "Coder"}, []string{"assistant-2"}},
+		{"KeywordsDescription", store.AssistantFilter{Keywords: "Write"}, []string{"assistant-1", "assistant-2"}},
+		{"Type", store.AssistantFilter{Type: "bot"}, []string{"bot-1"}},
+		{"Connector", store.AssistantFilter{Connector: "moapi"}, []string{"assistant-3", "bot-1"}},
+		{"AssistantID", store.AssistantFilter{AssistantID: "assistant-2"}, []string{"assistant-2"}},
+		{"AssistantIDs", store.AssistantFilter{AssistantIDs: []string{"assistant-1", "bot-1"}}, []string{"assistant-1", "bot-1"}},
+		{"Mentionable", store.AssistantFilter{Mentionable: &yes}, []string{"assistant-1", "assistant-3"}},
+		{"Automated", store.AssistantFilter{Automated: &no}, []string{"assistant-3", "bot-1"}},
+		{"BuiltIn", store.AssistantFilter{BuiltIn: &yes}, []string{"assistant-1", "bot-1"}},
+		{"Combined", store.AssistantFilter{Type: "assistant", Tags: []string{"general"}, Mentionable: &yes, Connector: "openai"}, []string{"assistant-1"}},
+		{"NotFound", store.AssistantFilter{Keywords: "not exists"}, []string{}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			res, err := s.GetAssistants(c.filter)
+			assert.Nil(t, err)
+			assert.Equal(t, int64(len(c.ids)), res.Total)
+			assert.Equal(t, c.ids, assistantIDs(res), "ordered by sort")
+		})
+	}
+
+	// Select fields
+	res, err := s.GetAssistants(store.AssistantFilter{AssistantID: "assistant-1", Select: []string{"assistant_id", "name", "tags"}})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(res.Data))
+	assert.Equal(t, "Writer", res.Data[0]["name"])
+	assert.Equal(t, []interface{}{"writing", "general"}, res.Data[0]["tags"])
+	assert.Nil(t, res.Data[0]["description"])
+}
+
+func testAssistantPagination(t *testing.T, factory Factory) {
+	s := open(t, factory, store.Setting{})
+	saveAssistants(t, s)
+
+	res, err := s.GetAssistants(store.AssistantFilter{Page: 1, PageSize: 3})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(4), res.Total)
+	assert.Equal(t, 1, res.Page)
+	assert.Equal(t, 3, res.PageSize)
+	assert.Equal(t, 2, res.PageCnt)
+	assert.Equal(t, 2, res.Next)
+	assert.Equal(t, 0, res.Prev)
+	assert.Equal(t, []string{"assistant-1", "assistant-2", "assistant-3"}, assistantIDs(res))
+
+	res, err = s.GetAssistants(store.AssistantFilter{Page: 2, PageSize: 3})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, res.Page)
+	assert.Equal(t, 0, res.Next)
+	assert.Equal(t, 1, res.Prev)
+	assert.Equal(t, []string{"bot-1"}, assistantIDs(res))
+
+	res, err = s.GetAssistants(store.AssistantFilter{Page: 3, PageSize: 3})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(res.Data))
+
+	// Defaults
+	res, err = s.GetAssistants(store.AssistantFilter{})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, res.Page)
+	assert.Equal(t, 20, res.PageSize)
+	assert.Equal(t, 1, res.PageCnt)
+}
+
+func testAssistantTags(t *testing.T, factory Factory) {
+	s := open(t, factory, store.Setting{})
+
+	tags, err := s.GetAssistantTags()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(tags))
+
+	saveAssistants(t, s)
+
+	// Only the tags of type assistant
+	tags, err = s.GetAssistantTags()
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []string{"writing", "general", "coding"}, tags)
+}
+
+func testDeleteAssistants(t *testing.T, factory Factory) {
+	s := open(t, factory, store.Setting{})
+	saveAssistants(t, s)
+
+	count, err := s.DeleteAssistants(store.AssistantFilter{Tags: []string{"general"}})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), count)
+
+	count, err = s.DeleteAssistants(store.AssistantFilter{AssistantIDs: []string{"assistant-2", "not-exists"}})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), count)
+
+	count, err = s.DeleteAssistants(store.AssistantFilter{Connector: "not-exists"})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), count)
+
+	res, err := s.GetAssistants(store.AssistantFilter{})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"bot-1"}, assistantIDs(res))
+}
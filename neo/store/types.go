@@ -25,6 +25,18 @@ type ChatFilter struct {
 	PageSize int    `json:"pagesize,omitempty"` // Number of items per page
 	Order    string `json:"order,omitempty"`    // Sort order: desc/asc
 	Silent   *bool  `json:"silent,omitempty"`   // Include silent messages (default: false)
+	Branch   string `json:"branch,omitempty"`   // The message id the history ends at (default: the active branch)
+}
+
+// Branch represents a branch of the chat
+// A branch is the path from the first message to a leaf message, the messages form a tree by parent_id.
+// Editing a user message or regenerating a reply saves the new messages with the parent of the original one.
+type Branch struct {
+	ID        string      `json:"branch_id"`  // The leaf message id
+	Active    bool        `json:"active"`     // Whether the branch is the active one
+	Size      int         `json:"size"`       // Number of the messages on the branch (silent messages excluded)
+	Preview   string      `json:"preview"`    // The last user message on the branch
+	CreatedAt interface{} `json:"created_at"` // The time the leaf message was created
 }
 
 // ChatGroup represents the chat group structure
@@ -116,6 +128,19 @@ type Store interface {
 	// Returns: Potential error
 	SaveHistory(sid string, messages []map[string]interface{}, cid string, context map[string]interface{}) error
 
+	// GetBranches retrieves the branches of the chat
+	// sid: Session ID
+	// cid: Chat ID
+	// Returns: Branch list and potential error
+	GetBranches(sid string, cid string) ([]Branch, error)
+
+	// SwitchBranch switches the active branch of the chat
+	// sid: Session ID
+	// cid: Chat ID
+	// messageID: Any message on the branch, the latest leaf message descending from it becomes the head
+	// Returns: Potential error
+	SwitchBranch(sid string, cid string, messageID string) error
+
 	// DeleteChat deletes a single chat
 	// sid: Session ID
 	// cid: Chat ID
@@ -0,0 +1,606 @@
+package store
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/yaoapp/gou/session"
+)
+
+// assistantJSONFields the assistant fields stored as JSON
+var assistantJSONFields = []string{"tags", "options", "prompts", "flows", "files", "tools", "permissions", "placeholder"}
+
+// assistantRequiredFields the assistant fields required when saving
+var assistantRequiredFields = []string{"name", "type", "connector"}
+
+// chatGroupLabels the chat group labels in display order
+var chatGroupLabels = []string{"Today", "Yesterday", "This Week", "Last Week", "Even Earlier"}
+
+// getUserID get the user id from the session, falls back to the session id
+func getUserID(setting Setting, sid string) (string, error) {
+	field := "user_id"
+	if setting.UserField != "" {
+		field = setting.UserField
+	}
+
+	id, err := session.Global().ID(sid).Get(field)
+	if err != nil {
+		return "", err
+	}
+
+	if id == nil || id == "" {
+		return sid, nil
+	}
+
+	return fmt.Sprintf("%v", id), nil
+}
+
+// getSilent get the silent flag from the chat context
+func getSilent(context map[string]interface{}) bool {
+	if context == nil {
+		return false
+	}
+
+	switch v := context["silent"].(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true" || v == "1" || v == "yes"
+	case int:
+		return v != 0
+	case float64:
+		return v != 0
+	}
+	return false
+}
+
+// getContextAssistantID get the assistant id from the chat context
+func getContextAssistantID(context map[string]interface{}) interface{} {
+	if context == nil {
+		return nil
+	}
+	if id, ok := context["assistant_id"].(string); ok && id != "" {
+		return id
+	}
+	return nil
+}
+
+// newHistoryMessages validate the messages and convert them to history records
+func newHistoryMessages(userID string, cid string, messages []map[string]interface{}, context map[string]interface{}, silent bool, now time.Time) ([]map[string]interface{}, error) {
+	var contextRaw interface{} = nil
+	if context != nil {
+		raw, err := jsoniter.MarshalToString(context)
+		if err != nil {
+			return nil, err
+		}
+		contextRaw = raw
+	}
+
+	values := []map[string]interface{}{}
+	for _, message := range messages {
+		role, ok := message["role"].(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid role type in message: %v", message["role"])
+		}
+
+		content, ok := message["content"].(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid content type in message: %v", message["content"])
+		}
+
+		var mentionsRaw interface{} = nil
+		if mentions, ok := message["mentions"].([]interface{}); ok && len(mentions) > 0 {
+			raw, err := jsoniter.MarshalToString(mentions)
+			if err != nil {
+				return nil, err
+			}
+			mentionsRaw = raw
+		}
+
+		value := map[string]interface{}{
+			"role":             role,
+			"name":             "",
+			"content":          content,
+			"context":          contextRaw,
+			"assistant_id":     nil,
+			"assistant_name":   nil,
+			"assistant_avatar": nil,
+			"mentions":         mentionsRaw,
+			"uid":              userID,
+			"silent":           silent,
+			"created_at":       now,
+			"updated_at":       nil,
+		}
+
+		if name, ok := message["name"].(string); ok {
+			value["name"] = name
+		}
+		if assistantID, ok := message["assistant_id"].(string); ok {
+			value["assistant_id"] = assistantID
+		}
+		if assistantName, ok := message["assistant_name"].(string); ok {
+			value["assistant_name"] = assistantName
+		}
+		if assistantAvatar, ok := message["assistant_avatar"].(string); ok {
+			value["assistant_avatar"] = assistantAvatar
+		}
+		if createdAt, ok := ParseTime(message["created_at"]); ok {
+			value["created_at"] = createdAt
+		}
+		if messageID, ok := message["message_id"].(string); ok && messageID != "" {
+			value["message_id"] = messageID
+		}
+		if parentID, ok := message["parent_id"].(string); ok && parentID != "" {
+			value["parent_id"] = parentID
+		}
+
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// filterHistory apply the silent filter and the pagination to the history (oldest first)
+func filterHistory(history []map[string]interface{}, filter ChatFilter, maxSize int) []map[string]interface{} {
+	includeSilent := filter.Silent != nil && *filter.Silent
+	filtered := []map[string]interface{}{}
+	for _, message := range history {
+		if !includeSilent && toBool(message["silent"]) {
+			continue
+		}
+		filtered = append(filtered, message)
+	}
+
+	limit, offset := historyPage(filter, maxSize)
+
+	// Pages are counted from the latest message
+	end := len(filtered) - offset
+	if end <= 0 {
+		return []map[string]interface{}{}
+	}
+	start := end - limit
+	if start < 0 {
+		start = 0
+	}
+
+	res := make([]map[string]interface{}, 0, end-start)
+	res = append(res, filtered[start:end]...)
+	return res
+}
+
+// historyPage the limit and the offset of the history page, counted from the latest message
+func historyPage(filter ChatFilter, maxSize int) (int, int) {
+	limit := 20
+	if maxSize > 0 {
+		limit = maxSize
+	}
+	if filter.PageSize > 0 {
+		limit = filter.PageSize
+	}
+
+	offset := 0
+	if filter.Page > 0 {
+		offset = (filter.Page - 1) * limit
+	}
+	return limit, offset
+}
+
+// matchChat check if the chat matches the filter
+func matchChat(chat map[string]interface{}, filter ChatFilter) bool {
+	if filter.Silent != nil && !*filter.Silent && toBool(chat["silent"]) {
+		return false
+	}
+
+	if filter.Keywords != "" {
+		title, _ := chat["title"].(string)
+		if !containsFold(title, filter.Keywords) {
+			return false
+		}
+	}
+	return true
+}
+
+// sortChats sort the chats by updated_at
+func sortChats(chats []map[string]interface{}, order string) {
+	sort.SliceStable(chats, func(i, j int) bool {
+		ti, _ := ParseTime(chats[i]["updated_at"])
+		tj, _ := ParseTime(chats[j]["updated_at"])
+		if strings.ToLower(order) == "asc" {
+			return ti.Before(tj)
+		}
+		return ti.After(tj)
+	})
+}
+
+// groupChats group the chats by date
+func groupChats(chats []map[string]interface{}) []ChatGroup {
+	today := time.Now().Truncate(24 * time.Hour)
+	yesterday := today.AddDate(0, 0, -1)
+	thisWeekStart := today.AddDate(0, 0, -int(today.Weekday()))
+	lastWeekStart := thisWeekStart.AddDate(0, 0, -7)
+	lastWeekEnd := thisWeekStart.AddDate(0, 0, -1)
+
+	groups := map[string][]map[string]interface{}{}
+	for _, chat := range chats {
+		datetime := chat["updated_at"]
+		if datetime == nil {
+			datetime = chat["created_at"]
+		}
+
+		createdAt, ok := ParseTime(datetime)
+		if !ok {
+			continue
+		}
+
+		createdDate := createdAt.Truncate(24 * time.Hour)
+		label := "Even Earlier"
+		switch {
+		case createdDate.Equal(today):
+			label = "Today"
+		case createdDate.Equal(yesterday):
+			label = "Yesterday"
+		case createdDate.After(thisWeekStart) && createdDate.Before(today):
+			label = "This Week"
+		case createdDate.After(lastWeekStart) && createdDate.Before(lastWeekEnd.AddDate(0, 0, 1)):
+			label = "Last Week"
+		}
+
+		item := map[string]interface{}{}
+		for _, field := range []string{"chat_id", "title", "assistant_id", "silent", "assistant_name", "assistant_avatar"} {
+			if value, has := chat[field]; has {
+				item[field] = value
+			}
+		}
+		groups[label] = append(groups[label], item)
+	}
+
+	result := []ChatGroup{}
+	for _, label := range chatGroupLabels {
+		if len(groups[label]) > 0 {
+			result = append(result, ChatGroup{Label: label, Chats: groups[label]})
+		}
+	}
+	return result
+}
+
+// lastPage calculate the last page number
+func lastPage(total int64, pageSize int) int {
+	last := int(math.Ceil(float64(total) / float64(pageSize)))
+	if last < 1 {
+		last = 1
+	}
+	return last
+}
+
+// validateAssistant check the required assistant fields
+func validateAssistant(assistant map[string]interface{}) error {
+	for _, field := range assistantRequiredFields {
+		if _, ok := assistant[field]; !ok {
+			return fmt.Errorf("field %s is required", field)
+		}
+		if assistant[field] == nil || assistant[field] == "" {
+			return fmt.Errorf("field %s cannot be empty", field)
+		}
+	}
+	return nil
+}
+
+// copyAssistant copy the assistant and parse the JSON string fields
+func copyAssistant(assistant map[string]interface{}) map[string]interface{} {
+	assistantCopy := copyMap(assistant)
+	parseJSONFields(assistantCopy, assistantJSONFields)
+	return assistantCopy
+}
+
+// copyMap returns a shallow copy of the map
+func copyMap(data map[string]interface{}) map[string]interface{} {
+	res := make(map[string]interface{}, len(data))
+	for key, value := range data {
+		res[key] = value
+	}
+	return res
+}
+
+// defaultAssistant fill the assistant defaults, the same as the xun assistant table defaults
+func defaultAssistant(assistant map[string]interface{}) {
+	defaults := map[string]interface{}{
+		"type":        "assistant",
+		"sort":        9999,
+		"built_in":    false,
+		"readonly":    false,
+		"automated":   true,
+		"mentionable": true,
+	}
+	for key, value := range defaults {
+		if v, has := assistant[key]; !has || v == nil {
+			assistant[key] = value
+		}
+	}
+}
+
+// parseJSONFields parses JSON string fields into their corresponding Go types
+func parseJSONFields(data map[string]interface{}, fields []string) {
+	for _, field := range fields {
+		if val := data[field]; val != nil {
+			if strVal, ok := val.(string); ok && strVal != "" {
+				var parsed interface{}
+				if err := jsoniter.UnmarshalFromString(strVal, &parsed); err == nil {
+					data[field] = parsed
+				}
+			}
+		}
+	}
+}
+
+// normalizeJSONFields convert the JSON fields to the JSON decoded types, the same as reading them from the database
+func normalizeJSONFields(data map[string]interface{}, fields []string) error {
+	for _, field := range fields {
+		if val := data[field]; val != nil {
+			raw, err := jsoniter.Marshal(val)
+			if err != nil {
+				return fmt.Errorf("failed to marshal %s to JSON: %v", field, err)
+			}
+
+			var parsed interface{}
+			err = jsoniter.Unmarshal(raw, &parsed)
+			if err != nil {
+				return err
+			}
+			data[field] = parsed
+		}
+	}
+	return nil
+}
+
+// matchAssistant check if the assistant matches the filter
+func matchAssistant(assistant map[string]interface{}, filter AssistantFilter) bool {
+	if len(filter.Tags) > 0 {
+		tags := toStrings(assistant["tags"])
+		matched := false
+		for _, tag := range filter.Tags {
+			for _, t := range tags {
+				if t == tag {
+					matched = true
+					break
+				}
+			}
+			if matched {
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	if filter.Keywords != "" {
+		name, _ := assistant["name"].(string)
+		description, _ := assistant["description"].(string)
+		if !containsFold(name, filter.Keywords) && !containsFold(description, filter.Keywords) {
+			return false
+		}
+	}
+
+	if filter.Type != "" && fmt.Sprintf("%v", assistant["type"]) != filter.Type {
+		return false
+	}
+
+	if filter.Connector != "" && fmt.Sprintf("%v", assistant["connector"]) != filter.Connector {
+		return false
+	}
+
+	id := fmt.Sprintf("%v", assistant["assistant_id"])
+	if filter.AssistantID != "" && id != filter.AssistantID {
+		return false
+	}
+
+	if len(filter.AssistantIDs) > 0 {
+		matched := false
+		for _, assistantID := range filter.AssistantIDs {
+			if assistantID == id {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	if filter.Mentionable != nil && toBool(assistant["mentionable"]) != *filter.Mentionable {
+		return false
+	}
+
+	if filter.Automated != nil && toBool(assistant["automated"]) != *filter.Automated {
+		return false
+	}
+
+	if filter.BuiltIn != nil && toBool(assistant["built_in"]) != *filter.BuiltIn {
+		return false
+	}
+
+	return true
+}
+
+// paginateAssistants sort, paginate and select the assistants in memory
+func paginateAssistants(assistants []map[string]interface{}, filter AssistantFilter) *AssistantResponse {
+	if filter.PageSize <= 0 {
+		filter.PageSize = 20
+	}
+	if filter.Page <= 0 {
+		filter.Page = 1
+	}
+
+	// Order by sort asc, updated_at desc
+	sort.SliceStable(assistants, func(i, j int) bool {
+		si, sj := toInt(assistants[i]["sort"]), toInt(assistants[j]["sort"])
+		if si != sj {
+			return si < sj
+		}
+		ti, _ := ParseTime(assistants[i]["updated_at"])
+		tj, _ := ParseTime(assistants[j]["updated_at"])
+		return ti.After(tj)
+	})
+
+	offset := (filter.Page - 1) * filter.PageSize
+	data := []map[string]interface{}{}
+	for i := offset; i < len(assistants) && i < offset+filter.PageSize; i++ {
+		if len(filter.Select) == 0 {
+			data = append(data, assistants[i])
+			continue
+		}
+
+		row := map[string]interface{}{}
+		for _, field := range filter.Select {
+			row[field] = assistants[i][field]
+		}
+		data = append(data, row)
+	}
+
+	return assistantResponse(data, int64(len(assistants)), filter)
+}
+
+// assistantResponse the paginated assistant response of the page data
+func assistantResponse(data []map[string]interface{}, total int64, filter AssistantFilter) *AssistantResponse {
+	totalPages := int(math.Ceil(float64(total) / float64(filter.PageSize)))
+	nextPage := filter.Page + 1
+	if nextPage > totalPages {
+		nextPage = 0
+	}
+	prevPage := filter.Page - 1
+	if prevPage < 1 {
+		prevPage = 0
+	}
+
+	return &AssistantResponse{
+		Data:     data,
+		Page:     filter.Page,
+		PageSize: filter.PageSize,
+		PageCnt:  totalPages,
+		Next:     nextPage,
+		Prev:     prevPage,
+		Total:    total,
+	}
+}
+
+// assistantTags collect the unique tags of the assistants (type=assistant)
+func assistantTags(assistants []map[string]interface{}) []string {
+	tagSet := map[string]bool{}
+	for _, assistant := range assistants {
+		if fmt.Sprintf("%v", assistant["type"]) != "assistant" {
+			continue
+		}
+		for _, tag := range toStrings(assistant["tags"]) {
+			tagSet[tag] = true
+		}
+	}
+
+	tags := make([]string, 0, len(tagSet))
+	for tag := range tagSet {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// containsFold reports whether substr is within s, case-insensitively (the same as SQL LIKE)
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+func toBool(v interface{}) bool {
+	switch value := v.(type) {
+	case bool:
+		return value
+	case int:
+		return value != 0
+	case int64:
+		return value != 0
+	case float64:
+		return value != 0
+	case string:
+		return value == "true" || value == "1"
+	}
+	return false
+}
+
+func toInt(v interface{}) int {
+	switch value := v.(type) {
+	case int:
+		return value
+	case int32:
+		return int(value)
+	case int64:
+		return int(value)
+	case float64:
+		return int(value)
+	}
+	return 0
+}
+
+func toString(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case []byte:
+		return string(value)
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+func toStrings(v interface{}) []string {
+	switch value := v.(type) {
+	case []string:
+		return value
+	case []interface{}:
+		res := []string{}
+		for _, item := range value {
+			if s, ok := item.(string); ok {
+				res = append(res, s)
+			}
+		}
+		return res
+	case string:
+		var res []string
+		if err := jsoniter.UnmarshalFromString(value, &res); err == nil {
+			return res
+		}
+	}
+	return []string{}
+}
+
+// timeLayouts the layouts of the times read from the stores and the transcripts, the fractional seconds are optional
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999Z07:00", // PostgreSQL
+	"2006-01-02 15:04:05.999999999-07",    // PostgreSQL, the offset without minutes
+	"2006-01-02 15:04:05.999999999",       // MySQL, SQLite
+	"2006-01-02T15:04:05.999999999",
+}
+
+// ParseTime parse the time of the stores (time.Time, string or []byte), the times without the zone are local
+func ParseTime(v interface{}) (time.Time, bool) {
+	switch value := v.(type) {
+	case time.Time:
+		return value, true
+	case *time.Time:
+		if value == nil {
+			return time.Time{}, false
+		}
+		return *value, true
+	case []byte:
+		return ParseTime(string(value))
+	case string:
+		for _, layout := range timeLayouts {
+			if parsed, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+				return parsed, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
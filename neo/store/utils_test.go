@@ -0,0 +1,87 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroupChats(t *testing.T) {
+	now := time.Now()
+	chats := []map[string]interface{}{
+		{"chat_id": "today", "updated_at": now},
+		{"chat_id": "yesterday", "updated_at": now.Truncate(24 * time.Hour).Add(-12 * time.Hour)},
+		{"chat_id": "earlier", "updated_at": now.AddDate(0, 0, -30).Format(time.RFC3339Nano)},
+		{"chat_id": "created", "updated_at": nil, "created_at": now},
+		{"chat_id": "invalid", "updated_at": "invalid"},
+	}
+
+	groups := groupChats(chats)
+	labels := map[string][]interface{}{}
+	for _, group := range groups {
+		for _, chat := range group.Chats {
+			labels[group.Label] = append(labels[group.Label], chat["chat_id"])
+			assert.NotContains(t, chat, "updated_at")
+		}
+	}
+
+	assert.Equal(t, "Today", groups[0].Label)
+	assert.Equal(t, []interface{}{"today", "created"}, labels["Today"])
+	assert.Equal(t, []interface{}{"yesterday"}, labels["Yesterday"])
+	assert.Equal(t, []interface{}{"earlier"}, labels["Even Earlier"])
+	assert.Equal(t, "Even Earlier", groups[len(groups)-1].Label)
+}
+
+func TestParseTime(t *testing.T) {
+	utc := time.Date(2025, 3, 4, 5, 6, 7, 123456000, time.UTC)
+	for _, value := range []interface{}{
+		utc,
+		&utc,
+		utc.Format(time.RFC3339Nano),
+		"2025-03-04 05:06:07.123456+00:00",
+		"2025-03-04 05:06:07.123456+00",
+		[]byte("2025-03-04T05:06:07.123456Z"),
+	} {
+		parsed, ok := ParseTime(value)
+		assert.True(t, ok, value)
+		assert.True(t, utc.Equal(parsed), value)
+	}
+
+	// MySQL and SQLite, the fractional seconds are optional
+	local := time.Date(2025, 3, 4, 5, 6, 7, 0, time.Local)
+	for _, value := range []string{"2025-03-04 05:06:07", "2025-03-04 05:06:07.000", "2025-03-04T05:06:07"} {
+		parsed, ok := ParseTime(value)
+		assert.True(t, ok, value)
+		assert.True(t, local.Equal(parsed), value)
+	}
+
+	parsed, ok := ParseTime("2025-03-04 05:06:07.5")
+	assert.True(t, ok)
+	assert.Equal(t, 500000000, parsed.Nanosecond())
+
+	_, ok = ParseTime("invalid")
+	assert.False(t, ok)
+	_, ok = ParseTime(nil)
+	assert.False(t, ok)
+}
+
+func TestFilterHistory(t *testing.T) {
+	history := []map[string]interface{}{
+		{"content": "1", "silent": false},
+		{"content": "2", "silent": true},
+		{"content": "3", "silent": 0},
+		{"content": "4", "silent": float64(1)},
+		{"content": "5"},
+	}
+
+	res := filterHistory(history, ChatFilter{}, 0)
+	assert.Equal(t, 3, len(res))
+
+	silent := true
+	res = filterHistory(history, ChatFilter{Silent: &silent, Page: 2, PageSize: 2}, 0)
+	assert.Equal(t, []map[string]interface{}{history[1], history[2]}, res)
+
+	res = filterHistory(history, ChatFilter{Silent: &silent}, 1)
+	assert.Equal(t, []map[string]interface{}{history[4]}, res)
+}
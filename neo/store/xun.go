@@ -10,6 +10,7 @@ import (
 	"github.com/yaoapp/gou/connector"
 	"github.com/yaoapp/gou/session"
 	"github.com/yaoapp/kun/log"
+	"github.com/yaoapp/xun"
 	"github.com/yaoapp/xun/capsule"
 	"github.com/yaoapp/xun/dbal/query"
 	"github.com/yaoapp/xun/dbal/schema"
@@ -38,6 +39,8 @@ type Xun struct {
 // GetChat retrieves a specific chat and its message history
 // GetHistory retrieves the message history for a specific chat
 // SaveHistory saves new messages to a chat's history
+// GetBranches retrieves the branches of a specific chat
+// SwitchBranch switches the active branch of a specific chat
 // DeleteChat deletes a specific chat and its history
 // DeleteAllChats deletes all chats and their histories for a user
 // SaveAssistant creates or updates an assistant
@@ -145,6 +148,8 @@ func (conv *Xun) initHistoryTable() error {
 			table.String("assistant_avatar", 200).Null()
 			table.JSON("mentions").Null()
 			table.Boolean("silent").SetDefault(false).Index()
+			table.String("message_id", 64).Null().Index()
+			table.String("parent_id", 64).Null().Index()
 			table.TimestampTz("created_at").SetDefaultRaw("NOW()").Index()
 			table.TimestampTz("updated_at").Null().Index()
 			table.TimestampTz("expired_at").Null().Index()
@@ -162,7 +167,24 @@ func (conv *Xun) initHistoryTable() error {
 		return err
 	}
 
-	fields := []string{"id", "sid", "cid", "uid", "role", "name", "content", "context", "assistant_id", "assistant_name", "assistant_avatar", "mentions", "silent", "created_at", "updated_at", "expired_at"}
+	// Add the message tree columns to the tables created before branching was supported
+	if !tab.HasColumn("message_id") {
+		err = conv.schema.AlterTable(historyTable, func(table schema.Blueprint) {
+			table.String("message_id", 64).Null().Index()
+			table.String("parent_id", 64).Null().Index()
+		})
+		if err != nil {
+			return err
+		}
+		log.Trace("Add the message tree columns to the conversation history table: %s", historyTable)
+
+		tab, err = conv.schema.GetTable(historyTable)
+		if err != nil {
+			return err
+		}
+	}
+
+	fields := []string{"id", "sid", "cid", "uid", "role", "name", "content", "context", "assistant_id", "assistant_name", "assistant_avatar", "mentions", "silent", "message_id", "parent_id", "created_at", "updated_at", "expired_at"}
 	for _, field := range fields {
 		if !tab.HasColumn(field) {
 			return fmt.Errorf("%s is required", field)
@@ -188,6 +210,7 @@ func (conv *Xun) initChatTable() error {
 			table.String("assistant_id", 200).Null().Index()
 			table.String("sid", 255).Index()
 			table.Boolean("silent").SetDefault(false).Index()
+			table.String("head_id", 64).Null()
 			table.TimestampTz("created_at").SetDefaultRaw("NOW()").Index()
 			table.TimestampTz("updated_at").Null().Index()
 		})
@@ -204,7 +227,23 @@ func (conv *Xun) initChatTable() error {
 		return err
 	}
 
-	fields := []string{"id", "chat_id", "title", "assistant_id", "sid", "silent", "created_at", "updated_at"}
+	// Add the head of the active branch to the tables created before branching was supported
+	if !tab.HasColumn("head_id") {
+		err = conv.schema.AlterTable(chatTable, func(table schema.Blueprint) {
+			table.String("head_id", 64).Null()
+		})
+		if err != nil {
+			return err
+		}
+		log.Trace("Add the head_id column to the chat table: %s", chatTable)
+
+		tab, err = conv.schema.GetTable(chatTable)
+		if err != nil {
+			return err
+		}
+	}
+
+	fields := []string{"id", "chat_id", "title", "assistant_id", "sid", "silent", "head_id", "created_at", "updated_at"}
 	for _, field := range fields {
 		if !tab.HasColumn(field) {
 			return fmt.Errorf("%s is required", field)
@@ -479,21 +518,8 @@ func (conv *Xun) getChatsWithFilter(sid string, filter ChatFilter) (*ChatGroupRe
 			dbDatetime = row.Get("created_at")
 		}
 
-		var createdAt time.Time
-		switch v := dbDatetime.(type) {
-		case time.Time:
-			createdAt = v
-		case string:
-			parsed, err := time.Parse("2006-01-02 15:04:05.999999-07:00", v)
-			if err != nil {
-				// Try alternative format
-				parsed, err = time.Parse(time.RFC3339, v)
-				if err != nil {
-					continue
-				}
-			}
-			createdAt = parsed
-		default:
+		createdAt, ok := ParseTime(dbDatetime)
+		if !ok {
 			continue
 		}
 
@@ -535,54 +561,7 @@ func (conv *Xun) getChatsWithFilter(sid string, filter ChatFilter) (*ChatGroupRe
 
 // GetHistory get the history
 func (conv *Xun) GetHistory(sid string, cid string) ([]map[string]interface{}, error) {
-	userID, err := conv.getUserID(sid)
-	if err != nil {
-		return nil, err
-	}
-
-	qb := conv.newQuery().
-		Select("role", "name", "content", "context", "assistant_id", "assistant_name", "assistant_avatar", "mentions", "uid", "silent", "created_at", "updated_at").
-		Where("sid", userID).
-		Where("cid", cid).
-		OrderBy("id", "desc")
-
-	// By default, exclude silent messages
-	qb.Where("silent", false)
-
-	if conv.setting.TTL > 0 {
-		qb.Where("expired_at", ">", time.Now())
-	}
-
-	limit := 20
-	if conv.setting.MaxSize > 0 {
-		limit = conv.setting.MaxSize
-	}
-
-	rows, err := qb.Limit(limit).Get()
-	if err != nil {
-		return nil, err
-	}
-
-	res := []map[string]interface{}{}
-	for _, row := range rows {
-		message := map[string]interface{}{
-			"role":             row.Get("role"),
-			"name":             row.Get("name"),
-			"content":          row.Get("content"),
-			"context":          row.Get("context"),
-			"assistant_id":     row.Get("assistant_id"),
-			"assistant_name":   row.Get("assistant_name"),
-			"assistant_avatar": row.Get("assistant_avatar"),
-			"mentions":         row.Get("mentions"),
-			"uid":              row.Get("uid"),
-			"silent":           row.Get("silent"),
-			"created_at":       row.Get("created_at"),
-			"updated_at":       row.Get("updated_at"),
-		}
-		res = append([]map[string]interface{}{message}, res...)
-	}
-
-	return res, nil
+	return conv.GetHistoryWithFilter(sid, cid, ChatFilter{})
 }
 
 // SaveHistory save the history
@@ -623,16 +602,17 @@ func (conv *Xun) SaveHistory(sid string, messages []map[string]interface{}, cid
 	}
 
 	// First ensure chat record exists
-	exists, err := conv.newQueryChat().
+	chat, err := conv.newQueryChat().
+		Select("chat_id", "head_id").
 		Where("chat_id", cid).
 		Where("sid", userID).
-		Exists()
+		First()
 
 	if err != nil {
 		return err
 	}
 
-	if !exists {
+	if chat.Get("chat_id") == nil {
 		// Create new chat record
 		err = conv.newQueryChat().
 			Insert(map[string]interface{}{
@@ -730,20 +710,31 @@ func (conv *Xun) SaveHistory(sid string, messages []map[string]interface{}, cid
 		if assistantAvatar, ok := message["assistant_avatar"].(string); ok {
 			value["assistant_avatar"] = assistantAvatar
 		}
+		if createdAt, ok := ParseTime(message["created_at"]); ok {
+			value["created_at"] = createdAt
+		}
+		if messageID, ok := message["message_id"].(string); ok && messageID != "" {
+			value["message_id"] = messageID
+		}
+		if parentID, ok := message["parent_id"].(string); ok && parentID != "" {
+			value["parent_id"] = parentID
+		}
 
 		values = append(values, value)
 	}
 
+	// Chain the messages to the head of the active branch
+	head := chainHistory(values, context, toString(chat.Get("head_id")), silent)
 	err = conv.newQuery().Insert(values)
 	if err != nil {
 		return err
 	}
 
-	// Update Chat updated_at
+	// Update Chat head_id and updated_at
 	_, err = conv.newQueryChat().
 		Where("chat_id", cid).
 		Where("sid", userID).
-		Update(map[string]interface{}{"updated_at": now})
+		Update(map[string]interface{}{"head_id": head, "updated_at": now})
 	if err != nil {
 		return err
 	}
@@ -1293,75 +1284,236 @@ func (conv *Xun) GetAssistantTags() ([]string, error) {
 	return tags, nil
 }
 
+// historyPageSize the rows read per query when walking the history of a chat
+var historyPageSize = 100
+
+// historyFields the fields of the history records
+var historyFields = []interface{}{"id", "role", "name", "content", "context", "assistant_id", "assistant_name", "assistant_avatar", "mentions", "uid", "silent", "message_id", "parent_id", "created_at", "updated_at"}
+
 // GetHistoryWithFilter get the history with filter options
+// The branch is walked from the head message to the root page by page, until the requested page is collected.
 func (conv *Xun) GetHistoryWithFilter(sid string, cid string, filter ChatFilter) ([]map[string]interface{}, error) {
 	userID, err := conv.getUserID(sid)
 	if err != nil {
 		return nil, err
 	}
 
-	qb := conv.newQuery().
-		Select("role", "name", "content", "context", "assistant_id", "assistant_name", "assistant_avatar", "mentions", "uid", "silent", "created_at", "updated_at").
-		Where("sid", userID).
-		Where("cid", cid).
-		OrderBy("id", "desc")
+	head := filter.Branch
+	if head == "" {
+		head, _, err = conv.getHead(userID, cid)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-	// Apply silent filter if provided, otherwise exclude silent messages by default
-	if filter.Silent != nil {
-		if *filter.Silent {
-			// Include all messages (both silent and non-silent)
-		} else {
-			// Only include non-silent messages
-			qb.Where("silent", false)
+	if head != "" && head != BranchRoot {
+		has, err := conv.newHistoryQuery(userID, cid).Where("message_id", head).Exists()
+		if err != nil {
+			return nil, err
+		}
+
+		if !has && filter.Branch != "" {
+			return nil, fmt.Errorf("message %s %w", head, ErrNotFound)
+		}
+
+		// The head has been removed (TTL, MaxSize), starts from the latest message
+		if !has {
+			head = ""
 		}
-	} else {
-		// Default behavior: exclude silent messages
-		qb.Where("silent", false)
 	}
 
-	if conv.setting.TTL > 0 {
-		qb.Where("expired_at", ">", time.Now())
+	limit, offset := historyPage(filter, conv.setting.MaxSize)
+	includeSilent := filter.Silent != nil && *filter.Silent
+	walker := newBranchWalker(head)
+	var last interface{}
+	for {
+		qb := conv.newHistoryQuery(userID, cid).
+			Select(historyFields...).
+			OrderBy("id", "desc").
+			Limit(historyPageSize)
+
+		if last != nil {
+			qb.Where("id", "<", last)
+		}
+
+		rows, err := qb.Get()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, row := range rows {
+			walker.add(historyRecord(row))
+			last = row.Get("id")
+		}
+
+		if len(rows) < historyPageSize || walker.count(includeSilent) >= offset+limit {
+			break
+		}
+	}
+	return filterHistory(walker.history(), filter, conv.setting.MaxSize), nil
+}
+
+// GetBranches get the branches of the chat
+func (conv *Xun) GetBranches(sid string, cid string) ([]Branch, error) {
+	userID, err := conv.getUserID(sid)
+	if err != nil {
+		return nil, err
 	}
 
-	limit := 20
-	if conv.setting.MaxSize > 0 {
-		limit = conv.setting.MaxSize
+	tree, err := conv.loadTree(userID, cid)
+	if err != nil {
+		return nil, err
+	}
+
+	head, _, err := conv.getHead(userID, cid)
+	if err != nil {
+		return nil, err
 	}
-	if filter.PageSize > 0 {
-		limit = filter.PageSize
+
+	// Read the contents of the preview messages only
+	branches, previews := branchTree(tree, head)
+	ids := []interface{}{}
+	for _, message := range previews {
+		if message != nil {
+			ids = append(ids, message["id"])
+		}
 	}
 
-	// Apply pagination if provided
-	if filter.Page > 0 {
-		offset := (filter.Page - 1) * limit
-		qb.Offset(offset)
+	if len(ids) == 0 {
+		return branches, nil
 	}
 
-	rows, err := qb.Limit(limit).Get()
+	rows, err := conv.newQuery().Select("id", "content").WhereIn("id", ids).Get()
 	if err != nil {
 		return nil, err
 	}
 
-	res := []map[string]interface{}{}
+	contents := map[string]interface{}{}
 	for _, row := range rows {
-		message := map[string]interface{}{
-			"role":             row.Get("role"),
-			"name":             row.Get("name"),
-			"content":          row.Get("content"),
-			"context":          row.Get("context"),
-			"assistant_id":     row.Get("assistant_id"),
-			"assistant_name":   row.Get("assistant_name"),
-			"assistant_avatar": row.Get("assistant_avatar"),
-			"mentions":         row.Get("mentions"),
-			"uid":              row.Get("uid"),
-			"silent":           row.Get("silent"),
-			"created_at":       row.Get("created_at"),
-			"updated_at":       row.Get("updated_at"),
-		}
-		res = append([]map[string]interface{}{message}, res...)
-	}
-
-	return res, nil
+		contents[toString(row.Get("id"))] = row.Get("content")
+	}
+
+	for i, message := range previews {
+		if message != nil {
+			branches[i].Preview = branchPreview(map[string]interface{}{"content": contents[toString(message["id"])]})
+		}
+	}
+	return branches, nil
+}
+
+// SwitchBranch switch the active branch of the chat
+func (conv *Xun) SwitchBranch(sid string, cid string, messageID string) error {
+	userID, err := conv.getUserID(sid)
+	if err != nil {
+		return err
+	}
+
+	_, has, err := conv.getHead(userID, cid)
+	if err != nil {
+		return err
+	}
+
+	if !has {
+		return fmt.Errorf("chat %s %w", cid, ErrNotFound)
+	}
+
+	tree, err := conv.loadTree(userID, cid)
+	if err != nil {
+		return err
+	}
+
+	head, err := branchLeaf(tree, messageID)
+	if err != nil {
+		return err
+	}
+
+	_, err = conv.newQueryChat().
+		Where("sid", userID).
+		Where("chat_id", cid).
+		Update(map[string]interface{}{"head_id": head})
+	return err
+}
+
+// getHead get the head message id of the chat, has is false if the chat does not exist
+func (conv *Xun) getHead(userID string, cid string) (string, bool, error) {
+	chat, err := conv.newQueryChat().
+		Select("chat_id", "head_id").
+		Where("sid", userID).
+		Where("chat_id", cid).
+		First()
+	if err != nil {
+		return "", false, err
+	}
+	return toString(chat.Get("head_id")), chat.Get("chat_id") != nil, nil
+}
+
+// loadTree load the message tree of the chat page by page (oldest first, silent messages included), the contents are not loaded
+func (conv *Xun) loadTree(userID string, cid string) ([]map[string]interface{}, error) {
+	tree := []map[string]interface{}{}
+	var last interface{}
+	for {
+		qb := conv.newHistoryQuery(userID, cid).
+			Select("id", "role", "silent", "message_id", "parent_id", "created_at").
+			OrderBy("id", "asc").
+			Limit(historyPageSize)
+
+		if last != nil {
+			qb.Where("id", ">", last)
+		}
+
+		rows, err := qb.Get()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, row := range rows {
+			tree = append(tree, map[string]interface{}{
+				"id":         row.Get("id"),
+				"role":       row.Get("role"),
+				"silent":     row.Get("silent"),
+				"message_id": row.Get("message_id"),
+				"parent_id":  row.Get("parent_id"),
+				"created_at": row.Get("created_at"),
+			})
+			last = row.Get("id")
+		}
+
+		if len(rows) < historyPageSize {
+			return tree, nil
+		}
+	}
+}
+
+// newHistoryQuery the query of the unexpired history of the chat
+func (conv *Xun) newHistoryQuery(userID string, cid string) query.Query {
+	qb := conv.newQuery().
+		Where("sid", userID).
+		Where("cid", cid)
+
+	if conv.setting.TTL > 0 {
+		qb.Where("expired_at", ">", time.Now())
+	}
+	return qb
+}
+
+// historyRecord the history record of the row
+func historyRecord(row xun.R) map[string]interface{} {
+	return map[string]interface{}{
+		"role":             row.Get("role"),
+		"name":             row.Get("name"),
+		"content":          row.Get("content"),
+		"context":          row.Get("context"),
+		"assistant_id":     row.Get("assistant_id"),
+		"assistant_name":   row.Get("assistant_name"),
+		"assistant_avatar": row.Get("assistant_avatar"),
+		"mentions":         row.Get("mentions"),
+		"uid":              row.Get("uid"),
+		"silent":           row.Get("silent"),
+		"message_id":       row.Get("message_id"),
+		"parent_id":        row.Get("parent_id"),
+		"created_at":       row.Get("created_at"),
+		"updated_at":       row.Get("updated_at"),
+	}
 }
 
 // GenerateAssistantID generates a random-looking 6-digit ID
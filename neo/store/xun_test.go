@@ -8,6 +8,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/yaoapp/gou/connector"
 	"github.com/yaoapp/xun/capsule"
+	"github.com/yaoapp/xun/dbal/schema"
 	"github.com/yaoapp/yao/config"
 	"github.com/yaoapp/yao/test"
 )
@@ -182,6 +183,171 @@ func TestNewXunConnector(t *testing.T) {
 	assert.Nil(t, err)
 }
 
+func TestXunMigrateBranches(t *testing.T) {
+	test.Prepare(t, config.Conf)
+	defer test.Clean()
+	defer capsule.Schema().DropTableIfExists("__unit_test_conversation_history")
+	defer capsule.Schema().DropTableIfExists("__unit_test_conversation_chat")
+	defer capsule.Schema().DropTableIfExists("__unit_test_conversation_assistant")
+
+	capsule.Schema().DropTableIfExists("__unit_test_conversation_history")
+	capsule.Schema().DropTableIfExists("__unit_test_conversation_chat")
+
+	// The tables created before branching was supported
+	err := capsule.Schema().CreateTable("__unit_test_conversation_history", func(table schema.Blueprint) {
+		table.ID("id")
+		table.String("sid", 255).Index()
+		table.String("cid", 200).Null().Index()
+		table.String("uid", 255).Null().Index()
+		table.String("role", 200).Null().Index()
+		table.String("name", 200).Null().Index()
+		table.Text("content").Null()
+		table.JSON("context").Null()
+		table.String("assistant_id", 200).Null().Index()
+		table.String("assistant_name", 200).Null()
+		table.String("assistant_avatar", 200).Null()
+		table.JSON("mentions").Null()
+		table.Boolean("silent").SetDefault(false).Index()
+		table.TimestampTz("created_at").SetDefaultRaw("NOW()").Index()
+		table.TimestampTz("updated_at").Null().Index()
+		table.TimestampTz("expired_at").Null().Index()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = capsule.Schema().CreateTable("__unit_test_conversation_chat", func(table schema.Blueprint) {
+		table.ID("id")
+		table.String("chat_id", 200).Unique().Index()
+		table.String("title", 200).Null()
+		table.String("assistant_id", 200).Null().Index()
+		table.String("sid", 255).Index()
+		table.Boolean("silent").SetDefault(false).Index()
+		table.TimestampTz("created_at").SetDefaultRaw("NOW()").Index()
+		table.TimestampTz("updated_at").Null().Index()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The legacy messages have no message_id
+	err = capsule.Query().Table("__unit_test_conversation_history").Insert(map[string]interface{}{
+		"sid": "test_user", "cid": "test_chat", "uid": "test_user", "role": "user", "name": "", "content": "legacy", "silent": false,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := NewXun(Setting{Connector: "default", Prefix: "__unit_test_conversation_"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tab, err := capsule.Schema().GetTable("__unit_test_conversation_history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, tab.HasColumn("message_id"))
+	assert.True(t, tab.HasColumn("parent_id"))
+
+	tab, err = capsule.Schema().GetTable("__unit_test_conversation_chat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, tab.HasColumn("head_id"))
+
+	err = store.SaveHistory("test_user", []map[string]interface{}{{"role": "assistant", "content": "reply"}}, "test_chat", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := store.GetHistory("test_user", "test_chat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 2, len(history))
+	assert.Equal(t, "legacy", history[0]["content"])
+	assert.Nil(t, history[0]["message_id"])
+	assert.NotNil(t, history[1]["message_id"])
+}
+
+func TestXunBranchPages(t *testing.T) {
+	test.Prepare(t, config.Conf)
+	defer test.Clean()
+	defer capsule.Schema().DropTableIfExists("__unit_test_conversation_history")
+	defer capsule.Schema().DropTableIfExists("__unit_test_conversation_chat")
+
+	capsule.Schema().DropTableIfExists("__unit_test_conversation_history")
+	capsule.Schema().DropTableIfExists("__unit_test_conversation_chat")
+
+	// Walk the branch with the pages of two rows
+	pageSize := historyPageSize
+	historyPageSize = 2
+	defer func() { historyPageSize = pageSize }()
+
+	store, err := NewXun(Setting{Connector: "default", Prefix: "__unit_test_conversation_"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 1; i <= 5; i++ {
+		err = store.SaveHistory("test_user", []map[string]interface{}{{"role": "user", "content": fmt.Sprintf("%d", i)}}, "test_chat", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	history, err := store.GetHistory("test_user", "test_chat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 5, len(history))
+
+	// Branch from the second message, the other branch is interleaved
+	err = store.SaveHistory("test_user", []map[string]interface{}{{"role": "user", "content": "3'"}, {"role": "assistant", "content": "4'"}}, "test_chat", map[string]interface{}{"parent_id": history[1]["message_id"]})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contents := func(history []map[string]interface{}) []interface{} {
+		res := []interface{}{}
+		for _, message := range history {
+			res = append(res, message["content"])
+		}
+		return res
+	}
+
+	history, err = store.GetHistory("test_user", "test_chat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []interface{}{"1", "2", "3'", "4'"}, contents(history))
+
+	history, err = store.GetHistoryWithFilter("test_user", "test_chat", ChatFilter{Page: 2, PageSize: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []interface{}{"1"}, contents(history))
+
+	branches, err := store.GetBranches("test_user", "test_chat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assert.Equal(t, 2, len(branches)) {
+		assert.Equal(t, 5, branches[0].Size)
+		assert.Equal(t, "5", branches[0].Preview)
+		assert.Equal(t, 4, branches[1].Size)
+		assert.Equal(t, "3'", branches[1].Preview)
+		assert.True(t, branches[1].Active)
+	}
+
+	_, err = store.GetHistoryWithFilter("test_user", "test_chat", ChatFilter{Branch: "message_not_exists"})
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	err = store.SwitchBranch("test_user", "chat_not_exists", fmt.Sprintf("%v", history[0]["message_id"]))
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
 func TestXunSaveAndGetHistory(t *testing.T) {
 	test.Prepare(t, config.Conf)
 	defer test.Clean()
@@ -0,0 +1,343 @@
+package transcript
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"strings"
+
+	jsoniter "github.com/json-iterator/go"
+	chatMessage "github.com/yaoapp/yao/neo/message"
+	"github.com/yaoapp/yao/neo/store"
+)
+
+// entry a message of the active branch to render
+type entry struct {
+	Role   string
+	Author string
+	Time   string
+	Parts  []part
+}
+
+// part a content of the message
+type part struct {
+	Type        string // text, think, tool, error, data, attachments
+	Title       string // the function of the tool, the type of the data
+	Text        string
+	Code        string // the arguments of the tool, the props of the data (JSON)
+	Result      string
+	Error       string
+	Attachments []chatMessage.Attachment
+}
+
+// entries returns the messages of the active branch, the silent messages are not rendered
+func (transcript *Transcript) entries() []entry {
+	entries := []entry{}
+	for _, row := range store.BranchHistory(transcript.History, transcript.HeadID) {
+		if toBool(row["silent"]) {
+			continue
+		}
+
+		role := toString(row["role"])
+		item := entry{Role: role, Author: transcript.author(row), Time: formatTime(row["created_at"]), Parts: []part{}}
+		messages, err := chatMessage.NewHistory(map[string]interface{}{"role": role, "content": toString(row["content"])})
+		if err != nil {
+			item.Parts = append(item.Parts, part{Type: "text", Text: toString(row["content"])})
+			entries = append(entries, item)
+			continue
+		}
+
+		for _, msg := range messages {
+			item.Parts = append(item.Parts, parts(msg)...)
+		}
+		entries = append(entries, item)
+	}
+	return entries
+}
+
+// author returns the display name of the message
+func (transcript *Transcript) author(row map[string]interface{}) string {
+	switch toString(row["role"]) {
+	case "user":
+		return "User"
+	case "system":
+		return "System"
+	case "assistant":
+		if name := toString(row["assistant_name"]); name != "" {
+			return name
+		}
+		if transcript.AssistantName != "" {
+			return transcript.AssistantName
+		}
+		return "Assistant"
+	}
+	return toString(row["role"])
+}
+
+// parts returns the parts of the message by the content type
+func parts(msg chatMessage.Message) []part {
+	res := []part{}
+	text := msg.Text
+	if text == "" && msg.Props != nil {
+		text = toString(msg.Props["text"])
+	}
+
+	switch msg.Type {
+	case "", "text":
+		if strings.TrimSpace(text) != "" {
+			res = append(res, part{Type: "text", Text: text})
+		}
+
+	case "think":
+		if strings.TrimSpace(text) != "" {
+			res = append(res, part{Type: "think", Text: strings.TrimSpace(text)})
+		}
+
+	case "tool":
+		res = append(res, toolPart(text, msg.Props))
+
+	case "error":
+		res = append(res, part{Type: "error", Text: text})
+
+	case "loading":
+		// The progress of the tool calls, not a content
+
+	default:
+		data := part{Type: "data", Title: msg.Type, Text: text}
+		if len(msg.Props) > 0 {
+			data.Code = indent(msg.Props)
+		}
+		res = append(res, data)
+	}
+
+	if len(msg.Attachments) > 0 {
+		res = append(res, part{Type: "attachments", Attachments: msg.Attachments})
+	}
+	return res
+}
+
+// toolPart returns the tool call part, the <tool> tag holds the JSON call, the props hold the result or the error
+func toolPart(text string, props map[string]interface{}) part {
+	res := part{Type: "tool", Code: strings.TrimSpace(text)}
+	if start := strings.Index(text, "<tool>"); start != -1 {
+		raw := text[start+len("<tool>"):]
+		if end := strings.LastIndex(raw, "</tool>"); end != -1 {
+			raw = raw[:end]
+		}
+		res.Code = strings.TrimSpace(raw)
+
+		var call map[string]interface{}
+		if err := jsoniter.UnmarshalFromString(res.Code, &call); err == nil {
+			res.Title = toString(call["function"])
+			if args, has := call["arguments"]; has {
+				res.Code = indent(args)
+			}
+		}
+	}
+
+	if props == nil {
+		return res
+	}
+
+	if result, has := props["result"]; has && result != nil {
+		res.Result = toString(result)
+		if _, ok := result.(string); !ok {
+			res.Result = indent(result)
+		}
+	}
+	res.Error = toString(props["error"])
+	return res
+}
+
+// markdown render the active branch in Markdown
+func (transcript *Transcript) markdown() ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# %s\n\n", transcript.title())
+	fmt.Fprintf(&buf, "- Chat: `%s`\n", transcript.ChatID)
+	if transcript.AssistantName != "" {
+		fmt.Fprintf(&buf, "- Assistant: %s\n", transcript.AssistantName)
+	}
+	fmt.Fprintf(&buf, "- Exported: %s\n", formatTime(transcript.ExportedAt))
+
+	for _, item := range transcript.entries() {
+		buf.WriteString("\n---\n\n")
+		fmt.Fprintf(&buf, "### %s", item.Author)
+		if item.Time != "" {
+			fmt.Fprintf(&buf, " · %s", item.Time)
+		}
+		buf.WriteString("\n")
+
+		for _, p := range item.Parts {
+			buf.WriteString("\n")
+			switch p.Type {
+			case "text":
+				buf.WriteString(p.Text + "\n")
+
+			case "think":
+				buf.WriteString("> **Thinking**\n>\n")
+				for _, line := range strings.Split(p.Text, "\n") {
+					buf.WriteString(strings.TrimRight("> "+line, " ") + "\n")
+				}
+
+			case "tool":
+				fmt.Fprintf(&buf, "**Tool call: `%s`**\n\n%s", p.Title, fence(p.Code, "json"))
+				if p.Result != "" {
+					fmt.Fprintf(&buf, "\n**Result**\n\n%s", fence(p.Result, lang(p.Result)))
+				}
+				if p.Error != "" {
+					fmt.Fprintf(&buf, "\n**Error:** %s\n", p.Error)
+				}
+
+			case "error":
+				buf.WriteString("**Error**")
+				if p.Text != "" {
+					buf.WriteString(": " + p.Text)
+				}
+				buf.WriteString("\n")
+
+			case "data":
+				fmt.Fprintf(&buf, "**%s**\n", p.Title)
+				if p.Text != "" {
+					buf.WriteString("\n" + p.Text + "\n")
+				}
+				if p.Code != "" {
+					buf.WriteString("\n" + fence(p.Code, "json"))
+				}
+
+			case "attachments":
+				buf.WriteString("**Attachments**\n\n")
+				for _, attachment := range p.Attachments {
+					name := attachmentName(attachment)
+					if attachment.URL != "" {
+						name = fmt.Sprintf("[%s](%s)", name, attachment.URL)
+					}
+					fmt.Fprintf(&buf, "- %s%s\n", name, attachmentInfo(attachment))
+				}
+			}
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// html render the active branch in a standalone HTML document
+func (transcript *Transcript) html() ([]byte, error) {
+	var buf bytes.Buffer
+	err := htmlTemplate.Execute(&buf, map[string]interface{}{
+		"Title":      transcript.title(),
+		"Transcript": transcript,
+		"ExportedAt": formatTime(transcript.ExportedAt),
+		"Entries":    transcript.entries(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// title returns the title of the transcript
+func (transcript *Transcript) title() string {
+	if transcript.Title != "" {
+		return transcript.Title
+	}
+	return fmt.Sprintf("Chat %s", transcript.ChatID)
+}
+
+// fence returns the fenced code block, longer than the backticks in the code
+func fence(code string, lang string) string {
+	ticks := "```"
+	for strings.Contains(code, ticks) {
+		ticks = ticks + "`"
+	}
+	return fmt.Sprintf("%s%s\n%s\n%s\n", ticks, lang, code, ticks)
+}
+
+// lang returns the language of the code block, json for the JSON objects and arrays
+func lang(code string) string {
+	code = strings.TrimSpace(code)
+	if strings.HasPrefix(code, "{") || strings.HasPrefix(code, "[") {
+		return "json"
+	}
+	return ""
+}
+
+// indent returns the indented JSON of the value
+func indent(v interface{}) string {
+	raw, err := jsoniter.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(raw)
+}
+
+func attachmentName(attachment chatMessage.Attachment) string {
+	switch {
+	case attachment.Name != "":
+		return attachment.Name
+	case attachment.FileID != "":
+		return attachment.FileID
+	}
+	return "attachment"
+}
+
+func attachmentInfo(attachment chatMessage.Attachment) string {
+	info := []string{}
+	if attachment.ContentType != "" {
+		info = append(info, attachment.ContentType)
+	}
+	if attachment.Bytes > 0 {
+		info = append(info, fmt.Sprintf("%d bytes", attachment.Bytes))
+	}
+	if len(info) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(info, ", ") + ")"
+}
+
+func formatTime(v interface{}) string {
+	if t, ok := store.ParseTime(v); ok && !t.IsZero() {
+		return t.Format("2006-01-02 15:04:05")
+	}
+	if v == nil {
+		return ""
+	}
+	return toString(v)
+}
+
+var htmlTemplate = template.Must(template.New("transcript").Funcs(template.FuncMap{
+	"name": attachmentName,
+	"info": attachmentInfo,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; max-width: 860px; margin: 0 auto; padding: 24px; color: #1f2328; }
+header { border-bottom: 1px solid #d0d7de; margin-bottom: 16px; color: #59636e; }
+.message { margin: 16px 0; padding: 12px 16px; border: 1px solid #d0d7de; border-radius: 8px; }
+.message.user { background: #f6f8fa; }
+.author { font-weight: 600; }
+.time { color: #59636e; font-size: 12px; margin-left: 8px; }
+.text { white-space: pre-wrap; }
+.think { white-space: pre-wrap; color: #59636e; border-left: 3px solid #d0d7de; padding-left: 12px; }
+.error { color: #cf222e; }
+pre { background: #f6f8fa; padding: 8px 12px; border-radius: 6px; overflow-x: auto; }
+</style>
+</head>
+<body>
+<header>
+<h1>{{.Title}}</h1>
+<p>Chat: <code>{{.Transcript.ChatID}}</code>{{if .Transcript.AssistantName}} · Assistant: {{.Transcript.AssistantName}}{{end}} · Exported: {{.ExportedAt}}</p>
+</header>
+{{range .Entries}}<section class="message {{.Role}}">
+<div><span class="author">{{.Author}}</span>{{if .Time}}<span class="time">{{.Time}}</span>{{end}}</div>
+{{range .Parts}}{{if eq .Type "text"}}<div class="text">{{.Text}}</div>
+{{else if eq .Type "think"}}<div class="think">{{.Text}}</div>
+{{else if eq .Type "tool"}}<div class="tool"><p>Tool call: <code>{{.Title}}</code></p><pre>{{.Code}}</pre>{{if .Result}}<p>Result</p><pre>{{.Result}}</pre>{{end}}{{if .Error}}<p class="error">Error: {{.Error}}</p>{{end}}</div>
+{{else if eq .Type "error"}}<p class="error">Error{{if .Text}}: {{.Text}}{{end}}</p>
+{{else if eq .Type "data"}}<div class="data"><p><strong>{{.Title}}</strong></p>{{if .Text}}<div class="text">{{.Text}}</div>{{end}}{{if .Code}}<pre>{{.Code}}</pre>{{end}}</div>
+{{else if eq .Type "attachments"}}<ul class="attachments">{{range .Attachments}}<li>{{if .URL}}<a href="{{.URL}}">{{name .}}</a>{{else}}{{name .}}{{end}}{{info .}}</li>{{end}}</ul>
+{{end}}{{end}}</section>
+{{end}}</body>
+</html>
+`))
@@ -0,0 +1,340 @@
+package transcript
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/yaoapp/yao/neo/store"
+)
+
+// Version the version of the transcript format
+const Version = 1
+
+const (
+	// FormatJSON the JSON transcript, it can be imported
+	FormatJSON = "json"
+	// FormatMarkdown the Markdown transcript of the active branch
+	FormatMarkdown = "md"
+	// FormatHTML the standalone HTML transcript of the active branch
+	FormatHTML = "html"
+)
+
+// ErrNotJSON the transcript to import is not a JSON transcript, the md and html transcripts can not be imported
+var ErrNotJSON = errors.New("only JSON transcripts can be imported")
+
+// Transcript the exported chat
+// History holds the messages of all branches and the silent messages (oldest first),
+// the messages keep the message_id and parent_id, HeadID is the active branch.
+type Transcript struct {
+	Version         int                      `json:"version"`
+	ChatID          string                   `json:"chat_id"`
+	Title           string                   `json:"title,omitempty"`
+	AssistantID     string                   `json:"assistant_id,omitempty"`
+	AssistantName   string                   `json:"assistant_name,omitempty"`
+	AssistantAvatar string                   `json:"assistant_avatar,omitempty"`
+	HeadID          string                   `json:"head_id,omitempty"`
+	ExportedAt      time.Time                `json:"exported_at"`
+	History         []map[string]interface{} `json:"history"`
+}
+
+// historyFields the fields of the history records kept in the transcript
+var historyFields = []string{
+	"message_id", "parent_id", "role", "name", "content", "context", "silent", "mentions",
+	"assistant_id", "assistant_name", "assistant_avatar", "created_at",
+}
+
+// Export export the chat from the store
+func Export(s store.Store, sid string, cid string) (*Transcript, error) {
+	chat, err := s.GetChat(sid, cid)
+	if err != nil {
+		return nil, err
+	}
+
+	if chat == nil {
+		return nil, fmt.Errorf("chat %s not found", cid)
+	}
+
+	transcript := &Transcript{
+		Version:         Version,
+		ChatID:          cid,
+		Title:           toString(chat.Chat["title"]),
+		AssistantID:     toString(chat.Chat["assistant_id"]),
+		AssistantName:   toString(chat.Chat["assistant_name"]),
+		AssistantAvatar: toString(chat.Chat["assistant_avatar"]),
+		ExportedAt:      time.Now(),
+		History:         []map[string]interface{}{},
+	}
+
+	branches, err := s.GetBranches(sid, cid)
+	if err != nil {
+		return nil, err
+	}
+
+	silent := true
+	if len(branches) == 0 {
+		history, err := s.GetHistoryWithFilter(sid, cid, store.ChatFilter{Silent: &silent, PageSize: math.MaxInt32})
+		if err != nil {
+			return nil, err
+		}
+		transcript.add(history, true)
+		return transcript, nil
+	}
+
+	// Merge the branches, the messages without message_id are on every branch
+	for i, branch := range branches {
+		history, err := s.GetHistoryWithFilter(sid, cid, store.ChatFilter{Branch: branch.ID, Silent: &silent, PageSize: math.MaxInt32})
+		if err != nil {
+			return nil, err
+		}
+		transcript.add(history, i == 0)
+		if branch.Active {
+			transcript.HeadID = branch.ID
+		}
+	}
+
+	sort.SliceStable(transcript.History, func(i, j int) bool {
+		ti, _ := store.ParseTime(transcript.History[i]["created_at"])
+		tj, _ := store.ParseTime(transcript.History[j]["created_at"])
+		return ti.Before(tj)
+	})
+	return transcript, nil
+}
+
+// Import restore the transcript into the store, returns the chat id
+// The chat id of the transcript is used if cid is empty, the chat must not exist.
+func Import(s store.Store, sid string, transcript *Transcript, cid string) (string, error) {
+	if transcript == nil {
+		return "", fmt.Errorf("transcript is required")
+	}
+
+	if len(transcript.History) == 0 {
+		return "", fmt.Errorf("the transcript has no messages")
+	}
+
+	if cid == "" {
+		cid = transcript.ChatID
+	}
+
+	if cid == "" {
+		return "", fmt.Errorf("chat_id is required")
+	}
+
+	chat, err := s.GetChat(sid, cid)
+	if err != nil {
+		return "", err
+	}
+
+	if chat != nil {
+		return "", fmt.Errorf("chat %s already exists", cid)
+	}
+
+	// One message a time, the context and the silent flag are saved with the message
+	for _, row := range transcript.History {
+		message := map[string]interface{}{"role": toString(row["role"]), "content": row["content"]}
+		if _, ok := row["content"].(string); !ok {
+			raw, err := jsoniter.MarshalToString(row["content"])
+			if err != nil {
+				return "", err
+			}
+			message["content"] = raw
+		}
+
+		for _, key := range []string{"name", "assistant_id", "assistant_name", "assistant_avatar", "message_id"} {
+			if value := toString(row[key]); value != "" {
+				message[key] = value
+			}
+		}
+
+		if createdAt, ok := store.ParseTime(row["created_at"]); ok {
+			message["created_at"] = createdAt
+		}
+
+		// Keep the tree, the legacy messages without message_id are chained in order
+		if toString(row["message_id"]) != "" {
+			message["parent_id"] = store.BranchRoot
+			if parent := toString(row["parent_id"]); parent != "" {
+				message["parent_id"] = parent
+			}
+		}
+
+		if mentions := toSlice(row["mentions"]); len(mentions) > 0 {
+			message["mentions"] = mentions
+		}
+
+		context := toMap(row["context"])
+		delete(context, "parent_id")
+		delete(context, "silent")
+		if transcript.AssistantID != "" {
+			context["assistant_id"] = transcript.AssistantID
+		}
+		if toBool(row["silent"]) {
+			context["silent"] = true
+		}
+
+		err := s.SaveHistory(sid, []map[string]interface{}{message}, cid, context)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if transcript.Title != "" {
+		err := s.UpdateChatTitle(sid, cid, transcript.Title)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if transcript.HeadID != "" {
+		err := s.SwitchBranch(sid, cid, transcript.HeadID)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return cid, nil
+}
+
+// Importable check the format and the content type of the transcript to import, only the JSON transcripts can be imported
+func Importable(format string, contentType string) error {
+	switch strings.ToLower(format) {
+	case "", FormatJSON:
+	default:
+		return ErrNotJSON
+	}
+
+	switch strings.ToLower(contentType) {
+	case "text/markdown", "text/html":
+		return ErrNotJSON
+	}
+	return nil
+}
+
+// Parse parse the JSON transcript, returns ErrNotJSON if the data is not a JSON object (e.g. the md or html transcript)
+func Parse(data []byte) (*Transcript, error) {
+	text := bytes.TrimSpace(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")))
+	if len(text) == 0 || text[0] != '{' {
+		return nil, ErrNotJSON
+	}
+
+	var transcript Transcript
+	err := jsoniter.Unmarshal(text, &transcript)
+	if err != nil {
+		return nil, err
+	}
+
+	if transcript.Version == 0 || transcript.Version > Version {
+		return nil, fmt.Errorf("transcript version %d is not supported", transcript.Version)
+	}
+	return &transcript, nil
+}
+
+// Render render the transcript in the format
+func (transcript *Transcript) Render(format string) ([]byte, error) {
+	switch strings.ToLower(format) {
+	case "", FormatJSON:
+		return jsoniter.MarshalIndent(transcript, "", "  ")
+	case FormatMarkdown, "markdown":
+		return transcript.markdown()
+	case FormatHTML:
+		return transcript.html()
+	}
+	return nil, fmt.Errorf("format %s is not supported, should be one of md, json, html", format)
+}
+
+// ContentType returns the content type and the file extension of the format
+func ContentType(format string) (string, string) {
+	switch strings.ToLower(format) {
+	case FormatMarkdown, "markdown":
+		return "text/markdown; charset=utf-8", "md"
+	case FormatHTML:
+		return "text/html; charset=utf-8", "html"
+	}
+	return "application/json; charset=utf-8", "json"
+}
+
+// add append the history records, the messages without message_id are added when legacy is true
+func (transcript *Transcript) add(history []map[string]interface{}, legacy bool) {
+	added := map[string]bool{}
+	for _, row := range transcript.History {
+		if id := toString(row["message_id"]); id != "" {
+			added[id] = true
+		}
+	}
+
+	for _, row := range history {
+		id := toString(row["message_id"])
+		if (id == "" && !legacy) || (id != "" && added[id]) {
+			continue
+		}
+		added[id] = true
+
+		record := map[string]interface{}{}
+		for _, key := range historyFields {
+			if value, has := row[key]; has && value != nil {
+				record[key] = value
+			}
+		}
+		transcript.History = append(transcript.History, record)
+	}
+}
+
+func toString(v interface{}) string {
+	switch value := v.(type) {
+	case string:
+		return value
+	case []byte:
+		return string(value)
+	case nil:
+		return ""
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+func toBool(v interface{}) bool {
+	switch value := v.(type) {
+	case bool:
+		return value
+	case int:
+		return value == 1
+	case int64:
+		return value == 1
+	case float64:
+		return value == 1
+	case string:
+		return value == "1" || value == "true"
+	}
+	return false
+}
+
+// toMap returns the map of the JSON object (string or map), an empty map if invalid
+func toMap(v interface{}) map[string]interface{} {
+	res := map[string]interface{}{}
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for key, item := range value {
+			res[key] = item
+		}
+	case string:
+		jsoniter.UnmarshalFromString(value, &res)
+	}
+	return res
+}
+
+// toSlice returns the slice of the JSON array (string or slice), nil if invalid
+func toSlice(v interface{}) []interface{} {
+	switch value := v.(type) {
+	case []interface{}:
+		return value
+	case string:
+		var res []interface{}
+		jsoniter.UnmarshalFromString(value, &res)
+		return res
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+package transcript
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yaoapp/yao/config"
+	"github.com/yaoapp/yao/neo/store"
+	"github.com/yaoapp/yao/test"
+)
+
+func TestExportImport(t *testing.T) {
+	test.Prepare(t, config.Conf)
+	defer test.Clean()
+
+	s := prepare(t)
+	transcript, err := Export(s, "sid-transcript", "chat-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, Version, transcript.Version)
+	assert.Equal(t, "Weather", transcript.Title)
+	assert.Equal(t, 5, len(transcript.History))
+	assert.NotEmpty(t, transcript.HeadID)
+
+	// Round trip into another store
+	raw, err := transcript.Render(FormatJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	target, err := store.NewMemory(store.Setting{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cid, err := Import(target, "sid-transcript", parsed, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "chat-1", cid)
+
+	imported, err := Export(target, "sid-transcript", "chat-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, transcript.Title, imported.Title)
+	assert.Equal(t, transcript.HeadID, imported.HeadID)
+	assert.Equal(t, len(transcript.History), len(imported.History))
+	for i, row := range transcript.History {
+		assert.Equal(t, row["message_id"], imported.History[i]["message_id"])
+		assert.Equal(t, row["content"], imported.History[i]["content"])
+		assert.Equal(t, toBool(row["silent"]), toBool(imported.History[i]["silent"]))
+
+		// The time of the messages is kept
+		createdAt, ok := store.ParseTime(row["created_at"])
+		assert.True(t, ok)
+		importedAt, _ := store.ParseTime(imported.History[i]["created_at"])
+		assert.True(t, createdAt.Equal(importedAt))
+	}
+
+	branches, err := target.GetBranches("sid-transcript", "chat-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 2, len(branches))
+
+	// The chat exists
+	_, err = Import(target, "sid-transcript", parsed, "")
+	assert.Contains(t, err.Error(), "already exists")
+
+	cid, err = Import(target, "sid-transcript", parsed, "chat-2")
+	assert.Nil(t, err)
+	assert.Equal(t, "chat-2", cid)
+
+	_, err = Parse([]byte(`{"version": 99, "history": []}`))
+	assert.NotNil(t, err)
+
+	// Only the JSON transcripts can be imported
+	for _, format := range []string{FormatMarkdown, FormatHTML} {
+		data, err := transcript.Render(format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = Parse(data)
+		assert.Equal(t, ErrNotJSON, err)
+		assert.Equal(t, ErrNotJSON, Importable(format, ""))
+	}
+
+	assert.Nil(t, Importable("", "application/json"))
+	assert.Nil(t, Importable(FormatJSON, ""))
+	assert.Equal(t, ErrNotJSON, Importable("", "text/markdown"))
+}
+
+func TestRender(t *testing.T) {
+	test.Prepare(t, config.Conf)
+	defer test.Clean()
+
+	transcript, err := Export(prepare(t), "sid-transcript", "chat-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := transcript.Render(FormatMarkdown)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	md := string(raw)
+	assert.Contains(t, md, "# Weather")
+	assert.Contains(t, md, "[paris.png](https://example.com/paris.png) (image/png, 1024 bytes)")
+	assert.Contains(t, md, "> **Thinking**\n>\n> The user asks the weather")
+	assert.Contains(t, md, "**Tool call: `weather`**")
+	assert.Contains(t, md, "\"city\": \"Paris\"")
+	assert.Contains(t, md, "**Result**\n\n```\nSunny <b>25°C</b>\n```")
+	assert.Contains(t, md, "It is sunny in Paris")
+	assert.Contains(t, md, "**chart**")
+	assert.NotContains(t, md, "the summary")
+	assert.NotContains(t, md, "How about London?")
+
+	raw, err = transcript.Render(FormatHTML)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	html := string(raw)
+	assert.Contains(t, html, "<title>Weather</title>")
+	assert.Contains(t, html, `<a href="https://example.com/paris.png">paris.png</a>`)
+	assert.Contains(t, html, "Sunny &lt;b&gt;25°C&lt;/b&gt;")
+	assert.NotContains(t, html, "How about London?")
+
+	// The other branch
+	transcript.HeadID = transcript.History[len(transcript.History)-1]["message_id"].(string)
+	raw, err = transcript.Render(FormatMarkdown)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Contains(t, string(raw), "How about London?")
+	assert.NotContains(t, string(raw), "Tool call")
+
+	_, err = transcript.Render("pdf")
+	assert.NotNil(t, err)
+}
+
+func prepare(t *testing.T) store.Store {
+	s, err := store.NewMemory(store.Setting{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sid := "sid-transcript"
+	context := map[string]interface{}{"assistant_id": "weather"}
+	err = s.SaveHistory(sid, []map[string]interface{}{
+		{"role": "user", "content": `{"text":"What is the weather in Paris?","attachments":[{"name":"paris.png","url":"https://example.com/paris.png","content_type":"image/png","bytes":1024}]}`, "name": sid},
+		{"role": "assistant", "content": `[` +
+			`{"type":"think","props":{"text":"The user asks the weather"}},` +
+			`{"type":"tool","props":{"text":"<tool>{\"function\":\"weather\",\"arguments\":{\"city\":\"Paris\"}}</tool>","executed":true,"result":"Sunny <b>25°C</b>"}},` +
+			`{"type":"text","text":"It is sunny in Paris"},` +
+			`{"type":"chart","props":{"data":[1,2,3]}}` +
+			`]`, "name": "weather", "assistant_id": "weather", "assistant_name": "Weather Bot"},
+	}, "chat-1", context)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.SaveHistory(sid, []map[string]interface{}{{"role": "system", "content": "the summary"}}, "chat-1", map[string]interface{}{"assistant_id": "weather", "silent": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Edit the first message, then switch back to the first branch
+	history, err := s.GetHistory(sid, "chat-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.SaveHistory(sid, []map[string]interface{}{
+		{"role": "user", "content": "How about London?", "name": sid},
+		{"role": "assistant", "content": `[{"type":"text","text":"It is raining"}]`, "name": "weather"},
+	}, "chat-1", map[string]interface{}{"assistant_id": "weather", "parent_id": store.BranchRoot})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.SwitchBranch(sid, "chat-1", history[1]["message_id"].(string))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.UpdateChatTitle(sid, "chat-1", "Weather")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
@@ -27,6 +27,7 @@ type DSL struct {
 	Prompts       []assistant.Prompt                    `json:"prompts,omitempty" yaml:"prompts,omitempty"`
 	Allows        []string                              `json:"allows,omitempty" yaml:"allows,omitempty"`
 	Connectors    map[string]assistant.ConnectorSetting `json:"connectors,omitempty" yaml:"connectors,omitempty"`
+	History       assistant.HistorySetting              `json:"history,omitempty" yaml:"history,omitempty"`
 	Assistant     assistant.API                         `json:"-" yaml:"-"` // The default assistant
 	Store         store.Store                           `json:"-" yaml:"-"`
 	RAG           *rag.RAG                              `json:"-" yaml:"-"`
@@ -0,0 +1,355 @@
+package openai
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/yaoapp/gou/http"
+	"github.com/yaoapp/kun/exception"
+)
+
+// anthropicVersion the version of the Anthropic Messages API
+const anthropicVersion = "2023-06-01"
+
+// anthropicMessages Creates a model response with the Anthropic Messages API
+// The request is translated from, and the response is translated to the OpenAI chat completions format.
+// https://docs.anthropic.com/en/api/messages
+func (openai OpenAI) anthropicMessages(ctx context.Context, messages []map[string]interface{}, option map[string]interface{}, cb func(data []byte) int) (interface{}, *exception.Exception) {
+
+	payload := openai.anthropicPayload(messages, option)
+	url := fmt.Sprintf("%s%s/messages", openai.host, openai.baseURL)
+	req := http.New(url).WithHeader(map[string][]string{
+		"Content-Type":      {"application/json; charset=utf-8"},
+		"X-Api-Key":         {openai.key},
+		"Anthropic-Version": {anthropicVersion},
+	})
+
+	if cb == nil {
+		payload["stream"] = false
+		res := req.Post(payload)
+		if err := openai.isError(res); err != nil {
+			return nil, err
+		}
+		return anthropicCompletion(res.Data)
+	}
+
+	payload["stream"] = true
+	stream := &anthropicStream{writer: newChunkWriter(fmt.Sprintf("%v", payload["model"]), cb), tools: map[int]int{}}
+	err := req.Stream(ctx, "POST", payload, stream.write)
+	if stream.writer.flush() {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, exception.New(err.Error(), 500)
+	}
+	return nil, nil
+}
+
+// anthropicPayload translate the OpenAI chat completions request to the Anthropic Messages request
+func (openai OpenAI) anthropicPayload(messages []map[string]interface{}, option map[string]interface{}) map[string]interface{} {
+
+	model := openai.model
+	if v, ok := option["model"].(string); ok && v != "" {
+		model = v
+	}
+
+	maxTokens := openai.maxToken
+	if v, ok := optionInt(option, "max_tokens", "max_completion_tokens"); ok {
+		maxTokens = v
+	}
+
+	system := []string{}
+	contents := []map[string]interface{}{}
+	for _, message := range messages {
+		role, _ := message["role"].(string)
+		switch role {
+		case "system", "developer":
+			for _, part := range messageParts(message["content"]) {
+				if text, ok := part["text"].(string); ok && text != "" {
+					system = append(system, text)
+				}
+			}
+
+		case "tool":
+			id, _ := message["tool_call_id"].(string)
+			content := []map[string]interface{}{}
+			for _, part := range messageParts(message["content"]) {
+				if text, ok := part["text"].(string); ok {
+					content = append(content, map[string]interface{}{"type": "text", "text": text})
+				}
+			}
+			contents = append(contents, map[string]interface{}{
+				"role":    "user",
+				"content": []map[string]interface{}{{"type": "tool_result", "tool_use_id": id, "content": content}},
+			})
+
+		default:
+			if role != "assistant" {
+				role = "user"
+			}
+
+			content := anthropicContent(message["content"])
+			for _, call := range messageToolCalls(message) {
+				input := map[string]interface{}{}
+				if strings.TrimSpace(call.Function.Arguments) != "" {
+					jsoniter.UnmarshalFromString(call.Function.Arguments, &input)
+				}
+				content = append(content, map[string]interface{}{"type": "tool_use", "id": call.ID, "name": call.Function.Name, "input": input})
+			}
+
+			if len(content) == 0 {
+				continue
+			}
+			contents = append(contents, map[string]interface{}{"role": role, "content": content})
+		}
+	}
+
+	payload := map[string]interface{}{
+		"model":      model,
+		"max_tokens": maxTokens,
+		"messages":   contents,
+	}
+
+	if len(system) > 0 {
+		payload["system"] = strings.Join(system, "\n\n")
+	}
+
+	for _, key := range []string{"temperature", "top_p", "top_k", "metadata", "thinking"} {
+		if v, has := option[key]; has && v != nil {
+			payload[key] = v
+		}
+	}
+
+	if stop := optionStrings(option, "stop"); len(stop) > 0 {
+		payload["stop_sequences"] = stop
+	}
+
+	// Tools
+	if v, has := option["tools"]; has && v != nil {
+		tools := []map[string]interface{}{}
+		for _, fn := range functionTools(v) {
+			tool := map[string]interface{}{"name": fn["name"], "input_schema": map[string]interface{}{"type": "object"}}
+			if desc, has := fn["description"]; has {
+				tool["description"] = desc
+			}
+			if params, has := fn["parameters"]; has {
+				tool["input_schema"] = params
+			}
+			tools = append(tools, tool)
+		}
+
+		if len(tools) > 0 {
+			payload["tools"] = tools
+			switch mode, name := toolChoice(option["tool_choice"]); mode {
+			case "auto":
+				payload["tool_choice"] = map[string]interface{}{"type": "auto"}
+			case "none":
+				payload["tool_choice"] = map[string]interface{}{"type": "none"}
+			case "required":
+				payload["tool_choice"] = map[string]interface{}{"type": "any"}
+			case "function":
+				payload["tool_choice"] = map[string]interface{}{"type": "tool", "name": name}
+			}
+		}
+	}
+
+	return payload
+}
+
+// anthropicContent translate the OpenAI message content to the Anthropic content blocks
+func anthropicContent(content interface{}) []map[string]interface{} {
+	blocks := []map[string]interface{}{}
+	for _, part := range messageParts(content) {
+		switch part["type"] {
+		case "text":
+			if text, ok := part["text"].(string); ok && text != "" {
+				blocks = append(blocks, map[string]interface{}{"type": "text", "text": text})
+			}
+
+		case "image_url":
+			url := ""
+			switch v := part["image_url"].(type) {
+			case string:
+				url = v
+			case map[string]interface{}:
+				url, _ = v["url"].(string)
+			}
+			if url == "" {
+				continue
+			}
+
+			mediaType, data, link := imageURL(url)
+			source := map[string]interface{}{"type": "url", "url": link}
+			if link == "" {
+				source = map[string]interface{}{"type": "base64", "media_type": mediaType, "data": data}
+			}
+			blocks = append(blocks, map[string]interface{}{"type": "image", "source": source})
+		}
+	}
+	return blocks
+}
+
+// anthropicCompletion translate the Anthropic Messages response to the OpenAI chat completions response
+func anthropicCompletion(data interface{}) (interface{}, *exception.Exception) {
+	var res struct {
+		ID      string `json:"id"`
+		Model   string `json:"model"`
+		Content []struct {
+			Type     string                 `json:"type"`
+			Text     string                 `json:"text"`
+			Thinking string                 `json:"thinking"`
+			ID       string                 `json:"id"`
+			Name     string                 `json:"name"`
+			Input    map[string]interface{} `json:"input"`
+		} `json:"content"`
+		StopReason string `json:"stop_reason"`
+		Usage      struct {
+			InputTokens  int `json:"input_tokens"`
+			OutputTokens int `json:"output_tokens"`
+		} `json:"usage"`
+	}
+
+	raw, err := jsoniter.Marshal(data)
+	if err != nil {
+		return nil, exception.New(err.Error(), 500)
+	}
+	if err := jsoniter.Unmarshal(raw, &res); err != nil {
+		return nil, exception.New("Anthropic response format error, %s", 500, err.Error())
+	}
+
+	content := ""
+	reasoning := ""
+	toolCalls := []ToolCall{}
+	for _, block := range res.Content {
+		switch block.Type {
+		case "text":
+			content += block.Text
+		case "thinking":
+			reasoning += block.Thinking
+		case "tool_use":
+			args, _ := jsoniter.MarshalToString(block.Input)
+			toolCalls = append(toolCalls, ToolCall{Index: len(toolCalls), ID: block.ID, Type: "function", Function: Function{Name: block.Name, Arguments: args}})
+		}
+	}
+
+	return completion(res.ID, res.Model, content, reasoning, toolCalls, anthropicFinishReason(res.StopReason), res.Usage.InputTokens, res.Usage.OutputTokens), nil
+}
+
+// anthropicFinishReason returns the OpenAI finish reason of the Anthropic stop reason
+func anthropicFinishReason(reason string) string {
+	switch reason {
+	case "end_turn", "stop_sequence", "pause_turn":
+		return "stop"
+	case "max_tokens":
+		return "length"
+	case "tool_use":
+		return "tool_calls"
+	case "refusal":
+		return "content_filter"
+	}
+	return reason
+}
+
+// anthropicStream translate the Anthropic Messages events to the OpenAI chat.completion.chunk stream
+// https://docs.anthropic.com/en/api/messages-streaming
+type anthropicStream struct {
+	writer *chunkWriter
+	tools  map[int]int // content block index => tool call index
+}
+
+// anthropicEvent the Anthropic Messages stream event
+type anthropicEvent struct {
+	Type    string `json:"type"`
+	Index   int    `json:"index"`
+	Message struct {
+		ID    string `json:"id"`
+		Model string `json:"model"`
+	} `json:"message"`
+	ContentBlock struct {
+		Type string `json:"type"`
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"content_block"`
+	Delta struct {
+		Type        string `json:"type"`
+		Text        string `json:"text"`
+		Thinking    string `json:"thinking"`
+		PartialJSON string `json:"partial_json"`
+		StopReason  string `json:"stop_reason"`
+	} `json:"delta"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (stream *anthropicStream) write(line []byte) int {
+	data, ok := stream.writer.data(line)
+	if !ok {
+		return 1 // continue
+	}
+
+	var event anthropicEvent
+	if err := jsoniter.Unmarshal(data, &event); err != nil {
+		return stream.writer.error("parse_error", fmt.Sprintf("%s %s", err.Error(), data))
+	}
+
+	switch event.Type {
+	case "message_start":
+		if event.Message.ID != "" {
+			stream.writer.id = event.Message.ID
+		}
+		if event.Message.Model != "" {
+			stream.writer.model = event.Message.Model
+		}
+		return stream.writer.delta(ChatCompletionChunkDelta{Role: "assistant"}, "")
+
+	case "content_block_start":
+		if event.ContentBlock.Type != "tool_use" {
+			return 1
+		}
+		index := len(stream.tools)
+		stream.tools[event.Index] = index
+		return stream.writer.delta(ChatCompletionChunkDelta{ToolCalls: []ToolCall{{
+			Index:    index,
+			ID:       event.ContentBlock.ID,
+			Type:     "function",
+			Function: Function{Name: event.ContentBlock.Name},
+		}}}, "")
+
+	case "content_block_delta":
+		switch event.Delta.Type {
+		case "text_delta":
+			return stream.writer.delta(ChatCompletionChunkDelta{Content: event.Delta.Text}, "")
+
+		case "thinking_delta":
+			return stream.writer.delta(ChatCompletionChunkDelta{ReasoningContent: event.Delta.Thinking}, "")
+
+		case "input_json_delta":
+			return stream.writer.delta(ChatCompletionChunkDelta{ToolCalls: []ToolCall{{
+				Index:    stream.tools[event.Index],
+				Function: Function{Arguments: event.Delta.PartialJSON},
+			}}}, "")
+		}
+		return 1
+
+	case "message_delta":
+		if event.Delta.StopReason == "" {
+			return 1
+		}
+		return stream.writer.delta(ChatCompletionChunkDelta{}, anthropicFinishReason(event.Delta.StopReason))
+
+	case "message_stop":
+		stream.writer.done()
+		return 0 // break
+
+	case "error":
+		stream.writer.error(event.Error.Type, event.Error.Message)
+		return 0 // break
+	}
+
+	return 1 // ping, content_block_stop
+}
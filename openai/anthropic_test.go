@@ -0,0 +1,193 @@
+package openai
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnthropicChatCompletions(t *testing.T) {
+	var request map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/messages", r.URL.Path)
+		assert.Equal(t, "test-key", r.Header.Get("X-Api-Key"))
+		assert.Equal(t, anthropicVersion, r.Header.Get("Anthropic-Version"))
+		body, _ := io.ReadAll(r.Body)
+		jsoniter.Unmarshal(body, &request)
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"msg_01","type":"message","role":"assistant","model":"claude-3-5-sonnet","content":[`+
+			`{"type":"thinking","thinking":"Let me think"},{"type":"text","text":"Hello"},`+
+			`{"type":"tool_use","id":"toolu_01","name":"weather","input":{"city":"Paris"}}],`+
+			`"stop_reason":"tool_use","usage":{"input_tokens":10,"output_tokens":5}}`)
+	}))
+	defer server.Close()
+
+	openai := prepareProvider(t, ProviderAnthropic, server.URL)
+	data, err := openai.ChatCompletions([]map[string]interface{}{
+		{"role": "system", "content": "You are a helpful assistant"},
+		{"role": "user", "content": []interface{}{
+			map[string]interface{}{"type": "text", "text": "What is in the image?"},
+			map[string]interface{}{"type": "image_url", "image_url": map[string]interface{}{"url": "data:image/png;base64,iVBORw0KGgo="}},
+		}},
+	}, map[string]interface{}{"tools": []interface{}{weatherTool()}, "tool_choice": "auto", "temperature": 0.5}, nil)
+	if err != nil {
+		t.Fatal(err.Message)
+	}
+
+	// Request
+	assert.Equal(t, "You are a helpful assistant", request["system"])
+	assert.Equal(t, float64(1024), request["max_tokens"])
+	assert.Equal(t, map[string]interface{}{"type": "auto"}, request["tool_choice"])
+	messages := request["messages"].([]interface{})
+	assert.Equal(t, 1, len(messages))
+	content := messages[0].(map[string]interface{})["content"].([]interface{})
+	assert.Equal(t, "image", content[1].(map[string]interface{})["type"])
+	assert.Equal(t, "image/png", content[1].(map[string]interface{})["source"].(map[string]interface{})["media_type"])
+	tool := request["tools"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "weather", tool["name"])
+	assert.NotNil(t, tool["input_schema"])
+
+	// Response
+	text, err := openai.GetContent(data)
+	if err != nil {
+		t.Fatal(err.Message)
+	}
+	assert.Equal(t, "Hello", text)
+
+	choice := data.(map[string]interface{})["choices"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "tool_calls", choice["finish_reason"])
+	message := choice["message"].(map[string]interface{})
+	assert.Equal(t, "Let me think", message["reasoning_content"])
+	call := message["tool_calls"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, `{"city":"Paris"}`, call["function"].(map[string]interface{})["arguments"])
+}
+
+func TestAnthropicChatCompletionsStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		events := []string{
+			`{"type":"message_start","message":{"id":"msg_01","model":"claude-3-5-sonnet","content":[]}}`,
+			`{"type":"content_block_start","index":0,"content_block":{"type":"thinking","thinking":""}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"Hmm"}}`,
+			`{"type":"content_block_stop","index":0}`,
+			`{"type":"content_block_start","index":1,"content_block":{"type":"text","text":""}}`,
+			`{"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"Checking"}}`,
+			`{"type":"content_block_stop","index":1}`,
+			`{"type":"ping"}`,
+			`{"type":"content_block_start","index":2,"content_block":{"type":"tool_use","id":"toolu_01","name":"weather","input":{}}}`,
+			`{"type":"content_block_delta","index":2,"delta":{"type":"input_json_delta","partial_json":"{\"city\":"}}`,
+			`{"type":"content_block_delta","index":2,"delta":{"type":"input_json_delta","partial_json":"\"Paris\"}"}}`,
+			`{"type":"content_block_stop","index":2}`,
+			`{"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":15}}`,
+			`{"type":"message_stop"}`,
+		}
+		for _, event := range events {
+			var e map[string]interface{}
+			jsoniter.UnmarshalFromString(event, &e)
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e["type"], event)
+		}
+	}))
+	defer server.Close()
+
+	openai := prepareProvider(t, ProviderAnthropic, server.URL)
+	chunks := collect(t, openai, nil)
+
+	assert.Equal(t, "data: [DONE]", chunks[len(chunks)-1])
+	deltas := chunkDeltas(t, chunks[:len(chunks)-1])
+	assert.Equal(t, "assistant", deltas[0].Delta.Role)
+	assert.Equal(t, "Hmm", deltas[1].Delta.ReasoningContent)
+	assert.Equal(t, "Checking", deltas[2].Delta.Content)
+	assert.Equal(t, "toolu_01", deltas[3].Delta.ToolCalls[0].ID)
+	assert.Equal(t, "weather", deltas[3].Delta.ToolCalls[0].Function.Name)
+	assert.Equal(t, `{"city":`, deltas[4].Delta.ToolCalls[0].Function.Arguments)
+	assert.Equal(t, `"Paris"}`, deltas[5].Delta.ToolCalls[0].Function.Arguments)
+	assert.Equal(t, "tool_calls", deltas[6].FinishReason)
+}
+
+func TestAnthropicChatCompletionsError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"type":"error","error":{"type":"invalid_request_error","message":"max_tokens: Field required"}}`)
+	}))
+	defer server.Close()
+
+	openai := prepareProvider(t, ProviderAnthropic, server.URL)
+	_, err := openai.ChatCompletions([]map[string]interface{}{{"role": "user", "content": "hello"}}, nil, nil)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Message, "max_tokens: Field required")
+
+	// The error is written to the stream or returned
+	chunks := []string{}
+	_, err = openai.ChatCompletions([]map[string]interface{}{{"role": "user", "content": "hello"}}, nil, func(data []byte) int {
+		chunks = append(chunks, string(data))
+		return 1
+	})
+	if err == nil {
+		assert.Contains(t, strings.Join(chunks, "\n"), `"message":"max_tokens: Field required"`)
+	}
+}
+
+func prepareProvider(t *testing.T, provider string, host string) *OpenAI {
+	openai, err := NewOpenAI(map[string]interface{}{
+		"provider":  provider,
+		"host":      host,
+		"key":       "test-key",
+		"model":     "test-model",
+		"max_token": 1024,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, provider, openai.Provider())
+	return openai
+}
+
+func collect(t *testing.T, openai *OpenAI, option map[string]interface{}) []string {
+	chunks := []string{}
+	_, err := openai.ChatCompletions([]map[string]interface{}{{"role": "user", "content": "hello"}}, option, func(data []byte) int {
+		chunks = append(chunks, string(data))
+		return 1
+	})
+	if err != nil {
+		t.Fatal(err.Message)
+	}
+	return chunks
+}
+
+func chunkDeltas(t *testing.T, chunks []string) []ChatCompletionChunkChoice {
+	choices := []ChatCompletionChunkChoice{}
+	for _, data := range chunks {
+		var chunk ChatCompletionChunk
+		err := jsoniter.UnmarshalFromString(strings.TrimPrefix(data, "data: "), &chunk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, "chat.completion.chunk", chunk.Object)
+		choices = append(choices, chunk.Choices[0])
+	}
+	return choices
+}
+
+func weatherTool() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "function",
+		"function": map[string]interface{}{
+			"name":        "weather",
+			"description": "Get the weather of a city",
+			"parameters": map[string]interface{}{
+				"type":                 "object",
+				"properties":           map[string]interface{}{"city": map[string]interface{}{"type": "string"}},
+				"required":             []string{"city"},
+				"additionalProperties": false,
+			},
+		},
+	}
+}
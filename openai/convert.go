@@ -0,0 +1,302 @@
+package openai
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"path/filepath"
+	"strings"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// chunkWriter writes the provider stream as the OpenAI chat.completion.chunk stream
+type chunkWriter struct {
+	id      string
+	model   string
+	created int64
+	cb      func(data []byte) int
+	raw     bytes.Buffer // The lines out of the event stream (error response)
+	closed  bool
+}
+
+func newChunkWriter(model string, cb func(data []byte) int) *chunkWriter {
+	return &chunkWriter{
+		id:      fmt.Sprintf("chatcmpl-%d", time.Now().UnixNano()),
+		model:   model,
+		created: time.Now().Unix(),
+		cb:      cb,
+	}
+}
+
+// delta write a delta chunk
+func (w *chunkWriter) delta(delta ChatCompletionChunkDelta, finishReason string) int {
+	chunk := ChatCompletionChunk{
+		ID:      w.id,
+		Object:  "chat.completion.chunk",
+		Created: w.created,
+		Model:   w.model,
+		Choices: []ChatCompletionChunkChoice{{Index: 0, Delta: delta, FinishReason: finishReason}},
+	}
+
+	raw, err := jsoniter.Marshal(chunk)
+	if err != nil {
+		return w.error("chunk_error", err.Error())
+	}
+	return w.cb(append([]byte("data: "), raw...))
+}
+
+// error write an OpenAI format error
+func (w *chunkWriter) error(code string, message string) int {
+	raw, _ := jsoniter.Marshal(map[string]interface{}{
+		"error": map[string]interface{}{"code": code, "message": message, "type": code},
+	})
+	return w.cb(raw)
+}
+
+// done write the end of the stream
+func (w *chunkWriter) done() int {
+	if w.closed {
+		return 0
+	}
+	w.closed = true
+	return w.cb([]byte("data: [DONE]"))
+}
+
+// data returns the payload of a server-sent event line, false if the line is not a data line
+func (w *chunkWriter) data(line []byte) ([]byte, bool) {
+	text := bytes.TrimSpace(line)
+	switch {
+	case len(text) == 0, bytes.HasPrefix(text, []byte("event:")), bytes.HasPrefix(text, []byte(":")):
+		return nil, false
+
+	case bytes.HasPrefix(text, []byte("data:")):
+		return bytes.TrimSpace(bytes.TrimPrefix(text, []byte("data:"))), true
+	}
+
+	// The error response is not an event stream
+	w.raw.Write(text)
+	return nil, false
+}
+
+// flush write the error response collected from the stream, returns false if there is nothing to write
+func (w *chunkWriter) flush() bool {
+	if w.raw.Len() == 0 {
+		return false
+	}
+
+	code, message := providerError(w.raw.Bytes())
+	w.raw.Reset()
+	w.error(code, message)
+	return true
+}
+
+// providerError returns the code and message of the Anthropic or Gemini error response
+func providerError(raw []byte) (string, string) {
+	var res struct {
+		Error struct {
+			Type    string      `json:"type"`
+			Status  string      `json:"status"`
+			Code    interface{} `json:"code"`
+			Message string      `json:"message"`
+		} `json:"error"`
+	}
+
+	if err := jsoniter.Unmarshal(raw, &res); err != nil || res.Error.Message == "" {
+		return "provider_error", string(raw)
+	}
+
+	code := res.Error.Type
+	if code == "" {
+		code = res.Error.Status
+	}
+	if code == "" && res.Error.Code != nil {
+		code = fmt.Sprintf("%v", res.Error.Code)
+	}
+	return code, res.Error.Message
+}
+
+// completion returns the OpenAI format response of the chat completion
+func completion(id string, model string, content string, reasoning string, toolCalls []ToolCall, finishReason string, promptTokens int, completionTokens int) interface{} {
+	message := map[string]interface{}{"role": "assistant", "content": content}
+	if reasoning != "" {
+		message["reasoning_content"] = reasoning
+	}
+	if len(toolCalls) > 0 {
+		message["tool_calls"] = toolCalls
+	}
+
+	res := map[string]interface{}{
+		"id":      id,
+		"object":  "chat.completion",
+		"created": time.Now().Unix(),
+		"model":   model,
+		"choices": []interface{}{
+			map[string]interface{}{"index": 0, "message": message, "finish_reason": finishReason},
+		},
+		"usage": map[string]interface{}{
+			"prompt_tokens":     promptTokens,
+			"completion_tokens": completionTokens,
+			"total_tokens":      promptTokens + completionTokens,
+		},
+	}
+
+	// Same types as the decoded OpenAI response
+	var data interface{}
+	raw, _ := jsoniter.Marshal(res)
+	jsoniter.Unmarshal(raw, &data)
+	return data
+}
+
+// imageURL returns the media type and the base64 data of a data url, or the url itself
+// data:image/png;base64,xxxx => "image/png", "xxxx", ""
+// https://example.com/a.png => "image/png", "", "https://example.com/a.png"
+func imageURL(url string) (string, string, string) {
+	if strings.HasPrefix(url, "data:") {
+		meta, data, found := strings.Cut(strings.TrimPrefix(url, "data:"), ",")
+		if !found {
+			return "", "", url
+		}
+		mediaType, _, _ := strings.Cut(meta, ";")
+		return mediaType, data, ""
+	}
+
+	mediaType := mime.TypeByExtension(strings.ToLower(filepath.Ext(strings.Split(url, "?")[0])))
+	if mediaType == "" {
+		mediaType = "image/jpeg"
+	}
+	return mediaType, "", url
+}
+
+// messageParts returns the content parts of an OpenAI message
+func messageParts(content interface{}) []map[string]interface{} {
+	switch v := content.(type) {
+	case string:
+		return []map[string]interface{}{{"type": "text", "text": v}}
+
+	case []map[string]interface{}:
+		return v
+
+	case []interface{}:
+		parts := []map[string]interface{}{}
+		for _, part := range v {
+			if p, ok := part.(map[string]interface{}); ok {
+				parts = append(parts, p)
+			}
+		}
+		return parts
+
+	case nil:
+		return []map[string]interface{}{}
+	}
+
+	raw, _ := jsoniter.Marshal(content)
+	parts := []map[string]interface{}{}
+	if err := jsoniter.Unmarshal(raw, &parts); err != nil {
+		return []map[string]interface{}{{"type": "text", "text": fmt.Sprintf("%v", content)}}
+	}
+	return parts
+}
+
+// messageToolCalls returns the tool calls of an OpenAI assistant message
+func messageToolCalls(message map[string]interface{}) []ToolCall {
+	v, has := message["tool_calls"]
+	if !has || v == nil {
+		return nil
+	}
+
+	if calls, ok := v.([]ToolCall); ok {
+		return calls
+	}
+
+	calls := []ToolCall{}
+	raw, _ := jsoniter.Marshal(v)
+	jsoniter.Unmarshal(raw, &calls)
+	return calls
+}
+
+// functionTools returns the function definitions of the OpenAI tools option
+func functionTools(tools interface{}) []map[string]interface{} {
+	var items []struct {
+		Type     string `json:"type"`
+		Function struct {
+			Name        string                 `json:"name"`
+			Description string                 `json:"description,omitempty"`
+			Parameters  map[string]interface{} `json:"parameters,omitempty"`
+		} `json:"function"`
+	}
+
+	raw, err := jsoniter.Marshal(tools)
+	if err != nil {
+		return nil
+	}
+	if err := jsoniter.Unmarshal(raw, &items); err != nil {
+		return nil
+	}
+
+	functions := []map[string]interface{}{}
+	for _, item := range items {
+		if item.Function.Name == "" {
+			continue
+		}
+		fn := map[string]interface{}{"name": item.Function.Name}
+		if item.Function.Description != "" {
+			fn["description"] = item.Function.Description
+		}
+		if item.Function.Parameters != nil {
+			fn["parameters"] = item.Function.Parameters
+		}
+		functions = append(functions, fn)
+	}
+	return functions
+}
+
+// toolChoice returns the mode and the function name of the OpenAI tool_choice option
+// "auto", "none", "required" or {"type": "function", "function": {"name": "xxx"}}
+func toolChoice(choice interface{}) (string, string) {
+	switch v := choice.(type) {
+	case string:
+		return v, ""
+	case map[string]interface{}:
+		if fn, ok := v["function"].(map[string]interface{}); ok {
+			name, _ := fn["name"].(string)
+			return "function", name
+		}
+	}
+	return "", ""
+}
+
+// optionInt returns the first integer option of the keys
+func optionInt(option map[string]interface{}, keys ...string) (int, bool) {
+	for _, key := range keys {
+		switch v := option[key].(type) {
+		case int:
+			return v, true
+		case int64:
+			return int(v), true
+		case float64:
+			return int(v), true
+		}
+	}
+	return 0, false
+}
+
+// optionStrings returns the string or string array option
+func optionStrings(option map[string]interface{}, key string) []string {
+	switch v := option[key].(type) {
+	case string:
+		return []string{v}
+	case []string:
+		return v
+	case []interface{}:
+		values := []string{}
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
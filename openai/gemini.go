@@ -0,0 +1,415 @@
+package openai
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/yaoapp/gou/http"
+	"github.com/yaoapp/kun/exception"
+)
+
+// geminiGenerateContent Creates a model response with the Gemini API
+// The request is translated from, and the response is translated to the OpenAI chat completions format.
+// https://ai.google.dev/api/generate-content
+func (openai OpenAI) geminiGenerateContent(ctx context.Context, messages []map[string]interface{}, option map[string]interface{}, cb func(data []byte) int) (interface{}, *exception.Exception) {
+
+	model := openai.model
+	if v, ok := option["model"].(string); ok && v != "" {
+		model = v
+	}
+
+	payload := geminiPayload(messages, option)
+	header := map[string][]string{
+		"Content-Type":   {"application/json; charset=utf-8"},
+		"X-Goog-Api-Key": {openai.key},
+	}
+
+	if cb == nil {
+		url := fmt.Sprintf("%s%s/models/%s:generateContent", openai.host, openai.baseURL, model)
+		res := http.New(url).WithHeader(header).Post(payload)
+		if err := openai.isError(res); err != nil {
+			return nil, err
+		}
+		return geminiCompletion(model, res.Data)
+	}
+
+	url := fmt.Sprintf("%s%s/models/%s:streamGenerateContent?alt=sse", openai.host, openai.baseURL, model)
+	stream := &geminiStream{writer: newChunkWriter(model, cb)}
+	err := http.New(url).WithHeader(header).Stream(ctx, "POST", payload, stream.write)
+	if stream.writer.flush() {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, exception.New(err.Error(), 500)
+	}
+
+	// The Gemini stream has no end event
+	stream.finish()
+	return nil, nil
+}
+
+// geminiPayload translate the OpenAI chat completions request to the Gemini generateContent request
+func geminiPayload(messages []map[string]interface{}, option map[string]interface{}) map[string]interface{} {
+
+	system := []map[string]interface{}{}
+	contents := []map[string]interface{}{}
+	functions := map[string]string{} // tool call id => function name
+	for _, message := range messages {
+		role, _ := message["role"].(string)
+		switch role {
+		case "system", "developer":
+			for _, part := range messageParts(message["content"]) {
+				if text, ok := part["text"].(string); ok && text != "" {
+					system = append(system, map[string]interface{}{"text": text})
+				}
+			}
+
+		case "tool":
+			id, _ := message["tool_call_id"].(string)
+			name := functions[id]
+			if name == "" {
+				name, _ = message["name"].(string)
+			}
+
+			text := []string{}
+			for _, part := range messageParts(message["content"]) {
+				if v, ok := part["text"].(string); ok {
+					text = append(text, v)
+				}
+			}
+
+			var response interface{}
+			if err := jsoniter.UnmarshalFromString(strings.Join(text, ""), &response); err != nil {
+				response = strings.Join(text, "")
+			}
+			if _, ok := response.(map[string]interface{}); !ok {
+				response = map[string]interface{}{"result": response}
+			}
+
+			contents = append(contents, map[string]interface{}{
+				"role":  "user",
+				"parts": []map[string]interface{}{{"functionResponse": map[string]interface{}{"name": name, "response": response}}},
+			})
+
+		default:
+			if role == "assistant" {
+				role = "model"
+			} else {
+				role = "user"
+			}
+
+			parts := geminiParts(message["content"])
+			for _, call := range messageToolCalls(message) {
+				args := map[string]interface{}{}
+				if strings.TrimSpace(call.Function.Arguments) != "" {
+					jsoniter.UnmarshalFromString(call.Function.Arguments, &args)
+				}
+				functions[call.ID] = call.Function.Name
+				parts = append(parts, map[string]interface{}{"functionCall": map[string]interface{}{"name": call.Function.Name, "args": args}})
+			}
+
+			if len(parts) == 0 {
+				continue
+			}
+			contents = append(contents, map[string]interface{}{"role": role, "parts": parts})
+		}
+	}
+
+	payload := map[string]interface{}{"contents": contents}
+	if len(system) > 0 {
+		payload["systemInstruction"] = map[string]interface{}{"parts": system}
+	}
+
+	// Generation config
+	config := map[string]interface{}{}
+	if v, has := option["temperature"]; has && v != nil {
+		config["temperature"] = v
+	}
+	if v, has := option["top_p"]; has && v != nil {
+		config["topP"] = v
+	}
+	if v, has := option["top_k"]; has && v != nil {
+		config["topK"] = v
+	}
+	if v, ok := optionInt(option, "max_tokens", "max_completion_tokens"); ok {
+		config["maxOutputTokens"] = v
+	}
+	if stop := optionStrings(option, "stop"); len(stop) > 0 {
+		config["stopSequences"] = stop
+	}
+	if v, has := option["thinking"]; has && v != nil {
+		config["thinkingConfig"] = v
+	}
+	if format, ok := option["response_format"].(map[string]interface{}); ok {
+		switch format["type"] {
+		case "json_object":
+			config["responseMimeType"] = "application/json"
+		case "json_schema":
+			config["responseMimeType"] = "application/json"
+			if v, ok := format["json_schema"].(map[string]interface{}); ok {
+				if schema, ok := v["schema"].(map[string]interface{}); ok {
+					config["responseSchema"] = geminiSchema(schema)
+				}
+			}
+		}
+	}
+	if len(config) > 0 {
+		payload["generationConfig"] = config
+	}
+
+	// Tools
+	if v, has := option["tools"]; has && v != nil {
+		declarations := []map[string]interface{}{}
+		for _, fn := range functionTools(v) {
+			if params, ok := fn["parameters"].(map[string]interface{}); ok {
+				fn["parameters"] = geminiSchema(params)
+			}
+			declarations = append(declarations, fn)
+		}
+
+		if len(declarations) > 0 {
+			payload["tools"] = []map[string]interface{}{{"functionDeclarations": declarations}}
+			mode, name := toolChoice(option["tool_choice"])
+			switch mode {
+			case "auto":
+				payload["toolConfig"] = map[string]interface{}{"functionCallingConfig": map[string]interface{}{"mode": "AUTO"}}
+			case "none":
+				payload["toolConfig"] = map[string]interface{}{"functionCallingConfig": map[string]interface{}{"mode": "NONE"}}
+			case "required":
+				payload["toolConfig"] = map[string]interface{}{"functionCallingConfig": map[string]interface{}{"mode": "ANY"}}
+			case "function":
+				payload["toolConfig"] = map[string]interface{}{"functionCallingConfig": map[string]interface{}{"mode": "ANY", "allowedFunctionNames": []string{name}}}
+			}
+		}
+	}
+
+	return payload
+}
+
+// geminiParts translate the OpenAI message content to the Gemini parts
+func geminiParts(content interface{}) []map[string]interface{} {
+	parts := []map[string]interface{}{}
+	for _, part := range messageParts(content) {
+		switch part["type"] {
+		case "text":
+			if text, ok := part["text"].(string); ok && text != "" {
+				parts = append(parts, map[string]interface{}{"text": text})
+			}
+
+		case "image_url":
+			url := ""
+			switch v := part["image_url"].(type) {
+			case string:
+				url = v
+			case map[string]interface{}:
+				url, _ = v["url"].(string)
+			}
+			if url == "" {
+				continue
+			}
+
+			mediaType, data, link := imageURL(url)
+			if link == "" {
+				parts = append(parts, map[string]interface{}{"inlineData": map[string]interface{}{"mimeType": mediaType, "data": data}})
+				continue
+			}
+			parts = append(parts, map[string]interface{}{"fileData": map[string]interface{}{"mimeType": mediaType, "fileUri": link}})
+		}
+	}
+	return parts
+}
+
+// geminiSchema remove the JSON schema keywords that Gemini does not support
+func geminiSchema(schema map[string]interface{}) map[string]interface{} {
+	res := map[string]interface{}{}
+	for key, value := range schema {
+		switch key {
+		case "additionalProperties", "strict", "$schema":
+			continue
+		}
+
+		switch v := value.(type) {
+		case map[string]interface{}:
+			res[key] = geminiSchema(v)
+		case []interface{}:
+			items := []interface{}{}
+			for _, item := range v {
+				if m, ok := item.(map[string]interface{}); ok {
+					items = append(items, geminiSchema(m))
+					continue
+				}
+				items = append(items, item)
+			}
+			res[key] = items
+		default:
+			res[key] = value
+		}
+	}
+	return res
+}
+
+// geminiResponse the Gemini generateContent response
+type geminiResponse struct {
+	ResponseID   string `json:"responseId"`
+	ModelVersion string `json:"modelVersion"`
+	Candidates   []struct {
+		Content struct {
+			Parts []struct {
+				Text         string `json:"text"`
+				Thought      bool   `json:"thought"`
+				FunctionCall *struct {
+					ID   string                 `json:"id"`
+					Name string                 `json:"name"`
+					Args map[string]interface{} `json:"args"`
+				} `json:"functionCall"`
+			} `json:"parts"`
+		} `json:"content"`
+		FinishReason string `json:"finishReason"`
+	} `json:"candidates"`
+	UsageMetadata struct {
+		PromptTokenCount     int `json:"promptTokenCount"`
+		CandidatesTokenCount int `json:"candidatesTokenCount"`
+		ThoughtsTokenCount   int `json:"thoughtsTokenCount"`
+	} `json:"usageMetadata"`
+}
+
+// geminiCompletion translate the Gemini generateContent response to the OpenAI chat completions response
+func geminiCompletion(model string, data interface{}) (interface{}, *exception.Exception) {
+	var res geminiResponse
+	raw, err := jsoniter.Marshal(data)
+	if err != nil {
+		return nil, exception.New(err.Error(), 500)
+	}
+	if err := jsoniter.Unmarshal(raw, &res); err != nil {
+		return nil, exception.New("Gemini response format error, %s", 500, err.Error())
+	}
+
+	if res.ModelVersion != "" {
+		model = res.ModelVersion
+	}
+
+	content := ""
+	reasoning := ""
+	finishReason := "stop"
+	toolCalls := []ToolCall{}
+	for _, candidate := range res.Candidates {
+		for _, part := range candidate.Content.Parts {
+			switch {
+			case part.FunctionCall != nil:
+				args, _ := jsoniter.MarshalToString(part.FunctionCall.Args)
+				id := part.FunctionCall.ID
+				if id == "" {
+					id = fmt.Sprintf("call_%d", len(toolCalls))
+				}
+				toolCalls = append(toolCalls, ToolCall{Index: len(toolCalls), ID: id, Type: "function", Function: Function{Name: part.FunctionCall.Name, Arguments: args}})
+			case part.Thought:
+				reasoning += part.Text
+			default:
+				content += part.Text
+			}
+		}
+		finishReason = geminiFinishReason(candidate.FinishReason, len(toolCalls) > 0)
+		break // The first candidate only
+	}
+
+	usage := res.UsageMetadata
+	return completion(res.ResponseID, model, content, reasoning, toolCalls, finishReason, usage.PromptTokenCount, usage.CandidatesTokenCount+usage.ThoughtsTokenCount), nil
+}
+
+// geminiFinishReason returns the OpenAI finish reason of the Gemini finish reason
+func geminiFinishReason(reason string, toolCalls bool) string {
+	switch reason {
+	case "STOP":
+		if toolCalls {
+			return "tool_calls"
+		}
+		return "stop"
+	case "MAX_TOKENS":
+		return "length"
+	case "":
+		return ""
+	}
+	return "content_filter" // SAFETY, RECITATION, BLOCKLIST, PROHIBITED_CONTENT ...
+}
+
+// geminiStream translate the Gemini stream to the OpenAI chat.completion.chunk stream
+type geminiStream struct {
+	writer   *chunkWriter
+	tools    int  // The number of the tool calls
+	finished bool // The finish reason is written
+}
+
+func (stream *geminiStream) write(line []byte) int {
+	data, ok := stream.writer.data(line)
+	if !ok {
+		return 1 // continue
+	}
+
+	var res geminiResponse
+	if err := jsoniter.Unmarshal(data, &res); err != nil {
+		return stream.writer.error("parse_error", fmt.Sprintf("%s %s", err.Error(), data))
+	}
+
+	if res.ResponseID != "" {
+		stream.writer.id = res.ResponseID
+	}
+	if res.ModelVersion != "" {
+		stream.writer.model = res.ModelVersion
+	}
+
+	if len(res.Candidates) == 0 {
+		return 1
+	}
+
+	candidate := res.Candidates[0]
+	for _, part := range candidate.Content.Parts {
+		var delta ChatCompletionChunkDelta
+		switch {
+		case part.FunctionCall != nil:
+			args, _ := jsoniter.MarshalToString(part.FunctionCall.Args)
+			id := part.FunctionCall.ID
+			if id == "" {
+				id = fmt.Sprintf("call_%d", stream.tools)
+			}
+			delta.ToolCalls = []ToolCall{{Index: stream.tools, ID: id, Type: "function", Function: Function{Name: part.FunctionCall.Name, Arguments: args}}}
+			stream.tools++
+
+		case part.Thought:
+			delta.ReasoningContent = part.Text
+
+		default:
+			delta.Content = part.Text
+		}
+
+		if delta.Content == "" && delta.ReasoningContent == "" && len(delta.ToolCalls) == 0 {
+			continue
+		}
+
+		if stream.writer.delta(delta, "") == 0 {
+			return 0 // break
+		}
+	}
+
+	if candidate.FinishReason != "" {
+		stream.finished = true
+		if stream.writer.delta(ChatCompletionChunkDelta{}, geminiFinishReason(candidate.FinishReason, stream.tools > 0)) == 0 {
+			return 0 // break
+		}
+		stream.writer.done()
+		return 0 // break
+	}
+
+	return 1 // continue
+}
+
+// finish write the end of the stream
+func (stream *geminiStream) finish() {
+	if !stream.finished {
+		stream.finished = true
+		stream.writer.delta(ChatCompletionChunkDelta{}, geminiFinishReason("STOP", stream.tools > 0))
+	}
+	stream.writer.done()
+}
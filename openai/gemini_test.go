@@ -0,0 +1,138 @@
+package openai
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGeminiChatCompletions(t *testing.T) {
+	var request map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1beta/models/test-model:generateContent", r.URL.Path)
+		assert.Equal(t, "test-key", r.Header.Get("X-Goog-Api-Key"))
+		body, _ := io.ReadAll(r.Body)
+		jsoniter.Unmarshal(body, &request)
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"candidates":[{"content":{"role":"model","parts":[`+
+			`{"text":"Thinking","thought":true},{"text":"Hello"},{"functionCall":{"name":"weather","args":{"city":"Paris"}}}]},`+
+			`"finishReason":"STOP"}],"usageMetadata":{"promptTokenCount":10,"candidatesTokenCount":5},"modelVersion":"gemini-2.0-flash","responseId":"resp_01"}`)
+	}))
+	defer server.Close()
+
+	openai := prepareProvider(t, ProviderGemini, server.URL)
+	data, err := openai.ChatCompletions([]map[string]interface{}{
+		{"role": "system", "content": "You are a helpful assistant"},
+		{"role": "user", "content": []interface{}{
+			map[string]interface{}{"type": "text", "text": "What is in the image?"},
+			map[string]interface{}{"type": "image_url", "image_url": map[string]interface{}{"url": "https://example.com/cat.png"}},
+		}},
+		{"role": "assistant", "content": "", "tool_calls": []interface{}{
+			map[string]interface{}{"id": "call_0", "type": "function", "function": map[string]interface{}{"name": "weather", "arguments": `{"city":"Paris"}`}},
+		}},
+		{"role": "tool", "tool_call_id": "call_0", "content": `{"weather":"sunny"}`},
+	}, map[string]interface{}{
+		"tools":       []interface{}{weatherTool()},
+		"tool_choice": "required",
+		"max_tokens":  100,
+		"response_format": map[string]interface{}{
+			"type":        "json_schema",
+			"json_schema": map[string]interface{}{"name": "weather", "schema": weatherTool()["function"].(map[string]interface{})["parameters"]},
+		},
+	}, nil)
+	if err != nil {
+		t.Fatal(err.Message)
+	}
+
+	// Request
+	system := request["systemInstruction"].(map[string]interface{})["parts"].([]interface{})
+	assert.Equal(t, "You are a helpful assistant", system[0].(map[string]interface{})["text"])
+	config := request["generationConfig"].(map[string]interface{})
+	assert.Equal(t, float64(100), config["maxOutputTokens"])
+	assert.Equal(t, "application/json", config["responseMimeType"])
+	assert.NotContains(t, config["responseSchema"], "additionalProperties")
+
+	contents := request["contents"].([]interface{})
+	assert.Equal(t, 3, len(contents))
+	image := contents[0].(map[string]interface{})["parts"].([]interface{})[1].(map[string]interface{})
+	assert.Equal(t, "image/png", image["fileData"].(map[string]interface{})["mimeType"])
+	assert.Equal(t, "model", contents[1].(map[string]interface{})["role"])
+	response := contents[2].(map[string]interface{})["parts"].([]interface{})[0].(map[string]interface{})["functionResponse"].(map[string]interface{})
+	assert.Equal(t, "weather", response["name"])
+	assert.Equal(t, map[string]interface{}{"weather": "sunny"}, response["response"])
+
+	declaration := request["tools"].([]interface{})[0].(map[string]interface{})["functionDeclarations"].([]interface{})[0].(map[string]interface{})
+	assert.NotContains(t, declaration["parameters"], "additionalProperties")
+	assert.Equal(t, "ANY", request["toolConfig"].(map[string]interface{})["functionCallingConfig"].(map[string]interface{})["mode"])
+
+	// Response
+	text, err := openai.GetContent(data)
+	if err != nil {
+		t.Fatal(err.Message)
+	}
+	assert.Equal(t, "Hello", text)
+
+	choice := data.(map[string]interface{})["choices"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "tool_calls", choice["finish_reason"])
+	message := choice["message"].(map[string]interface{})
+	assert.Equal(t, "Thinking", message["reasoning_content"])
+	call := message["tool_calls"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "weather", call["function"].(map[string]interface{})["name"])
+}
+
+func TestGeminiChatCompletionsStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1beta/models/test-model:streamGenerateContent", r.URL.Path)
+		assert.Equal(t, "sse", r.URL.Query().Get("alt"))
+		w.Header().Set("Content-Type", "text/event-stream")
+		chunks := []string{
+			`{"candidates":[{"content":{"role":"model","parts":[{"text":"Hmm","thought":true}]}}],"responseId":"resp_01"}`,
+			`{"candidates":[{"content":{"role":"model","parts":[{"text":"Hello"}]}}]}`,
+			`{"candidates":[{"content":{"role":"model","parts":[{"functionCall":{"name":"weather","args":{"city":"Paris"}}}]},"finishReason":"STOP"}]}`,
+		}
+		for _, chunk := range chunks {
+			fmt.Fprintf(w, "data: %s\r\n\r\n", chunk)
+		}
+	}))
+	defer server.Close()
+
+	openai := prepareProvider(t, ProviderGemini, server.URL)
+	chunks := collect(t, openai, nil)
+
+	assert.Equal(t, "data: [DONE]", chunks[len(chunks)-1])
+	deltas := chunkDeltas(t, chunks[:len(chunks)-1])
+	assert.Equal(t, 4, len(deltas))
+	assert.Equal(t, "Hmm", deltas[0].Delta.ReasoningContent)
+	assert.Equal(t, "Hello", deltas[1].Delta.Content)
+	assert.Equal(t, "call_0", deltas[2].Delta.ToolCalls[0].ID)
+	assert.Equal(t, `{"city":"Paris"}`, deltas[2].Delta.ToolCalls[0].Function.Arguments)
+	assert.Equal(t, "tool_calls", deltas[3].FinishReason)
+}
+
+func TestProviderHost(t *testing.T) {
+	openai, err := NewOpenAI(map[string]interface{}{"host": "https://api.anthropic.com", "key": "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, ProviderAnthropic, openai.Provider())
+	assert.Equal(t, "/v1", openai.baseURL)
+
+	openai, err = NewOpenAI(map[string]interface{}{"provider": "gemini", "key": "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "https://generativelanguage.googleapis.com", openai.host)
+	assert.Equal(t, "/v1beta", openai.baseURL)
+
+	openai, err = NewOpenAI(map[string]interface{}{"key": "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, ProviderOpenAI, openai.Provider())
+}
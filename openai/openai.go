@@ -31,7 +31,40 @@ type OpenAI struct {
 	baseURL      string
 	organization string
 	maxToken     int
-	azure        bool // Azure Credentials, "true" or "false" or ""
+	window       int    // The context window of the model, 0 for the known models (see ContextWindows)
+	azure        bool   // Azure Credentials, "true" or "false" or ""
+	provider     string // The wire format of the API, openai, anthropic or gemini
+}
+
+// Providers
+const (
+	// ProviderOpenAI the OpenAI chat completions API (default)
+	ProviderOpenAI = "openai"
+	// ProviderAnthropic the Anthropic Messages API
+	ProviderAnthropic = "anthropic"
+	// ProviderGemini the Google Gemini API
+	ProviderGemini = "gemini"
+)
+
+// DefaultContextWindow the context window of the unknown models
+const DefaultContextWindow = 8192
+
+// ContextWindows the context window of the models by the model name prefix, the longest prefix matches
+var ContextWindows = map[string]int{
+	"gpt-3.5-turbo": 16385,
+	"gpt-4":         8192,
+	"gpt-4-32k":     32768,
+	"gpt-4-turbo":   128000,
+	"gpt-4o":        128000,
+	"gpt-4.1":       1047576,
+	"o1":            200000,
+	"o3":            200000,
+	"o4":            200000,
+	"claude":        200000,
+	"gemini-1.5":    1048576,
+	"gemini-2":      1048576,
+	"deepseek":      65536,
+	"qwen":          32768,
 }
 
 // New create a new OpenAI instance by connector id
@@ -72,8 +105,28 @@ func NewOpenAI(setting map[string]interface{}) (*OpenAI, error) {
 		model = v
 	}
 
+	provider := ProviderOpenAI
+	if v, ok := setting["provider"].(string); ok && v != "" {
+		provider = strings.ToLower(v)
+	} else if v, ok := setting["host"].(string); ok {
+		switch {
+		case strings.Contains(v, "anthropic.com"):
+			provider = ProviderAnthropic
+		case strings.Contains(v, "generativelanguage.googleapis.com"):
+			provider = ProviderGemini
+		}
+	}
+
 	host := "https://api.openai.com"
 	baseURL := "/v1"
+	switch provider {
+	case ProviderAnthropic:
+		host = "https://api.anthropic.com"
+	case ProviderGemini:
+		host = "https://generativelanguage.googleapis.com"
+		baseURL = "/v1beta"
+	}
+
 	if v, ok := setting["host"].(string); ok {
 		// Trim trailing slashes
 		v = strings.TrimRight(v, "/")
@@ -95,6 +148,11 @@ func NewOpenAI(setting map[string]interface{}) (*OpenAI, error) {
 		maxToken = v
 	}
 
+	window := 0
+	if v, ok := setting["context_window"].(int); ok {
+		window = v
+	}
+
 	azure := false
 	if v, ok := setting["azure"].(string); ok {
 		azure = v == "true" || v == "1"
@@ -107,7 +165,9 @@ func NewOpenAI(setting map[string]interface{}) (*OpenAI, error) {
 		baseURL:      baseURL,
 		organization: organization,
 		maxToken:     maxToken,
+		window:       window,
 		azure:        azure,
+		provider:     provider,
 	}, nil
 }
 
@@ -150,6 +210,14 @@ func (openai OpenAI) Model() string {
 	return openai.model
 }
 
+// Provider get the provider
+func (openai OpenAI) Provider() string {
+	if openai.provider == "" {
+		return ProviderOpenAI
+	}
+	return openai.provider
+}
+
 // Completions Creates a completion for the provided prompt and parameters.
 // https://platform.openai.com/docs/api-reference/completions/create
 func (openai OpenAI) Completions(prompt interface{}, option map[string]interface{}, cb func(data []byte) int) (interface{}, *exception.Exception) {
@@ -190,6 +258,14 @@ func (openai OpenAI) ChatCompletions(messages []map[string]interface{}, option m
 	if option == nil {
 		option = map[string]interface{}{}
 	}
+
+	switch openai.provider {
+	case ProviderAnthropic:
+		return openai.anthropicMessages(context.Background(), messages, option, cb)
+	case ProviderGemini:
+		return openai.geminiGenerateContent(context.Background(), messages, option, cb)
+	}
+
 	option["messages"] = messages
 
 	if cb != nil {
@@ -207,6 +283,14 @@ func (openai OpenAI) ChatCompletionsWith(ctx context.Context, messages []map[str
 	if option == nil {
 		option = map[string]interface{}{}
 	}
+
+	switch openai.provider {
+	case ProviderAnthropic:
+		return openai.anthropicMessages(ctx, messages, option, cb)
+	case ProviderGemini:
+		return openai.geminiGenerateContent(ctx, messages, option, cb)
+	}
+
 	option["messages"] = messages
 
 	if cb != nil {
@@ -335,6 +419,23 @@ func (openai OpenAI) MaxToken() int {
 	return openai.maxToken
 }
 
+// ContextWindow get the context window of the model
+// The context_window of the connector overrides the known models, see ContextWindows
+func (openai OpenAI) ContextWindow() int {
+	if openai.window > 0 {
+		return openai.window
+	}
+
+	model := strings.ToLower(openai.model)
+	window, size := DefaultContextWindow, 0
+	for prefix, tokens := range ContextWindows {
+		if len(prefix) > size && strings.HasPrefix(model, prefix) {
+			window, size = tokens, len(prefix)
+		}
+	}
+	return window
+}
+
 // GetContent get the content of chat completions
 func (openai OpenAI) GetContent(response interface{}) (string, *exception.Exception) {
 	if response == nil {
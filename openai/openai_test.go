@@ -244,6 +244,15 @@ func TestImageVariations(t *testing.T) {
 }
 
 // ProcessTiktoken get number of tokens
+func TestContextWindow(t *testing.T) {
+	assert.Equal(t, 128000, OpenAI{model: "gpt-4o-mini"}.ContextWindow())
+	assert.Equal(t, 8192, OpenAI{model: "gpt-4"}.ContextWindow())
+	assert.Equal(t, 32768, OpenAI{model: "gpt-4-32k-0613"}.ContextWindow())
+	assert.Equal(t, 200000, OpenAI{model: "claude-3-5-sonnet-latest"}.ContextWindow())
+	assert.Equal(t, DefaultContextWindow, OpenAI{model: "unknown"}.ContextWindow())
+	assert.Equal(t, 4096, OpenAI{model: "gpt-4o", window: 4096}.ContextWindow())
+}
+
 func TestTiktoken(t *testing.T) {
 	test.Prepare(t, config.Conf)
 	defer test.Clean()
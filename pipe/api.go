@@ -0,0 +1,199 @@
+package pipe
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	jsoniter "github.com/json-iterator/go"
+)
+
+// apiRequest the request body of the run endpoint
+type apiRequest struct {
+	Args    []any          `json:"args,omitempty"`
+	Payload map[string]any `json:"payload,omitempty"` // $payload, the client data is never merged into $global
+}
+
+// apiResume the request body of the resume endpoint, the data of the context is not changed by the client
+type apiResume struct {
+	Args []any `json:"args,omitempty"`
+}
+
+// API registers the pipe HTTP API endpoints, the guards are the middlewares of the endpoints (e.g. bearer-jwt)
+// Only the pipes with "api": true can be run, the endpoints response with the server-sent events if the request accepts text/event-stream.
+func API(router *gin.Engine, path string, guards ...gin.HandlerFunc) {
+
+	// Run the pipe
+	// curl -X POST 'http://localhost:5099/api/__yao/pipes/web.translator/run' \
+	//   -H 'Content-Type: application/json' -d '{"args": ["hello"], "payload": {"foo": "bar"}}'
+	router.POST(path+"/:id/run", append(guards, handleRun)...)
+
+	// Get the context waiting for the user input
+	// curl -X GET 'http://localhost:5099/api/__yao/pipes/contexts/<context.id>'
+	router.GET(path+"/contexts/:cid", append(guards, handleContext)...)
+
+	// Resume the context with the user input
+	// curl -X POST 'http://localhost:5099/api/__yao/pipes/contexts/<context.id>/resume' \
+	//   -H 'Content-Type: application/json' -d '{"args": ["translate", "hello"]}'
+	router.POST(path+"/contexts/:cid/resume", append(guards, handleResume)...)
+}
+
+// handleRun handles running the pipe
+func handleRun(c *gin.Context) {
+	pipe, err := Get(c.Param("id"))
+	if err != nil {
+		c.JSON(404, gin.H{"code": 404, "message": err.Error()})
+		return
+	}
+
+	if !pipe.API {
+		c.JSON(403, gin.H{"code": 403, "message": fmt.Sprintf("pipe %s is not exposed to the API", pipe.ID)})
+		return
+	}
+
+	var req apiRequest
+	if err := bindRequest(c, &req); err != nil {
+		c.JSON(400, gin.H{"code": 400, "message": err.Error()})
+		return
+	}
+
+	ctx := pipe.Create().
+		With(c.Request.Context()).
+		WithPayload(req.Payload).
+		WithSid(c.GetString("__sid"))
+
+	respond(c, ctx, func() (any, error) { return ctx.Exec(req.Args...) })
+}
+
+// handleContext handles getting the context waiting for the user input
+func handleContext(c *gin.Context) {
+	ctx, ok := openContext(c)
+	if !ok {
+		return
+	}
+
+	if ctx.current == nil || ctx.current.Type != "user-input" {
+		c.JSON(200, gin.H{"id": ctx.id, "status": "running"})
+		return
+	}
+
+	res, err := ctx.resumeContext(ctx.current, ctx.in[ctx.current])
+	if err != nil {
+		c.JSON(500, gin.H{"code": 500, "message": err.Error()})
+		return
+	}
+	c.JSON(200, result(ctx, res))
+}
+
+// handleResume handles resuming the context with the user input
+func handleResume(c *gin.Context) {
+	ctx, ok := openContext(c)
+	if !ok {
+		return
+	}
+
+	if ctx.current == nil || ctx.current.Type != "user-input" {
+		c.JSON(409, gin.H{"code": 409, "message": fmt.Sprintf("context %s is not waiting for the user input", ctx.id)})
+		return
+	}
+
+	var req apiResume
+	if err := bindRequest(c, &req); err != nil {
+		c.JSON(400, gin.H{"code": 400, "message": err.Error()})
+		return
+	}
+
+	ctx.With(c.Request.Context())
+	respond(c, ctx, func() (any, error) { return ctx.resume(req.Args...) })
+}
+
+// openContext open the context and check the session, the contexts of other sessions and the contexts without session are not allowed
+func openContext(c *gin.Context) (*Context, bool) {
+	ctx, err := Open(c.Param("cid"))
+	if err != nil {
+		c.JSON(404, gin.H{"code": 404, "message": err.Error()})
+		return nil, false
+	}
+
+	if !ctx.root().API {
+		c.JSON(403, gin.H{"code": 403, "message": fmt.Sprintf("pipe %s is not exposed to the API", ctx.root().ID)})
+		return nil, false
+	}
+
+	sid := c.GetString("__sid")
+	if ctx.sid == "" || sid == "" || ctx.sid != sid {
+		c.JSON(403, gin.H{"code": 403, "message": "Not Authorized"})
+		return nil, false
+	}
+	return ctx, true
+}
+
+// respond execute the pipe and write the result, the progress events are sent if the request accepts text/event-stream
+func respond(c *gin.Context, ctx *Context, exec func() (any, error)) {
+	if !strings.Contains(c.GetHeader("Accept"), "text/event-stream") {
+		output, err := exec()
+		if err != nil {
+			c.JSON(500, gin.H{"code": 500, "message": err.Error()})
+			return
+		}
+		c.JSON(200, result(ctx, output))
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream;charset=utf-8")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+
+	// The sub-pipes of the parallel and foreach nodes report at the same time
+	var mutex sync.Mutex
+	send := func(event string, data any) {
+		raw, err := jsoniter.Marshal(data)
+		if err != nil {
+			raw, _ = jsoniter.Marshal(gin.H{"code": 500, "message": err.Error()})
+			event = "error"
+		}
+
+		mutex.Lock()
+		defer mutex.Unlock()
+		fmt.Fprintf(c.Writer, "event: %s\ndata: %s\n\n", event, raw)
+		c.Writer.Flush()
+	}
+
+	ctx.WithProgress(func(p Progress) { send("progress", p) })
+	defer ctx.WithProgress(nil)
+
+	output, err := exec()
+	if err != nil {
+		send("error", gin.H{"code": 500, "message": err.Error()})
+		return
+	}
+	send("result", result(ctx, output))
+}
+
+// result the response of the pipe, status is done or waiting (for the user input)
+func result(ctx *Context, output any) gin.H {
+	res := gin.H{"id": ctx.id, "status": "done", "output": output}
+	if resume, ok := output.(ResumeContext); ok {
+		res = gin.H{"id": ctx.id, "status": "waiting", "context": resume}
+	}
+
+	if usage := ctx.Usage(); len(usage.Nodes) > 0 {
+		res["usage"] = usage
+	}
+	return res
+}
+
+// bindRequest bind the request body, the body is optional
+func bindRequest(c *gin.Context, req any) error {
+	if c.Request.Body == nil || c.Request.ContentLength == 0 {
+		return nil
+	}
+
+	err := jsoniter.NewDecoder(c.Request.Body).Decode(req)
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("invalid request body: %s", err.Error())
+	}
+	return nil
+}
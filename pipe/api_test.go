@@ -0,0 +1,158 @@
+package pipe
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/yaoapp/yao/config"
+	"github.com/yaoapp/yao/test"
+)
+
+func TestAPI(t *testing.T) {
+	test.Prepare(t, config.Conf)
+	defer test.Clean()
+
+	router, approval := prepareAPI(t)
+
+	// Run
+	res := request(router, "POST", "/pipes/unit.approval/run", `{"args": ["hello"], "payload": {"user": "alice"}, "global": {"role": "admin"}}`, "")
+	assert.Equal(t, 200, res.Code)
+
+	data := map[string]interface{}{}
+	jsoniter.Unmarshal(res.Body.Bytes(), &data)
+	assert.Equal(t, "waiting", data["status"])
+
+	id := data["id"].(string)
+	resume := data["context"].(map[string]interface{})
+	assert.Equal(t, "web", resume["__ui"])
+	assert.Equal(t, "Confirm HELLO", resume["label"])
+
+	// Context
+	res = request(router, "GET", "/pipes/contexts/"+id, "", "")
+	assert.Equal(t, 200, res.Code)
+	data = map[string]interface{}{}
+	jsoniter.Unmarshal(res.Body.Bytes(), &data)
+	assert.Equal(t, "waiting", data["status"])
+	assert.Equal(t, "Confirm HELLO", data["context"].(map[string]interface{})["label"])
+
+	// Other sessions
+	res = request(router, "GET", "/pipes/contexts/"+id, "", "sid-other")
+	assert.Equal(t, 403, res.Code)
+
+	// Resume, the global data can not be changed by the client
+	res = request(router, "POST", "/pipes/contexts/"+id+"/resume", `{"args": ["yes"], "global": {"role": "admin"}}`, "")
+	assert.Equal(t, 200, res.Code)
+	data = map[string]interface{}{}
+	jsoniter.Unmarshal(res.Body.Bytes(), &data)
+	assert.Equal(t, "done", data["status"])
+	assert.Equal(t, map[string]interface{}{"upper": "HELLO", "confirm": []interface{}{"yes"}, "user": "alice", "role": "guest"}, data["output"])
+
+	res = request(router, "GET", "/pipes/contexts/"+id, "", "")
+	assert.Equal(t, 404, res.Code)
+
+	res = request(router, "POST", "/pipes/unit.not-found/run", "", "")
+	assert.Equal(t, 404, res.Code)
+
+	// The pipe is not exposed to the API
+	res = request(router, "POST", "/pipes/unit.private/run", "", "")
+	assert.Equal(t, 403, res.Code)
+
+	// The context without session
+	ctx := approval.Create().WithGlobal(map[string]interface{}{"role": "user"})
+	_, err := ctx.Exec("hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res = request(router, "GET", "/pipes/contexts/"+ctx.id, "", "")
+	assert.Equal(t, 403, res.Code)
+	res = request(router, "POST", "/pipes/contexts/"+ctx.id+"/resume", `{"args": ["yes"]}`, "")
+	assert.Equal(t, 403, res.Code)
+}
+
+func TestAPIStream(t *testing.T) {
+	test.Prepare(t, config.Conf)
+	defer test.Clean()
+
+	router, _ := prepareAPI(t)
+	req := httptest.NewRequest("POST", "/pipes/unit.approval/run", strings.NewReader(`{"args": ["hello"]}`))
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("X-Sid", "sid-stream")
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+
+	events := map[string][]map[string]interface{}{}
+	for _, block := range strings.Split(strings.TrimSpace(res.Body.String()), "\n\n") {
+		lines := strings.SplitN(block, "\n", 2)
+		data := map[string]interface{}{}
+		jsoniter.Unmarshal([]byte(strings.TrimPrefix(lines[1], "data: ")), &data)
+		event := strings.TrimPrefix(lines[0], "event: ")
+		events[event] = append(events[event], data)
+	}
+
+	progress := events["progress"]
+	if assert.Equal(t, 4, len(progress)) {
+		assert.Equal(t, "upper", progress[0]["node"])
+		assert.Equal(t, ProgressStart, progress[0]["status"])
+		assert.Equal(t, ProgressDone, progress[1]["status"])
+		assert.Equal(t, "HELLO", progress[1]["output"])
+		assert.Equal(t, "confirm", progress[3]["node"])
+		assert.Equal(t, ProgressWaiting, progress[3]["status"])
+	}
+
+	if assert.Equal(t, 1, len(events["result"])) {
+		assert.Equal(t, "waiting", events["result"][0]["status"])
+		assert.Equal(t, progress[0]["id"], events["result"][0]["id"])
+	}
+}
+
+func prepareAPI(t *testing.T) (*gin.Engine, *Pipe) {
+	pipe, err := New([]byte(`{
+		"name": "approval",
+		"api": true,
+		"nodes": [
+			{"name": "upper", "process": {"name": "unit.pipe.upper", "args": ["{{ $in[0] }}"]}},
+			{"name": "confirm", "ui": "web", "label": "{{ 'Confirm ' + upper }}"}
+		],
+		"output": {"upper": "{{ upper }}", "confirm": "{{ confirm }}", "user": "{{ $payload.user }}", "role": "{{ $global.role ?? 'guest' }}"}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pipe.ID = "unit.approval"
+	Set(pipe.ID, pipe)
+	t.Cleanup(func() { Remove(pipe.ID) })
+
+	private, err := New([]byte(`{"name": "private", "nodes": [{"name": "upper", "process": {"name": "unit.pipe.upper", "args": ["{{ $in[0] }}"]}}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	private.ID = "unit.private"
+	Set(private.ID, private)
+	t.Cleanup(func() { Remove(private.ID) })
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	API(router, "/pipes", func(c *gin.Context) {
+		sid := c.GetHeader("X-Sid")
+		if sid == "" {
+			sid = "sid-api"
+		}
+		c.Set("__sid", sid)
+	})
+	return router, pipe
+}
+
+func request(router *gin.Engine, method string, path string, body string, sid string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if sid != "" {
+		req.Header.Set("X-Sid", sid)
+	}
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	return res
+}
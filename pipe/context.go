@@ -4,15 +4,23 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/yaoapp/kun/exception"
+	"github.com/yaoapp/kun/log"
 )
 
 var contexts = sync.Map{}
 
+// sweepInterval the interval of removing the expired contexts from the memory
+var sweepInterval = time.Minute
+var sweepOnce sync.Once
+
 // Create create new context
 func (pipe *Pipe) Create() *Context {
+	sweepOnce.Do(func() { go sweeper(sweepInterval) })
+
 	id := uuid.NewString()
 	ctx := &Context{
 		id:      id,
@@ -24,7 +32,11 @@ func (pipe *Pipe) Create() *Context {
 
 		input:  []any{},
 		output: nil,
+
+		usage:     &usageRecorder{},
+		createdAt: time.Now(),
 	}
+	ctx.expiresAt = ctx.createdAt.Add(ctx.ttl())
 
 	// Set the current node
 	if pipe.HasNodes() {
@@ -35,18 +47,109 @@ func (pipe *Pipe) Create() *Context {
 	return ctx
 }
 
-// Open the context
+// Open the context, the context waiting for the user input is restored from the store
 func Open(id string) (*Context, error) {
-	ctx, ok := contexts.Load(id)
-	if !ok {
+	if v, ok := contexts.Load(id); ok {
+		ctx := v.(*Context)
+		if time.Now().Before(ctx.expiresAt) {
+			return ctx, nil
+		}
+		ctx.close()
 		return nil, fmt.Errorf("context %s not found", id)
 	}
-	return ctx.(*Context), nil
+
+	for _, s := range allStores() {
+		state, err := s.Load(id)
+		if err != nil {
+			return nil, err
+		}
+
+		if state == nil {
+			continue
+		}
+
+		ctx, err := restore(state)
+		if err != nil {
+			return nil, err
+		}
+
+		ctx.saved = true
+		contexts.Store(id, ctx)
+		return ctx, nil
+	}
+
+	return nil, fmt.Errorf("context %s not found", id)
 }
 
 // Close the context
+// The context not in the memory (restarted, or saved by the other instances) is removed from the store holding it.
 func Close(id string) {
-	contexts.Delete(id)
+	if v, ok := contexts.Load(id); ok {
+		v.(*Context).close()
+		return
+	}
+
+	for _, s := range allStores() {
+		state, err := s.Load(id)
+		if err != nil {
+			log.Error("pipe: close the context %s %s", id, err.Error())
+			continue
+		}
+
+		if state == nil {
+			continue
+		}
+
+		if err := s.Delete(id); err != nil {
+			log.Error("pipe: close the context %s %s", id, err.Error())
+		}
+		return
+	}
+}
+
+// close remove the context from the memory, and from the store of the pipe if the context was saved
+// The forks of the parallel and foreach nodes are neither in the memory nor saved.
+func (ctx *Context) close() {
+	if ctx.forked {
+		return
+	}
+
+	contexts.Delete(ctx.id)
+	if !ctx.saved {
+		return
+	}
+
+	s, err := ctx.store()
+	if err != nil {
+		log.Error("pipe: close the context %s %s", ctx.id, err.Error())
+		return
+	}
+
+	if err := s.Delete(ctx.id); err != nil {
+		log.Error("pipe: close the context %s %s", ctx.id, err.Error())
+		return
+	}
+	ctx.saved = false
+}
+
+// sweeper remove the expired contexts from the memory at the interval
+func sweeper(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		sweep()
+	}
+}
+
+// sweep remove the expired contexts from the memory
+func sweep() {
+	now := time.Now()
+	contexts.Range(func(key, value any) bool {
+		if now.After(value.(*Context).expiresAt) {
+			contexts.Delete(key)
+		}
+		return true
+	})
 }
 
 // Resume the context by id
@@ -67,8 +170,11 @@ func (ctx *Context) resume(args ...any) (any, error) {
 	node := ctx.current
 	output, err := ctx.parseNodeOutput(node, args)
 	if err != nil {
-		return nil, node.Errorf(ctx, err.Error())
+		err = node.Errorf(ctx, err.Error())
+		ctx.report(node, ProgressError, nil, err)
+		return nil, err
 	}
+	ctx.report(node, ProgressDone, output, nil)
 
 	// Next node
 	next, eof, err := ctx.next()
@@ -78,7 +184,7 @@ func (ctx *Context) resume(args ...any) (any, error) {
 
 	// End of the pipe
 	if eof {
-		defer Close(ctx.id)
+		defer ctx.close()
 		output, err := ctx.parseOutput()
 		if err != nil {
 			return nil, err
@@ -115,48 +221,54 @@ func (ctx *Context) Exec(args ...any) (any, error) {
 // Exec and return error
 func (ctx *Context) exec(node *Node, input Input) (output any, err error) {
 
+	ctx.report(node, ProgressStart, nil, nil)
+
 	var out any
+	var pause bool = false
 	switch node.Type {
 
 	case "process":
 		out, err = node.YaoProcess(ctx, input)
-		if err != nil {
-			return nil, err
-		}
 
-	// case "request":
-	// 	err := node.ExecRequest(ctx, args)
-	// 	if err != nil {
-	// 		return nil, err
-	// 	}
+	case "request":
+		out, err = node.HTTP(ctx, input)
 
 	case "ai":
 		out, err = node.AI(ctx, input)
-		if err != nil {
-			return nil, err
-		}
 
 	case "switch":
 		out, err = node.Case(ctx, input)
-		if err != nil {
-			return nil, err
-		}
+
+	case "parallel":
+		out, err = node.RunParallel(ctx, input)
+
+	case "foreach":
+		out, err = node.RunForeach(ctx, input)
 
 	case "user-input":
-		var pause bool = false
 		out, pause, err = node.Render(ctx, input)
+
+	default:
+		err = node.Errorf(ctx, "type '%s' not support", node.Type)
+	}
+
+	if err != nil {
+		ctx.report(node, ProgressError, nil, err)
+		return nil, err
+	}
+
+	// Pause the pipe waiting for user input
+	if pause {
+		err = ctx.save()
 		if err != nil {
+			err = node.Errorf(ctx, "save the context: %s", err.Error())
+			ctx.report(node, ProgressError, nil, err)
 			return nil, err
 		}
-
-		// Pause the pipe waiting for user input
-		if pause {
-			return out, nil
-		}
-
-	default:
-		return nil, node.Errorf(ctx, "type '%s' not support", node.Type)
+		ctx.report(node, ProgressWaiting, out, nil)
+		return out, nil
 	}
+	ctx.report(node, ProgressDone, out, nil)
 
 	// Execute the next node
 	next, eof, err := ctx.next()
@@ -166,7 +278,7 @@ func (ctx *Context) exec(node *Node, input Input) (output any, err error) {
 
 	// End of the pipe
 	if eof {
-		defer Close(ctx.id)
+		defer ctx.close()
 		output, err := ctx.parseOutput()
 		if err != nil {
 			return nil, err
@@ -286,10 +398,12 @@ func (ctx *Context) parseOutput() (any, error) {
 func (ctx *Context) data(node *Node) Data {
 
 	data := map[string]any{
-		"$sid":    ctx.sid,
-		"$global": ctx.global,
-		"$input":  ctx.input,
-		"$output": ctx.output,
+		"$sid":     ctx.sid,
+		"$global":  ctx.global,
+		"$payload": ctx.payload,
+		"$input":   ctx.input,
+		"$output":  ctx.output,
+		"$usage":   ctx.Usage().Map(),
 	}
 
 	if ctx.in != nil {
@@ -332,6 +446,12 @@ func (ctx *Context) WithGlobal(data map[string]interface{}) *Context {
 	return ctx
 }
 
+// WithPayload with the data of the API client, the data is $payload in the expressions and is not merged into $global
+func (ctx *Context) WithPayload(data map[string]interface{}) *Context {
+	ctx.payload = data
+	return ctx
+}
+
 // WithSid with the sid
 func (ctx *Context) WithSid(sid string) *Context {
 	ctx.sid = sid
@@ -343,8 +463,11 @@ func (ctx *Context) inheritance(parent *Context) *Context {
 	ctx.out = parent.out
 	ctx.history = parent.history
 	ctx.global = parent.global
+	ctx.payload = parent.payload
 	ctx.sid = parent.sid
 	ctx.parent = parent
+	ctx.progress = parent.progress
+	ctx.usage = parent.usage
 	return ctx
 }
 
@@ -0,0 +1,226 @@
+package pipe
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// graph the flowchart of the pipe, rendered as the mermaid or the graphviz dot
+type graph struct {
+	dot   bool
+	seq   int
+	ids   map[*Node]string
+	lines []string
+	edges []string
+}
+
+// Mermaid returns the mermaid flowchart of the pipe
+func (pipe *Pipe) Mermaid() string {
+	g := &graph{ids: map[*Node]string{}}
+	g.render(pipe)
+	return "flowchart TD\n" + strings.Join(append(g.lines, g.edges...), "\n") + "\n"
+}
+
+// DOT returns the graphviz dot of the pipe
+func (pipe *Pipe) DOT() string {
+	g := &graph{dot: true, ids: map[*Node]string{}}
+	g.render(pipe)
+	name := pipe.ID
+	if name == "" {
+		name = pipe.Name
+	}
+	body := strings.Join(append(g.lines, g.edges...), "\n")
+	return fmt.Sprintf("digraph %s {\n    rankdir=TB;\n%s\n}\n", g.quote(name), body)
+}
+
+func (g *graph) render(pipe *Pipe) {
+	g.terminal("start", "START")
+	g.terminal("stop", "END") // end is the keyword of the mermaid
+	first := g.pipe(pipe, 1, "stop")
+	if first == "" {
+		g.edge("start", "stop", "", false)
+		return
+	}
+	g.edge("start", first, "", false)
+}
+
+// pipe emit the nodes of the pipe, returns the id of the first node
+// The nodes ending the pipe are linked to the exit, the stop of the root pipe, or the parent node of the sub-pipe.
+func (g *graph) pipe(pipe *Pipe, depth int, exit string) string {
+	if !pipe.HasNodes() {
+		return ""
+	}
+
+	for i := range pipe.Nodes {
+		g.id(&pipe.Nodes[i])
+	}
+
+	indent := strings.Repeat("    ", depth)
+	for i := range pipe.Nodes {
+		node := &pipe.Nodes[i]
+		id := g.ids[node]
+		g.node(indent, id, node)
+
+		// The sub-pipes
+		for _, sub := range node.subPipes() {
+			cluster := fmt.Sprintf("s%d", g.seq)
+			g.seq++
+			if g.dot {
+				g.lines = append(g.lines, fmt.Sprintf("%ssubgraph cluster_%s {", indent, cluster), fmt.Sprintf("%s    label=%s;", indent, g.quote(sub.label)))
+			} else {
+				g.lines = append(g.lines, fmt.Sprintf("%ssubgraph %s [%s]", indent, cluster, g.quote(sub.label)))
+			}
+
+			first := g.pipe(sub.pipe, depth+1, id)
+			if g.dot {
+				g.lines = append(g.lines, indent+"}")
+			} else {
+				g.lines = append(g.lines, indent+"end")
+			}
+
+			if first != "" {
+				g.edge(id, first, sub.key, false)
+			}
+		}
+
+		// The next nodes
+		next, eof := pipe.targets(node)
+		label := ""
+		if node.Goto != "" {
+			label = "goto"
+		}
+
+		for _, index := range next {
+			g.edge(id, g.ids[&pipe.Nodes[index]], label, false)
+		}
+
+		if eof {
+			g.edge(id, exit, label, pipe.parent != nil)
+		}
+	}
+
+	return g.ids[&pipe.Nodes[0]]
+}
+
+func (g *graph) id(node *Node) string {
+	if id, has := g.ids[node]; has {
+		return id
+	}
+	id := fmt.Sprintf("n%d", len(g.ids))
+	g.ids[node] = id
+	return id
+}
+
+func (g *graph) terminal(id string, label string) {
+	if g.dot {
+		g.lines = append(g.lines, fmt.Sprintf("    %s [shape=circle, label=%s];", id, g.quote(label)))
+		return
+	}
+	g.lines = append(g.lines, fmt.Sprintf("    %s((%s))", id, g.quote(label)))
+}
+
+func (g *graph) node(indent string, id string, node *Node) {
+	label := node.Label
+	if label == "" {
+		label = node.Name
+	}
+
+	if node.Type != "" {
+		if g.dot {
+			label = label + "\n" + node.Type
+		} else {
+			label = label + "<br/>" + node.Type
+		}
+	}
+
+	if g.dot {
+		shape := "box"
+		if node.Type == "switch" {
+			shape = "diamond"
+		}
+		g.lines = append(g.lines, fmt.Sprintf("%s%s [shape=%s, label=%s];", indent, id, shape, g.quote(label)))
+		return
+	}
+
+	if node.Type == "switch" {
+		g.lines = append(g.lines, fmt.Sprintf("%s%s{%s}", indent, id, g.quote(label)))
+		return
+	}
+	g.lines = append(g.lines, fmt.Sprintf("%s%s[%s]", indent, id, g.quote(label)))
+}
+
+// edge the dashed edges link the sub-pipes back to the parent node
+func (g *graph) edge(from string, to string, label string, dashed bool) {
+	if g.dot {
+		attrs := []string{}
+		if label != "" {
+			attrs = append(attrs, "label="+g.quote(label))
+		}
+		if dashed {
+			attrs = append(attrs, "style=dashed")
+		}
+
+		if len(attrs) == 0 {
+			g.edges = append(g.edges, fmt.Sprintf("    %s -> %s;", from, to))
+			return
+		}
+		g.edges = append(g.edges, fmt.Sprintf("    %s -> %s [%s];", from, to, strings.Join(attrs, ", ")))
+		return
+	}
+
+	arrow := "-->"
+	if dashed {
+		arrow = "-.->"
+	}
+
+	if label != "" {
+		arrow = fmt.Sprintf("%s|%s|", arrow, g.quote(label))
+	}
+	g.edges = append(g.edges, fmt.Sprintf("    %s %s %s", from, arrow, to))
+}
+
+// quote the label, the new lines are kept in the dot labels
+func (g *graph) quote(label string) string {
+	if g.dot {
+		label = strings.ReplaceAll(label, `\`, `\\`)
+		label = strings.ReplaceAll(label, `"`, `\"`)
+		label = strings.ReplaceAll(label, "\n", `\n`)
+		return `"` + label + `"`
+	}
+	label = strings.ReplaceAll(label, `"`, "#quot;")
+	label = strings.ReplaceAll(label, "\n", " ")
+	return `"` + label + `"`
+}
+
+// subPipe the sub-pipe of the switch, parallel and foreach nodes
+type subPipe struct {
+	key   string
+	label string
+	pipe  *Pipe
+}
+
+// subPipes returns the sub-pipes of the node, sorted by the key
+func (node *Node) subPipes() []subPipe {
+	res := []subPipe{}
+	for key, child := range node.Switch {
+		if child != nil {
+			res = append(res, subPipe{key: key, label: "case " + key, pipe: child})
+		}
+	}
+
+	if node.Parallel != nil {
+		for key, child := range node.Parallel.Pipes {
+			if child != nil {
+				res = append(res, subPipe{key: key, label: "parallel " + key, pipe: child})
+			}
+		}
+	}
+
+	if node.Foreach != nil && node.Foreach.Pipe != nil {
+		res = append(res, subPipe{key: "each", label: "foreach " + node.Foreach.Items, pipe: node.Foreach.Pipe})
+	}
+
+	sort.Slice(res, func(i, j int) bool { return res[i].key < res[j].key })
+	return res
+}
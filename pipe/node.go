@@ -1,6 +1,7 @@
 package pipe
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
@@ -138,37 +139,30 @@ func (node *Node) chatCompletions(ctx *Context, prompts []Prompt, options map[st
 		return nil, err
 	}
 
-	response := []string{}
-	content := []string{}
-	_, ex := ai.ChatCompletions(promptsToMap(prompts), node.Options, func(data []byte) int {
-
-		// Prograss Hook
-
-		if len(data) > 5 && string(data[:5]) == "data:" {
-			var res ChatCompletionChunk
-			err := jsoniter.Unmarshal(data[5:], &res)
-			if err != nil {
-				return 0
-			}
-			if len(res.Choices) > 0 {
-				response = append(response, res.Choices[0].Delta.Content)
-			}
-		} else {
-			content = append(content, string(data))
-		}
+	c := ctx.context
+	if c == nil {
+		c = context.Background()
+	}
 
-		return 1
-	})
+	option := aiOptions(options)
 
+	// Forward the deltas to the progress callback and the progress hook
+	stream := &aiStream{delta: func(delta string) { ctx.report(node, ProgressDelta, delta, nil) }}
+	_, ex := ai.ChatCompletionsWith(c, promptsToMap(prompts), option, stream.write)
 	if ex != nil {
 		return nil, node.Errorf(ctx, "AI error: %s", ex.Message)
 	}
 
-	if (len(response) == 0) && (len(content) > 0) {
-		return nil, node.Errorf(ctx, "AI error: %s", strings.Join(content, ""))
+	if (len(stream.response) == 0) && (len(stream.content) > 0) {
+		return nil, node.Errorf(ctx, "AI error: %s", strings.Join(stream.content, ""))
 	}
 
-	raw := strings.Join(response, "")
+	// Token usage
+	if ctx.usage != nil {
+		ctx.usage.add(node.Name, stream.tokens(ai.Model(), prompts))
+	}
+
+	raw := strings.Join(stream.response, "")
 
 	// try to parse the response
 	var res any
@@ -180,6 +174,16 @@ func (node *Node) chatCompletions(ctx *Context, prompts []Prompt, options map[st
 	return res, nil
 }
 
+// aiOptions returns a copy of the node options, the options are changed by the connector and the node may run at the same time (parallel, foreach)
+// The usage is requested in the last chunk of the stream (stream_options.include_usage), see aiStream.tokens
+func aiOptions(options map[string]interface{}) map[string]interface{} {
+	option := map[string]interface{}{"stream_options": map[string]interface{}{"include_usage": true}}
+	for key, value := range options {
+		option[key] = value
+	}
+	return option
+}
+
 func (node *Node) aiMergeHistory(ctx *Context, prompts []Prompt) []Prompt {
 	if ctx.history == nil {
 		ctx.history = map[*Node][]Prompt{}
@@ -226,16 +230,42 @@ func (node *Node) Render(ctx *Context, input Input) (any, bool, error) {
 			return nil, true, err
 		}
 
-		return ResumeContext{
-			ID:    ctx.id,
-			Input: input,
-			Node:  node,
-			Data:  ctx.data(node),
-			Type:  node.Type,
-			UI:    node.UI,
-		}, true, nil
+		res, err := ctx.resumeContext(node, input)
+		if err != nil {
+			return nil, true, err
+		}
+		return res, true, nil
+
+	}
+}
 
+// resumeContext returns the resume context of the user input node, the label and the autofill are rendered
+func (ctx *Context) resumeContext(node *Node, input Input) (ResumeContext, error) {
+	data := ctx.data(node)
+	label, err := data.replaceString(node.Label)
+	if err != nil {
+		return ResumeContext{}, err
 	}
+
+	var autofill *AutoFill = nil
+	if node.AutoFill != nil {
+		value, err := data.replace(node.AutoFill.Value)
+		if err != nil {
+			return ResumeContext{}, err
+		}
+		autofill = &AutoFill{Value: value, Action: node.AutoFill.Action}
+	}
+
+	return ResumeContext{
+		ID:       ctx.id,
+		Input:    input,
+		Node:     node,
+		Data:     data,
+		Type:     node.Type,
+		UI:       node.UI,
+		Label:    label,
+		AutoFill: autofill,
+	}, nil
 }
 
 func (node *Node) renderCli(ctx *Context, input Input) (any, error) {
@@ -0,0 +1,310 @@
+package pipe
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// The failure policies of the parallel and foreach nodes
+const (
+	PolicyAll     = "all"     // all the sub-pipes must succeed, the first error cancels the others (default)
+	PolicyAny     = "any"     // at least one sub-pipe must succeed, the output of the failed ones is null
+	PolicySettled = "settled" // never fails, each output is {"output": ...} or {"error": "..."}
+)
+
+var policies = map[string]bool{"": true, PolicyAll: true, PolicyAny: true, PolicySettled: true}
+
+// task the sub-pipe execution of the parallel and foreach nodes
+type task struct {
+	name  string // the branch name or the item index, for the error message
+	pipe  *Pipe
+	input Input
+}
+
+// RunParallel Execute the sub-pipes in parallel and join the outputs
+func (node *Node) RunParallel(ctx *Context, input Input) (any, error) {
+
+	if node.Parallel == nil || len(node.Parallel.Pipes) == 0 {
+		return nil, node.Errorf(ctx, "parallel pipes not found")
+	}
+
+	input, err := ctx.parseNodeInput(node, input)
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for name := range node.Parallel.Pipes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	tasks := []task{}
+	for _, name := range names {
+		tasks = append(tasks, task{name: name, pipe: node.Parallel.Pipes[name], input: input})
+	}
+
+	outputs, err := node.fanout(ctx, tasks, len(tasks), node.Parallel.Policy)
+	if err != nil {
+		return nil, err
+	}
+
+	res := map[string]any{}
+	for i, name := range names {
+		res[name] = outputs[i]
+	}
+
+	output, err := ctx.parseNodeOutput(node, res)
+	if err != nil {
+		return nil, err
+	}
+
+	return output, nil
+}
+
+// RunForeach Execute the sub-pipe for each item of the array and collect the outputs
+func (node *Node) RunForeach(ctx *Context, input Input) (any, error) {
+
+	if node.Foreach == nil || node.Foreach.Pipe == nil {
+		return nil, node.Errorf(ctx, "foreach pipe not found")
+	}
+
+	input, err := ctx.parseNodeInput(node, input)
+	if err != nil {
+		return nil, err
+	}
+
+	data := ctx.data(node)
+	value, err := data.replace(node.Foreach.Items)
+	if err != nil {
+		return nil, node.Errorf(ctx, err.Error())
+	}
+
+	items, err := toArray(value)
+	if err != nil {
+		return nil, node.Errorf(ctx, "foreach items %s", err.Error())
+	}
+
+	tasks := []task{}
+	for i, item := range items {
+		tasks = append(tasks, task{name: fmt.Sprintf("[%d]", i), pipe: node.Foreach.Pipe, input: Input{item, i}})
+	}
+
+	concurrency := node.Foreach.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	outputs, err := node.fanout(ctx, tasks, concurrency, node.Foreach.Policy)
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := ctx.parseNodeOutput(node, outputs)
+	if err != nil {
+		return nil, err
+	}
+
+	return output, nil
+}
+
+// fanout run the tasks with the bounded concurrency and join the outputs by the failure policy
+func (node *Node) fanout(ctx *Context, tasks []task, concurrency int, policy string) ([]any, error) {
+
+	outputs := make([]any, len(tasks))
+	if len(tasks) == 0 {
+		return outputs, nil
+	}
+
+	if concurrency > len(tasks) {
+		concurrency = len(tasks)
+	}
+
+	parent := ctx.context
+	if parent == nil {
+		parent = context.Background()
+	}
+	c, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	errs := make([]error, len(tasks))
+	subs := make([]*Context, len(tasks))
+	global := copyGlobal(ctx.global)
+	var first error
+	var once sync.Once
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+
+	for i := range tasks {
+		select {
+		case sem <- struct{}{}:
+		case <-c.Done():
+		}
+
+		// Cancelled by the first error or the parent context, the rest tasks are skipped
+		if c.Err() != nil {
+			errs[i] = c.Err()
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			defer func() {
+				if r := recover(); r != nil {
+					errs[i] = fmt.Errorf("%v", r)
+				}
+				if errs[i] != nil && (policy == "" || policy == PolicyAll) {
+					once.Do(func() { first = fmt.Errorf("%s %s", tasks[i].name, errs[i].Error()) })
+					cancel()
+				}
+			}()
+			subs[i] = ctx.fork(tasks[i].pipe, c)
+			outputs[i], errs[i] = subs[i].Exec(tasks[i].input...)
+		}(i)
+	}
+	wg.Wait()
+	ctx.mergeGlobal(global, subs, errs)
+
+	switch policy {
+	case "", PolicyAll:
+		if first != nil {
+			return nil, node.Errorf(ctx, first.Error())
+		}
+
+		if err := parent.Err(); err != nil {
+			return nil, node.Errorf(ctx, err.Error())
+		}
+
+	case PolicyAny:
+		succeeded := false
+		for i, err := range errs {
+			if err != nil {
+				outputs[i] = nil
+				continue
+			}
+			succeeded = true
+		}
+
+		if !succeeded {
+			return nil, node.Errorf(ctx, "all failed, %s %s", tasks[0].name, errs[0].Error())
+		}
+
+	case PolicySettled:
+		for i, err := range errs {
+			if err != nil {
+				outputs[i] = map[string]any{"error": err.Error()}
+				continue
+			}
+			outputs[i] = map[string]any{"output": outputs[i]}
+		}
+
+	default:
+		return nil, node.Errorf(ctx, "policy %s not support", policy)
+	}
+
+	return outputs, nil
+}
+
+// fork create the sub context of the parallel and foreach nodes
+// The sub context has a copy of the node inputs, the outputs and $global, the sub-pipes running at the same time do not share them.
+func (ctx *Context) fork(pipe *Pipe, c context.Context) *Context {
+	sub := &Context{
+		id:        uuid.NewString(),
+		Pipe:      pipe,
+		parent:    ctx,
+		forked:    true,
+		context:   c,
+		global:    copyGlobal(ctx.global),
+		payload:   ctx.payload,
+		sid:       ctx.sid,
+		progress:  ctx.progress,
+		usage:     ctx.usage,
+		in:        map[*Node][]any{},
+		out:       map[*Node]any{},
+		history:   map[*Node][]Prompt{},
+		input:     []any{},
+		createdAt: ctx.createdAt,
+		expiresAt: ctx.expiresAt,
+	}
+
+	for node, value := range ctx.in {
+		sub.in[node] = value
+	}
+
+	for node, value := range ctx.out {
+		sub.out[node] = value
+	}
+
+	if pipe.HasNodes() {
+		sub.current = &pipe.Nodes[0]
+	}
+	return sub
+}
+
+// mergeGlobal merge the $global changes of the sub contexts back after the join
+// The changes are merged in the order of the tasks, the later task overrides the same key, the failed tasks are not merged.
+// The global is the copy of $global before the fork, the top-level keys are compared with it.
+func (ctx *Context) mergeGlobal(global map[string]interface{}, subs []*Context, errs []error) {
+	for i, sub := range subs {
+		if sub == nil || errs[i] != nil {
+			continue
+		}
+
+		for key, value := range sub.global {
+			if origin, has := global[key]; has && reflect.DeepEqual(origin, value) {
+				continue
+			}
+			if ctx.global == nil {
+				ctx.global = map[string]interface{}{}
+			}
+			ctx.global[key] = value
+		}
+
+		for key := range global {
+			if _, has := sub.global[key]; !has {
+				delete(ctx.global, key)
+			}
+		}
+	}
+}
+
+// copyGlobal copy the top-level keys of $global
+func copyGlobal(global map[string]interface{}) map[string]interface{} {
+	res := map[string]interface{}{}
+	for key, value := range global {
+		res[key] = value
+	}
+	return res
+}
+
+// toArray convert the slice or array value to []any, null is an empty array
+func toArray(value any) ([]any, error) {
+	switch v := value.(type) {
+	case nil:
+		return []any{}, nil
+
+	case []any:
+		return v, nil
+
+	case Input:
+		return v, nil
+	}
+
+	rv := reflect.ValueOf(value)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, fmt.Errorf("should be an array, but got %T", value)
+	}
+
+	items := make([]any, rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		items[i] = rv.Index(i).Interface()
+	}
+	return items, nil
+}
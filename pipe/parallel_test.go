@@ -0,0 +1,213 @@
+package pipe
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yaoapp/gou/process"
+	"github.com/yaoapp/kun/exception"
+	"github.com/yaoapp/yao/config"
+	"github.com/yaoapp/yao/test"
+)
+
+var running, peak int32
+
+func init() {
+	process.Register("unit.pipe.upper", func(process *process.Process) interface{} {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+
+		time.Sleep(20 * time.Millisecond)
+		value := process.ArgsString(0)
+		if value == "fail" {
+			exception.New("%s failed", 500, value).Throw()
+		}
+		return strings.ToUpper(value)
+	})
+
+	process.Register("unit.pipe.global", func(process *process.Process) interface{} {
+		name := process.ArgsString(0)
+		for i := 0; i < 100; i++ {
+			process.Global[name] = i
+			process.Global["last"] = name
+		}
+		delete(process.Global, "remove")
+		return process.Global[name]
+	})
+}
+
+func TestParallelNode(t *testing.T) {
+	test.Prepare(t, config.Conf)
+	defer test.Clean()
+
+	dsl := `{
+		"name": "parallel",
+		"nodes": [
+			{"name": "prefix", "process": {"name": "unit.pipe.upper", "args": ["{{ $in[0] }}"]}},
+			{"name": "fetch", "input": ["{{ prefix }}", "{{ $input[1] }}"], "parallel": {
+				"policy": "%s",
+				"pipes": {
+					"user": {"nodes": [{"name": "name", "process": {"name": "unit.pipe.upper", "args": ["{{ $in[0] + '-user' }}"]}}]},
+					"order": {"nodes": [{"name": "id", "process": {"name": "unit.pipe.upper", "args": ["{{ $in[1] }}"]}}]}
+				}
+			}}
+		],
+		"output": "{{ fetch }}"
+	}`
+
+	pipe, err := New([]byte(fmt.Sprintf(dsl, "")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := pipe.Create().Exec("alice", "order-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, map[string]any{"user": "ALICE-USER", "order": "ORDER-1"}, output)
+
+	// all (default)
+	_, err = pipe.Create().Exec("alice", "fail")
+	assert.Contains(t, err.Error(), "order")
+	assert.Contains(t, err.Error(), "fail failed")
+
+	// any
+	pipe, err = New([]byte(fmt.Sprintf(dsl, PolicyAny)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output, err = pipe.Create().Exec("alice", "fail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, map[string]any{"user": "ALICE-USER", "order": nil}, output)
+
+	// settled
+	pipe, err = New([]byte(fmt.Sprintf(dsl, PolicySettled)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output, err = pipe.Create().Exec("alice", "fail")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := output.(map[string]any)
+	assert.Equal(t, map[string]any{"output": "ALICE-USER"}, res["user"])
+	assert.Contains(t, res["order"].(map[string]any)["error"], "fail failed")
+
+	_, err = New([]byte(fmt.Sprintf(dsl, "first")))
+	assert.Contains(t, err.Error(), "policy must be all, any, settled")
+}
+
+func TestParallelGlobal(t *testing.T) {
+	test.Prepare(t, config.Conf)
+	defer test.Clean()
+
+	pipe, err := New([]byte(`{
+		"name": "parallel",
+		"nodes": [
+			{"name": "write", "parallel": {
+				"pipes": {
+					"a": {"nodes": [{"name": "a", "process": {"name": "unit.pipe.global", "args": ["a"]}}]},
+					"b": {"nodes": [{"name": "b", "process": {"name": "unit.pipe.global", "args": ["b"]}}]}
+				}
+			}}
+		],
+		"output": "{{ $global }}"
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 10; i++ {
+		output, err := pipe.Create().WithGlobal(map[string]interface{}{"keep": 1, "remove": 1}).Exec()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The changes are merged in the order of the branch names
+		assert.Equal(t, map[string]any{"keep": 1, "a": 99, "b": 99, "last": "b"}, output)
+	}
+}
+
+func TestForeachNode(t *testing.T) {
+	test.Prepare(t, config.Conf)
+	defer test.Clean()
+
+	dsl := `{
+		"name": "foreach",
+		"nodes": [
+			{"name": "docs", "foreach": {
+				"items": "{{ $in[0] }}",
+				"concurrency": 2,
+				"policy": "%s",
+				"pipe": {
+					"nodes": [{"name": "upper", "process": {"name": "unit.pipe.upper", "args": ["{{ $in[0] }}"]}}],
+					"output": "{{ upper + '#' + string($input[1]) }}"
+				}
+			}}
+		],
+		"output": "{{ docs }}"
+	}`
+
+	pipe, err := New([]byte(fmt.Sprintf(dsl, "")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	atomic.StoreInt32(&peak, 0)
+	output, err := pipe.Create().Exec([]string{"a", "b", "c", "d", "e"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []any{"A#0", "B#1", "C#2", "D#3", "E#4"}, output)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&peak))
+
+	output, err = pipe.Create().Exec([]any{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []any{}, output)
+
+	_, err = pipe.Create().Exec("a")
+	assert.Contains(t, err.Error(), "should be an array")
+
+	_, err = pipe.Create().Exec([]string{"a", "fail", "c"})
+	assert.Contains(t, err.Error(), "[1]")
+	assert.Contains(t, err.Error(), "fail failed")
+
+	// settled
+	pipe, err = New([]byte(fmt.Sprintf(dsl, PolicySettled)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output, err = pipe.Create().Exec([]string{"a", "fail"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := output.([]any)
+	assert.Equal(t, map[string]any{"output": "A#0"}, res[0])
+	assert.Contains(t, res[1].(map[string]any)["error"], "fail failed")
+
+	// the user input is not supported
+	_, err = New([]byte(`{
+		"name": "foreach",
+		"nodes": [{"name": "docs", "foreach": {"items": "{{ $in[0] }}", "pipe": {"nodes": [{"name": "ask", "ui": "web"}]}}}]
+	}`))
+	assert.Contains(t, err.Error(), "the user input node ask is not supported")
+}
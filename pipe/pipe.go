@@ -53,6 +53,7 @@ func New(source []byte) (*Pipe, error) {
 		return nil, fmt.Errorf("build pipe: %s", err)
 	}
 
+	pipe.source = source
 	return &pipe, nil
 }
 
@@ -152,6 +153,27 @@ func (pipe *Pipe) _build() error {
 
 		} else if node.Request != nil {
 			pipe.Nodes[i].Type = "request"
+
+			// Validate the request, the url with expressions is checked when the node is executed
+			if node.Request.URL == "" {
+				return fmt.Errorf("pipe: %s nodes[%d] request url is required", pipe.Name, i)
+			}
+
+			if method := node.Request.Method; method != "" && !IsExpression(method) && !requestMethods[strings.ToUpper(method)] {
+				return fmt.Errorf("pipe: %s nodes[%d] request method %s is not supported", pipe.Name, i, method)
+			}
+
+			if !IsExpression(node.Request.URL) {
+				u, err := parseRequestURL(node.Request.URL)
+				if err != nil {
+					return fmt.Errorf("pipe: %s nodes[%d] %s", pipe.Name, i, err)
+				}
+
+				// Security check
+				if err := pipe.Hosts.checkHost(u.Host); err != nil {
+					return fmt.Errorf("pipe: %s nodes[%d] %s", pipe.Name, i, err.Error())
+				}
+			}
 			continue
 
 		} else if node.Prompts != nil {
@@ -170,6 +192,7 @@ func (pipe *Pipe) _build() error {
 			for key, pip := range node.Switch {
 				key = ref(key)
 				pip.Whitelist = pipe.Whitelist // Copy the whitelist
+				pip.Hosts = pipe.Hosts
 				pip.namespace = node.Name
 				pip.parent = pipe
 				if pip.ID == "" {
@@ -181,10 +204,82 @@ func (pipe *Pipe) _build() error {
 				pip._build()
 			}
 			continue
+
+		} else if node.Parallel != nil {
+			pipe.Nodes[i].Type = "parallel"
+			if len(node.Parallel.Pipes) == 0 {
+				return fmt.Errorf("pipe: %s nodes[%d] parallel pipes is required", pipe.Name, i)
+			}
+
+			if !policies[node.Parallel.Policy] {
+				return fmt.Errorf("pipe: %s nodes[%d] parallel policy must be all, any, settled", pipe.Name, i)
+			}
+
+			for key, pip := range node.Parallel.Pipes {
+				err := pipe.buildChild(&pipe.Nodes[i], key, pip)
+				if err != nil {
+					return err
+				}
+			}
+			continue
+
+		} else if node.Foreach != nil {
+			pipe.Nodes[i].Type = "foreach"
+			if node.Foreach.Items == "" {
+				return fmt.Errorf("pipe: %s nodes[%d] foreach items is required", pipe.Name, i)
+			}
+
+			if !policies[node.Foreach.Policy] {
+				return fmt.Errorf("pipe: %s nodes[%d] foreach policy must be all, any, settled", pipe.Name, i)
+			}
+
+			err := pipe.buildChild(&pipe.Nodes[i], "each", node.Foreach.Pipe)
+			if err != nil {
+				return err
+			}
+			continue
 		}
 
-		return fmt.Errorf("pipe: %s nodes[%d] process, request, case, prompts or ui is required at least one", pipe.Name, i)
+		return fmt.Errorf("pipe: %s nodes[%d] process, request, case, parallel, foreach, prompts or ui is required at least one", pipe.Name, i)
+	}
+
+	return nil
+}
+
+// buildChild build the sub-pipe of the parallel and foreach nodes
+// The sub-pipes run at the same time, so the user input nodes are not supported.
+func (pipe *Pipe) buildChild(node *Node, key string, child *Pipe) error {
+	if child == nil || !child.HasNodes() {
+		return fmt.Errorf("pipe: %s nodes[%d] %s %s nodes is required", pipe.Name, node.index, node.Type, key)
+	}
+
+	child.Whitelist = pipe.Whitelist // Copy the whitelist
+	child.Hosts = pipe.Hosts
+	child.namespace = node.Name
+	child.parent = pipe
+	if child.ID == "" {
+		child.ID = fmt.Sprintf("%s.%s#%s", pipe.ID, node.Name, ref(key))
+	}
+	if child.Name == "" {
+		child.Name = fmt.Sprintf("%s(%s#%s)", pipe.Name, node.Name, key)
+	}
+
+	err := child._build()
+	if err != nil {
+		return err
 	}
 
+	var input string
+	child.walk(func(p *Pipe) {
+		for _, n := range p.Nodes {
+			if input == "" && n.Type == "user-input" {
+				input = n.Name
+			}
+		}
+	})
+
+	if input != "" {
+		return fmt.Errorf("pipe: %s nodes[%d] %s %s the user input node %s is not supported", pipe.Name, node.index, node.Type, key, input)
+	}
 	return nil
 }
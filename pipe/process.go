@@ -1,6 +1,8 @@
 package pipe
 
 import (
+	"strings"
+
 	"github.com/yaoapp/gou/process"
 	"github.com/yaoapp/kun/exception"
 )
@@ -14,12 +16,18 @@ func init() {
 		"resume":     processResume,
 		"resumewith": processResumeWith, // resume with global data
 		"close":      processClose,
+		"abort":      processAbort,
 	})
 }
 
 // processScripts
 func processPipes(process *process.Process) interface{} {
 
+	// The built-in processes are resolved ahead of the pipes, the ids are reserved (see validate)
+	if handler, has := builtin(process.ID); has {
+		return handler(process)
+	}
+
 	pipe, err := Get(process.ID)
 	if err != nil {
 		exception.New("pipes.%s not loaded", 404, process.ID).Throw()
@@ -29,6 +37,19 @@ func processPipes(process *process.Process) interface{} {
 	return ctx.Run(process.Args...)
 }
 
+// builtin returns the built-in process of the pipes group by id
+func builtin(id string) (process.Handler, bool) {
+	switch strings.ToLower(id) {
+	case "list":
+		return processList, true
+	case "abort":
+		return processAbort, true
+	case "validate":
+		return processValidate, true
+	}
+	return nil, false
+}
+
 // processCreate process the create pipe.create <pipe.id> [...args]
 func processCreate(process *process.Process) interface{} {
 	process.ValidateArgNums(1)
@@ -164,3 +185,87 @@ func processClose(process *process.Process) interface{} {
 	Close(id)
 	return nil
 }
+
+// processList process the list of the contexts of the session waiting for the user input pipes.list [filter]
+// filter: {"pipe": "<pipe.id>"}, the contexts of all the sessions are listed if the caller has no session (CLI, schedules ...)
+func processList(process *process.Process) interface{} {
+	filter := process.ArgsMap(0, map[string]any{})
+	states, err := List()
+	if err != nil {
+		exception.New("pipes.list %s", 500, err.Error()).Throw()
+	}
+
+	pipe, _ := filter["pipe"].(string)
+	res := []map[string]any{}
+	for _, state := range states {
+		if (process.Sid != "" && state.Sid != process.Sid) || (pipe != "" && state.Pipe != pipe) {
+			continue
+		}
+
+		res = append(res, map[string]any{
+			"id":         state.ID,
+			"pipe":       state.Pipe,
+			"name":       state.Name,
+			"node":       state.Node,
+			"ui":         state.UI,
+			"status":     state.Status,
+			"sid":        state.Sid,
+			"input":      state.In[state.Current],
+			"created_at": state.CreatedAt,
+			"updated_at": state.UpdatedAt,
+			"expires_at": state.ExpiresAt,
+		})
+	}
+	return res
+}
+
+// processAbort process the abort of the waiting context pipes.abort <id>, pipe.abort <id>
+// The context of any session can be aborted if the caller has no session (CLI, schedules ...)
+func processAbort(process *process.Process) interface{} {
+	process.ValidateArgNums(1)
+	id := process.ArgsString(0)
+	ctx, err := Open(id)
+	if err != nil || (process.Sid != "" && ctx.sid != process.Sid) {
+		exception.New("pipes.%s not found", 404, id).Throw()
+	}
+	ctx.close()
+	return nil
+}
+
+// processValidate process the validate pipes.validate [pipe.id], validate all the pipes if the id is not given
+func processValidate(process *process.Process) interface{} {
+	id := ""
+	if len(process.Args) > 0 {
+		id = process.ArgsString(0)
+	}
+
+	var issues []Issue
+	if pipe, err := Get(id); id != "" && err == nil {
+		issues = pipe.Validate()
+
+	} else {
+		all, err := ValidateAll()
+		if err != nil {
+			exception.New("pipes.validate %s", 500, err.Error()).Throw()
+		}
+
+		// The pipe failed to load is not in the pipes, filter the issues by the id
+		for _, issue := range all {
+			if id == "" || issue.Pipe == id || strings.HasPrefix(issue.Pipe, id+".") {
+				issues = append(issues, issue)
+			}
+		}
+	}
+
+	res := []map[string]any{}
+	for _, issue := range issues {
+		res = append(res, map[string]any{
+			"pipe":    issue.Pipe,
+			"file":    issue.File,
+			"node":    issue.Node,
+			"level":   issue.Level,
+			"message": issue.Message,
+		})
+	}
+	return res
+}
@@ -0,0 +1,110 @@
+package pipe
+
+import (
+	"time"
+
+	"github.com/yaoapp/gou/process"
+	"github.com/yaoapp/kun/log"
+)
+
+// The progress status of the nodes
+const (
+	ProgressStart   = "start"
+	ProgressDone    = "done"
+	ProgressError   = "error"
+	ProgressWaiting = "waiting" // waiting for the user input
+	ProgressDelta   = "delta"   // the delta of the AI node output, the output is the delta text
+)
+
+// Progress the progress event of the node
+type Progress struct {
+	ID     string `json:"id"`   // the context id, the sub-pipes report with the id of the root context
+	Pipe   string `json:"pipe"` // the pipe id, or the sub-pipe id
+	Node   string `json:"node"`
+	Label  string `json:"label"`
+	Type   string `json:"type"`
+	Status string `json:"status"` // start, done, error, waiting, delta
+	Output any    `json:"output,omitempty"`
+	Error  string `json:"error,omitempty"`
+	Time   int64  `json:"time"` // unix milliseconds
+}
+
+// WithProgress set the progress callback
+// The callback is called by the sub-pipes of the parallel and foreach nodes at the same time, it should be thread safe.
+func (ctx *Context) WithProgress(cb func(Progress)) *Context {
+	ctx.progress = cb
+	return ctx
+}
+
+// report the progress to the callback and the progress hook process
+func (ctx *Context) report(node *Node, status string, output any, err error) {
+	hook := ""
+	if hooks := ctx.root().Hooks; hooks != nil && (status != ProgressDelta || hooks.Stream) {
+		hook = hooks.Progress
+	}
+
+	if ctx.progress == nil && hook == "" {
+		return
+	}
+
+	top := ctx
+	for top.parent != nil {
+		top = top.parent
+	}
+
+	p := Progress{
+		ID:     top.id,
+		Pipe:   ctx.Pipe.ID,
+		Node:   node.Name,
+		Label:  node.Label,
+		Type:   node.Type,
+		Status: status,
+		Output: output,
+		Time:   time.Now().UnixMilli(),
+	}
+
+	if err != nil {
+		p.Error = err.Error()
+	}
+
+	if ctx.progress != nil {
+		ctx.progress(p)
+	}
+
+	if hook == "" {
+		return
+	}
+
+	proc, err := process.Of(hook, p.Map())
+	if err != nil {
+		log.Error("pipe: %s progress hook %s", ctx.Name, err.Error())
+		return
+	}
+
+	_, err = proc.WithGlobal(ctx.global).WithSID(ctx.sid).Exec()
+	if err != nil {
+		log.Error("pipe: %s progress hook %s", ctx.Name, err.Error())
+	}
+}
+
+// Map returns the progress as a map, the argument of the progress hook process
+func (p Progress) Map() map[string]any {
+	res := map[string]any{
+		"id":     p.ID,
+		"pipe":   p.Pipe,
+		"node":   p.Node,
+		"label":  p.Label,
+		"type":   p.Type,
+		"status": p.Status,
+		"time":   p.Time,
+	}
+
+	if p.Output != nil {
+		res["output"] = p.Output
+	}
+
+	if p.Error != "" {
+		res["error"] = p.Error
+	}
+	return res
+}
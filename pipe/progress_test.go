@@ -0,0 +1,58 @@
+package pipe
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yaoapp/gou/process"
+	"github.com/yaoapp/yao/config"
+	"github.com/yaoapp/yao/test"
+)
+
+func TestProgressHook(t *testing.T) {
+	test.Prepare(t, config.Conf)
+	defer test.Clean()
+
+	var mutex sync.Mutex
+	events := []map[string]any{}
+	process.Register("unit.pipe.progress", func(process *process.Process) interface{} {
+		mutex.Lock()
+		defer mutex.Unlock()
+		events = append(events, process.ArgsMap(0))
+		return nil
+	})
+
+	pipe, err := New([]byte(`{
+		"name": "progress",
+		"hooks": {"progress": "unit.pipe.progress"},
+		"nodes": [
+			{"name": "docs", "foreach": {
+				"items": "{{ $in[0] }}",
+				"pipe": {"nodes": [{"name": "upper", "process": {"name": "unit.pipe.upper", "args": ["{{ $in[0] }}"]}}]}
+			}}
+		],
+		"output": "{{ docs }}"
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := pipe.Create()
+	output, err := ctx.Exec([]string{"a", "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []any{"A", "B"}, output)
+
+	// docs start, upper start, upper done (x2), docs done
+	if assert.Equal(t, 6, len(events)) {
+		assert.Equal(t, "docs", events[0]["node"])
+		assert.Equal(t, ProgressStart, events[0]["status"])
+		assert.Equal(t, "upper", events[1]["node"])
+		assert.Equal(t, ctx.id, events[1]["id"])
+		assert.Equal(t, "A", events[2]["output"])
+		assert.Equal(t, ProgressDone, events[5]["status"])
+		assert.Equal(t, []any{"A", "B"}, events[5]["output"])
+	}
+}
@@ -0,0 +1,431 @@
+package pipe
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+var requestMethods = map[string]bool{
+	"GET": true, "POST": true, "PUT": true, "PATCH": true, "DELETE": true, "HEAD": true, "OPTIONS": true,
+}
+
+// idempotentMethods the methods retried by default, POST and PATCH are retried if the request is marked idempotent
+var idempotentMethods = map[string]bool{
+	"GET": true, "PUT": true, "DELETE": true, "HEAD": true, "OPTIONS": true,
+}
+
+// maxRetryInterval the maximum interval between the retries
+const maxRetryInterval = 30 * time.Second
+
+// HTTP Execute the http request
+// The output ($out) is {"status": 200, "headers": {...}, "data": ...}, the data is parsed by the response type
+func (node *Node) HTTP(ctx *Context, input Input) (any, error) {
+
+	if node.Request == nil {
+		return nil, node.Errorf(ctx, "request not set")
+	}
+
+	input, err := ctx.parseNodeInput(node, input)
+	if err != nil {
+		return nil, err
+	}
+
+	data := ctx.data(node)
+	req, err := node.Request.build(data)
+	if err != nil {
+		return nil, node.Errorf(ctx, err.Error())
+	}
+
+	// Security check
+	if err := ctx.Hosts.checkHost(req.url.Host); err != nil {
+		return nil, node.Errorf(ctx, err.Error())
+	}
+
+	res, err := node.Request.do(ctx.context, req, ctx.Hosts)
+	if err != nil {
+		return nil, node.Errorf(ctx, err.Error())
+	}
+
+	output, err := ctx.parseNodeOutput(node, res)
+	if err != nil {
+		return nil, err
+	}
+
+	return output, nil
+}
+
+// httpRequest the request with the expressions replaced
+type httpRequest struct {
+	method  string
+	url     *url.URL
+	headers http.Header
+	body    []byte
+}
+
+// build replace the expressions of the request
+func (request *Request) build(data Data) (*httpRequest, error) {
+	method, err := data.replaceString(request.Method)
+	if err != nil {
+		return nil, err
+	}
+
+	method = strings.ToUpper(method)
+	if method == "" {
+		method = "GET"
+	}
+
+	if !requestMethods[method] {
+		return nil, fmt.Errorf("method %s is not supported", method)
+	}
+
+	raw, err := data.replaceString(request.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := parseRequestURL(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	// Headers
+	headers := http.Header{}
+	values, err := data.replaceMap(request.Headers)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range values {
+		if value != nil {
+			headers.Set(name, fmt.Sprintf("%v", value))
+		}
+	}
+
+	// Query string
+	values, err = data.replaceMap(request.Query)
+	if err != nil {
+		return nil, err
+	}
+
+	query := u.Query()
+	for name, value := range values {
+		switch v := value.(type) {
+		case nil:
+		case []any:
+			for _, item := range v {
+				query.Add(name, fmt.Sprintf("%v", item))
+			}
+		default:
+			query.Set(name, fmt.Sprintf("%v", v))
+		}
+	}
+	u.RawQuery = query.Encode()
+
+	// Body
+	body, err := data.replace(request.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	req := &httpRequest{method: method, url: u, headers: headers}
+	switch v := body.(type) {
+	case nil:
+	case string:
+		req.body = []byte(v)
+		if headers.Get("Content-Type") == "" {
+			headers.Set("Content-Type", "text/plain; charset=utf-8")
+		}
+
+	case []byte:
+		req.body = v
+
+	default:
+		if strings.HasPrefix(headers.Get("Content-Type"), "application/x-www-form-urlencoded") {
+			if form, ok := v.(map[string]any); ok {
+				values := url.Values{}
+				for name, value := range form {
+					values.Set(name, fmt.Sprintf("%v", value))
+				}
+				req.body = []byte(values.Encode())
+				break
+			}
+		}
+
+		req.body, err = jsoniter.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		if headers.Get("Content-Type") == "" {
+			headers.Set("Content-Type", "application/json; charset=utf-8")
+		}
+	}
+
+	return req, nil
+}
+
+// do send the request, retry the idempotent requests on the network errors, 429 and 5xx responses
+func (request *Request) do(parent context.Context, req *httpRequest, hosts Whitelist) (map[string]any, error) {
+	if parent == nil {
+		parent = context.Background()
+	}
+
+	timeout := 30 * time.Second
+	if request.Timeout > 0 {
+		timeout = time.Duration(request.Timeout) * time.Second
+	}
+
+	interval := 500 * time.Millisecond
+	if request.Interval > 0 {
+		interval = time.Duration(request.Interval) * time.Millisecond
+	}
+
+	client := &http.Client{
+		Timeout:   timeout,
+		Transport: transport(hosts),
+		CheckRedirect: func(r *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return fmt.Errorf("stopped after 10 redirects")
+			}
+			if err := hosts.checkHost(r.URL.Host); err != nil {
+				return fmt.Errorf("redirect %s", err.Error())
+			}
+			return nil
+		},
+	}
+	retryable := idempotentMethods[req.method] || request.Idempotent
+	for attempt := 0; ; attempt++ {
+		status, header, body, err := request.send(parent, client, req)
+		retry := retryable && (err != nil || status == http.StatusTooManyRequests || status >= 500)
+		if !retry || attempt >= request.Retry {
+			if err != nil {
+				return nil, err
+			}
+
+			if status >= 400 {
+				return nil, fmt.Errorf("%s %s %d %s", req.method, req.url.Redacted(), status, abbreviate(string(body), 200))
+			}
+
+			data, err := request.parseBody(header.Get("Content-Type"), body)
+			if err != nil {
+				return nil, err
+			}
+
+			headers := map[string]any{}
+			for name := range header {
+				headers[strings.ToLower(name)] = header.Get(name)
+			}
+			return map[string]any{"status": status, "headers": headers, "data": data}, nil
+		}
+
+		select {
+		case <-parent.Done():
+			return nil, parent.Err()
+		case <-time.After(backoff(interval, attempt)):
+		}
+	}
+}
+
+// backoff returns the interval doubled after each retry, up to maxRetryInterval
+func backoff(interval time.Duration, attempt int) time.Duration {
+	for i := 0; i < attempt && interval < maxRetryInterval; i++ {
+		interval = interval * 2
+	}
+
+	if interval > maxRetryInterval {
+		return maxRetryInterval
+	}
+	return interval
+}
+
+// transport returns the transport of the request nodes
+// The private, loopback and link-local addresses are refused when connecting if the host whitelist is not set,
+// the host names resolved to these addresses are refused as well.
+func transport(hosts Whitelist) http.RoundTripper {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	if hosts != nil {
+		return t
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control: func(network, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+
+			if ip := net.ParseIP(host); ip != nil && privateIP(ip) {
+				return fmt.Errorf("address %s is not allowed, set the hosts of the pipe to request the private network", address)
+			}
+			return nil
+		},
+	}
+	t.DialContext = dialer.DialContext
+	return t
+}
+
+// send send the request once
+func (request *Request) send(parent context.Context, client *http.Client, req *httpRequest) (int, http.Header, []byte, error) {
+	var body io.Reader = nil
+	if req.body != nil {
+		body = bytes.NewReader(req.body)
+	}
+
+	r, err := http.NewRequestWithContext(parent, req.method, req.url.String(), body)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	r.Header = req.headers.Clone()
+
+	resp, err := client.Do(r)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	return resp.StatusCode, resp.Header, raw, nil
+}
+
+// parseBody parse the response body by the response type, or by the content type
+func (request *Request) parseBody(contentType string, body []byte) (any, error) {
+	switch request.Response {
+	case "json":
+		if len(body) == 0 {
+			return nil, nil
+		}
+		var data any
+		err := jsoniter.Unmarshal(body, &data)
+		if err != nil {
+			return nil, fmt.Errorf("parse the response: %s", err.Error())
+		}
+		return data, nil
+
+	case "text":
+		return string(body), nil
+
+	case "binary":
+		return body, nil
+
+	case "":
+		contentType = strings.ToLower(contentType)
+		switch {
+		case strings.Contains(contentType, "json"):
+			var data any
+			if err := jsoniter.Unmarshal(body, &data); err == nil {
+				return data, nil
+			}
+			return string(body), nil
+
+		case contentType == "", strings.HasPrefix(contentType, "text/"), strings.Contains(contentType, "xml"),
+			strings.Contains(contentType, "javascript"), strings.Contains(contentType, "x-www-form-urlencoded"):
+			return string(body), nil
+		}
+		return body, nil
+	}
+
+	return nil, fmt.Errorf("response type %s is not supported, should be json, text or binary", request.Response)
+}
+
+// parseRequestURL parse the url, only http and https are allowed
+func parseRequestURL(raw string) (*url.URL, error) {
+	if raw == "" {
+		return nil, fmt.Errorf("url is required")
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("url %s is not supported, should be http or https", u.Redacted())
+	}
+
+	if u.Host == "" {
+		return nil, fmt.Errorf("url %s host is required", u.Redacted())
+	}
+	return u, nil
+}
+
+// allowHost check if the host is allowed
+// The whitelist supports the host, the host with port and the wildcard subdomain (*.example.com)
+// If the whitelist is not set, the hosts except the private, loopback and link-local addresses are allowed.
+func (whitelist Whitelist) allowHost(host string) bool {
+	host = strings.ToLower(host)
+	hostname := host
+	if u, err := url.Parse("//" + host); err == nil {
+		hostname = u.Hostname()
+	}
+
+	if whitelist == nil {
+		return !privateHost(hostname)
+	}
+
+	if _, has := whitelist[host]; has {
+		return true
+	}
+
+	if _, has := whitelist[hostname]; has {
+		return true
+	}
+
+	for pattern := range whitelist {
+		if strings.HasPrefix(pattern, "*.") && strings.HasSuffix(hostname, pattern[1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkHost returns the error if the host is not allowed
+func (whitelist Whitelist) checkHost(host string) error {
+	if whitelist.allowHost(host) {
+		return nil
+	}
+
+	if whitelist == nil {
+		return fmt.Errorf("host %s is a private address, set the hosts of the pipe to allow it", host)
+	}
+	return fmt.Errorf("host %s is not in the whitelist", host)
+}
+
+// privateHost check if the host name is the localhost or a private, loopback or link-local address
+// The other host names are checked when connecting, see transport
+func privateHost(hostname string) bool {
+	if hostname == "localhost" || strings.HasSuffix(hostname, ".localhost") {
+		return true
+	}
+
+	if ip := net.ParseIP(hostname); ip != nil {
+		return privateIP(ip)
+	}
+	return false
+}
+
+// privateIP check if the ip is a private, loopback, link-local or unspecified address
+func privateIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast()
+}
+
+func abbreviate(s string, size int) string {
+	runes := []rune(s)
+	if len(runes) <= size {
+		return s
+	}
+	return string(runes[:size]) + "..."
+}
@@ -0,0 +1,238 @@
+package pipe
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/yaoapp/kun/any"
+	"github.com/yaoapp/yao/config"
+	"github.com/yaoapp/yao/test"
+)
+
+func TestRequestNode(t *testing.T) {
+	test.Prepare(t, config.Conf)
+	defer test.Clean()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		jsoniter.NewEncoder(w).Encode(map[string]interface{}{
+			"method": r.Method,
+			"path":   r.URL.Path,
+			"query":  r.URL.Query(),
+			"token":  r.Header.Get("X-Token"),
+			"type":   r.Header.Get("Content-Type"),
+			"body":   string(body),
+		})
+	}))
+	defer server.Close()
+
+	pipe, err := New([]byte(`{
+		"name": "request",
+		"hosts": ["127.0.0.1"],
+		"nodes": [
+			{
+				"name": "user",
+				"request": {
+					"method": "POST",
+					"url": "{{ $global.base + '/users' }}",
+					"headers": {"X-Token": "{{ $global.token }}"},
+					"query": {"page": 1, "tags": ["a", "b"]},
+					"body": {"name": "{{ $in[0] }}", "role": "admin"}
+				},
+				"output": {"status": "{{ $out.status }}", "data": "{{ $out.data }}"}
+			}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := pipe.Create().WithGlobal(map[string]interface{}{"base": server.URL, "token": "secret"})
+	output, err := ctx.Exec("Alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := any.Of(output).Map().MapStrAny.Dot()
+	assert.Equal(t, 200, res.Get("status"))
+	assert.Equal(t, "POST", res.Get("data.method"))
+	assert.Equal(t, "/users", res.Get("data.path"))
+	assert.Equal(t, "secret", res.Get("data.token"))
+	assert.Equal(t, "1", res.Get("data.query.page[0]"))
+	assert.Equal(t, "b", res.Get("data.query.tags[1]"))
+	assert.Equal(t, "application/json; charset=utf-8", res.Get("data.type"))
+	assert.JSONEq(t, `{"name":"Alice","role":"admin"}`, res.Get("data.body").(string))
+}
+
+func TestRequestNodeRetry(t *testing.T) {
+	test.Prepare(t, config.Conf)
+	defer test.Clean()
+
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	dsl := `{
+		"name": "retry",
+		"hosts": ["127.0.0.1"],
+		"nodes": [{"name": "ping", "request": {"url": "%s", "retry": %d, "interval": 1 %s}}],
+		"output": "{{ ping.data }}"
+	}`
+
+	pipe, err := New([]byte(fmt.Sprintf(dsl, server.URL, 2, "")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := pipe.Create().Exec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "ok", output)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+
+	// No retry
+	atomic.StoreInt32(&calls, 0)
+	pipe, err = New([]byte(fmt.Sprintf(dsl, server.URL, 0, "")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = pipe.Create().Exec()
+	assert.Contains(t, err.Error(), "503")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	// The POST requests are retried only if they are idempotent
+	atomic.StoreInt32(&calls, 0)
+	pipe, err = New([]byte(fmt.Sprintf(dsl, server.URL, 2, `, "method": "POST"`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = pipe.Create().Exec()
+	assert.Contains(t, err.Error(), "503")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	atomic.StoreInt32(&calls, 0)
+	pipe, err = New([]byte(fmt.Sprintf(dsl, server.URL, 2, `, "method": "POST", "idempotent": true`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output, err = pipe.Create().Exec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "ok", output)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+
+	// The interval is capped
+	assert.Equal(t, time.Second, backoff(500*time.Millisecond, 1))
+	assert.Equal(t, maxRetryInterval, backoff(500*time.Millisecond, 100))
+}
+
+func TestRequestNodeHosts(t *testing.T) {
+	test.Prepare(t, config.Conf)
+	defer test.Clean()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	_, err := New([]byte(fmt.Sprintf(`{
+		"name": "hosts",
+		"hosts": ["api.example.com"],
+		"nodes": [{"name": "ping", "request": {"url": "%s"}}]
+	}`, server.URL)))
+	assert.Contains(t, err.Error(), "is not in the whitelist")
+
+	pipe, err := New([]byte(`{
+		"name": "hosts",
+		"hosts": ["api.example.com"],
+		"nodes": [{"name": "ping", "request": {"url": "{{ $global.url }}"}}]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = pipe.Create().WithGlobal(map[string]interface{}{"url": server.URL}).Exec()
+	assert.Contains(t, err.Error(), "is not in the whitelist")
+
+	_, err = New([]byte(`{"name": "scheme", "nodes": [{"name": "file", "request": {"url": "file:///etc/passwd"}}]}`))
+	assert.Contains(t, err.Error(), "should be http or https")
+
+	// The private addresses are denied if the whitelist is not set
+	_, err = New([]byte(fmt.Sprintf(`{"name": "private", "nodes": [{"name": "ping", "request": {"url": "%s"}}]}`, server.URL)))
+	assert.Contains(t, err.Error(), "is a private address")
+
+	pipe, err = New([]byte(`{"name": "private", "nodes": [{"name": "ping", "request": {"url": "{{ $global.url }}"}}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = pipe.Create().WithGlobal(map[string]interface{}{"url": server.URL}).Exec()
+	assert.Contains(t, err.Error(), "is a private address")
+
+	// The host names resolved to the private addresses are refused when connecting
+	host := strings.TrimPrefix(server.URL, "http://")
+	u, err := url.Parse("http://localhost:" + strings.Split(host, ":")[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = (&Request{}).do(nil, &httpRequest{method: "GET", url: u, headers: http.Header{}}, nil)
+	assert.Contains(t, err.Error(), "is not allowed")
+
+	assert.False(t, Whitelist(nil).allowHost(host))
+	assert.False(t, Whitelist(nil).allowHost("localhost:8080"))
+	assert.False(t, Whitelist(nil).allowHost("169.254.169.254"))
+	assert.False(t, Whitelist(nil).allowHost("10.0.0.1"))
+	assert.False(t, Whitelist(nil).allowHost("[::1]:8080"))
+	assert.True(t, Whitelist(nil).allowHost("api.example.com"))
+	assert.True(t, Whitelist(nil).allowHost("8.8.8.8"))
+	assert.True(t, Whitelist{"127.0.0.1": true}.allowHost(host))
+	assert.True(t, Whitelist{host: true}.allowHost(host))
+	assert.True(t, Whitelist{"*.example.com": true}.allowHost("api.example.com:8443"))
+	assert.False(t, Whitelist{"*.example.com": true}.allowHost("example.com.evil.io"))
+	assert.False(t, Whitelist{"example.com": true}.allowHost(host))
+}
+
+func TestRequestParseBody(t *testing.T) {
+	request := &Request{}
+	data, err := request.parseBody("application/json", []byte(`{"a": 1}`))
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"a": float64(1)}, data)
+
+	data, err = request.parseBody("text/html; charset=utf-8", []byte(`<p>hi</p>`))
+	assert.Nil(t, err)
+	assert.Equal(t, "<p>hi</p>", data)
+
+	data, err = request.parseBody("image/png", []byte{0x89, 0x50})
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0x89, 0x50}, data)
+
+	request.Response = "json"
+	_, err = request.parseBody("text/plain", []byte(`not json`))
+	assert.NotNil(t, err)
+
+	request.Response = "xml"
+	_, err = request.parseBody("text/plain", []byte(`<a/>`))
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,253 @@
+package pipe
+
+import (
+	"fmt"
+	"time"
+)
+
+// DefaultTTL the default time to live of the contexts
+const DefaultTTL = 24 * time.Hour
+
+// State the serializable state of the context, the nodes are referenced by key (see nodeKeys)
+type State struct {
+	ID        string              `json:"id"`
+	Pipe      string              `json:"pipe,omitempty"`   // the root pipe id
+	Source    string              `json:"source,omitempty"` // the DSL of the root pipe without id (pipe.Create)
+	Sub       string              `json:"sub,omitempty"`    // the id of the switch case pipe, if the context is a case
+	Name      string              `json:"name"`
+	Node      string              `json:"node"`   // the current node name
+	UI        string              `json:"ui"`     // the user interface of the current node
+	Status    string              `json:"status"` // waiting
+	Current   string              `json:"current"`
+	Sid       string              `json:"sid,omitempty"`
+	Global    map[string]any      `json:"global,omitempty"`
+	Payload   map[string]any      `json:"payload,omitempty"`
+	Input     []any               `json:"input,omitempty"`
+	Output    any                 `json:"output,omitempty"`
+	In        map[string][]any    `json:"in,omitempty"`
+	Out       map[string]any      `json:"out,omitempty"`
+	History   map[string][]Prompt `json:"history,omitempty"`
+	Usage     map[string]Usage    `json:"usage,omitempty"`
+	CreatedAt time.Time           `json:"created_at"`
+	UpdatedAt time.Time           `json:"updated_at"`
+	ExpiresAt time.Time           `json:"expires_at"`
+}
+
+// StatusWaiting the context is waiting for the user input
+const StatusWaiting = "waiting"
+
+// save persist the context waiting for the user input
+func (ctx *Context) save() error {
+	s, err := ctx.store()
+	if err != nil {
+		return err
+	}
+
+	root := ctx.root()
+	keys := root.nodeKeys()
+	state := &State{
+		ID:        ctx.id,
+		Pipe:      root.ID,
+		Name:      ctx.Name,
+		Status:    StatusWaiting,
+		Sid:       ctx.sid,
+		Global:    ctx.global,
+		Payload:   ctx.payload,
+		Input:     ctx.input,
+		Output:    ctx.output,
+		In:        map[string][]any{},
+		Out:       map[string]any{},
+		History:   map[string][]Prompt{},
+		Usage:     ctx.Usage().Nodes,
+		CreatedAt: ctx.createdAt,
+		UpdatedAt: time.Now(),
+	}
+
+	if root.ID == "" {
+		state.Source = string(root.source)
+	}
+
+	if ctx.Pipe != root {
+		state.Sub = ctx.Pipe.ID
+	}
+
+	if ctx.current != nil {
+		state.Current = keys[ctx.current]
+		state.Node = ctx.current.Name
+		state.UI = ctx.current.UI
+	}
+
+	for node, value := range ctx.in {
+		state.In[keys[node]] = value
+	}
+	for node, value := range ctx.out {
+		state.Out[keys[node]] = value
+	}
+	for node, value := range ctx.history {
+		state.History[keys[node]] = value
+	}
+
+	ttl := ctx.ttl()
+	ctx.expiresAt = state.UpdatedAt.Add(ttl)
+	state.ExpiresAt = ctx.expiresAt
+	err = s.Save(state, ttl)
+	if err != nil {
+		return err
+	}
+
+	ctx.saved = true
+	return nil
+}
+
+// restore the context from the state
+func restore(state *State) (*Context, error) {
+	var root *Pipe
+	var err error
+	if state.Pipe != "" {
+		root, err = Get(state.Pipe)
+	} else {
+		root, err = New([]byte(state.Source))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	pipe := root
+	if state.Sub != "" {
+		pipe = root.find(state.Sub)
+		if pipe == nil {
+			return nil, fmt.Errorf("pipe %s not found", state.Sub)
+		}
+	}
+
+	nodes := map[string]*Node{}
+	for node, key := range root.nodeKeys() {
+		nodes[key] = node
+	}
+
+	ctx := &Context{
+		id:        state.ID,
+		Pipe:      pipe,
+		sid:       state.Sid,
+		global:    state.Global,
+		payload:   state.Payload,
+		input:     state.Input,
+		output:    state.Output,
+		in:        map[*Node][]any{},
+		out:       map[*Node]any{},
+		history:   map[*Node][]Prompt{},
+		usage:     &usageRecorder{nodes: state.Usage},
+		createdAt: state.CreatedAt,
+		expiresAt: state.ExpiresAt,
+	}
+
+	if state.Current != "" {
+		node, has := nodes[state.Current]
+		if !has {
+			return nil, fmt.Errorf("pipe %s node %s not found, the pipe may be changed", pipe.Name, state.Node)
+		}
+		ctx.current = node
+	}
+
+	for key, value := range state.In {
+		if node, has := nodes[key]; has {
+			ctx.in[node] = value
+		}
+	}
+	for key, value := range state.Out {
+		if node, has := nodes[key]; has {
+			ctx.out[node] = value
+		}
+	}
+	for key, value := range state.History {
+		if node, has := nodes[key]; has {
+			ctx.history[node] = value
+		}
+	}
+
+	return ctx, nil
+}
+
+// List returns the contexts waiting for the user input, the latest first
+func List() ([]*State, error) {
+	states := []*State{}
+	for _, s := range allStores() {
+		items, err := s.List()
+		if err != nil {
+			return nil, err
+		}
+		states = append(states, items...)
+	}
+	sortStates(states)
+	return states, nil
+}
+
+// store returns the context store of the pipe
+func (ctx *Context) store() (ContextStore, error) {
+	return getStore(ctx.root().Store)
+}
+
+// ttl returns the time to live of the context
+func (ctx *Context) ttl() time.Duration {
+	if ttl := ctx.root().TTL; ttl > 0 {
+		return time.Duration(ttl) * time.Second
+	}
+	return DefaultTTL
+}
+
+// root returns the root pipe of the context
+func (ctx *Context) root() *Pipe {
+	root := ctx.Pipe
+	for root.parent != nil {
+		root = root.parent
+	}
+	return root
+}
+
+// nodeKeys returns the keys of the nodes of the pipe and the sub-pipes
+// The key is the pipe id with the node index, e.g. "web.translator[1]", "web.translator.switch#8e2a1b[0]"
+func (pipe *Pipe) nodeKeys() map[*Node]string {
+	keys := map[*Node]string{}
+	pipe.walk(func(p *Pipe) {
+		for i := range p.Nodes {
+			keys[&p.Nodes[i]] = fmt.Sprintf("%s[%d]", p.ID, i)
+		}
+	})
+	return keys
+}
+
+// find returns the pipe or the sub-pipe by id
+func (pipe *Pipe) find(id string) *Pipe {
+	var res *Pipe
+	pipe.walk(func(p *Pipe) {
+		if res == nil && p.ID == id {
+			res = p
+		}
+	})
+	return res
+}
+
+// walk visit the pipe and the sub-pipes (switch, parallel and foreach)
+func (pipe *Pipe) walk(visit func(p *Pipe)) {
+	visit(pipe)
+	for i := range pipe.Nodes {
+		node := &pipe.Nodes[i]
+		for _, child := range node.Switch {
+			if child != nil {
+				child.walk(visit)
+			}
+		}
+
+		if node.Parallel != nil {
+			for _, child := range node.Parallel.Pipes {
+				if child != nil {
+					child.walk(visit)
+				}
+			}
+		}
+
+		if node.Foreach != nil && node.Foreach.Pipe != nil {
+			node.Foreach.Pipe.walk(visit)
+		}
+	}
+}
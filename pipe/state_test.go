@@ -0,0 +1,350 @@
+package pipe
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yaoapp/gou/process"
+	"github.com/yaoapp/yao/config"
+	"github.com/yaoapp/yao/test"
+)
+
+const approvalDSL = `{
+	"name": "approval",
+	%s
+	"nodes": [
+		{"name": "approve", "ui": "web", "label": "Approve", "input": ["{{ $in[0] }}"]},
+		{"name": "review", "ui": "web", "label": "Review"}
+	],
+	"output": {"request": "{{ $input[0] }}", "approve": "{{ approve }}", "review": "{{ review }}", "global": "{{ $global }}"}
+}`
+
+func TestContextPersistence(t *testing.T) {
+	test.Prepare(t, config.Conf)
+	defer test.Clean()
+
+	pipe, err := New([]byte(fmt.Sprintf(approvalDSL, "")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := pipe.Create().WithGlobal(map[string]any{"user": "alice"}).WithSid("sid-approval")
+	output, err := ctx.Exec("purchase #1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resume := output.(ResumeContext)
+	assert.Equal(t, "approve", resume.Node.Name)
+
+	// Restart: the contexts in memory are lost
+	contexts.Delete(resume.ID)
+	states, err := List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := findState(states, resume.ID)
+	if assert.NotNil(t, state) {
+		assert.Equal(t, StatusWaiting, state.Status)
+		assert.Equal(t, "approve", state.Node)
+		assert.Equal(t, "sid-approval", state.Sid)
+		assert.NotEmpty(t, state.Source)
+	}
+
+	ctx, err = Open(resume.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output, err = ctx.resume("yes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resume = output.(ResumeContext)
+	assert.Equal(t, "review", resume.Node.Name)
+
+	contexts.Delete(resume.ID)
+	ctx, err = Open(resume.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output, err = ctx.resume("ok")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := output.(map[string]any)
+	assert.Equal(t, "purchase #1", res["request"])
+	assert.Equal(t, []any{"yes"}, res["approve"])
+	assert.Equal(t, []any{"ok"}, res["review"])
+	assert.Equal(t, "alice", res["global"].(map[string]any)["user"])
+
+	// Closed at the end of the pipe
+	_, err = Open(resume.ID)
+	assert.NotNil(t, err)
+}
+
+func TestContextTTL(t *testing.T) {
+	test.Prepare(t, config.Conf)
+	defer test.Clean()
+
+	pipe, err := New([]byte(fmt.Sprintf(approvalDSL, `"ttl": 1,`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := pipe.Create().Exec("purchase #2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id := output.(ResumeContext).ID
+	states, err := List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := findState(states, id)
+	if assert.NotNil(t, state) {
+		assert.WithinDuration(t, state.UpdatedAt.Add(time.Second), state.ExpiresAt, time.Millisecond)
+	}
+
+	time.Sleep(1100 * time.Millisecond)
+	_, err = Open(id)
+	assert.Contains(t, err.Error(), "not found")
+
+	contexts.Delete(id)
+	_, err = Open(id)
+	assert.Contains(t, err.Error(), "not found")
+}
+
+func TestContextStore(t *testing.T) {
+	test.Prepare(t, config.Conf)
+	defer test.Clean()
+
+	store := &memoryStore{states: map[string]memoryState{}}
+	RegisterStore("approvals", store)
+	defer func() {
+		contextStoresMutex.Lock()
+		delete(contextStores, "approvals")
+		contextStoresMutex.Unlock()
+	}()
+
+	_, err := New([]byte(fmt.Sprintf(approvalDSL, `"store": "not-found",`)))
+	assert.Nil(t, err)
+
+	pipe, err := New([]byte(fmt.Sprintf(approvalDSL, `"store": "approvals",`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := pipe.Create().WithSid("sid-store").Exec("purchase #3")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id := output.(ResumeContext).ID
+	state, err := store.Load(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "approve", state.Node)
+
+	// pipes.list
+	p, err := process.Of("pipes.list", map[string]any{"pipe": pipe.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := p.WithSID("sid-store").Exec()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	list := res.([]map[string]any)
+	assert.Equal(t, 1, len(list))
+	assert.Equal(t, id, list[0]["id"])
+	assert.Equal(t, "web", list[0]["ui"])
+
+	// The contexts of the other sessions are not listed
+	p, err = process.Of("pipes.list")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err = p.WithSID("sid-other").Exec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, res)
+
+	// The contexts of all the sessions are listed without the session (CLI, schedules ...)
+	p, err = process.Of("pipes.list", map[string]any{"pipe": pipe.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err = p.Exec()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sids := map[any]any{}
+	for _, item := range res.([]map[string]any) {
+		sids[item["id"]] = item["sid"]
+	}
+	assert.Equal(t, "sid-store", sids[id])
+
+	// pipes.abort
+	p, err = process.Of("pipes.abort", id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = p.WithSID("sid-other").Exec()
+	assert.NotNil(t, err)
+
+	p, err = process.Of("pipes.abort", id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = p.WithSID("sid-store").Exec()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state, err = store.Load(id)
+	assert.Nil(t, err)
+	assert.Nil(t, state)
+
+	_, err = Open(id)
+	assert.NotNil(t, err)
+
+	// The context of any session is aborted without the session
+	output, err = pipe.Create().WithSid("sid-store").Exec("purchase #4")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id = output.(ResumeContext).ID
+	p, err = process.Of("pipes.abort", id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = p.Exec()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state, err = store.Load(id)
+	assert.Nil(t, err)
+	assert.Nil(t, state)
+}
+
+func TestContextClose(t *testing.T) {
+	test.Prepare(t, config.Conf)
+	defer test.Clean()
+
+	store := &deleteStore{memoryStore: &memoryStore{states: map[string]memoryState{}}}
+	RegisterStore("deletes", store)
+	defer func() {
+		contextStoresMutex.Lock()
+		delete(contextStores, "deletes")
+		contextStoresMutex.Unlock()
+	}()
+
+	// The contexts of the other stores
+	pipe, err := New([]byte(fmt.Sprintf(approvalDSL, "")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := pipe.Create().Exec("purchase #4")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, err := Open(output.(ResumeContext).ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ctx.resume("yes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ctx.resume("ok")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, store.deleted)
+
+	// The saved context is deleted from the store of the pipe
+	pipe, err = New([]byte(fmt.Sprintf(approvalDSL, `"store": "deletes",`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output, err = pipe.Create().Exec("purchase #5")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id := output.(ResumeContext).ID
+	contexts.Delete(id)
+	Close(id)
+	assert.Equal(t, []string{id}, store.deleted)
+
+	_, err = Open(id)
+	assert.NotNil(t, err)
+}
+
+func TestNodeKeys(t *testing.T) {
+	test.Prepare(t, config.Conf)
+	defer test.Clean()
+
+	pipe, err := New([]byte(`{
+		"name": "keys",
+		"nodes": [
+			{"name": "ask", "ui": "web"},
+			{"name": "switch", "case": {
+				"default": {"nodes": [{"name": "confirm", "ui": "web"}]}
+			}}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := pipe.nodeKeys()
+	assert.Equal(t, 3, len(keys))
+	assert.Equal(t, "[0]", keys[&pipe.Nodes[0]])
+
+	child := pipe.Nodes[1].Switch["default"]
+	assert.Equal(t, child, pipe.find(child.ID))
+	assert.Equal(t, child.ID+"[0]", keys[&child.Nodes[0]])
+	assert.Nil(t, pipe.find("not-found"))
+}
+
+func findState(states []*State, id string) *State {
+	for _, state := range states {
+		if state.ID == id {
+			return state
+		}
+	}
+	return nil
+}
+
+// deleteStore records the deleted contexts
+type deleteStore struct {
+	*memoryStore
+	deleted []string
+}
+
+func (s *deleteStore) Delete(id string) error {
+	s.deleted = append(s.deleted, id)
+	return s.memoryStore.Delete(id)
+}
@@ -0,0 +1,243 @@
+package pipe
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/yaoapp/gou/store"
+)
+
+// ContextStore the persistence of the paused contexts
+type ContextStore interface {
+	Save(state *State, ttl time.Duration) error
+	Load(id string) (*State, error) // returns nil if the context is not found or expired
+	Delete(id string) error
+	List() ([]*State, error)
+}
+
+// contextStorePrefix the key prefix of the contexts in the kv stores
+const contextStorePrefix = "pipe:context:"
+
+var defaultStore ContextStore = &memoryStore{states: map[string]memoryState{}}
+var contextStores = map[string]ContextStore{}
+var contextStoresMutex sync.RWMutex
+
+// RegisterStore register a context store, the pipe selects it by the store option
+func RegisterStore(name string, store ContextStore) {
+	contextStoresMutex.Lock()
+	defer contextStoresMutex.Unlock()
+	contextStores[name] = store
+}
+
+// getStore returns the context store by name
+// The registered stores first, then the kv stores (stores/*.yao), the memory store if the name is empty.
+func getStore(name string) (ContextStore, error) {
+	if name == "" {
+		return defaultStore, nil
+	}
+
+	contextStoresMutex.RLock()
+	s, has := contextStores[name]
+	contextStoresMutex.RUnlock()
+	if has {
+		return s, nil
+	}
+
+	if _, has := store.Pools[name]; has {
+		return &kvStore{name: name}, nil
+	}
+	return nil, fmt.Errorf("context store %s not found", name)
+}
+
+// allStores returns the memory store, the registered stores and the stores used by the loaded pipes
+func allStores() []ContextStore {
+	names := map[string]bool{}
+	contextStoresMutex.RLock()
+	for name := range contextStores {
+		names[name] = true
+	}
+	contextStoresMutex.RUnlock()
+
+	for _, pipe := range pipes {
+		if pipe.Store != "" {
+			names[pipe.Store] = true
+		}
+	}
+
+	stores := []ContextStore{defaultStore}
+	for name := range names {
+		if s, err := getStore(name); err == nil {
+			stores = append(stores, s)
+		}
+	}
+	return stores
+}
+
+// memoryStore the default context store, the contexts are lost on restart
+type memoryState struct {
+	raw     []byte
+	expires time.Time
+}
+
+type memoryStore struct {
+	states map[string]memoryState
+	mutex  sync.Mutex
+}
+
+func (mem *memoryStore) Save(state *State, ttl time.Duration) error {
+	raw, err := jsoniter.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	mem.mutex.Lock()
+	defer mem.mutex.Unlock()
+	mem.states[state.ID] = memoryState{raw: raw, expires: time.Now().Add(ttl)}
+	return nil
+}
+
+func (mem *memoryStore) Load(id string) (*State, error) {
+	mem.mutex.Lock()
+	item, has := mem.states[id]
+	if has && time.Now().After(item.expires) {
+		delete(mem.states, id)
+		has = false
+	}
+	mem.mutex.Unlock()
+
+	if !has {
+		return nil, nil
+	}
+	return parseState(item.raw)
+}
+
+func (mem *memoryStore) Delete(id string) error {
+	mem.mutex.Lock()
+	defer mem.mutex.Unlock()
+	delete(mem.states, id)
+	return nil
+}
+
+func (mem *memoryStore) List() ([]*State, error) {
+	mem.mutex.Lock()
+	defer mem.mutex.Unlock()
+
+	now := time.Now()
+	states := []*State{}
+	for id, item := range mem.states {
+		if now.After(item.expires) {
+			delete(mem.states, id)
+			continue
+		}
+
+		state, err := parseState(item.raw)
+		if err != nil {
+			return nil, err
+		}
+		states = append(states, state)
+	}
+	return states, nil
+}
+
+// kvStore the context store backed by a kv store (redis, mongo, lru ...)
+type kvStore struct {
+	name string
+}
+
+func (kv *kvStore) pool() (store.Store, error) {
+	s, has := store.Pools[kv.name]
+	if !has {
+		return nil, fmt.Errorf("store %s not found", kv.name)
+	}
+	return s, nil
+}
+
+func (kv *kvStore) Save(state *State, ttl time.Duration) error {
+	s, err := kv.pool()
+	if err != nil {
+		return err
+	}
+
+	raw, err := jsoniter.MarshalToString(state)
+	if err != nil {
+		return err
+	}
+	return s.Set(contextStorePrefix+state.ID, raw, ttl)
+}
+
+func (kv *kvStore) Load(id string) (*State, error) {
+	s, err := kv.pool()
+	if err != nil {
+		return nil, err
+	}
+
+	value, has := s.Get(contextStorePrefix + id)
+	if !has || value == nil {
+		return nil, nil
+	}
+
+	switch v := value.(type) {
+	case string:
+		return parseState([]byte(v))
+	case []byte:
+		return parseState(v)
+	}
+
+	raw, err := jsoniter.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return parseState(raw)
+}
+
+func (kv *kvStore) Delete(id string) error {
+	s, err := kv.pool()
+	if err != nil {
+		return err
+	}
+	return s.Del(contextStorePrefix + id)
+}
+
+func (kv *kvStore) List() ([]*State, error) {
+	s, err := kv.pool()
+	if err != nil {
+		return nil, err
+	}
+
+	states := []*State{}
+	for _, key := range s.Keys() {
+		if !strings.HasPrefix(key, contextStorePrefix) {
+			continue
+		}
+
+		state, err := kv.Load(strings.TrimPrefix(key, contextStorePrefix))
+		if err != nil {
+			return nil, err
+		}
+
+		if state != nil {
+			states = append(states, state)
+		}
+	}
+	return states, nil
+}
+
+// sortStates sort the states by the updated time, the latest first
+func sortStates(states []*State) {
+	sort.SliceStable(states, func(i, j int) bool {
+		return states[i].UpdatedAt.After(states[j].UpdatedAt)
+	})
+}
+
+func parseState(raw []byte) (*State, error) {
+	var state State
+	err := jsoniter.Unmarshal(raw, &state)
+	if err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
@@ -2,6 +2,7 @@ package pipe
 
 import (
 	"context"
+	"time"
 )
 
 // Pipe the pipe
@@ -14,11 +15,16 @@ type Pipe struct {
 	Output    any       `json:"output,omitempty"`    // the pipe output expression
 	Input     Input     `json:"input,omitempty"`     // the pipe input expression
 	Whitelist Whitelist `json:"whitelist,omitempty"` // the process whitelist
+	Hosts     Whitelist `json:"hosts,omitempty"`     // the host whitelist of the request nodes
 	Goto      string    `json:"goto,omitempty"`      // goto node name / EOF
+	Store     string    `json:"store,omitempty"`     // the store of the contexts waiting for the user input (default: memory)
+	TTL       int       `json:"ttl,omitempty"`       // the time to live of the contexts in seconds (default: 86400)
+	API       bool      `json:"api,omitempty"`       // expose the pipe to the HTTP API (default: false)
 
 	parent    *Pipe            // the parent pipe
 	namespace string           // the namespace of the pipe
 	mapping   map[string]*Node // the mapping of the nodes Key:name Value:index
+	source    []byte           // the DSL of the pipe created from the source
 }
 
 // Context the Context
@@ -29,6 +35,7 @@ type Context struct {
 
 	context context.Context
 	global  map[string]interface{} // $global
+	payload map[string]interface{} // $payload the data of the API client, it is not trusted
 	sid     string                 // $sid
 	current *Node                  // current position
 
@@ -38,17 +45,26 @@ type Context struct {
 
 	input  []any // $input the pipe input value
 	output any   // $output the pipe output value
+
+	progress func(Progress) // the progress callback
+	usage    *usageRecorder // the token usage of the AI nodes, shared with the sub contexts
+
+	createdAt time.Time
+	expiresAt time.Time
+	saved     bool // the context is saved to the store, waiting for the user input
+	forked    bool // the sub context of the parallel and foreach nodes
 }
 
 // Hooks the Hooks
 type Hooks struct {
 	Progress string `json:"progress,omitempty"`
+	Stream   bool   `json:"stream,omitempty"` // report the deltas of the AI nodes to the progress hook
 }
 
 // Node the pip node
 type Node struct {
 	Name     string           `json:"name"`
-	Type     string           `json:"type,omitempty"`     // user-input, ai, process, switch, request
+	Type     string           `json:"type,omitempty"`     // user-input, ai, process, switch, request, parallel, foreach
 	Label    string           `json:"label,omitempty"`    // Display
 	Process  *Process         `json:"process,omitempty"`  // Yao Process
 	Prompts  []Prompt         `json:"prompts,omitempty"`  // AI prompts
@@ -58,6 +74,8 @@ type Node struct {
 	UI       string           `json:"ui,omitempty"`       // The User Interface cli, web, app, wxapp ...
 	AutoFill *AutoFill        `json:"autofill,omitempty"` // Autofill the user input with the expression
 	Switch   map[string]*Pipe `json:"case,omitempty"`     // Switch
+	Parallel *Parallel        `json:"parallel,omitempty"` // Run the sub-pipes in parallel
+	Foreach  *Foreach         `json:"foreach,omitempty"`  // Run the sub-pipe for each item
 	Input    Input            `json:"input,omitempty"`    // the node input expression
 	Output   any              `json:"output,omitempty"`   // the node output expression
 	Goto     string           `json:"goto,omitempty"`     // goto node name / EOF
@@ -65,6 +83,20 @@ type Node struct {
 	index int // the index of the node
 }
 
+// Parallel the parallel node, the output ($out) is {"<branch>": <output>}
+type Parallel struct {
+	Pipes  map[string]*Pipe `json:"pipes"`            // the branches
+	Policy string           `json:"policy,omitempty"` // the failure policy all, any, settled (default: all)
+}
+
+// Foreach the foreach node, the output ($out) is [<output>...] in the order of the items
+type Foreach struct {
+	Items       string `json:"items"`                 // the array expression, e.g. "{{ $in[0] }}"
+	Pipe        *Pipe  `json:"pipe"`                  // the sub-pipe, the input is [<item>, <index>]
+	Concurrency int    `json:"concurrency,omitempty"` // the max number of the items running at the same time (default: 1)
+	Policy      string `json:"policy,omitempty"`      // the failure policy all, any, settled (default: all)
+}
+
 // Whitelist the Whitelist
 type Whitelist map[string]bool
 
@@ -85,6 +117,9 @@ type ResumeContext struct {
 	Input Input  `json:"input"`
 	Node  *Node  `json:"node"`
 	Data  Data   `json:"data"`
+
+	Label    string    `json:"label,omitempty"`    // the rendered label
+	AutoFill *AutoFill `json:"autofill,omitempty"` // the rendered autofill
 }
 
 // AutoFill the autofill
@@ -112,8 +147,19 @@ type Process struct {
 	Args Args   `json:"args,omitempty"`
 }
 
-// Request the request
-type Request struct{}
+// Request the http request
+type Request struct {
+	Method     string         `json:"method,omitempty"`     // GET, POST, PUT, PATCH, DELETE, HEAD, OPTIONS (default: GET)
+	URL        string         `json:"url"`                  // the request url
+	Headers    map[string]any `json:"headers,omitempty"`    // the request headers
+	Query      map[string]any `json:"query,omitempty"`      // the query string, the array value is sent as multiple values
+	Body       any            `json:"body,omitempty"`       // the request body, the string is sent as is, others are encoded as JSON (or the form)
+	Timeout    int            `json:"timeout,omitempty"`    // timeout of each attempt in seconds (default: 30)
+	Retry      int            `json:"retry,omitempty"`      // retry times on the network errors, 429 and 5xx responses (default: 0)
+	Interval   int            `json:"interval,omitempty"`   // the retry interval in milliseconds, doubled after each retry up to 30 seconds (default: 500)
+	Idempotent bool           `json:"idempotent,omitempty"` // retry the POST and PATCH requests, the other methods are retried by default
+	Response   string         `json:"response,omitempty"`   // json, text, binary (default: by the content type)
+}
 
 // ChatCompletionChunk the chat completion chunk
 type ChatCompletionChunk struct {
@@ -128,6 +174,7 @@ type ChatCompletionChunk struct {
 		Logprobs     interface{} `json:"logprobs"`
 		FinishReason interface{} `json:"finish_reason"`
 	} `json:"choices"`
+	Usage *Usage `json:"usage,omitempty"` // the last chunk, if the provider reports the usage
 }
 
 // DeltaStruct the delta struct
@@ -0,0 +1,161 @@
+package pipe
+
+import (
+	"strings"
+	"sync"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/yaoapp/yao/openai"
+)
+
+// Usage the token usage of the AI nodes
+type Usage struct {
+	PromptTokens     int  `json:"prompt_tokens"`
+	CompletionTokens int  `json:"completion_tokens"`
+	TotalTokens      int  `json:"total_tokens"`
+	Estimated        bool `json:"estimated,omitempty"` // counted by tiktoken, the provider omits the usage
+}
+
+// UsageReport the token usage of the pipe, the nodes are keyed by name
+// The usage of a node running more than once (e.g. in a foreach node) is summed.
+type UsageReport struct {
+	Total Usage            `json:"total"`
+	Nodes map[string]Usage `json:"nodes"`
+}
+
+// usageRecorder the token usage shared by the context and the sub contexts
+type usageRecorder struct {
+	nodes map[string]Usage
+	mutex sync.Mutex
+}
+
+// estimateModel the model of the tiktoken encoding if the model is not supported by tiktoken
+const estimateModel = "gpt-3.5-turbo"
+
+// Usage returns the token usage of the AI nodes
+func (ctx *Context) Usage() UsageReport {
+	if ctx.usage == nil {
+		return UsageReport{Nodes: map[string]Usage{}}
+	}
+	return ctx.usage.report()
+}
+
+// add the usage of the node
+func (recorder *usageRecorder) add(name string, usage Usage) {
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+	if recorder.nodes == nil {
+		recorder.nodes = map[string]Usage{}
+	}
+	recorder.nodes[name] = recorder.nodes[name].add(usage)
+}
+
+func (recorder *usageRecorder) report() UsageReport {
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+
+	report := UsageReport{Nodes: map[string]Usage{}}
+	for name, usage := range recorder.nodes {
+		report.Nodes[name] = usage
+		report.Total = report.Total.add(usage)
+	}
+	return report
+}
+
+func (usage Usage) add(other Usage) Usage {
+	return Usage{
+		PromptTokens:     usage.PromptTokens + other.PromptTokens,
+		CompletionTokens: usage.CompletionTokens + other.CompletionTokens,
+		TotalTokens:      usage.TotalTokens + other.TotalTokens,
+		Estimated:        usage.Estimated || other.Estimated,
+	}
+}
+
+// Map returns the usage as a map, for the expressions ($usage.total.total_tokens)
+func (usage Usage) Map() map[string]any {
+	res := map[string]any{
+		"prompt_tokens":     usage.PromptTokens,
+		"completion_tokens": usage.CompletionTokens,
+		"total_tokens":      usage.TotalTokens,
+	}
+	if usage.Estimated {
+		res["estimated"] = true
+	}
+	return res
+}
+
+// Map returns the report as a map, for the expressions ($usage)
+func (report UsageReport) Map() map[string]any {
+	nodes := map[string]any{}
+	for name, usage := range report.Nodes {
+		nodes[name] = usage.Map()
+	}
+	return map[string]any{"total": report.Total.Map(), "nodes": nodes}
+}
+
+// aiStream collect the chat completion chunks and forward the deltas
+type aiStream struct {
+	response []string
+	content  []string // the lines out of the stream (error response)
+	usage    *Usage   // the usage of the last chunk, if the provider reports it
+	delta    func(delta string)
+}
+
+func (stream *aiStream) write(data []byte) int {
+	if len(data) > 5 && string(data[:5]) == "data:" {
+		var res ChatCompletionChunk
+		err := jsoniter.Unmarshal(data[5:], &res)
+		if err != nil {
+			return 0
+		}
+
+		if res.Usage != nil {
+			stream.usage = res.Usage
+		}
+
+		if len(res.Choices) > 0 {
+			delta := res.Choices[0].Delta.Content
+			stream.response = append(stream.response, delta)
+			if delta != "" && stream.delta != nil {
+				stream.delta(delta)
+			}
+		}
+	} else {
+		stream.content = append(stream.content, string(data))
+	}
+
+	return 1
+}
+
+// tokens returns the usage reported by the provider, or counted by tiktoken
+// The provider reports the usage in the last chunk if stream_options.include_usage is sent, see aiOptions
+func (stream *aiStream) tokens(model string, prompts []Prompt) Usage {
+	if stream.usage != nil && stream.usage.TotalTokens > 0 {
+		return *stream.usage
+	}
+
+	text := []string{}
+	for _, prompt := range prompts {
+		text = append(text, prompt.Content)
+	}
+
+	usage := Usage{
+		PromptTokens:     countTokens(model, strings.Join(text, "\n")),
+		CompletionTokens: countTokens(model, strings.Join(stream.response, "")),
+		Estimated:        true,
+	}
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	return usage
+}
+
+func countTokens(model string, input string) int {
+	if input == "" {
+		return 0
+	}
+
+	n, err := openai.Tiktoken(model, input)
+	if err != nil {
+		n, _ = openai.Tiktoken(estimateModel, input)
+	}
+	return n
+}
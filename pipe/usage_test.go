@@ -0,0 +1,61 @@
+package pipe
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAIStream(t *testing.T) {
+	deltas := []string{}
+	stream := &aiStream{delta: func(delta string) { deltas = append(deltas, delta) }}
+	assert.Equal(t, 1, stream.write([]byte(`data: {"choices":[{"index":0,"delta":{"content":"Hello"}}]}`)))
+	assert.Equal(t, 1, stream.write([]byte(`data: {"choices":[{"index":0,"delta":{"content":" world"}}]}`)))
+	assert.Equal(t, 1, stream.write([]byte(`data: {"choices":[],"usage":{"prompt_tokens":12,"completion_tokens":2,"total_tokens":14}}`)))
+	assert.Equal(t, 0, stream.write([]byte(`data: [DONE]`)))
+
+	assert.Equal(t, []string{"Hello", " world"}, deltas)
+	assert.Equal(t, Usage{PromptTokens: 12, CompletionTokens: 2, TotalTokens: 14}, stream.tokens("gpt-4o", nil))
+
+	// The provider omits the usage
+	stream = &aiStream{}
+	stream.write([]byte(`data: {"choices":[{"index":0,"delta":{"content":"hello world"}}]}`))
+	usage := stream.tokens("unknown-model", []Prompt{{Role: "user", Content: "say hello world"}})
+	assert.True(t, usage.Estimated)
+	assert.True(t, usage.PromptTokens > 0)
+	assert.True(t, usage.CompletionTokens > 0)
+	assert.Equal(t, usage.PromptTokens+usage.CompletionTokens, usage.TotalTokens)
+}
+
+func TestAIOptions(t *testing.T) {
+	options := map[string]interface{}{"temperature": 0.2}
+	option := aiOptions(options)
+	assert.Equal(t, map[string]interface{}{"include_usage": true}, option["stream_options"])
+	assert.Equal(t, 0.2, option["temperature"])
+	assert.NotContains(t, options, "stream_options")
+
+	// The node options take precedence
+	option = aiOptions(map[string]interface{}{"stream_options": nil})
+	assert.Nil(t, option["stream_options"])
+}
+
+func TestUsageReport(t *testing.T) {
+	recorder := &usageRecorder{}
+	recorder.add("summary", Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15})
+	recorder.add("summary", Usage{PromptTokens: 20, CompletionTokens: 5, TotalTokens: 25, Estimated: true})
+	recorder.add("title", Usage{PromptTokens: 3, CompletionTokens: 1, TotalTokens: 4})
+
+	ctx := &Context{usage: recorder}
+	report := ctx.Usage()
+	assert.Equal(t, Usage{PromptTokens: 30, CompletionTokens: 10, TotalTokens: 40, Estimated: true}, report.Nodes["summary"])
+	assert.Equal(t, Usage{PromptTokens: 33, CompletionTokens: 11, TotalTokens: 44, Estimated: true}, report.Total)
+
+	data := Data{"$usage": report.Map()}
+	v, err := data.Exec("{{ $usage.total.total_tokens }}")
+	assert.Nil(t, err)
+	assert.Equal(t, 44, v)
+
+	v, err = data.Exec("{{ $usage.nodes.title.prompt_tokens }}")
+	assert.Nil(t, err)
+	assert.Equal(t, 3, v)
+}
@@ -0,0 +1,316 @@
+package pipe
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/yaoapp/gou/application"
+	"github.com/yaoapp/gou/process"
+	"github.com/yaoapp/yao/share"
+)
+
+// The levels of the validation issues
+const (
+	IssueError   = "error"
+	IssueWarning = "warning"
+)
+
+// Issue the validation issue of the pipe
+type Issue struct {
+	Pipe    string `json:"pipe"`           // the pipe id, or the sub-pipe id
+	File    string `json:"file,omitempty"` // the file of the pipe, if the pipe is loaded from the file
+	Node    string `json:"node,omitempty"`
+	Level   string `json:"level"` // error, warning
+	Message string `json:"message"`
+}
+
+// literalRe the string literals of the expressions, the possible goto targets
+var literalRe = regexp.MustCompile(`'([^']*)'|"([^"]*)"`)
+
+// Validate check the pipe without running it
+// The expressions are compiled, the goto targets are resolved, the processes are checked,
+// and the nodes can not be reached are reported.
+func (pipe *Pipe) Validate() []Issue {
+	issues := []Issue{}
+	pipe.walk(func(p *Pipe) {
+		issues = append(issues, p.validate()...)
+	})
+
+	// The sub-pipes are visited in the random order of the maps
+	sort.SliceStable(issues, func(i, j int) bool { return issues[i].Pipe < issues[j].Pipe })
+	return issues
+}
+
+// ValidateAll check the pipes of the application, including the pipes failed to load
+func ValidateAll() ([]Issue, error) {
+	issues := []Issue{}
+	exts := []string{"*.pip.yao", "*.pipe.yao"}
+	err := application.App.Walk("pipes", func(root, file string, isdir bool) error {
+		if isdir {
+			return nil
+		}
+
+		id := share.ID(root, file)
+		pipe, err := NewFile(file, root)
+		if err != nil {
+			issues = append(issues, Issue{Pipe: id, File: file, Level: IssueError, Message: err.Error()})
+			return nil
+		}
+
+		for _, issue := range pipe.Validate() {
+			issue.File = file
+			issues = append(issues, issue)
+		}
+		return nil
+	}, exts...)
+
+	if err != nil {
+		return nil, err
+	}
+	return issues, nil
+}
+
+// HasError check if the issues have errors
+func HasError(issues []Issue) bool {
+	for _, issue := range issues {
+		if issue.Level == IssueError {
+			return true
+		}
+	}
+	return false
+}
+
+// validate check the nodes of the pipe, not including the sub-pipes
+func (pipe *Pipe) validate() []Issue {
+	issues := []Issue{}
+	add := func(node *Node, level string, format string, args ...any) {
+		issue := Issue{Pipe: pipe.ID, Level: level, Message: fmt.Sprintf(format, args...)}
+		if node != nil {
+			issue.Node = node.Name
+		}
+		issues = append(issues, issue)
+	}
+
+	if pipe.parent != nil && !pipe.HasNodes() {
+		add(nil, IssueWarning, "%s has no nodes", pipe.Name)
+	}
+
+	// The built-in processes are resolved ahead of the pipes
+	if _, has := builtin(pipe.ID); has && pipe.parent == nil {
+		add(nil, IssueError, "pipe id %s is reserved by the process pipes.%s", pipe.ID, pipe.ID)
+	}
+
+	// The node outputs are the variables, the names may override the builtin functions (e.g. upper)
+	env := Data{}
+	for p := pipe; p != nil; p = p.parent {
+		for _, node := range p.Nodes {
+			env[node.Name] = nil
+		}
+	}
+
+	// The pipe expressions
+	for _, stmt := range expressions(pipe.Input, pipe.Output) {
+		if err := compile(env, stmt); err != nil {
+			add(nil, IssueError, "expression %s %s", stmt, err.Error())
+		}
+	}
+
+	names := map[string]bool{}
+	for i := range pipe.Nodes {
+		node := &pipe.Nodes[i]
+		if names[node.Name] {
+			add(node, IssueError, "node name %s is duplicated", node.Name)
+		}
+		names[node.Name] = true
+
+		// Expressions
+		for _, stmt := range node.expressions() {
+			if err := compile(env, stmt); err != nil {
+				add(node, IssueError, "expression %s %s", stmt, err.Error())
+			}
+		}
+
+		// Goto targets
+		if node.Goto != "" && !IsExpression(node.Goto) && node.Goto != "EOF" {
+			if _, has := pipe.mapping[node.Goto]; !has {
+				add(node, IssueError, "goto %s node not found", node.Goto)
+			}
+		}
+
+		// Processes
+		if node.Process != nil && !IsExpression(node.Process.Name) {
+			if pipe.Whitelist != nil {
+				if _, has := pipe.Whitelist[node.Process.Name]; !has {
+					add(node, IssueError, "process %s is not in the whitelist", node.Process.Name)
+				}
+			}
+			if _, err := process.Of(node.Process.Name); err != nil {
+				add(node, IssueError, "process %s %s", node.Process.Name, err.Error())
+			}
+		}
+
+		// Switch targets
+		if node.Switch != nil {
+			if _, has := node.Switch["default"]; !has {
+				add(node, IssueWarning, "switch has no default case, the node fails if no case matches")
+			}
+
+			for key, child := range node.Switch {
+				if child == nil {
+					add(node, IssueError, "switch case %s has no pipe", key)
+				}
+			}
+		}
+	}
+
+	// Unreachable nodes
+	reachable := pipe.reachable()
+	for i := range pipe.Nodes {
+		if !reachable[i] {
+			add(&pipe.Nodes[i], IssueWarning, "node %s is unreachable", pipe.Nodes[i].Name)
+		}
+	}
+
+	return issues
+}
+
+// reachable returns the indexes of the nodes can be reached from the first node
+func (pipe *Pipe) reachable() map[int]bool {
+	reached := map[int]bool{}
+	if !pipe.HasNodes() {
+		return reached
+	}
+
+	queue := []int{0}
+	for len(queue) > 0 {
+		i := queue[0]
+		queue = queue[1:]
+		if reached[i] {
+			continue
+		}
+		reached[i] = true
+
+		next, _ := pipe.targets(&pipe.Nodes[i])
+		for _, index := range next {
+			if !reached[index] {
+				queue = append(queue, index)
+			}
+		}
+	}
+	return reached
+}
+
+// targets returns the indexes of the next nodes, and if the node may end the pipe (EOF)
+// The goto expression targets are the string literals of the expression, all the nodes if none of the literals is a node.
+func (pipe *Pipe) targets(node *Node) ([]int, bool) {
+	if node.Goto == "" {
+		if node.index+1 < len(pipe.Nodes) {
+			return []int{node.index + 1}, false
+		}
+		return []int{}, true
+	}
+
+	if !IsExpression(node.Goto) {
+		if next, has := pipe.mapping[node.Goto]; has {
+			return []int{next.index}, false
+		}
+		return []int{}, node.Goto == "EOF"
+	}
+
+	targets := []int{}
+	eof := false
+	for _, match := range literalRe.FindAllStringSubmatch(node.Goto, -1) {
+		name := match[1] + match[2]
+		if name == "EOF" {
+			eof = true
+			continue
+		}
+		if next, has := pipe.mapping[name]; has {
+			targets = append(targets, next.index)
+		}
+	}
+
+	if len(targets) == 0 && !eof {
+		for i := range pipe.Nodes {
+			targets = append(targets, i)
+		}
+		eof = true
+	}
+	return targets, eof
+}
+
+// expressions returns the expressions of the node
+func (node *Node) expressions() []string {
+	values := []any{node.Input, node.Output, node.Goto, node.Label}
+	if node.Process != nil {
+		values = append(values, node.Process.Name, []any(node.Process.Args))
+	}
+
+	for _, prompt := range node.Prompts {
+		values = append(values, prompt.Role, prompt.Content)
+	}
+
+	if node.Request != nil {
+		values = append(values, node.Request.Method, node.Request.URL, node.Request.Headers, node.Request.Query, node.Request.Body)
+	}
+
+	if node.AutoFill != nil {
+		values = append(values, node.AutoFill.Value)
+	}
+
+	for key := range node.Switch {
+		values = append(values, key)
+	}
+
+	if node.Foreach != nil {
+		values = append(values, node.Foreach.Items)
+	}
+
+	return expressions(values...)
+}
+
+// expressions returns the expressions of the values, sorted
+func expressions(values ...any) []string {
+	stmts := []string{}
+	var visit func(value any)
+	visit = func(value any) {
+		switch v := value.(type) {
+		case string:
+			if IsExpression(v) {
+				stmts = append(stmts, v)
+			}
+
+		case []any:
+			for _, item := range v {
+				visit(item)
+			}
+
+		case Input:
+			for _, item := range v {
+				visit(item)
+			}
+
+		case map[string]any:
+			for _, item := range v {
+				visit(item)
+			}
+		}
+	}
+
+	for _, value := range values {
+		visit(value)
+	}
+	sort.Strings(stmts)
+	return stmts
+}
+
+// compile the expression, the undefined variables are allowed
+func compile(env Data, stmt string) error {
+	_, err := env.New(stmt)
+	if err != nil {
+		return fmt.Errorf("%s", strings.Split(err.Error(), "\n")[0])
+	}
+	return nil
+}
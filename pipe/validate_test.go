@@ -0,0 +1,119 @@
+package pipe
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yaoapp/yao/config"
+	"github.com/yaoapp/yao/test"
+)
+
+func TestValidate(t *testing.T) {
+	test.Prepare(t, config.Conf)
+	defer test.Clean()
+
+	pipe, err := New([]byte(`{
+		"name": "validate",
+		"nodes": [
+			{"name": "upper", "process": {"name": "unit.pipe.upper", "args": ["{{ $in[0] }}"]}},
+			{"name": "broken", "process": {"name": "unit.pipe.upper", "args": ["{{ $in[0] + }}"]}, "goto": "missing"},
+			{"name": "unknown", "process": {"name": "unit.pipe.not-found"}, "goto": "EOF"},
+			{"name": "orphan", "process": {"name": "unit.pipe.upper"}},
+			{"name": "route", "case": {
+				"{{ $in[0] == 'a' }}": {"nodes": [{"name": "a", "process": {"name": "unit.pipe.upper"}, "goto": "nowhere"}]}
+			}, "goto": "{{ $in[0] == 'a' ? 'upper' : 'EOF' }}"}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	issues := pipe.Validate()
+	assert.True(t, HasError(issues))
+
+	messages := map[string][]string{}
+	for _, issue := range issues {
+		key := issue.Pipe + "/" + issue.Node + "/" + issue.Level
+		messages[key] = append(messages[key], issue.Message)
+	}
+	for _, values := range messages {
+		sort.Strings(values)
+	}
+
+	assert.Contains(t, strings.Join(messages["/broken/error"], "\n"), "expression {{ $in[0] + }}")
+	assert.Contains(t, messages["/broken/error"], "goto missing node not found")
+	assert.Contains(t, strings.Join(messages["/unknown/error"], "\n"), "process unit.pipe.not-found")
+	assert.Equal(t, []string{"node orphan is unreachable"}, messages["/orphan/warning"])
+	assert.Equal(t, []string{"node route is unreachable", "switch has no default case, the node fails if no case matches"}, messages["/route/warning"])
+	assert.Equal(t, []string{"goto nowhere node not found"}, messages[".route#"+ref("{{ $in[0] == 'a' }}")+"/a/error"])
+	assert.Empty(t, messages["/upper/error"])
+
+	// The valid pipe
+	pipe, err = New([]byte(`{
+		"name": "valid",
+		"nodes": [
+			{"name": "upper", "process": {"name": "unit.pipe.upper", "args": ["{{ $in[0] }}"]}, "goto": "{{ upper == 'A' ? 'again' : 'EOF' }}"},
+			{"name": "skip", "process": {"name": "unit.pipe.upper", "args": ["{{ $in[0] }}"]}},
+			{"name": "again", "process": {"name": "unit.pipe.upper", "args": ["{{ upper }}"]}}
+		],
+		"whitelist": ["unit.pipe.upper"]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	issues = pipe.Validate()
+	assert.False(t, HasError(issues))
+	assert.Equal(t, 1, len(issues))
+	assert.Equal(t, "skip", issues[0].Node)
+
+	// The id of the built-in process
+	pipe.ID = "list"
+	issues = pipe.Validate()
+	assert.True(t, HasError(issues))
+	assert.Equal(t, "pipe id list is reserved by the process pipes.list", issues[0].Message)
+}
+
+func TestGraph(t *testing.T) {
+	test.Prepare(t, config.Conf)
+	defer test.Clean()
+
+	pipe, err := New([]byte(`{
+		"name": "graph",
+		"nodes": [
+			{"name": "upper", "label": "Say \"hi\"", "process": {"name": "unit.pipe.upper", "args": ["{{ $in[0] }}"]}},
+			{"name": "route", "case": {
+				"default": {"nodes": [{"name": "a", "process": {"name": "unit.pipe.upper"}}]}
+			}, "goto": "EOF"},
+			{"name": "each", "foreach": {"items": "{{ $in[0] }}", "pipe": {"nodes": [{"name": "b", "process": {"name": "unit.pipe.upper"}}]}}}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pipe.ID = "unit.graph"
+
+	mermaid := pipe.Mermaid()
+	assert.True(t, strings.HasPrefix(mermaid, "flowchart TD\n"))
+	assert.Contains(t, mermaid, `n0["Say #quot;hi#quot;<br/>process"]`)
+	assert.Contains(t, mermaid, `n1{"ROUTE<br/>switch"}`)
+	assert.Contains(t, mermaid, `subgraph s0 ["case default"]`)
+	assert.Contains(t, mermaid, "start --> n0")
+	assert.Contains(t, mermaid, "n0 --> n1")
+	assert.Contains(t, mermaid, `n1 -->|"default"| n3`)
+	assert.Contains(t, mermaid, "n3 -.-> n1")
+	assert.Contains(t, mermaid, `n1 -->|"goto"| stop`)
+	assert.Contains(t, mermaid, "n2 --> stop")
+
+	dot := pipe.DOT()
+	assert.True(t, strings.HasPrefix(dot, `digraph "unit.graph" {`))
+	assert.Contains(t, dot, `n0 [shape=box, label="Say \"hi\"\nprocess"];`)
+	assert.Contains(t, dot, `n1 [shape=diamond, label="ROUTE\nswitch"];`)
+	assert.Contains(t, dot, "subgraph cluster_s0 {")
+	assert.Contains(t, dot, `n1 -> n3 [label="default"];`)
+	assert.Contains(t, dot, "n3 -> n1 [style=dashed];")
+	assert.Contains(t, dot, `n1 -> stop [label="goto"];`)
+	assert.Equal(t, mermaid, pipe.Mermaid())
+}
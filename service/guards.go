@@ -11,6 +11,7 @@ import (
 	"github.com/yaoapp/yao/widgets/dashboard"
 	"github.com/yaoapp/yao/widgets/form"
 	"github.com/yaoapp/yao/widgets/list"
+	"github.com/yaoapp/yao/widgets/login"
 	"github.com/yaoapp/yao/widgets/table"
 )
 
@@ -58,6 +59,11 @@ func guardCookieJWT(c *gin.Context) {
 	}
 
 	claims := helper.JwtValidate(tokenString)
+	if login.Revoked(claims.SID) {
+		c.JSON(401, gin.H{"code": 401, "message": "Token revoked"})
+		c.Abort()
+		return
+	}
 	c.Set("__sid", claims.SID)
 	return
 }
@@ -73,6 +79,11 @@ func guardBearerJWT(c *gin.Context) {
 	}
 
 	claims := helper.JwtValidate(tokenString)
+	if login.Revoked(claims.SID) {
+		c.JSON(401, gin.H{"code": 401, "message": "Token revoked"})
+		c.Abort()
+		return
+	}
 	c.Set("__sid", claims.SID)
 }
 
@@ -86,6 +97,11 @@ func guardQueryJWT(c *gin.Context) {
 	}
 
 	claims := helper.JwtValidate(tokenString)
+	if login.Revoked(claims.SID) {
+		c.JSON(401, gin.H{"code": 401, "message": "Token revoked"})
+		c.Abort()
+		return
+	}
 	c.Set("__sid", claims.SID)
 }
 
@@ -8,6 +8,7 @@ import (
 	"github.com/yaoapp/gou/server/http"
 	"github.com/yaoapp/yao/config"
 	"github.com/yaoapp/yao/neo"
+	"github.com/yaoapp/yao/pipe"
 	"github.com/yaoapp/yao/share"
 )
 
@@ -40,6 +41,9 @@ func Start(cfg config.Config) (*http.Server, error) {
 		neo.Neo.API(router, "/api/__yao/neo")
 	}
 
+	// Pipe API
+	pipe.API(router, "/api/__yao/pipes", Guards["bearer-jwt"])
+
 	go func() {
 		err = srv.Start()
 	}()
@@ -15,6 +15,7 @@ import (
 	"github.com/yaoapp/gou/runtime/v8/bridge"
 	"github.com/yaoapp/kun/log"
 	"github.com/yaoapp/yao/helper"
+	"github.com/yaoapp/yao/widgets/login"
 	"rogchap.com/v8go"
 )
 
@@ -41,6 +42,11 @@ func guardBearerJWT(r *Request) error {
 	}
 
 	claims := helper.JwtValidate(tokenString)
+	if login.Revoked(claims.SID) {
+		c.JSON(401, gin.H{"code": 401, "message": "Token revoked"})
+		c.Abort()
+		return fmt.Errorf("Token revoked")
+	}
 	c.Set("__sid", claims.SID)
 	r.Sid = claims.SID
 	return nil
@@ -67,6 +73,11 @@ func guardCookieJWT(r *Request) error {
 	}
 
 	claims := helper.JwtValidate(tokenString)
+	if login.Revoked(claims.SID) {
+		c.JSON(401, gin.H{"code": 401, "message": "Token revoked"})
+		c.Abort()
+		return fmt.Errorf("Token revoked")
+	}
 	c.Set("__sid", claims.SID)
 	r.Sid = claims.SID
 	return nil
@@ -106,6 +117,11 @@ func guardQueryJWT(r *Request) error {
 	}
 
 	claims := helper.JwtValidate(tokenString)
+	if login.Revoked(claims.SID) {
+		c.JSON(401, gin.H{"code": 401, "message": "Token revoked"})
+		c.Abort()
+		return fmt.Errorf("Token revoked")
+	}
 	c.Set("__sid", claims.SID)
 	r.Sid = claims.SID
 	return nil
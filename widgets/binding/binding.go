@@ -0,0 +1,57 @@
+package binding
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/yaoapp/gou/model"
+)
+
+var rowType = reflect.TypeOf(map[string]interface{}{})
+
+// Model the model bound to the process models.<name>.<method>, feature is the widget feature requiring the model (e.g. the audit)
+func Model(bind string, feature string) (*model.Model, error) {
+	end := strings.LastIndex(bind, ".")
+	if !strings.HasPrefix(strings.ToLower(bind), "models.") || end < len("models.") {
+		return nil, fmt.Errorf("%s of %s requires the model process", feature, bind)
+	}
+
+	name := bind[len("models."):end]
+	mod, has := model.Models[name]
+	if !has {
+		return nil, fmt.Errorf("the model %s is not loaded", name)
+	}
+	return mod, nil
+}
+
+// Row the row data (map[string]interface{}, maps.MapStr, maps.MapStrAny...), the changes are applied to the value
+func Row(value interface{}) (map[string]interface{}, bool) {
+	if row, ok := value.(map[string]interface{}); ok {
+		return row, row != nil
+	}
+
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Map || v.IsNil() || !v.Type().ConvertibleTo(rowType) {
+		return nil, false
+	}
+	return v.Convert(rowType).Interface().(map[string]interface{}), true
+}
+
+// Copy copy the map with the string keys to map[string]interface{}, nil if the value is not a map
+func Copy(value interface{}) map[string]interface{} {
+	if row, ok := Row(value); ok {
+		return row
+	}
+
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Map || v.Type().Key().Kind() != reflect.String {
+		return nil
+	}
+
+	row := map[string]interface{}{}
+	for _, key := range v.MapKeys() {
+		row[key.String()] = v.MapIndex(key).Interface()
+	}
+	return row
+}
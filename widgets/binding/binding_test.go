@@ -0,0 +1,44 @@
+package binding
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yaoapp/kun/maps"
+	"github.com/yaoapp/yao/config"
+	"github.com/yaoapp/yao/test"
+)
+
+func TestModel(t *testing.T) {
+	test.Prepare(t, config.Conf)
+	defer test.Clean()
+
+	mod, err := Model("models.pet.Save", "the audit")
+	if assert.Nil(t, err) {
+		assert.Equal(t, "pet", mod.ID)
+	}
+
+	_, err = Model("scripts.pet.Save", "the audit")
+	assert.EqualError(t, err, "the audit of scripts.pet.Save requires the model process")
+
+	_, err = Model("models.not-found.Save", "the audit")
+	assert.EqualError(t, err, "the model not-found is not loaded")
+}
+
+func TestRow(t *testing.T) {
+	value := maps.MapStr{"name": "Cookie"}
+	row, ok := Row(value)
+	if assert.True(t, ok) {
+		row["name"] = "Baby"
+		assert.Equal(t, "Baby", value["name"])
+	}
+
+	_, ok = Row(map[string]string{"name": "Cookie"})
+	assert.False(t, ok)
+
+	_, ok = Row(nil)
+	assert.False(t, ok)
+
+	assert.Equal(t, map[string]interface{}{"name": "Cookie"}, Copy(map[string]string{"name": "Cookie"}))
+	assert.Nil(t, Copy([]interface{}{"Cookie"}))
+}
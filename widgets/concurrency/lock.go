@@ -0,0 +1,300 @@
+package concurrency
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yaoapp/gou/model"
+	"github.com/yaoapp/gou/process"
+	"github.com/yaoapp/gou/types"
+	"github.com/yaoapp/kun/any"
+	"github.com/yaoapp/yao/widgets/binding"
+)
+
+// DefaultColumn the default token column
+const DefaultColumn = "version"
+
+// Check check the args of the widget action, returns the args to call the process.
+// name is the action name (e.g. yao.form.Save), bind is the process bound to the action (e.g. models.pet.Save)
+//
+//	find:   the token column is selected
+//	update: the row args[1] must have the token
+//	save:   the same as update if the row has the primary key
+//
+// The token is renewed by Acquire before the bound process writes the row.
+func (lock *Lock) Check(name string, bind string, sid string, args []interface{}) ([]interface{}, error) {
+	if lock == nil {
+		return args, nil
+	}
+
+	if action(name) == "find" {
+		return args, lock.selectToken(args)
+	}
+
+	mod, _, row, err := lock.target(name, bind, args)
+	if err != nil || mod == nil {
+		return args, err
+	}
+
+	column := lock.column()
+	if token, has := row[column]; !has || token == nil {
+		return args, fmt.Errorf("the concurrency token %s is required", column)
+	}
+	return args, nil
+}
+
+// Acquire renew the token of the row of update and save if it is not changed, nil if the action is not locked.
+// Only the token is written, conditionally:
+//
+//	UPDATE <table> SET <column> = <next token> WHERE <primary> = ? AND <column> = <current token>
+//
+// The row of args is set to the next token, then the bound process writes it (relations, hooks... are kept).
+// A *Conflict error is returned if the tokens are different or no row is updated (modified concurrently).
+// Release the token if the bound process fails.
+func (lock *Lock) Acquire(name string, bind string, sid string, args []interface{}) (*Token, error) {
+	if lock == nil {
+		return nil, nil
+	}
+
+	mod, id, row, err := lock.target(name, bind, args)
+	if err != nil || mod == nil {
+		return nil, err
+	}
+
+	current, err := lock.find(mod, sid, id)
+	if err != nil {
+		return nil, err
+	}
+
+	column := lock.column()
+	if tokenString(row[column]) != tokenString(current[column]) {
+		return nil, lock.conflict(mod, id, row, current)
+	}
+
+	token := &Token{lock: lock, model: mod, sid: sid, primary: id, previous: current[column], next: renew(current[column])}
+	written, err := token.write(token.previous, token.next)
+	if err != nil {
+		return nil, err
+	}
+
+	if !written {
+		// Modified after the token was compared
+		current, err := lock.find(mod, sid, id)
+		if err != nil {
+			return nil, err
+		}
+		return nil, lock.conflict(mod, id, row, current)
+	}
+
+	row[column] = token.next
+	return token, nil
+}
+
+// Release restore the previous token, the row is not written by the bound process
+func (token *Token) Release() error {
+	if token == nil {
+		return nil
+	}
+	_, err := token.write(token.next, token.previous)
+	return err
+}
+
+// write set the token to value if the current token is from, written is false if no row is updated
+func (token *Token) write(from interface{}, value interface{}) (bool, error) {
+	column := token.lock.column()
+	where := types.QueryWhere{Column: column, Value: from}
+	if from == nil {
+		where = types.QueryWhere{Column: column, OP: "null"}
+	}
+
+	mod := token.model
+	param := types.QueryParam{Wheres: []types.QueryWhere{{Column: mod.PrimaryKey, Value: token.primary}, where}}
+	res, err := process.New(fmt.Sprintf("models.%s.UpdateWhere", mod.ID), param, map[string]interface{}{column: value}).WithSID(token.sid).Exec()
+	if err != nil {
+		return false, err
+	}
+	return any.Of(res).CInt() > 0, nil
+}
+
+// Error the error message
+func (conflict *Conflict) Error() string {
+	fields := []string{}
+	for name := range conflict.Fields {
+		fields = append(fields, name)
+	}
+	sort.Strings(fields)
+
+	if len(fields) == 0 {
+		return fmt.Sprintf("the record %v has been modified by others", conflict.Primary)
+	}
+	return fmt.Sprintf("the record %v has been modified by others, conflicting fields: %s", conflict.Primary, strings.Join(fields, ", "))
+}
+
+// Map cast to map, used as the context of the 409 exception
+func (conflict *Conflict) Map() map[string]interface{} {
+	fields := map[string]interface{}{}
+	for name, field := range conflict.Fields {
+		fields[name] = map[string]interface{}{"yours": field.Yours, "theirs": field.Theirs}
+	}
+
+	return map[string]interface{}{
+		"primary": conflict.Primary,
+		"column":  conflict.Column,
+		"token":   conflict.Token,
+		"fields":  fields,
+	}
+}
+
+func (lock *Lock) column() string {
+	if lock.Column == "" {
+		return DefaultColumn
+	}
+	return lock.Column
+}
+
+// target the model, the primary key and the row written by update and save, the model is nil if the row is not locked
+func (lock *Lock) target(name string, bind string, args []interface{}) (*model.Model, interface{}, map[string]interface{}, error) {
+	switch action(name) {
+	case "update":
+		if len(args) < 2 {
+			return nil, nil, nil, nil
+		}
+		row, ok := binding.Row(args[1])
+		if !ok {
+			return nil, nil, nil, nil
+		}
+
+		mod, err := binding.Model(bind, "the optimistic lock")
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		return mod, args[0], row, nil
+
+	case "save":
+		if len(args) < 1 {
+			return nil, nil, nil, nil
+		}
+		row, ok := binding.Row(args[0])
+		if !ok {
+			return nil, nil, nil, nil
+		}
+
+		mod, err := binding.Model(bind, "the optimistic lock")
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		id, has := row[mod.PrimaryKey]
+		if !has || id == nil {
+			return nil, nil, nil, nil
+		}
+		return mod, id, row, nil
+	}
+
+	return nil, nil, nil, nil
+}
+
+// find the current row
+func (lock *Lock) find(mod *model.Model, sid string, id interface{}) (map[string]interface{}, error) {
+	res, err := process.New(fmt.Sprintf("models.%s.Find", mod.ID), id, types.QueryParam{}).WithSID(sid).Exec()
+	if err != nil {
+		return nil, err
+	}
+
+	current, ok := binding.Row(res)
+	if !ok {
+		return nil, fmt.Errorf("the record %v does not exist", id)
+	}
+	return current, nil
+}
+
+// conflict the submitted fields having a different current value
+func (lock *Lock) conflict(mod *model.Model, id interface{}, row map[string]interface{}, current map[string]interface{}) *Conflict {
+	column := lock.column()
+	conflict := &Conflict{Primary: id, Column: column, Token: current[column], Fields: map[string]FieldConflict{}}
+	for name, value := range row {
+		if name == column || name == mod.PrimaryKey {
+			continue
+		}
+		if theirs := current[name]; tokenString(value) != tokenString(theirs) {
+			conflict.Fields[name] = FieldConflict{Yours: value, Theirs: theirs}
+		}
+	}
+	return conflict
+}
+
+// selectToken add the token column to the select of the find param args[1]
+func (lock *Lock) selectToken(args []interface{}) error {
+	if len(args) < 2 || args[1] == nil {
+		return nil
+	}
+
+	param, ok := args[1].(types.QueryParam)
+	if !ok {
+		param, ok = types.AnyToQueryParam(args[1])
+		if !ok {
+			return fmt.Errorf("the query param is invalid %#v", args[1])
+		}
+	}
+
+	if len(param.Select) == 0 {
+		return nil
+	}
+
+	column := lock.column()
+	for _, field := range param.Select {
+		if fmt.Sprintf("%v", field) == column {
+			return nil
+		}
+	}
+
+	param.Select = append(param.Select, column)
+	args[1] = param
+	return nil
+}
+
+// renew the next token, the integer version is increased, otherwise the current time
+func renew(token interface{}) interface{} {
+	switch value := token.(type) {
+	case int:
+		return value + 1
+	case int64:
+		return value + 1
+	case float64:
+		return int64(value) + 1
+	case nil:
+		return 1
+	case string:
+		if version, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return version + 1
+		}
+	}
+	return time.Now()
+}
+
+// tokenString the text of the token, the time is formatted as the JSON encoding
+func tokenString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case *time.Time:
+		if v == nil {
+			return ""
+		}
+		return v.Format(time.RFC3339Nano)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+// action the last part of the action name, e.g. save of yao.form.Save
+func action(name string) string {
+	namer := strings.Split(strings.ToLower(name), ".")
+	return namer[len(namer)-1]
+}
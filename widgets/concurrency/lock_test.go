@@ -0,0 +1,286 @@
+package concurrency
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yaoapp/gou/model"
+	"github.com/yaoapp/gou/process"
+	"github.com/yaoapp/gou/types"
+	"github.com/yaoapp/kun/any"
+	"github.com/yaoapp/kun/exception"
+	"github.com/yaoapp/yao/config"
+	"github.com/yaoapp/yao/test"
+)
+
+func TestCheck(t *testing.T) {
+	test.Prepare(t, config.Conf)
+	defer test.Clean()
+	prepare(t)
+
+	// the stay column is used as the version
+	lock := &Lock{Column: "stay"}
+
+	// The token is required
+	_, err := lock.Check("yao.form.Update", "models.pet.Update", "", []interface{}{1, map[string]interface{}{"name": "New Cookie"}})
+	assert.Error(t, err)
+
+	_, err = lock.Check("yao.form.Update", "models.pet.Update", "", []interface{}{1, map[string]interface{}{"name": "New Cookie", "stay": 200}})
+	assert.Nil(t, err)
+
+	// Create
+	row := map[string]interface{}{"name": "Poo"}
+	_, err = lock.Check("yao.form.Save", "models.pet.Save", "", []interface{}{row})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"name": "Poo"}, row)
+
+	// Find selects the token
+	args, err := lock.Check("yao.form.Find", "models.pet.Find", "", []interface{}{1, types.QueryParam{Select: []interface{}{"name"}}})
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{"name", "stay"}, args[1].(types.QueryParam).Select)
+
+	// No lock
+	var empty *Lock
+	args, err = empty.Check("yao.form.Update", "models.pet.Update", "", []interface{}{1, map[string]interface{}{"name": "New Cookie"}})
+	assert.Nil(t, err)
+	assert.Len(t, args, 2)
+}
+
+func TestAcquire(t *testing.T) {
+	test.Prepare(t, config.Conf)
+	defer test.Clean()
+	prepare(t)
+
+	// the stay column is used as the version
+	lock := &Lock{Column: "stay"}
+
+	// Conflict
+	_, err := lock.Acquire("yao.form.Update", "models.pet.Update", "", []interface{}{1, map[string]interface{}{"name": "New Cookie", "stay": 199}})
+	conflict, ok := err.(*Conflict)
+	if assert.True(t, ok) {
+		assert.Equal(t, "200", fmt.Sprintf("%v", conflict.Token))
+		assert.Equal(t, FieldConflict{Yours: "New Cookie", Theirs: "Cookie"}, conflict.Fields["name"])
+		assert.Contains(t, conflict.Error(), "conflicting fields: name")
+		assert.Equal(t, "stay", conflict.Map()["column"])
+	}
+
+	// The token is renewed, the row is written by the bound process
+	args := []interface{}{1, map[string]interface{}{"name": "New Cookie", "stay": 200}}
+	token, err := lock.Acquire("yao.form.Update", "models.pet.Update", "", args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NotNil(t, token)
+	assert.Equal(t, "201", fmt.Sprintf("%v", args[1].(map[string]interface{})["stay"]))
+	assert.Equal(t, "201", fmt.Sprintf("%v", current(t)["stay"]))
+	assert.Equal(t, "Cookie", current(t)["name"])
+
+	_, err = process.New("models.pet.Update", args...).Exec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "New Cookie", current(t)["name"])
+	assert.Equal(t, "201", fmt.Sprintf("%v", current(t)["stay"]))
+
+	// The token is restored if the bound process fails
+	row := map[string]interface{}{"id": 1, "name": "Cookie", "stay": "201"}
+	token, err = lock.Acquire("yao.table.Save", "models.pet.Save", "", []interface{}{row})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "202", fmt.Sprintf("%v", current(t)["stay"]))
+	assert.Nil(t, token.Release())
+	assert.Equal(t, "201", fmt.Sprintf("%v", current(t)["stay"]))
+
+	// Modified after the token was compared
+	written, err := (&Token{lock: lock, model: model.Select("pet"), primary: 1}).write(199, 200)
+	assert.Nil(t, err)
+	assert.False(t, written)
+
+	// Not locked
+	token, err = lock.Acquire("yao.form.Save", "models.pet.Save", "", []interface{}{map[string]interface{}{"name": "Poo"}})
+	assert.Nil(t, token)
+	assert.Nil(t, err)
+	assert.Nil(t, token.Release())
+}
+
+func TestAcquireHasMany(t *testing.T) {
+	test.Prepare(t, config.Conf)
+	defer test.Clean()
+	prepareOrder(t)
+
+	// The bound process saves the order and the items of the hasMany relation
+	lock := &Lock{}
+	args := []interface{}{map[string]interface{}{
+		"id":      1,
+		"name":    "Order 1 (changed)",
+		"version": 1,
+		"items":   []interface{}{map[string]interface{}{"name": "Item 1"}, map[string]interface{}{"name": "Item 2"}},
+	}}
+
+	args, err := lock.Check("yao.form.Save", "models.unit.lock.order.SaveWithItems", "", args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = lock.Acquire("yao.form.Save", "models.unit.lock.order.SaveWithItems", "", args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = process.New("models.unit.lock.order.SaveWithItems", args...).Exec()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	order, err := model.Select("unit.lock.order").Find(1, model.QueryParam{Withs: map[string]model.With{"items": {}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "Order 1 (changed)", order["name"])
+	assert.Equal(t, "2", fmt.Sprintf("%v", order["version"]))
+	assert.Len(t, order["items"], 2)
+
+	// The stale token
+	_, err = lock.Acquire("yao.form.Save", "models.unit.lock.order.SaveWithItems", "", []interface{}{map[string]interface{}{"id": 1, "name": "Order 1", "version": 1}})
+	_, ok := err.(*Conflict)
+	assert.True(t, ok)
+}
+
+func TestAcquireConcurrent(t *testing.T) {
+	test.Prepare(t, config.Conf)
+	defer test.Clean()
+	prepare(t)
+
+	lock := &Lock{Column: "stay"}
+	var wg sync.WaitGroup
+	var acquired, conflicts int32
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			row := map[string]interface{}{"name": fmt.Sprintf("Cookie %d", i), "stay": 200}
+			_, err := lock.Acquire("yao.form.Update", "models.pet.Update", "", []interface{}{1, row})
+			if err == nil {
+				atomic.AddInt32(&acquired, 1)
+				return
+			}
+			if _, ok := err.(*Conflict); ok {
+				atomic.AddInt32(&conflicts, 1)
+				return
+			}
+			t.Error(err)
+		}(i)
+	}
+	wg.Wait()
+
+	// Only one of the writes using the same token acquires it
+	assert.Equal(t, int32(1), acquired)
+	assert.Equal(t, int32(7), conflicts)
+	assert.Equal(t, "201", fmt.Sprintf("%v", current(t)["stay"]))
+}
+
+func TestRenew(t *testing.T) {
+	assert.Equal(t, 2, renew(1))
+	assert.Equal(t, int64(8), renew(int64(7)))
+	assert.Equal(t, int64(4), renew("3"))
+	assert.Equal(t, 1, renew(nil))
+	_, ok := renew("2024-01-02 15:04:05").(time.Time)
+	assert.True(t, ok)
+}
+
+func prepare(t *testing.T) {
+	pet := model.Select("pet")
+	err := pet.DropTable()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = pet.Migrate(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = pet.Insert(
+		[]string{"name", "type", "status", "mode", "stay", "cost", "doctor_id"},
+		[][]interface{}{{"Cookie", "cat", "checked", "enabled", 200, 105, 1}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func current(t *testing.T) map[string]interface{} {
+	row, err := model.Select("pet").Find(1, model.QueryParam{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return row
+}
+
+// prepareOrder the order model having many items, and the SaveWithItems process of the models
+func prepareOrder(t *testing.T) {
+	sources := map[string]string{
+		"unit.lock.order": `{
+			"name": "Order",
+			"table": {"name": "unit_lock_order"},
+			"columns": [
+				{"name": "id", "type": "ID"},
+				{"name": "name", "type": "string"},
+				{"name": "version", "type": "integer", "default": 1}
+			],
+			"relations": {
+				"items": {"type": "hasMany", "model": "unit.lock.item", "key": "order_id", "foreign": "id"}
+			}
+		}`,
+		"unit.lock.item": `{
+			"name": "Item",
+			"table": {"name": "unit_lock_item"},
+			"columns": [
+				{"name": "id", "type": "ID"},
+				{"name": "order_id", "type": "integer", "index": true},
+				{"name": "name", "type": "string"}
+			]
+		}`,
+	}
+
+	for id, source := range sources {
+		mod, err := model.LoadSource([]byte(source), id, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = mod.Migrate(true)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err := model.Select("unit.lock.order").Create(map[string]interface{}{"name": "Order 1", "version": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	process.Register("models.SaveWithItems", func(p *process.Process) interface{} {
+		p.ValidateArgNums(1)
+		row := any.Of(p.Args[0]).Map().MapStrAny
+		items, _ := row.Get("items").([]interface{})
+		row.Del("items")
+
+		id := model.Select(p.ID).MustSave(row)
+		rows := []map[string]interface{}{}
+		for _, item := range items {
+			rows = append(rows, item.(map[string]interface{}))
+		}
+
+		_, err := model.Select("unit.lock.item").EachSave(rows, map[string]interface{}{"order_id": id})
+		if err != nil {
+			exception.New(err.Error(), 500).Throw()
+		}
+		return id
+	})
+}
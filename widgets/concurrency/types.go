@@ -0,0 +1,33 @@
+package concurrency
+
+import "github.com/yaoapp/gou/model"
+
+// Lock the optimistic lock of the table and form widgets.
+// The concurrency token is returned by find, checked and renewed by update and save.
+type Lock struct {
+	Column string `json:"column,omitempty"` // the token column, e.g. version (the integer increased on each write) or updated_at, the default is version
+}
+
+// Token the token of the row renewed by Acquire
+type Token struct {
+	lock     *Lock
+	model    *model.Model
+	sid      string
+	primary  interface{}
+	previous interface{} // the token read before the write
+	next     interface{} // the token written to the row
+}
+
+// Conflict the row has been modified since the token was read
+type Conflict struct {
+	Primary interface{}              `json:"primary"`
+	Column  string                   `json:"column"`
+	Token   interface{}              `json:"token"`  // the current token, resubmit it to overwrite the row
+	Fields  map[string]FieldConflict `json:"fields"` // the submitted fields having a different current value
+}
+
+// FieldConflict the submitted value and the current value of the field
+type FieldConflict struct {
+	Yours  interface{} `json:"yours"`
+	Theirs interface{} `json:"theirs"`
+}
@@ -7,10 +7,12 @@ import (
 	"github.com/yaoapp/gou/model"
 	gouProcess "github.com/yaoapp/gou/process"
 	"github.com/yaoapp/gou/session"
+	"github.com/yaoapp/kun/exception"
 	"github.com/yaoapp/kun/log"
 	"github.com/yaoapp/yao/config"
 	"github.com/yaoapp/yao/i18n"
 	"github.com/yaoapp/yao/widgets/action"
+	"github.com/yaoapp/yao/widgets/concurrency"
 )
 
 // ********************************
@@ -56,20 +58,57 @@ func processHandler(p *action.Process, process *gouProcess.Process) (interface{}
 		log.Error("[form] %s %s Compute Edit Error: %s", form.ID, p.Name, err.Error())
 	}
 
+	// Data permissions
+	args, err = form.Permissions.Apply(p.Name, name, process.Sid, args)
+	if err != nil {
+		log.Warn("[form] %s %s %s", form.ID, p.Name, err.Error())
+		exception.New(err.Error(), 403).Throw()
+	}
+
+	// Optimistic lock
+	args, err = form.Lock.Check(p.Name, name, process.Sid, args)
+	if err != nil {
+		log.Warn("[form] %s %s %s", form.ID, p.Name, err.Error())
+		if conflict, ok := err.(*concurrency.Conflict); ok {
+			exception.New(err.Error(), 409).Ctx(conflict.Map()).Throw()
+		}
+		exception.New(err.Error(), 400).Throw()
+	}
+
+	// Audit trail
+	trail := form.Audit.Begin("form", form.ID, p.Name, name, process.Sid, args)
+
+	// Optimistic lock, the token is renewed before the process writes the row
+	token, err := form.Lock.Acquire(p.Name, name, process.Sid, args)
+	if err != nil {
+		log.Warn("[form] %s %s %s", form.ID, p.Name, err.Error())
+		if conflict, ok := err.(*concurrency.Conflict); ok {
+			exception.New(err.Error(), 409).Ctx(conflict.Map()).Throw()
+		}
+		return nil, fmt.Errorf("[form] %s %s -> %s %s", form.ID, p.Name, name, err.Error())
+	}
+
 	// Execute Process
 	act, err := gouProcess.Of(name, args...)
 	if err != nil {
+		if err := token.Release(); err != nil {
+			log.Error("[form] %s %s release the concurrency token: %s", form.ID, p.Name, err.Error())
+		}
 		log.Error("[form] %s %s -> %s %s", form.ID, p.Name, name, err.Error())
 		return nil, fmt.Errorf("[form] %s %s -> %s %s", form.ID, p.Name, name, err.Error())
 	}
 
 	err = act.WithGlobal(process.Global).WithSID(process.Sid).Execute()
 	if err != nil {
+		if err := token.Release(); err != nil {
+			log.Error("[form] %s %s release the concurrency token: %s", form.ID, p.Name, err.Error())
+		}
 		log.Error("[form] %s %s -> %s %s", form.ID, p.Name, name, err.Error())
 		return nil, fmt.Errorf("[form] %s %s -> %s %s", form.ID, p.Name, name, err.Error())
 	}
 	defer act.Release()
 	res := act.Value()
+	trail.Commit(res)
 
 	// Compute View
 	err = form.ComputeView(p.Name, process, res, form.getField())
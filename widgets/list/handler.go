@@ -7,6 +7,7 @@ import (
 	"github.com/yaoapp/gou/model"
 	gouProcess "github.com/yaoapp/gou/process"
 	"github.com/yaoapp/gou/session"
+	"github.com/yaoapp/kun/exception"
 	"github.com/yaoapp/kun/log"
 	"github.com/yaoapp/yao/config"
 	"github.com/yaoapp/yao/i18n"
@@ -56,6 +57,16 @@ func processHandler(p *action.Process, process *gouProcess.Process) (interface{}
 		log.Error("[list] %s %s Compute Edit Error: %s", list.ID, p.Name, err.Error())
 	}
 
+	// Data permissions
+	args, err = list.Permissions.Apply(p.Name, name, process.Sid, args)
+	if err != nil {
+		log.Warn("[list] %s %s %s", list.ID, p.Name, err.Error())
+		exception.New(err.Error(), 403).Throw()
+	}
+
+	// Audit trail
+	trail := list.Audit.Begin("list", list.ID, p.Name, name, process.Sid, args)
+
 	// Execute Process
 	act, err := gouProcess.Of(name, args...)
 	if err != nil {
@@ -70,6 +81,7 @@ func processHandler(p *action.Process, process *gouProcess.Process) (interface{}
 	}
 	defer act.Release()
 	res := act.Value()
+	trail.Commit(res)
 
 	// Compute View
 	err = list.ComputeView(p.Name, process, res, list.getField())
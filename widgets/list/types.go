@@ -1,24 +1,28 @@
 package list
 
 import (
+	"github.com/yaoapp/yao/audit"
 	"github.com/yaoapp/yao/widgets/action"
 	"github.com/yaoapp/yao/widgets/component"
 	"github.com/yaoapp/yao/widgets/compute"
 	"github.com/yaoapp/yao/widgets/field"
 	"github.com/yaoapp/yao/widgets/hook"
 	"github.com/yaoapp/yao/widgets/mapping"
+	"github.com/yaoapp/yao/widgets/permission"
 )
 
 // DSL the list DSL
 type DSL struct {
-	ID     string                 `json:"id,omitempty"`
-	Root   string                 `json:"-"`
-	Name   string                 `json:"name,omitempty"`
-	Action *ActionDSL             `json:"action"`
-	Layout *LayoutDSL             `json:"layout"`
-	Fields *FieldsDSL             `json:"fields"`
-	Config map[string]interface{} `json:"config,omitempty"`
-	CProps field.CloudProps       `json:"-"`
+	ID          string                 `json:"id,omitempty"`
+	Root        string                 `json:"-"`
+	Name        string                 `json:"name,omitempty"`
+	Action      *ActionDSL             `json:"action"`
+	Layout      *LayoutDSL             `json:"layout"`
+	Fields      *FieldsDSL             `json:"fields"`
+	Config      map[string]interface{} `json:"config,omitempty"`
+	CProps      field.CloudProps       `json:"-"`
+	Permissions *permission.Policy     `json:"permissions,omitempty"`
+	Audit       *audit.Option          `json:"audit,omitempty"`
 	compute.Computable
 	*mapping.Mapping
 }
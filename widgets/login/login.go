@@ -14,6 +14,9 @@ import (
 // API:
 //   GET  /api/__yao/login/:id/captcha  -> Default process: yao.utils.Captcha :query
 //  POST  /api/__yao/login/:id  		-> Default process: yao.login.Admin :payload
+//  POST  /api/__yao/login/refresh  	-> yao.login.Refresh :payload
+//  POST  /api/__yao/login/logout  	-> yao.login.Logout
+//   GET  /api/__yao/login/sessions  	-> yao.login.Sessions
 //
 
 // Logins the loaded login widgets
@@ -118,6 +121,38 @@ func exportAPI() error {
 
 	}
 
+	// session
+	http.Paths = append(http.Paths,
+		api.Path{
+			Label:       "Refresh token",
+			Description: "Exchange the refresh token for a new token",
+			Guard:       "-",
+			Path:        "/refresh",
+			Method:      "POST",
+			Process:     "yao.login.Refresh",
+			In:          []interface{}{":payload"},
+			Out:         api.Out{Status: 200, Type: "application/json"},
+		},
+		api.Path{
+			Label:       "Logout",
+			Description: "Revoke the current session",
+			Path:        "/logout",
+			Method:      "POST",
+			Process:     "yao.login.Logout",
+			In:          []interface{}{},
+			Out:         api.Out{Status: 200, Type: "application/json"},
+		},
+		api.Path{
+			Label:       "Sessions",
+			Description: "The active sessions of the current user",
+			Path:        "/sessions",
+			Method:      "GET",
+			Process:     "yao.login.Sessions",
+			In:          []interface{}{},
+			Out:         api.Out{Status: 200, Type: "application/json"},
+		},
+	)
+
 	// api source
 	source, err := jsoniter.Marshal(http)
 	if err != nil {
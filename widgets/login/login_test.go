@@ -160,5 +160,5 @@ func TestExport(t *testing.T) {
 
 	api, has := api.APIs["widgets.login"]
 	assert.True(t, has)
-	assert.Equal(t, 4, len(api.HTTP.Paths))
+	assert.Equal(t, 7, len(api.HTTP.Paths))
 }
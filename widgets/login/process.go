@@ -1,7 +1,7 @@
 package login
 
 import (
-	"time"
+	"fmt"
 
 	"github.com/yaoapp/gou/model"
 	"github.com/yaoapp/gou/process"
@@ -24,6 +24,11 @@ var loginTypes = map[string]string{
 
 func exportProcess() {
 	process.Register("yao.login.admin", processLoginAdmin)
+	process.Register("yao.login.refresh", processRefresh)
+	process.Register("yao.login.logout", processLogout)
+	process.Register("yao.login.sessions", processSessions)
+	process.Register("yao.login.kill", processKill)
+	process.Register("yao.login.killall", processKillAll)
 }
 
 // processLoginAdmin yao.admin.login 用户登录
@@ -49,7 +54,7 @@ func processLoginAdmin(process *process.Process) interface{} {
 	}
 
 	sid := session.ID()
-	if csid, ok := payload["sid"].(string); ok {
+	if csid, ok := payload["sid"].(string); ok && csid != "" && !Revoked(csid) {
 		sid = csid
 	}
 
@@ -99,25 +104,23 @@ func auth(field string, value string, password string, sid string) maps.Map {
 		exception.New("Login password error (%v)", 403, value).Throw()
 	}
 
-	expiresAt := time.Now().Unix() + 3600*8
-
-	// token := MakeToken(row, expiresAt)
 	id := any.Of(row.Get("id")).CInt()
-	token := helper.JwtMake(id, map[string]interface{}{}, map[string]interface{}{
-		"expires_at": expiresAt,
-		"sid":        sid,
-		"issuer":     "yao",
-	})
+	token, err := NewSession(sid, id, value)
+	if err != nil {
+		log.Error("[login] create the session %s: %s", sid, err.Error())
+		exception.New("Create session error", 500).Throw()
+	}
+
 	log.Debug("[login] auth sid=%s", sid)
-	session.Global().Expire(time.Duration(token.ExpiresAt)*time.Second).ID(sid).Set("user_id", id)
-	session.Global().Expire(time.Duration(token.ExpiresAt)*time.Second).ID(sid).Set("user", row)
-	session.Global().Expire(time.Duration(token.ExpiresAt)*time.Second).ID(sid).Set("issuer", "yao")
+	session.Global().Expire(tokenTTL()).ID(sid).Set("user_id", id)
+	session.Global().Expire(tokenTTL()).ID(sid).Set("user", row)
+	session.Global().Expire(tokenTTL()).ID(sid).Set("issuer", "yao")
 
 	studio := map[string]interface{}{}
 	if config.Conf.Mode == "development" {
 
 		studioToken := helper.JwtMake(id, map[string]interface{}{}, map[string]interface{}{
-			"expires_at": expiresAt,
+			"expires_at": token.ExpiresAt,
 			"sid":        sid,
 			"issuer":     "yao",
 		}, []byte(config.Conf.Studio.Secret))
@@ -130,10 +133,134 @@ func auth(field string, value string, password string, sid string) maps.Map {
 	// Get user menus
 	menus := process.New("yao.app.menu").WithSID(sid).Run()
 	return maps.Map{
-		"expires_at": token.ExpiresAt,
-		"token":      token.Token,
-		"user":       row,
-		"menus":      menus,
-		"studio":     studio,
+		"expires_at":         token.ExpiresAt,
+		"token":              token.Token,
+		"refresh_token":      token.RefreshToken,
+		"refresh_expires_at": token.RefreshExpiresAt,
+		"user":               row,
+		"menus":              menus,
+		"studio":             studio,
+	}
+}
+
+// processRefresh yao.login.Refresh 使用 refresh token 换取新的 token
+// Args[0] string|map: the refresh token or the payload {"refresh_token": "..."}
+func processRefresh(process *process.Process) interface{} {
+	process.ValidateArgNums(1)
+	refresh := ""
+	switch arg := process.Args[0].(type) {
+	case string:
+		refresh = arg
+	default:
+		refresh = any.Of(process.ArgsMap(0).Get("refresh_token")).CString()
+	}
+
+	if refresh == "" {
+		exception.New("Please enter the refresh token", 400).Throw()
+	}
+
+	token, err := Refresh(refresh)
+	if err != nil {
+		log.Warn("[login] refresh: %s", err.Error())
+		exception.New(err.Error(), 401).Throw()
+	}
+
+	return maps.Map{
+		"expires_at":         token.ExpiresAt,
+		"token":              token.Token,
+		"refresh_token":      token.RefreshToken,
+		"refresh_expires_at": token.RefreshExpiresAt,
+	}
+}
+
+// processLogout yao.login.Logout 注销当前会话, token 和 refresh token 立即失效
+func processLogout(process *process.Process) interface{} {
+	if process.Sid == "" {
+		exception.New("Session not found", 400).Throw()
+	}
+
+	err := Revoke(process.Sid)
+	if err != nil {
+		exception.New(err.Error(), 500).Throw()
+	}
+	return nil
+}
+
+// processSessions yao.login.Sessions 用户的有效会话
+// Args[0] int: the user id, the default is the user of the current session. The sessions of the other users require the admin
+func processSessions(process *process.Process) interface{} {
+	userID := targetUserID(process)
+	sessions, err := Sessions(userID)
+	if err != nil {
+		exception.New(err.Error(), 500).Throw()
+	}
+	return sessions
+}
+
+// processKill yao.login.Kill 注销当前用户的指定会话
+// Args[0] string: the session id, the session must belong to the user of the current session
+func processKill(process *process.Process) interface{} {
+	process.ValidateArgNums(1)
+	sid := process.ArgsString(0)
+	if sid == "" {
+		exception.New("Please enter the session id", 400).Throw()
+	}
+
+	owner, err := Owner(sid)
+	if err != nil {
+		exception.New(err.Error(), 500).Throw()
+	}
+
+	if owner == "" || owner != fmt.Sprintf("%v", currentUserID(process)) {
+		exception.New("Session not found (%s)", 404, sid).Throw()
+	}
+
+	err = Revoke(sid)
+	if err != nil {
+		exception.New(err.Error(), 500).Throw()
+	}
+	return nil
+}
+
+// processKillAll yao.login.KillAll 注销用户的全部会话
+// Args[0] int: the user id, the default is the user of the current session. The sessions of the other users require the admin
+func processKillAll(process *process.Process) interface{} {
+	userID := targetUserID(process)
+	count, err := RevokeUser(userID)
+	if err != nil {
+		exception.New(err.Error(), 500).Throw()
+	}
+	return count
+}
+
+// targetUserID the user id of the first argument or the current session, the other users require the admin
+func targetUserID(process *process.Process) interface{} {
+	userID := currentUserID(process)
+	if len(process.Args) == 0 || process.Args[0] == nil {
+		return userID
+	}
+
+	target := process.Args[0]
+	if fmt.Sprintf("%v", target) != fmt.Sprintf("%v", userID) && !isAdmin(process.Sid) {
+		exception.New("Only the admin can manage the sessions of the other users", 403).Throw()
+	}
+	return target
+}
+
+// currentUserID the user id of the current session
+func currentUserID(process *process.Process) interface{} {
+	userID, err := session.Global().ID(process.Sid).Get("user_id")
+	if err != nil || userID == nil {
+		exception.New("Not authenticated", 401).Throw()
+	}
+	return userID
+}
+
+// isAdmin check if the user of the session is the admin (the type of the admin.user is admin)
+func isAdmin(sid string) bool {
+	user, err := session.Global().ID(sid).Get("user")
+	if err != nil || user == nil {
+		return false
 	}
+	return any.Of(user).MapStr().Get("type") == "admin"
 }
@@ -0,0 +1,484 @@
+package login
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/yaoapp/gou/model"
+	"github.com/yaoapp/gou/session"
+	"github.com/yaoapp/kun/log"
+	"github.com/yaoapp/xun"
+	"github.com/yaoapp/xun/capsule"
+	"github.com/yaoapp/xun/dbal/query"
+	"github.com/yaoapp/xun/dbal/schema"
+	"github.com/yaoapp/yao/config"
+	"github.com/yaoapp/yao/helper"
+)
+
+// SessionTable the table of the login sessions and the revocation list, it is created on the first use
+var SessionTable = "yao_login_session"
+
+// RevokedSyncInterval how often the revoked sessions are synced from the session table,
+// a session killed on the other instance is rejected after at most this duration
+var RevokedSyncInterval = 5 * time.Second
+
+// revokedSkew the overlap of the syncs, the revocations of the other instances with the clock skew are not missed
+const revokedSkew = time.Minute
+
+var sessionQuery query.Query
+var sessionLock sync.Mutex
+
+// revoked the revoked sessions cached in memory
+var revoked = &revocations{sids: map[string]time.Time{}}
+
+// revocations the revoked sessions, all the guards share one query per sync interval
+type revocations struct {
+	mutex    sync.RWMutex
+	sids     map[string]time.Time // sid -> the time the last access token of the session expires, the entry is removed after it
+	since    time.Time            // the revocations after since are synced on the next sync
+	syncedAt time.Time            // the time of the last sync
+}
+
+// Token the access token and the refresh token
+type Token struct {
+	Token            string `json:"token"`
+	ExpiresAt        int64  `json:"expires_at"`
+	RefreshToken     string `json:"refresh_token"`
+	RefreshExpiresAt int64  `json:"refresh_expires_at"`
+}
+
+// NewSession create the session of the user, returns the access token and the refresh token
+func NewSession(sid string, userID int, login string) (*Token, error) {
+	qb, err := newSessionQuery()
+	if err != nil {
+		return nil, err
+	}
+
+	refresh, err := refreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(refreshTTL())
+	// The revoked session is kept until the access tokens expire, the sid can not be reused
+	_, err = qb.Clone().Where("sid", sid).WhereNull("revoked_at").Delete()
+	if err != nil {
+		return nil, err
+	}
+
+	err = qb.Insert(map[string]interface{}{
+		"sid":           sid,
+		"user_id":       fmt.Sprintf("%d", userID),
+		"login":         login,
+		"refresh_token": tokenHash(refresh),
+		"created_at":    now,
+		"expires_at":    expiresAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sweepSessions()
+	token := accessToken(userID, sid)
+	return &Token{
+		Token:            token.Token,
+		ExpiresAt:        token.ExpiresAt,
+		RefreshToken:     refresh,
+		RefreshExpiresAt: expiresAt.Unix(),
+	}, nil
+}
+
+// Refresh rotate the refresh token, returns the new access token and refresh token.
+// The refresh token can be used once, the session is revoked if a used refresh token is presented again.
+func Refresh(refresh string) (*Token, error) {
+	qb, err := newSessionQuery()
+	if err != nil {
+		return nil, err
+	}
+
+	hash := tokenHash(refresh)
+	row, err := qb.Clone().Where("refresh_token", hash).First()
+	if err != nil {
+		return nil, err
+	}
+
+	if row.Get("sid") == nil {
+		// The used refresh token, the token may be stolen
+		used, err := qb.Clone().Where("prev_token", hash).First()
+		if err != nil {
+			return nil, err
+		}
+
+		if used.Get("sid") != nil {
+			sid := fmt.Sprintf("%v", used.Get("sid"))
+			log.Warn("[login] the used refresh token of the session %s is presented again, the session is revoked", sid)
+			Revoke(sid)
+		}
+		return nil, fmt.Errorf("the refresh token is invalid")
+	}
+
+	sid := fmt.Sprintf("%v", row.Get("sid"))
+	if row.Get("revoked_at") != nil {
+		return nil, fmt.Errorf("the session has been revoked")
+	}
+
+	if expiresAt, ok := timeOf(row.Get("expires_at")); ok && expiresAt.Before(time.Now()) {
+		return nil, fmt.Errorf("the refresh token has expired")
+	}
+
+	next, err := refreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(refreshTTL())
+	affected, err := qb.Clone().
+		Where("sid", sid).
+		Where("refresh_token", hash).
+		Update(map[string]interface{}{
+			"refresh_token": tokenHash(next),
+			"prev_token":    hash,
+			"refreshed_at":  now,
+			"expires_at":    expiresAt,
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	// The token has been rotated by the concurrent request
+	if affected == 0 {
+		return nil, fmt.Errorf("the refresh token is invalid")
+	}
+
+	var userID int
+	fmt.Sscanf(fmt.Sprintf("%v", row.Get("user_id")), "%d", &userID)
+	extendSession(sid, userID)
+
+	token := accessToken(userID, sid)
+	return &Token{
+		Token:            token.Token,
+		ExpiresAt:        token.ExpiresAt,
+		RefreshToken:     next,
+		RefreshExpiresAt: expiresAt.Unix(),
+	}, nil
+}
+
+// Revoke revoke the session, the tokens of the session are rejected by the jwt guards.
+// The session that is not created by the login widget is added to the revocation list.
+func Revoke(sid string) error {
+	qb, err := newSessionQuery()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(tokenTTL())
+	has, err := qb.Clone().Where("sid", sid).Exists()
+	if err != nil {
+		return err
+	}
+
+	if !has {
+		err = qb.Insert(map[string]interface{}{
+			"sid":        sid,
+			"created_at": now,
+			"expires_at": expiresAt,
+			"revoked_at": now,
+		})
+	} else {
+		// Keep the record until the last access token expires
+		_, err = qb.Clone().Where("sid", sid).Where("expires_at", "<", expiresAt).Update(map[string]interface{}{"expires_at": expiresAt})
+		if err == nil {
+			_, err = qb.Clone().Where("sid", sid).Update(map[string]interface{}{"revoked_at": now, "refresh_token": nil})
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	revoked.add(sid, expiresAt)
+	clearSession(sid)
+	return nil
+}
+
+// RevokeUser revoke all the sessions of the user, returns the number of the revoked sessions
+func RevokeUser(userID interface{}) (int, error) {
+	sessions, err := Sessions(userID)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, sess := range sessions {
+		err = Revoke(fmt.Sprintf("%v", sess["sid"]))
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(sessions), nil
+}
+
+// Sessions the active sessions of the user
+func Sessions(userID interface{}) ([]map[string]interface{}, error) {
+	qb, err := newSessionQuery()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := qb.
+		Select("sid", "user_id", "login", "created_at", "refreshed_at", "expires_at").
+		Where("user_id", fmt.Sprintf("%v", userID)).
+		WhereNull("revoked_at").
+		Where("expires_at", ">", time.Now()).
+		OrderBy("created_at", "desc").
+		Get()
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := []map[string]interface{}{}
+	for _, row := range rows {
+		sessions = append(sessions, map[string]interface{}(row))
+	}
+	return sessions, nil
+}
+
+// Owner the user id of the session, empty if the session is not found or revoked
+func Owner(sid string) (string, error) {
+	qb, err := newSessionQuery()
+	if err != nil {
+		return "", err
+	}
+
+	row, err := qb.Select("user_id").Where("sid", sid).WhereNull("revoked_at").First()
+	if err != nil {
+		return "", err
+	}
+
+	if row.Get("user_id") == nil {
+		return "", nil
+	}
+	return fmt.Sprintf("%v", row.Get("user_id")), nil
+}
+
+// Revoked check if the session is revoked. The revoked sessions are cached in memory and synced every RevokedSyncInterval,
+// if the sync fails, the cached revocations are used.
+func Revoked(sid string) bool {
+	if sid == "" {
+		return false
+	}
+
+	revoked.sync()
+	return revoked.has(sid)
+}
+
+func (r *revocations) has(sid string) bool {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	_, has := r.sids[sid]
+	return has
+}
+
+func (r *revocations) add(sid string, until time.Time) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if until.After(r.sids[sid]) {
+		r.sids[sid] = until
+	}
+}
+
+// sync load the revocations since the last sync and remove the expired entries
+func (r *revocations) sync() {
+	r.mutex.Lock()
+	now := time.Now()
+	if now.Sub(r.syncedAt) < RevokedSyncInterval {
+		r.mutex.Unlock()
+		return
+	}
+
+	// the other requests use the cached revocations during the sync
+	r.syncedAt = now
+	since := r.since
+	if since.IsZero() {
+		since = now.Add(-tokenTTL())
+	}
+	r.mutex.Unlock()
+
+	rows, err := r.load(since)
+	if err != nil {
+		log.Error("[login] sync the revoked sessions: %s, the cached revocations are used", err.Error())
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, row := range rows {
+		sid := fmt.Sprintf("%v", row.Get("sid"))
+		until := now.Add(tokenTTL())
+		if revokedAt, ok := timeOf(row.Get("revoked_at")); ok {
+			until = revokedAt.Add(tokenTTL())
+		}
+		if until.After(r.sids[sid]) {
+			r.sids[sid] = until
+		}
+	}
+
+	for sid, until := range r.sids {
+		if now.After(until) {
+			delete(r.sids, sid)
+		}
+	}
+	r.since = now.Add(-revokedSkew)
+}
+
+func (r *revocations) load(since time.Time) ([]xun.R, error) {
+	qb, err := newSessionQuery()
+	if err != nil {
+		return nil, err
+	}
+	return qb.Select("sid", "revoked_at").Where("revoked_at", ">=", since).Get()
+}
+
+// reset clear the cached revocations
+func (r *revocations) reset() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.sids = map[string]time.Time{}
+	r.since = time.Time{}
+	r.syncedAt = time.Time{}
+}
+
+// accessToken make the access token of the session
+func accessToken(userID int, sid string) helper.JwtToken {
+	return helper.JwtMake(userID, map[string]interface{}{}, map[string]interface{}{
+		"expires_at": time.Now().Add(tokenTTL()).Unix(),
+		"sid":        sid,
+		"issuer":     "yao",
+	})
+}
+
+// userFields the fields of the user saved in the session data
+var userFields = []interface{}{"id", "name", "type", "email", "mobile", "extra", "status"}
+
+// extendSession extend the session data to the lifetime of the new access token, the expired data is restored
+func extendSession(sid string, userID int) {
+	user, err := session.Global().ID(sid).Get("user")
+	if err != nil || user == nil {
+		user, err = model.Select("admin.user").Find(userID, model.QueryParam{Select: userFields})
+		if err != nil {
+			log.Error("[login] restore the user %d of the session %s: %s", userID, sid, err.Error())
+			user = nil
+		}
+	}
+
+	session.Global().Expire(tokenTTL()).ID(sid).Set("user_id", userID)
+	session.Global().Expire(tokenTTL()).ID(sid).Set("user", user)
+	session.Global().Expire(tokenTTL()).ID(sid).Set("issuer", "yao")
+}
+
+// clearSession remove the user of the session data
+func clearSession(sid string) {
+	for _, key := range []string{"user_id", "user", "issuer"} {
+		session.Global().ID(sid).Set(key, nil)
+	}
+}
+
+// sweepSessions remove the expired sessions
+func sweepSessions() {
+	qb, err := newSessionQuery()
+	if err != nil {
+		return
+	}
+
+	_, err = qb.Where("expires_at", "<", time.Now()).Delete()
+	if err != nil {
+		log.Error("[login] clean the sessions: %s", err.Error())
+	}
+}
+
+func tokenTTL() time.Duration {
+	if config.Conf.Session.TokenTTL <= 0 {
+		return 8 * time.Hour
+	}
+	return time.Duration(config.Conf.Session.TokenTTL) * time.Second
+}
+
+func refreshTTL() time.Duration {
+	if config.Conf.Session.RefreshTTL <= 0 {
+		return 7 * 24 * time.Hour
+	}
+	return time.Duration(config.Conf.Session.RefreshTTL) * time.Second
+}
+
+// refreshToken the random refresh token
+func refreshToken() (string, error) {
+	raw := make([]byte, 32)
+	_, err := rand.Read(raw)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(raw), nil
+}
+
+// tokenHash the refresh tokens are saved as the SHA-256 hash
+func tokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func timeOf(value interface{}) (time.Time, bool) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, true
+	case string:
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999999-07:00", "2006-01-02 15:04:05"} {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// newSessionQuery the query of the session table, the table is created if it does not exist
+func newSessionQuery() (query.Query, error) {
+	sessionLock.Lock()
+	defer sessionLock.Unlock()
+
+	if sessionQuery == nil {
+		sch := capsule.Global.Schema()
+		has, err := sch.HasTable(SessionTable)
+		if err != nil {
+			return nil, err
+		}
+
+		if !has {
+			err = sch.CreateTable(SessionTable, func(table schema.Blueprint) {
+				table.ID("id")
+				table.String("sid", 255).Unique()
+				table.String("user_id", 200).Null().Index()
+				table.String("login", 200).Null()
+				table.String("refresh_token", 64).Null().Index()
+				table.String("prev_token", 64).Null().Index()
+				table.TimestampTz("created_at").Index()
+				table.TimestampTz("refreshed_at").Null()
+				table.TimestampTz("expires_at").Index()
+				table.TimestampTz("revoked_at").Null().Index()
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+		sessionQuery = capsule.Global.Query()
+	}
+
+	qb := sessionQuery.New()
+	qb.Table(SessionTable)
+	return qb, nil
+}
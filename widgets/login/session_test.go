@@ -0,0 +1,206 @@
+package login
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yaoapp/gou/process"
+	"github.com/yaoapp/gou/session"
+	"github.com/yaoapp/xun/capsule"
+	"github.com/yaoapp/yao/config"
+	"github.com/yaoapp/yao/helper"
+	"github.com/yaoapp/yao/test"
+)
+
+func TestSessionRefresh(t *testing.T) {
+	test.Prepare(t, config.Conf)
+	defer test.Clean()
+	prepareSession(t)
+
+	sid := session.ID()
+	token, err := NewSession(sid, 1, "admin@yao.run")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, sid, helper.JwtValidate(token.Token).SID)
+	assert.NotEmpty(t, token.RefreshToken)
+	assert.False(t, Revoked(sid))
+
+	// Rotate
+	next, err := Refresh(token.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NotEqual(t, token.RefreshToken, next.RefreshToken)
+	assert.Equal(t, sid, helper.JwtValidate(next.Token).SID)
+
+	// The used refresh token revokes the session
+	_, err = Refresh(token.RefreshToken)
+	assert.Error(t, err)
+	assert.True(t, Revoked(sid))
+
+	_, err = Refresh(next.RefreshToken)
+	assert.Error(t, err)
+
+	_, err = Refresh("invalid")
+	assert.Error(t, err)
+}
+
+func TestSessionRevoke(t *testing.T) {
+	test.Prepare(t, config.Conf)
+	defer test.Clean()
+	prepareSession(t)
+	exportProcess()
+
+	sid1 := session.ID()
+	sid2 := session.ID()
+	_, err := NewSession(sid1, 1, "admin@yao.run")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := NewSession(sid2, 1, "admin@yao.run")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sessions, err := Sessions(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, sessions, 2)
+
+	// Logout
+	session.Global().ID(sid2).Set("user_id", 1)
+	_, err = process.New("yao.login.Logout").WithSID(sid2).Exec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, Revoked(sid2))
+	assert.False(t, Revoked(sid1))
+
+	userID, _ := session.Global().ID(sid2).Get("user_id")
+	assert.Nil(t, userID)
+
+	_, err = Refresh(token.RefreshToken)
+	assert.Error(t, err)
+
+	sessions, err = Sessions(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assert.Len(t, sessions, 1) {
+		assert.Equal(t, sid1, sessions[0]["sid"])
+	}
+
+	// Kill the session of the other user
+	sid3 := session.ID()
+	_, err = NewSession(sid3, 2, "staff@yao.run")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	session.Global().ID(sid1).Set("user_id", 1)
+	_, err = process.New("yao.login.Kill", sid3).WithSID(sid1).Exec()
+	assert.Error(t, err)
+	assert.False(t, Revoked(sid3))
+
+	_, err = process.New("yao.login.KillAll", 2).WithSID(sid1).Exec()
+	assert.Error(t, err)
+
+	_, err = process.New("yao.login.Sessions", 2).WithSID(sid1).Exec()
+	assert.Error(t, err)
+
+	// Kill the own session
+	sid4 := session.ID()
+	_, err = NewSession(sid4, 1, "admin@yao.run")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = process.New("yao.login.Kill", sid4).WithSID(sid1).Exec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, Revoked(sid4))
+
+	// The admin kills all the sessions of the other user
+	session.Global().ID(sid1).Set("user", map[string]interface{}{"id": 1, "type": "admin"})
+	count, err := process.New("yao.login.KillAll", 2).WithSID(sid1).Exec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 1, count)
+	assert.True(t, Revoked(sid3))
+
+	// Kill all the own sessions
+	count, err = process.New("yao.login.KillAll").WithSID(sid1).Exec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 1, count)
+	assert.True(t, Revoked(sid1))
+}
+
+func TestSessionRevokedSync(t *testing.T) {
+	test.Prepare(t, config.Conf)
+	defer test.Clean()
+	prepareSession(t)
+
+	sid1 := session.ID()
+	sid2 := session.ID()
+	for _, sid := range []string{sid1, sid2} {
+		_, err := NewSession(sid, 1, "admin@yao.run")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Revoked on the other instance
+	qb, err := newSessionQuery()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = qb.Where("sid", sid2).Update(map[string]interface{}{"revoked_at": time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	revoked.syncedAt = time.Time{}
+	assert.False(t, Revoked(sid1))
+	assert.True(t, Revoked(sid2))
+
+	// The cached revocations are used if the sync fails
+	err = capsule.Global.Schema().DropTableIfExists(SessionTable)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	revoked.syncedAt = time.Time{}
+	assert.False(t, Revoked(sid1))
+	assert.True(t, Revoked(sid2))
+
+	// The expired revocations are removed
+	revoked.sids[sid2] = time.Now().Add(-time.Second)
+	revoked.syncedAt = time.Time{}
+	prepareTable(t)
+	assert.False(t, Revoked(sid2))
+	assert.Len(t, revoked.sids, 0)
+}
+
+func prepareSession(t *testing.T) {
+	revoked.reset()
+	err := capsule.Global.Schema().DropTableIfExists(SessionTable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prepareTable(t)
+}
+
+// prepareTable create the session table on the next query
+func prepareTable(t *testing.T) {
+	sessionLock.Lock()
+	defer sessionLock.Unlock()
+	sessionQuery = nil
+}
@@ -0,0 +1,388 @@
+package permission
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/yaoapp/gou/process"
+	"github.com/yaoapp/gou/session"
+	"github.com/yaoapp/gou/types"
+	"github.com/yaoapp/kun/any"
+	"github.com/yaoapp/yao/widgets/binding"
+)
+
+// DefaultRoles the default session variable of the user roles
+const DefaultRoles = "$.__roles"
+
+// Apply apply the policy to the args of the widget action, returns the args to call the process.
+// name is the action name (e.g. yao.table.Search), bind is the process bound to the action (e.g. models.pet.Paginate)
+//
+//	Row-level:   search, get, find, updatewhere, updatein, deletewhere, deletein append the wheres to the query param;
+//	             update, delete and save (with the primary key) check the row matches the wheres;
+//	             all the write actions set the columns of the equal conditions, so the row can not be moved out of the wheres.
+//	Field-level: save, create, update, updatewhere, updatein and insert strip or reject the readonly columns.
+func (policy *Policy) Apply(name string, bind string, sid string, args []interface{}) ([]interface{}, error) {
+	if policy == nil || len(policy.Rules) == 0 {
+		return args, nil
+	}
+
+	rule := policy.match(sid)
+	if rule == nil {
+		return nil, fmt.Errorf("no permission to %s", name)
+	}
+
+	wheres, err := rule.wheres(sid)
+	if err != nil {
+		return nil, err
+	}
+
+	namer := strings.Split(strings.ToLower(name), ".")
+	switch namer[len(namer)-1] {
+	case "search", "get", "deletewhere", "deletein":
+		err = withWheres(args, 0, wheres)
+
+	case "find":
+		err = withWheres(args, 1, wheres)
+
+	case "updatewhere", "updatein":
+		err = withWheres(args, 0, wheres)
+		if err == nil {
+			err = rule.writeRow(args, 1)
+		}
+		if err == nil {
+			setRow(args, 1, wheres)
+		}
+
+	case "update":
+		err = visible(bind, sid, args, 0, wheres)
+		if err == nil {
+			err = rule.writeRow(args, 1)
+		}
+		if err == nil {
+			setRow(args, 1, wheres)
+		}
+
+	case "delete":
+		err = visible(bind, sid, args, 0, wheres)
+
+	case "save":
+		err = rule.writeRow(args, 0)
+		if err == nil {
+			err = saveVisible(bind, sid, args, wheres)
+		}
+		if err == nil {
+			setRow(args, 0, wheres)
+		}
+
+	case "create":
+		err = rule.writeRow(args, 0)
+		if err == nil {
+			setRow(args, 0, wheres)
+		}
+
+	case "insert":
+		err = rule.writeRows(args)
+		if err == nil {
+			setRows(args, wheres)
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return args, nil
+}
+
+// match the first rule matching the user roles
+func (policy *Policy) match(sid string) *Rule {
+	name := policy.Roles
+	if name == "" {
+		name = DefaultRoles
+	}
+
+	roles := map[string]bool{}
+	switch values := sessionValue(sid, name).(type) {
+	case string:
+		for _, role := range strings.Split(values, ",") {
+			roles[strings.TrimSpace(role)] = true
+		}
+	case []string:
+		for _, role := range values {
+			roles[role] = true
+		}
+	case []interface{}:
+		for _, role := range values {
+			roles[fmt.Sprintf("%v", role)] = true
+		}
+	}
+
+	for i, rule := range policy.Rules {
+		if len(rule.Roles) == 0 {
+			return &policy.Rules[i]
+		}
+		for _, role := range rule.Roles {
+			if roles[role] {
+				return &policy.Rules[i]
+			}
+		}
+	}
+	return nil
+}
+
+// wheres the wheres of the rule, the session variables are replaced with the session values
+func (rule *Rule) wheres(sid string) ([]types.QueryWhere, error) {
+	wheres := make([]types.QueryWhere, len(rule.Wheres))
+	for i, where := range rule.Wheres {
+		wheres[i] = where
+		if value, ok := where.Value.(string); ok && strings.HasPrefix(strings.TrimSpace(value), "$.") {
+			wheres[i].Value = sessionValue(sid, value)
+			if wheres[i].Value == nil {
+				return nil, fmt.Errorf("no permission, the session variable %s is not set", value)
+			}
+		}
+	}
+	return wheres, nil
+}
+
+// writeRow strip or reject the readonly columns of the row args[i]
+func (rule *Rule) writeRow(args []interface{}, i int) error {
+	if len(rule.Readonly) == 0 || len(args) <= i {
+		return nil
+	}
+
+	row, ok := binding.Row(args[i])
+	if !ok {
+		return nil
+	}
+
+	for _, column := range rule.Readonly {
+		if _, has := row[column]; !has {
+			continue
+		}
+		if rule.Reject {
+			return fmt.Errorf("no permission to write the column %s", column)
+		}
+		delete(row, column)
+	}
+	return nil
+}
+
+// writeRows strip or reject the readonly columns of the insert args (columns, values)
+func (rule *Rule) writeRows(args []interface{}) error {
+	if len(rule.Readonly) == 0 || len(args) < 2 {
+		return nil
+	}
+
+	columns, ok := args[0].([]string)
+	if !ok {
+		return nil
+	}
+
+	values, ok := args[1].([][]interface{})
+	if !ok {
+		return nil
+	}
+
+	readonly := map[string]bool{}
+	for _, column := range rule.Readonly {
+		readonly[column] = true
+	}
+
+	keep := []int{}
+	newColumns := []string{}
+	for i, column := range columns {
+		if readonly[column] {
+			if rule.Reject {
+				return fmt.Errorf("no permission to write the column %s", column)
+			}
+			continue
+		}
+		keep = append(keep, i)
+		newColumns = append(newColumns, column)
+	}
+
+	newValues := [][]interface{}{}
+	for _, value := range values {
+		row := []interface{}{}
+		for _, i := range keep {
+			if i < len(value) {
+				row = append(row, value[i])
+			}
+		}
+		newValues = append(newValues, row)
+	}
+
+	args[0] = newColumns
+	args[1] = newValues
+	return nil
+}
+
+// withWheres append the wheres to the query param args[i]
+func withWheres(args []interface{}, i int, wheres []types.QueryWhere) error {
+	if len(wheres) == 0 || len(args) <= i {
+		return nil
+	}
+
+	param := types.QueryParam{}
+	switch value := args[i].(type) {
+	case nil:
+	case types.QueryParam:
+		param = value
+	default:
+		var ok bool
+		param, ok = types.AnyToQueryParam(value)
+		if !ok {
+			return fmt.Errorf("the query param is invalid %#v", value)
+		}
+	}
+
+	// Group the original wheres, so that the orwhere conditions can not skip the policy
+	origin := param.Wheres
+	param.Wheres = []types.QueryWhere{}
+	if len(origin) > 0 {
+		param.Wheres = append(param.Wheres, types.QueryWhere{Wheres: origin})
+	}
+	param.Wheres = append(param.Wheres, wheres...)
+	args[i] = param
+	return nil
+}
+
+// visible check the row of the primary key args[i] matches the wheres
+func visible(bind string, sid string, args []interface{}, i int, wheres []types.QueryWhere) error {
+	if len(wheres) == 0 || len(args) <= i {
+		return nil
+	}
+
+	mod, err := binding.Model(bind, "the row permission")
+	if err != nil {
+		return err
+	}
+
+	query := types.QueryParam{
+		Select: []interface{}{mod.PrimaryKey},
+		Wheres: append([]types.QueryWhere{{Column: mod.PrimaryKey, Value: args[i]}}, wheres...),
+		Limit:  1,
+	}
+
+	res, err := process.New(fmt.Sprintf("models.%s.Get", mod.ID), query).WithSID(sid).Exec()
+	if err != nil {
+		return err
+	}
+
+	rows := reflect.ValueOf(res)
+	if rows.Kind() != reflect.Slice || rows.Len() == 0 {
+		return fmt.Errorf("no permission to access the row %v", args[i])
+	}
+	return nil
+}
+
+// saveVisible check the saved row matches the wheres if the row has the primary key
+func saveVisible(bind string, sid string, args []interface{}, wheres []types.QueryWhere) error {
+	if len(wheres) == 0 || len(args) == 0 {
+		return nil
+	}
+
+	row, ok := binding.Row(args[0])
+	if !ok {
+		return nil
+	}
+
+	mod, err := binding.Model(bind, "the row permission")
+	if err != nil {
+		return err
+	}
+
+	id, has := row[mod.PrimaryKey]
+	if !has || id == nil {
+		return nil
+	}
+	return visible(bind, sid, []interface{}{id}, 0, wheres)
+}
+
+// setRow set the columns of the equal conditions to the row args[i]
+func setRow(args []interface{}, i int, wheres []types.QueryWhere) {
+	if len(args) <= i {
+		return
+	}
+
+	row, ok := binding.Row(args[i])
+	if !ok {
+		return
+	}
+
+	for column, value := range equals(wheres) {
+		row[column] = value
+	}
+}
+
+// setRows set the columns of the equal conditions to the insert args (columns, values)
+func setRows(args []interface{}, wheres []types.QueryWhere) {
+	if len(args) < 2 {
+		return
+	}
+
+	columns, ok := args[0].([]string)
+	if !ok {
+		return
+	}
+
+	values, ok := args[1].([][]interface{})
+	if !ok {
+		return
+	}
+
+	for column, value := range equals(wheres) {
+		index := -1
+		for i, name := range columns {
+			if name == column {
+				index = i
+				break
+			}
+		}
+
+		if index == -1 {
+			columns = append(columns, column)
+			index = len(columns) - 1
+		}
+
+		for i := range values {
+			for len(values[i]) <= index {
+				values[i] = append(values[i], nil)
+			}
+			values[i][index] = value
+		}
+	}
+
+	args[0] = columns
+	args[1] = values
+}
+
+// equals the columns of the equal conditions
+func equals(wheres []types.QueryWhere) map[string]interface{} {
+	columns := map[string]interface{}{}
+	for _, where := range wheres {
+		if where.Column == "" || len(where.Wheres) > 0 || strings.Contains(strings.ToLower(where.Method), "or") {
+			continue
+		}
+		if where.OP == "" || where.OP == "eq" {
+			columns[fmt.Sprintf("%v", where.Column)] = where.Value
+		}
+	}
+	return columns
+}
+
+// sessionValue get the session variable $.user.id $.user_id
+func sessionValue(sid string, name string) interface{} {
+	name = strings.TrimPrefix(strings.TrimSpace(name), "$.")
+	namer := strings.Split(name, ".")
+	value, err := session.Global().ID(sid).Get(namer[0])
+	if err != nil || value == nil {
+		return nil
+	}
+
+	if len(namer) == 1 {
+		return value
+	}
+	return any.Of(value).MapStr().Dot().Get(strings.Join(namer[1:], "."))
+}
@@ -0,0 +1,134 @@
+package permission
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yaoapp/gou/session"
+	"github.com/yaoapp/gou/types"
+	"github.com/yaoapp/yao/config"
+	"github.com/yaoapp/yao/test"
+)
+
+func TestApplyRows(t *testing.T) {
+	test.Prepare(t, config.Conf)
+	defer test.Clean()
+	defer session.Global().Set("__roles", nil)
+
+	policy := testPolicy()
+	session.Global().Set("user", map[string]interface{}{"id": 1, "tenant_id": 2})
+
+	// Admin
+	session.Global().Set("__roles", []string{"admin"})
+	args, err := policy.Apply("yao.table.Search", "models.pet.Paginate", "", []interface{}{nil, 1, 20})
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{nil, 1, 20}, args)
+
+	// Staff
+	session.Global().Set("__roles", "staff, sales")
+	args, err = policy.Apply("yao.table.Search", "models.pet.Paginate", "", []interface{}{nil, 1, 20})
+	assert.Nil(t, err)
+	assert.Equal(t, types.QueryParam{Wheres: []types.QueryWhere{{Column: "tenant_id", Value: 2}}}, args[0])
+
+	args, err = policy.Apply("yao.table.Find", "models.pet.Find", "", []interface{}{1, map[string]interface{}{
+		"wheres": []interface{}{
+			map[string]interface{}{"column": "status", "value": "checked"},
+			map[string]interface{}{"column": "status", "value": "curing", "method": "orwhere"},
+		},
+	}})
+	assert.Nil(t, err)
+	param := args[1].(types.QueryParam)
+	if assert.Len(t, param.Wheres, 2) {
+		assert.Len(t, param.Wheres[0].Wheres, 2)
+		assert.Equal(t, types.QueryWhere{Column: "tenant_id", Value: 2}, param.Wheres[1])
+	}
+
+	// No matching rule
+	session.Global().Set("__roles", []interface{}{"guest"})
+	_, err = policy.Apply("yao.table.Get", "models.pet.Get", "", []interface{}{nil})
+	assert.Error(t, err)
+
+	// The session variable is not set
+	session.Global().Set("__roles", []string{"staff"})
+	session.Global().Set("user", nil)
+	_, err = policy.Apply("yao.table.Get", "models.pet.Get", "", []interface{}{nil})
+	assert.Error(t, err)
+
+	// No policy
+	var empty *Policy
+	args, err = empty.Apply("yao.table.Get", "models.pet.Get", "", []interface{}{nil})
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{nil}, args)
+}
+
+func TestApplyFields(t *testing.T) {
+	test.Prepare(t, config.Conf)
+	defer test.Clean()
+	defer session.Global().Set("__roles", nil)
+
+	policy := testPolicy()
+	session.Global().Set("__roles", []string{"staff"})
+	session.Global().Set("user", map[string]interface{}{"id": 1, "tenant_id": 2})
+
+	// Strip the readonly columns, set the tenant
+	args, err := policy.Apply("yao.table.Create", "models.pet.Create", "", []interface{}{
+		map[string]interface{}{"name": "Cookie", "cost": 100, "tenant_id": 9},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"name": "Cookie", "tenant_id": 2}, args[0])
+
+	args, err = policy.Apply("yao.table.Insert", "models.pet.Insert", "", []interface{}{
+		[]string{"name", "cost"},
+		[][]interface{}{{"Cookie", 100}, {"Baby", 24}},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"name", "tenant_id"}, args[0])
+	assert.Equal(t, [][]interface{}{{"Cookie", 2}, {"Baby", 2}}, args[1])
+
+	args, err = policy.Apply("yao.table.UpdateWhere", "models.pet.UpdateWhere", "", []interface{}{
+		nil, map[string]interface{}{"name": "Cookie", "cost": 100},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"name": "Cookie", "tenant_id": 2}, args[1])
+
+	// The columns of the equal conditions are set even if they are writable
+	policy.Rules[1].Readonly = []string{"cost"}
+	for _, name := range []string{"UpdateWhere", "UpdateIn"} {
+		args, err = policy.Apply("yao.table."+name, "models.pet."+name, "", []interface{}{
+			nil, map[string]interface{}{"name": "Cookie", "tenant_id": 9},
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]interface{}{"name": "Cookie", "tenant_id": 2}, args[1], name)
+	}
+	policy.Rules[1].Readonly = []string{"cost", "tenant_id"}
+
+	// Reject the readonly columns
+	policy.Rules[1].Reject = true
+	_, err = policy.Apply("yao.table.Create", "models.pet.Create", "", []interface{}{
+		map[string]interface{}{"name": "Cookie", "cost": 100},
+	})
+	assert.Error(t, err)
+
+	_, err = policy.Apply("yao.table.Insert", "models.pet.Insert", "", []interface{}{
+		[]string{"name", "cost"},
+		[][]interface{}{{"Cookie", 100}},
+	})
+	assert.Error(t, err)
+
+	// The row permission of the primary key requires the model process
+	_, err = policy.Apply("yao.table.Delete", "scripts.pet.Delete", "", []interface{}{1})
+	assert.Error(t, err)
+}
+
+func testPolicy() *Policy {
+	return &Policy{
+		Rules: []Rule{
+			{Roles: []string{"admin"}},
+			{
+				Roles:    []string{"staff"},
+				Wheres:   []types.QueryWhere{{Column: "tenant_id", Value: "$.user.tenant_id"}},
+				Readonly: []string{"cost", "tenant_id"},
+			},
+		},
+	}
+}
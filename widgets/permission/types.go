@@ -0,0 +1,18 @@
+package permission
+
+import "github.com/yaoapp/gou/types"
+
+// Policy the data permission policy of the table, form and list widgets.
+// The first rule matching the user roles applies, the request is rejected if no rule matches.
+type Policy struct {
+	Roles string `json:"roles,omitempty"` // the session variable of the user roles, the default is $.__roles
+	Rules []Rule `json:"rules,omitempty"`
+}
+
+// Rule the permission rule
+type Rule struct {
+	Roles    []string           `json:"roles,omitempty"`    // the rule applies to the users having any of the roles, empty for all users
+	Wheres   []types.QueryWhere `json:"wheres,omitempty"`   // row-level, the rows the user can access. the value could be a session variable $.user.tenant_id
+	Readonly []string           `json:"readonly,omitempty"` // field-level, the columns the user can not write
+	Reject   bool               `json:"reject,omitempty"`   // reject the request writing the readonly columns, the columns are stripped by default
+}
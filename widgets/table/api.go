@@ -57,6 +57,11 @@ func (table *DSL) getAction(path string) (*action.Process, error) {
 		return table.Action.Download, nil
 	case "/api/__yao/table/:id/search":
 		return table.Action.Search, nil
+	case "/api/__yao/table/:id/export",
+		"/api/__yao/table/:id/export/:job",
+		"/api/__yao/table/:id/export/:job/cancel",
+		"/api/__yao/table/:id/export/:job/resume":
+		return table.Action.Search, nil // export reads the same data as search
 	case "/api/__yao/table/:id/get":
 		return table.Action.Get, nil
 	case "/api/__yao/table/:id/find/:primary":
@@ -196,6 +201,72 @@ func exportAPI() error {
 	}
 	http.Paths = append(http.Paths, path)
 
+	//  POST  /api/__yao/table/:id/export  					-> Default process: yao.table.ExportStart $param.id :query-param $query.format $query.pagesize
+	path = api.Path{
+		Label:       "Export",
+		Description: "Export in the background",
+		Path:        "/:id/export",
+		Method:      "POST",
+		Process:     "yao.table.ExportStart",
+		In:          []interface{}{"$param.id", ":query-param", "$query.format", "$query.pagesize"},
+		Out:         api.Out{Status: 200, Type: "application/json"},
+	}
+	http.Paths = append(http.Paths, path)
+
+	//   GET  /api/__yao/table/:id/export/:job  				-> Default process: yao.table.ExportJob $param.id $param.job
+	path = api.Path{
+		Label:       "Export Job",
+		Description: "Export Job",
+		Path:        "/:id/export/:job",
+		Method:      "GET",
+		Process:     "yao.table.ExportJob",
+		In:          []interface{}{"$param.id", "$param.job"},
+		Out:         api.Out{Status: 200, Type: "application/json"},
+	}
+	http.Paths = append(http.Paths, path)
+
+	//  POST  /api/__yao/table/:id/export/:job/cancel  		-> Default process: yao.table.ExportCancel $param.id $param.job
+	path = api.Path{
+		Label:       "Export Cancel",
+		Description: "Export Cancel",
+		Path:        "/:id/export/:job/cancel",
+		Method:      "POST",
+		Process:     "yao.table.ExportCancel",
+		In:          []interface{}{"$param.id", "$param.job"},
+		Out:         api.Out{Status: 200, Type: "application/json"},
+	}
+	http.Paths = append(http.Paths, path)
+
+	//  POST  /api/__yao/table/:id/export/:job/resume  		-> Default process: yao.table.ExportResume $param.id $param.job
+	path = api.Path{
+		Label:       "Export Resume",
+		Description: "Export Resume",
+		Path:        "/:id/export/:job/resume",
+		Method:      "POST",
+		Process:     "yao.table.ExportResume",
+		In:          []interface{}{"$param.id", "$param.job"},
+		Out:         api.Out{Status: 200, Type: "application/json"},
+	}
+	http.Paths = append(http.Paths, path)
+
+	//   GET  /api/__yao/table/:id/export/:job/download  		-> Default process: yao.table.ExportDownload $param.id $param.job $query.expires $query.signature
+	// The link is signed, no guard required
+	path = api.Path{
+		Label:       "Export Download",
+		Description: "Export Download",
+		Guard:       "-",
+		Path:        "/:id/export/:job/download",
+		Method:      "GET",
+		Process:     "yao.table.ExportDownload",
+		In:          []interface{}{"$param.id", "$param.job", "$query.expires", "$query.signature"},
+		Out: api.Out{
+			Status:  200,
+			Body:    "{{content}}",
+			Headers: map[string]string{"Content-Type": "{{type}}"},
+		},
+	}
+	http.Paths = append(http.Paths, path)
+
 	//  POST  /api/__yao/table/:id/save  						-> Default process: yao.table.Save $param.id :payload
 	path = api.Path{
 		Label:       "Save",
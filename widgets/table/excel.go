@@ -1,23 +1,14 @@
 package table
 
 import (
-	"errors"
 	"fmt"
-	"os"
-	"path/filepath"
 
-	"github.com/xuri/excelize/v2"
-	"github.com/yaoapp/gou/fs"
 	"github.com/yaoapp/kun/any"
-	"github.com/yaoapp/kun/log"
 	"github.com/yaoapp/kun/maps"
 )
 
-// Export Export query result to Excel
-func (dsl *DSL) Export(filename string, data interface{}, page int, chunkSize int) error {
-
-	log.Trace("[Export] %s %d %d Before: %#v", filename, page, chunkSize, data)
-
+// exportRows convert the search result data to the rows, the nested values can be read by the dot path
+func exportRows(data interface{}) []maps.MapStr {
 	rows := []maps.MapStr{}
 	if values, ok := data.([]maps.MapStrAny); ok {
 		for _, row := range values {
@@ -32,64 +23,20 @@ func (dsl *DSL) Export(filename string, data interface{}, page int, chunkSize in
 			rows = append(rows, any.Of(row).MapStr().Dot())
 		}
 	}
+	return rows
+}
 
-	log.Trace("[Export] %s %d %d After: %#v", filename, page, chunkSize, data)
+// exportColumns the exported columns, returns an error if the table does not support export
+func (dsl *DSL) exportColumns() ([]map[string]string, error) {
 	columns, err := dsl.exportSetting()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if len(columns) == 0 {
-		return fmt.Errorf("the table does not support export")
-	}
-
-	// filename = filepath.Join(xfs.Stor.Root, filename)
-	fs, err := fs.Get("system")
-	if err != nil {
-		return err
+		return nil, fmt.Errorf("the table does not support export")
 	}
-
-	filename = filepath.Join(fs.Root(), filename)
-	if _, err := os.Stat(filename); errors.Is(err, os.ErrNotExist) {
-		f := excelize.NewFile()
-		index := f.GetActiveSheetIndex()
-		name := f.GetSheetName(index)
-		f.SetSheetName(name, dsl.Name)
-		for i, column := range columns {
-			axis, err := excelize.CoordinatesToCellName(i+1, 1)
-			if err != nil {
-				return err
-			}
-			f.SetCellValue(dsl.Name, axis, column["name"])
-		}
-		if err := f.SaveAs(filename); err != nil {
-			fmt.Println(err)
-			return err
-		}
-	}
-
-	f, err := excelize.OpenFile(filename)
-	if err != nil {
-		return err
-	}
-
-	defer f.Close()
-	offset := (page-1)*chunkSize + 2
-	for line, row := range rows {
-		for i, column := range columns {
-			v := row.Get(column["field"])
-			if v != nil {
-				axis, err := excelize.CoordinatesToCellName(i+1, line+offset)
-				if err != nil {
-					return err
-				}
-				f.SetCellValue(dsl.Name, axis, v)
-			}
-		}
-		// fmt.Println("--", line, page, offset, filename, chunkSize, "--")
-	}
-
-	return f.Save()
+	return columns, nil
 }
 
 func (dsl *DSL) exportSetting() ([]map[string]string, error) {
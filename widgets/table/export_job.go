@@ -0,0 +1,376 @@
+package table
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	gouProcess "github.com/yaoapp/gou/process"
+	"github.com/yaoapp/gou/types"
+	"github.com/yaoapp/kun/any"
+	"github.com/yaoapp/kun/exception"
+	"github.com/yaoapp/kun/log"
+	"github.com/yaoapp/kun/maps"
+	"github.com/yaoapp/yao/config"
+)
+
+// The export job status
+const (
+	ExportPending  = "pending"
+	ExportRunning  = "running"
+	ExportDone     = "done"
+	ExportFailed   = "failed"
+	ExportCanceled = "canceled"
+)
+
+// exportTTL how long the ended jobs are kept, the failed jobs can be resumed before they expire
+var exportTTL = 24 * time.Hour
+
+// exportLinkTTL how long the download link is valid
+var exportLinkTTL = time.Hour
+
+// exportJobs the export jobs
+var exportJobs = sync.Map{}
+
+// ExportJob the asynchronous export job, pages through the search action and writes the rows page by page
+type ExportJob struct {
+	ID        string     `json:"id"`
+	Table     string     `json:"table"`
+	Format    string     `json:"format"`
+	File      string     `json:"file"` // the file path relative to the data root
+	Sid       string     `json:"-"`
+	Status    string     `json:"status"`
+	Page      int        `json:"page"`  // the last exported page
+	Rows      int        `json:"rows"`  // the exported rows
+	Total     int        `json:"total"` // the total rows, 0 if the search action does not return it
+	Error     string     `json:"error,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	StartedAt *time.Time `json:"started_at,omitempty"`
+	EndedAt   *time.Time `json:"ended_at,omitempty"`
+	dsl       *DSL
+	params    types.QueryParam
+	pagesize  int
+	next      int // the next page to export
+	global    map[string]interface{}
+	writer    exportWriter // kept open when the job fails, so that it can be resumed
+	cancel    context.CancelFunc
+	mutex     sync.RWMutex
+}
+
+// NewExport create the export job, the file is created under /exports/<date>/<job>.<format>
+func (dsl *DSL) NewExport(params types.QueryParam, format string, pagesize int, sid string, global map[string]interface{}) (*ExportJob, error) {
+	if format == "" {
+		format = ExportXlsx
+	}
+
+	if pagesize <= 0 {
+		pagesize = 50
+	}
+
+	columns, err := dsl.exportColumns()
+	if err != nil {
+		return nil, err
+	}
+
+	id := uuid.NewString()
+	file := "/" + filepath.ToSlash(filepath.Join("exports", time.Now().Format("20060102"), fmt.Sprintf("%s.%s", id, format)))
+	writer, err := newExportWriter(format, file, dsl.Name, columns)
+	if err != nil {
+		return nil, err
+	}
+
+	sweepExports()
+	job := &ExportJob{
+		ID:        id,
+		Table:     dsl.ID,
+		Format:    format,
+		File:      file,
+		Sid:       sid,
+		Status:    ExportPending,
+		CreatedAt: time.Now(),
+		dsl:       dsl,
+		params:    params,
+		pagesize:  pagesize,
+		next:      1,
+		global:    global,
+		writer:    writer,
+	}
+	exportJobs.Store(job.ID, job)
+	return job, nil
+}
+
+// GetExport get the export job
+func GetExport(id string) (*ExportJob, error) {
+	job, has := exportJobs.Load(id)
+	if !has {
+		return nil, fmt.Errorf("the export job %s does not exist or has expired", id)
+	}
+	return job.(*ExportJob), nil
+}
+
+// Start run the job in the background
+func (job *ExportJob) Start() {
+	ctx := job.prepare()
+	go job.Run(ctx)
+}
+
+// Run run the job in the current goroutine, exports the rest pages
+func (job *ExportJob) Run(ctx context.Context) {
+	job.mutex.Lock()
+	now := time.Now()
+	job.Status = ExportRunning
+	job.StartedAt = &now
+	job.mutex.Unlock()
+
+	err := job.export(ctx)
+	switch {
+	case err != nil:
+		log.Error("[table] %s export %s %s", job.Table, job.ID, err.Error())
+		job.finish(ExportFailed, err.Error())
+
+	case ctx.Err() != nil:
+		job.closeWriter()
+		job.finish(ExportCanceled, "")
+
+	default:
+		err = job.closeWriter()
+		if err != nil {
+			job.finish(ExportFailed, err.Error())
+			return
+		}
+		job.finish(ExportDone, "")
+	}
+}
+
+// Cancel cancel the running job, a failed job is canceled immediately
+func (job *ExportJob) Cancel() error {
+	job.mutex.Lock()
+	status := job.Status
+	cancel := job.cancel
+	job.mutex.Unlock()
+
+	switch status {
+	case ExportPending, ExportRunning:
+		if cancel != nil {
+			cancel()
+		}
+		return nil
+
+	case ExportFailed:
+		job.closeWriter()
+		job.mutex.Lock()
+		job.Status = ExportCanceled
+		job.mutex.Unlock()
+		return nil
+	}
+
+	return fmt.Errorf("the export job %s has ended", job.ID)
+}
+
+// Resume continue the failed job from the first page that was not exported
+func (job *ExportJob) Resume() error {
+	job.mutex.Lock()
+	if job.Status != ExportFailed || job.writer == nil {
+		job.mutex.Unlock()
+		return fmt.Errorf("the export job %s is %s, only the failed job can be resumed", job.ID, job.Status)
+	}
+	job.Status = ExportPending
+	job.Error = ""
+	job.EndedAt = nil
+	job.mutex.Unlock()
+
+	job.Start()
+	return nil
+}
+
+// Ended check if the job has ended
+func (job *ExportJob) Ended() bool {
+	job.mutex.RLock()
+	defer job.mutex.RUnlock()
+	return job.EndedAt != nil
+}
+
+// Link the signed download link of the finished job
+func (job *ExportJob) Link() string {
+	expires := strconv.FormatInt(time.Now().Add(exportLinkTTL).Unix(), 10)
+	query := url.Values{}
+	query.Set("expires", expires)
+	query.Set("signature", exportSignature(job.ID, expires))
+	return fmt.Sprintf("/api/__yao/table/%s/export/%s/download?%s", job.Table, job.ID, query.Encode())
+}
+
+// Verify verify the signature of the download link
+func (job *ExportJob) Verify(expires string, signature string) error {
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > expiresAt {
+		return fmt.Errorf("the download link has expired")
+	}
+
+	if !hmac.Equal([]byte(exportSignature(job.ID, expires)), []byte(signature)) {
+		return fmt.Errorf("the download link signature is invalid")
+	}
+	return nil
+}
+
+// Map cast to map, the finished job has the download link
+func (job *ExportJob) Map() map[string]interface{} {
+	job.mutex.RLock()
+	defer job.mutex.RUnlock()
+
+	res := map[string]interface{}{
+		"id":         job.ID,
+		"table":      job.Table,
+		"format":     job.Format,
+		"file":       job.File,
+		"status":     job.Status,
+		"page":       job.Page,
+		"rows":       job.Rows,
+		"total":      job.Total,
+		"progress":   job.progress(),
+		"error":      job.Error,
+		"created_at": job.CreatedAt,
+		"started_at": job.StartedAt,
+		"ended_at":   job.EndedAt,
+	}
+
+	if job.Status == ExportDone {
+		res["link"] = job.Link()
+	}
+	return res
+}
+
+// progress the percentage of the exported rows
+func (job *ExportJob) progress() int {
+	if job.Status == ExportDone {
+		return 100
+	}
+
+	if job.Total <= 0 {
+		return 0
+	}
+
+	progress := job.Rows * 100 / job.Total
+	if progress > 99 {
+		progress = 99
+	}
+	return progress
+}
+
+// prepare create the context of the run
+func (job *ExportJob) prepare() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	job.mutex.Lock()
+	job.cancel = cancel
+	job.mutex.Unlock()
+	return ctx
+}
+
+// export page through the search action from the next page, the exceptions thrown by the action are returned as errors
+func (job *ExportJob) export(ctx context.Context) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			message := fmt.Sprintf("%v", r)
+			if e, ok := r.(*exception.Exception); ok {
+				message = e.Message
+			}
+			err = fmt.Errorf("page %d: %s", job.next, message)
+		}
+	}()
+
+	for job.next > 0 {
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		process := gouProcess.New("yao.table.search", job.Table, job.params, job.next, job.pagesize).
+			WithSID(job.Sid).
+			WithGlobal(job.global)
+
+		data, err := job.dsl.Action.Search.Exec(process)
+		if err != nil {
+			return fmt.Errorf("page %d: %s", job.next, err.Error())
+		}
+
+		res, ok := data.(map[string]interface{})
+		if !ok {
+			res, ok = data.(maps.MapStrAny)
+			if !ok {
+				return fmt.Errorf("page %d: the search action response data error %#v", job.next, data)
+			}
+		}
+
+		rows := exportRows(res["data"])
+		err = job.writer.Write(rows)
+		if err != nil {
+			return fmt.Errorf("page %d: %s", job.next, err.Error())
+		}
+
+		next := -1
+		if _, has := res["next"]; has {
+			next = any.Of(res["next"]).CInt()
+		}
+
+		job.mutex.Lock()
+		job.Page = job.next
+		job.Rows = job.Rows + len(rows)
+		if total, has := res["total"]; has {
+			job.Total = any.Of(total).CInt()
+		}
+		job.next = next
+		job.mutex.Unlock()
+	}
+	return nil
+}
+
+func (job *ExportJob) finish(status string, message string) {
+	job.mutex.Lock()
+	defer job.mutex.Unlock()
+	now := time.Now()
+	job.Status = status
+	job.Error = message
+	job.EndedAt = &now
+}
+
+// closeWriter close the writer, the file is complete after it is closed
+func (job *ExportJob) closeWriter() error {
+	job.mutex.Lock()
+	writer := job.writer
+	job.writer = nil
+	job.mutex.Unlock()
+
+	if writer == nil {
+		return nil
+	}
+	return writer.Close()
+}
+
+// exportSignature the HMAC-SHA256 signature of the job and the expiration time
+func exportSignature(id string, expires string) string {
+	mac := hmac.New(sha256.New, []byte(config.Conf.JWTSecret))
+	mac.Write([]byte(id + ":" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// sweepExports remove the expired jobs, the writers of the failed jobs are closed
+func sweepExports() {
+	expired := time.Now().Add(-exportTTL)
+	exportJobs.Range(func(key, value interface{}) bool {
+		job := value.(*ExportJob)
+		job.mutex.RLock()
+		ended := job.EndedAt
+		job.mutex.RUnlock()
+		if ended != nil && ended.Before(expired) {
+			job.closeWriter()
+			exportJobs.Delete(key)
+		}
+		return true
+	})
+}
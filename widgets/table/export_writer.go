@@ -0,0 +1,217 @@
+package table
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/yaoapp/kun/maps"
+	"github.com/yaoapp/yao/config"
+	"github.com/yaoapp/yao/excel"
+)
+
+// The export formats
+const (
+	ExportXlsx  = "xlsx"
+	ExportCSV   = "csv"
+	ExportJSONL = "jsonl"
+)
+
+// exportWriter write the exported rows page by page, the rows are flushed after each page
+type exportWriter interface {
+	Write(rows []maps.MapStr) error
+	Close() error
+}
+
+// newExportWriter create the writer of the format, the file path is relative to the data root
+func newExportWriter(format string, file string, sheet string, columns []map[string]string) (exportWriter, error) {
+	switch format {
+	case ExportXlsx:
+		return newXlsxWriter(file, sheet, columns)
+	case ExportCSV:
+		return newCSVWriter(file, columns)
+	case ExportJSONL:
+		return newJSONLWriter(file, columns)
+	}
+	return nil, fmt.Errorf("the export format %s does not support, should be one of xlsx, csv, jsonl", format)
+}
+
+// xlsxWriter write the rows to the Excel stream
+type xlsxWriter struct {
+	id      string
+	stream  *excel.Stream
+	columns []map[string]string
+}
+
+func newXlsxWriter(file string, sheet string, columns []map[string]string) (*xlsxWriter, error) {
+	id, err := excel.OpenStream(file, sheet)
+	if err != nil {
+		return nil, err
+	}
+
+	stream, err := excel.GetStream(id)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]interface{}, len(columns))
+	for i, column := range columns {
+		header[i] = column["name"]
+	}
+
+	err = stream.Write([][]interface{}{header})
+	if err != nil {
+		excel.CloseStream(id)
+		return nil, err
+	}
+	return &xlsxWriter{id: id, stream: stream, columns: columns}, nil
+}
+
+func (w *xlsxWriter) Write(rows []maps.MapStr) error {
+	values := [][]interface{}{}
+	for _, row := range rows {
+		value := make([]interface{}, len(w.columns))
+		for i, column := range w.columns {
+			value[i] = row.Get(column["field"])
+		}
+		values = append(values, value)
+	}
+	return w.stream.Write(values)
+}
+
+func (w *xlsxWriter) Close() error {
+	return excel.CloseStream(w.id)
+}
+
+// csvWriter write the rows to the CSV file, starts with the UTF-8 BOM so that Excel can read it
+type csvWriter struct {
+	file    *os.File
+	writer  *csv.Writer
+	columns []map[string]string
+}
+
+func newCSVWriter(file string, columns []map[string]string) (*csvWriter, error) {
+	f, err := createExportFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = f.WriteString("\xEF\xBB\xBF")
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	header := make([]string, len(columns))
+	for i, column := range columns {
+		header[i] = column["name"]
+	}
+
+	w := &csvWriter{file: f, writer: csv.NewWriter(f), columns: columns}
+	w.writer.Write(header)
+	w.writer.Flush()
+	if err := w.writer.Error(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *csvWriter) Write(rows []maps.MapStr) error {
+	for _, row := range rows {
+		record := make([]string, len(w.columns))
+		for i, column := range w.columns {
+			record[i] = csvValue(row.Get(column["field"]))
+		}
+		w.writer.Write(record)
+	}
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+func (w *csvWriter) Close() error {
+	w.writer.Flush()
+	if err := w.writer.Error(); err != nil {
+		w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
+
+// csvValue the cell text, the maps and slices are encoded as JSON
+func csvValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	}
+
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.Map, reflect.Slice, reflect.Array:
+		text, err := jsoniter.MarshalToString(value)
+		if err == nil {
+			return text
+		}
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+// jsonlWriter write one JSON object per line, the keys are the column names
+type jsonlWriter struct {
+	file    *os.File
+	writer  *bufio.Writer
+	columns []map[string]string
+}
+
+func newJSONLWriter(file string, columns []map[string]string) (*jsonlWriter, error) {
+	f, err := createExportFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return &jsonlWriter{file: f, writer: bufio.NewWriter(f), columns: columns}, nil
+}
+
+func (w *jsonlWriter) Write(rows []maps.MapStr) error {
+	for _, row := range rows {
+		line := map[string]interface{}{}
+		for _, column := range w.columns {
+			line[column["name"]] = row.Get(column["field"])
+		}
+
+		raw, err := jsoniter.Marshal(line)
+		if err != nil {
+			return err
+		}
+
+		_, err = w.writer.Write(append(raw, '\n'))
+		if err != nil {
+			return err
+		}
+	}
+	return w.writer.Flush()
+}
+
+func (w *jsonlWriter) Close() error {
+	if err := w.writer.Flush(); err != nil {
+		w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
+
+// createExportFile create the file in the data root
+func createExportFile(file string) (*os.File, error) {
+	path := filepath.Join(config.Conf.DataRoot, file)
+	err := os.MkdirAll(filepath.Dir(path), os.ModePerm)
+	if err != nil {
+		return nil, err
+	}
+	return os.Create(path)
+}
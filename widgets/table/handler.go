@@ -7,10 +7,12 @@ import (
 	"github.com/yaoapp/gou/model"
 	gouProcess "github.com/yaoapp/gou/process"
 	"github.com/yaoapp/gou/session"
+	"github.com/yaoapp/kun/exception"
 	"github.com/yaoapp/kun/log"
 	"github.com/yaoapp/yao/config"
 	"github.com/yaoapp/yao/i18n"
 	"github.com/yaoapp/yao/widgets/action"
+	"github.com/yaoapp/yao/widgets/concurrency"
 )
 
 // ********************************
@@ -63,20 +65,57 @@ func processHandler(p *action.Process, process *gouProcess.Process) (interface{}
 		log.Error("[table] %s %s Compute Edit Error: %s", tab.ID, p.Name, err.Error())
 	}
 
+	// Data permissions
+	args, err = tab.Permissions.Apply(p.Name, name, process.Sid, args)
+	if err != nil {
+		log.Warn("[table] %s %s %s", tab.ID, p.Name, err.Error())
+		exception.New(err.Error(), 403).Throw()
+	}
+
+	// Optimistic lock
+	args, err = tab.Lock.Check(p.Name, name, process.Sid, args)
+	if err != nil {
+		log.Warn("[table] %s %s %s", tab.ID, p.Name, err.Error())
+		if conflict, ok := err.(*concurrency.Conflict); ok {
+			exception.New(err.Error(), 409).Ctx(conflict.Map()).Throw()
+		}
+		exception.New(err.Error(), 400).Throw()
+	}
+
+	// Audit trail
+	trail := tab.Audit.Begin("table", tab.ID, p.Name, name, process.Sid, args)
+
+	// Optimistic lock, the token is renewed before the process writes the row
+	token, err := tab.Lock.Acquire(p.Name, name, process.Sid, args)
+	if err != nil {
+		log.Warn("[table] %s %s %s", tab.ID, p.Name, err.Error())
+		if conflict, ok := err.(*concurrency.Conflict); ok {
+			exception.New(err.Error(), 409).Ctx(conflict.Map()).Throw()
+		}
+		return nil, fmt.Errorf("[table] %s %s -> %s %s", tab.ID, p.Name, name, err.Error())
+	}
+
 	// Execute Process
 	act, err := gouProcess.Of(name, args...)
 	if err != nil {
+		if err := token.Release(); err != nil {
+			log.Error("[table] %s %s release the concurrency token: %s", tab.ID, p.Name, err.Error())
+		}
 		log.Error("[table] %s %s -> %s %s %v", tab.ID, p.Name, name, err.Error(), args)
 		return nil, fmt.Errorf("[table] %s %s -> %s %s", tab.ID, p.Name, name, err.Error())
 	}
 
 	err = act.WithGlobal(process.Global).WithSID(process.Sid).Execute()
 	if err != nil {
+		if err := token.Release(); err != nil {
+			log.Error("[table] %s %s release the concurrency token: %s", tab.ID, p.Name, err.Error())
+		}
 		log.Error("[table] %s %s -> %s %s %v", tab.ID, p.Name, name, err.Error(), args)
 		return nil, fmt.Errorf("[table] %s %s -> %s %s", tab.ID, p.Name, name, err.Error())
 	}
 	defer act.Release()
 	res := act.Value()
+	trail.Commit(res)
 
 	// Compute View
 	err = tab.ComputeView(p.Name, process, res, tab.getField())
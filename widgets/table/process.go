@@ -4,19 +4,14 @@ import (
 	"fmt"
 	"net/url"
 	"os"
-	"path/filepath"
 	"strings"
-	"time"
 
-	"github.com/google/uuid"
 	"github.com/yaoapp/gou/fs"
 	"github.com/yaoapp/gou/model"
 	gouProcess "github.com/yaoapp/gou/process"
 	"github.com/yaoapp/gou/types"
-	"github.com/yaoapp/kun/any"
 	"github.com/yaoapp/kun/exception"
 	"github.com/yaoapp/kun/log"
-	"github.com/yaoapp/kun/maps"
 	"github.com/yaoapp/yao/helper"
 	"github.com/yaoapp/yao/widgets/app"
 )
@@ -44,6 +39,11 @@ func exportProcess() {
 	gouProcess.Register("yao.table.deletewhere", processDeleteWhere)
 	gouProcess.Register("yao.table.deletein", processDeleteIn)
 	gouProcess.Register("yao.table.export", processExport)
+	gouProcess.Register("yao.table.exportstart", processExportStart)
+	gouProcess.Register("yao.table.exportjob", processExportJob)
+	gouProcess.Register("yao.table.exportcancel", processExportCancel)
+	gouProcess.Register("yao.table.exportresume", processExportResume)
+	gouProcess.Register("yao.table.exportdownload", processExportDownload)
 
 	// DSL Operations
 	gouProcess.Register("yao.table.exists", processExists)
@@ -261,66 +261,120 @@ func processDeleteIn(process *gouProcess.Process) interface{} {
 	return tab.Action.DeleteIn.MustExec(process)
 }
 
-// processExport yao.table.Export (:table, :queryParam, :chunkSize)
+// processExport yao.table.Export (:table, :queryParam, :chunkSize, :format)
 func processExport(process *gouProcess.Process) interface{} {
 	process.ValidateArgNums(1)
 	tab := MustGet(process) // 0
 	params := process.ArgsQueryParams(1, types.QueryParam{})
 	pagesize := process.ArgsInt(2, 50)
-	log.Trace("[table] export %s %v %d", tab.ID, params, pagesize)
+	format := process.ArgsString(3, ExportXlsx)
+	log.Trace("[table] export %s %v %d %s", tab.ID, params, pagesize, format)
 
-	// Filename
-	fingerprint := uuid.NewString()
-	dir := time.Now().Format("20060102")
-	filename := filepath.Join(string(os.PathSeparator), dir, fmt.Sprintf("%s.xlsx", fingerprint))
+	job, err := tab.NewExport(params, format, pagesize, process.Sid, process.Global)
+	if err != nil {
+		exception.New(err.Error(), 400).Throw()
+	}
 
-	// Create Data Path
-	fs := fs.MustGet("system")
-	if has, _ := fs.Exists(dir); !has {
-		fs.MkdirAll(dir, uint32(os.ModePerm))
+	job.Run(job.prepare())
+	if job.Status == ExportFailed {
+		job.closeWriter()
+		exception.New(job.Error, 500).Throw()
 	}
+	return job.File
+}
 
-	// Query
-	page := 1
-	for page > 0 {
-		process.Args = []interface{}{tab.ID, params, page, pagesize}
-		data, err := tab.Action.Search.Exec(process)
-		if err != nil {
-			log.Error("[table] export error %s", err.Error())
-			page = -1
-			continue
-		}
+// processExportStart yao.table.ExportStart (:table, :queryParam, :format, :chunkSize)
+// Export in the background, returns the job
+func processExportStart(process *gouProcess.Process) interface{} {
+	process.ValidateArgNums(1)
+	tab := MustGet(process) // 0
+	params := process.ArgsQueryParams(1, types.QueryParam{})
+	format := process.ArgsString(2, ExportXlsx)
+	pagesize := process.ArgsInt(3, 50)
 
-		res, ok := data.(map[string]interface{})
-		if !ok {
-			res, ok = data.(maps.MapStrAny)
-			if !ok {
-				log.Error("[table] export Search Action response data error %#v", data)
-				page = -1
-				continue
-			}
-		}
+	job, err := tab.NewExport(params, format, pagesize, process.Sid, process.Global)
+	if err != nil {
+		exception.New(err.Error(), 400).Throw()
+	}
 
-		if _, ok := res["next"]; !ok {
-			page = -1
-			continue
-		}
+	job.Start()
+	return job.Map()
+}
 
-		size := pagesize
-		if _, ok := res["pagesize"]; ok {
-			size = any.Of(res["pagesize"]).CInt()
-		}
+// processExportJob yao.table.ExportJob (:table, :job)
+// The job progress, returns the signed download link when the job is done
+func processExportJob(process *gouProcess.Process) interface{} {
+	process.ValidateArgNums(2)
+	return exportJobOf(process).Map()
+}
 
-		// Export
-		err = tab.Export(filename, res["data"], page, size)
-		if err != nil {
-			log.Error("Export %s %s", tab.ID, err.Error())
-		}
+// processExportCancel yao.table.ExportCancel (:table, :job)
+func processExportCancel(process *gouProcess.Process) interface{} {
+	process.ValidateArgNums(2)
+	job := exportJobOf(process)
+	err := job.Cancel()
+	if err != nil {
+		exception.New(err.Error(), 400).Throw()
+	}
+	return job.Map()
+}
+
+// processExportResume yao.table.ExportResume (:table, :job)
+// Continue the failed job from the first page that was not exported
+func processExportResume(process *gouProcess.Process) interface{} {
+	process.ValidateArgNums(2)
+	job := exportJobOf(process)
+	err := job.Resume()
+	if err != nil {
+		exception.New(err.Error(), 400).Throw()
+	}
+	return job.Map()
+}
+
+// processExportDownload yao.table.ExportDownload (:table, :job, :expires, :signature)
+// Download the exported file by the signed link
+func processExportDownload(process *gouProcess.Process) interface{} {
+	process.ValidateArgNums(4)
+	job, err := GetExport(process.ArgsString(1))
+	if err != nil || job.Table != process.ArgsString(0) {
+		exception.New("the export job %s does not exist or has expired", 404, process.ArgsString(1)).Throw()
+	}
+
+	err = job.Verify(process.ArgsString(2), process.ArgsString(3))
+	if err != nil {
+		exception.New(err.Error(), 403).Throw()
+	}
+
+	if !job.Ended() || job.Status != ExportDone {
+		exception.New("the export job %s is %s", 400, job.ID, job.Status).Throw()
+	}
+
+	p, err := gouProcess.Of("fs.system.Download", job.File)
+	if err != nil {
+		exception.New(err.Error(), 400).Throw()
+	}
+
+	err = p.WithGlobal(process.Global).WithSID(process.Sid).Execute()
+	if err != nil {
+		log.Error("[table] %s export download %s", job.Table, err.Error())
+		exception.New(err.Error(), 500).Throw()
+	}
+	defer p.Release()
+	return p.Value()
+}
 
-		page = any.Of(res["next"]).CInt()
+// exportJobOf get the export job of the table, only the session that created the job can read it
+func exportJobOf(process *gouProcess.Process) *ExportJob {
+	tab := MustGet(process) // 0
+	job, err := GetExport(process.ArgsString(1))
+	if err != nil {
+		exception.New(err.Error(), 404).Throw()
 	}
 
-	return filename
+	if job.Table != tab.ID || (job.Sid != "" && process.Sid != "" && job.Sid != process.Sid) {
+		exception.New("the export job %s does not exist or has expired", 404, job.ID).Throw()
+	}
+	return job
 }
 
 // processLoad yao.table.Load table_name file <source>
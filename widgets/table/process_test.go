@@ -5,7 +5,10 @@ import (
 	"io"
 	"net/url"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/yaoapp/gou/fs"
@@ -14,10 +17,12 @@ import (
 	"github.com/yaoapp/gou/session"
 	"github.com/yaoapp/gou/types"
 	"github.com/yaoapp/kun/any"
+	"github.com/yaoapp/yao/audit"
 	"github.com/yaoapp/yao/config"
 	"github.com/yaoapp/yao/helper"
 	"github.com/yaoapp/yao/test"
 	"github.com/yaoapp/yao/widgets/component"
+	"github.com/yaoapp/yao/widgets/permission"
 )
 
 func TestProcessSearch(t *testing.T) {
@@ -582,6 +587,179 @@ func TestProcessExport(t *testing.T) {
 	assert.Greater(t, size, 1000)
 }
 
+func TestProcessDataPermissions(t *testing.T) {
+	test.Prepare(t, config.Conf)
+	defer test.Clean()
+
+	prepare(t)
+	clear(t)
+	testData(t)
+
+	tab := Tables["pet"]
+	tab.Permissions = &permission.Policy{
+		Rules: []permission.Rule{
+			{Roles: []string{"admin"}},
+			{
+				Wheres:   []types.QueryWhere{{Column: "doctor_id", Value: "$.user.doctor_id"}},
+				Readonly: []string{"cost"},
+			},
+		},
+	}
+	defer func() {
+		tab.Permissions = nil
+		session.Global().Set("user", nil)
+		session.Global().Set("__roles", nil)
+	}()
+
+	// The rows of the other doctor
+	session.Global().Set("user", map[string]interface{}{"doctor_id": 2})
+	res, err := process.New("yao.table.Search", "pet", nil, 1, 20).Exec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "0", fmt.Sprintf("%v", any.Of(res).MapStr().Dot().Get("total")))
+
+	_, err = process.New("yao.table.Update", "pet", 1, map[string]interface{}{"name": "New Pet"}).Exec()
+	assert.Error(t, err)
+
+	_, err = process.New("yao.table.Find", "pet", 1).Exec()
+	assert.Error(t, err)
+
+	// The rows of the doctor, the cost is readonly
+	session.Global().Set("user", map[string]interface{}{"doctor_id": 1})
+	res, err = process.New("yao.table.Search", "pet", nil, 1, 20).Exec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "3", fmt.Sprintf("%v", any.Of(res).MapStr().Dot().Get("total")))
+
+	_, err = process.New("yao.table.Update", "pet", 1, map[string]interface{}{"name": "New Pet", "cost": 1}).Exec()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	session.Global().Set("__roles", []string{"admin"})
+	res, err = process.New("yao.table.Find", "pet", 1).Exec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := any.Of(res).MapStr().Dot()
+	assert.Equal(t, "New Pet", data.Get("name"))
+	assert.Equal(t, "105", fmt.Sprintf("%v", data.Get("cost")))
+}
+
+func TestProcessAudit(t *testing.T) {
+	test.Prepare(t, config.Conf)
+	defer test.Clean()
+
+	prepare(t)
+	clear(t)
+	testData(t)
+
+	tab := Tables["pet"]
+	tab.Audit = &audit.Option{Actions: []string{"update"}}
+	defer func() {
+		tab.Audit = nil
+		session.Global().Set("user_id", nil)
+	}()
+
+	session.Global().Set("user_id", 9)
+	_, err := process.New("yao.table.Update", "pet", 2, map[string]interface{}{"name": "New Baby", "cost": 24}).Exec()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := process.New("yao.audit.Search", types.QueryParam{
+		Wheres: []types.QueryWhere{{Column: "widget_id", Value: "pet"}, {Column: "actor", Value: "9"}},
+	}, 1, 1).Exec()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := any.Of(res).MapStr().Get("data").([]map[string]interface{})
+	if assert.Len(t, data, 1) {
+		assert.Equal(t, "table", data[0]["widget"])
+		assert.Equal(t, "update", data[0]["action"])
+		assert.Equal(t, "2", data[0]["primary_key"])
+		assert.Contains(t, data[0]["fields"], "name")
+		assert.NotContains(t, data[0]["fields"], "cost")
+	}
+}
+
+func TestProcessExportJob(t *testing.T) {
+	test.Prepare(t, config.Conf)
+	defer test.Clean()
+
+	prepare(t)
+	clear(t)
+	testData(t)
+
+	fs := fs.MustGet("system")
+	for _, format := range []string{"csv", "jsonl", "xlsx"} {
+		res, err := process.New("yao.table.ExportStart", "pet", nil, format, 2).Exec()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		id := res.(map[string]interface{})["id"].(string)
+		job, err := GetExport(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i := 0; i < 500 && !job.Ended(); i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		res, err = process.New("yao.table.ExportJob", "pet", id).Exec()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data := res.(map[string]interface{})
+		assert.Equal(t, ExportDone, data["status"])
+		assert.Equal(t, 3, data["rows"])
+		assert.Equal(t, 100, data["progress"])
+		assert.Equal(t, format, filepath.Ext(job.File)[1:])
+
+		size, _ := fs.Size(job.File)
+		assert.Greater(t, size, 0)
+		if format == "csv" {
+			content, err := fs.ReadFile(job.File)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, 4, strings.Count(string(content), "\n"))
+		}
+
+		// Download by the signed link
+		link, err := url.Parse(data["link"].(string))
+		if err != nil {
+			t.Fatal(err)
+		}
+		expires := link.Query().Get("expires")
+		signature := link.Query().Get("signature")
+		_, err = process.New("yao.table.ExportDownload", "pet", id, expires, signature).Exec()
+		assert.Nil(t, err)
+
+		_, err = process.New("yao.table.ExportDownload", "pet", id, expires, "invalid").Exec()
+		assert.Error(t, err)
+
+		// Ended jobs can not be canceled or resumed
+		_, err = process.New("yao.table.ExportCancel", "pet", id).Exec()
+		assert.Error(t, err)
+		_, err = process.New("yao.table.ExportResume", "pet", id).Exec()
+		assert.Error(t, err)
+		fs.Remove(job.File)
+	}
+
+	_, err := process.New("yao.table.ExportStart", "pet", nil, "pdf").Exec()
+	assert.Error(t, err)
+
+	_, err = process.New("yao.table.ExportJob", "pet", "not-found").Exec()
+	assert.Error(t, err)
+}
+
 func TestProcessLoad(t *testing.T) {
 	test.Prepare(t, config.Conf)
 	defer test.Clean()
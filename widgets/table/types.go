@@ -1,26 +1,32 @@
 package table
 
 import (
+	"github.com/yaoapp/yao/audit"
 	"github.com/yaoapp/yao/widgets/action"
 	"github.com/yaoapp/yao/widgets/component"
 	"github.com/yaoapp/yao/widgets/compute"
+	"github.com/yaoapp/yao/widgets/concurrency"
 	"github.com/yaoapp/yao/widgets/field"
 	"github.com/yaoapp/yao/widgets/hook"
 	"github.com/yaoapp/yao/widgets/mapping"
+	"github.com/yaoapp/yao/widgets/permission"
 )
 
 // DSL the table DSL
 type DSL struct {
 	// Root   string                 `json:"-"`
-	ID     string                 `json:"id,omitempty"`
-	Name   string                 `json:"name,omitempty"`
-	Action *ActionDSL             `json:"action"`
-	Layout *LayoutDSL             `json:"layout"`
-	Fields *FieldsDSL             `json:"fields"`
-	Config map[string]interface{} `json:"config,omitempty"`
-	CProps field.CloudProps       `json:"-"`
-	file   string                 `json:"-"`
-	source []byte                 `json:"-"`
+	ID          string                 `json:"id,omitempty"`
+	Name        string                 `json:"name,omitempty"`
+	Action      *ActionDSL             `json:"action"`
+	Layout      *LayoutDSL             `json:"layout"`
+	Fields      *FieldsDSL             `json:"fields"`
+	Config      map[string]interface{} `json:"config,omitempty"`
+	CProps      field.CloudProps       `json:"-"`
+	Permissions *permission.Policy     `json:"permissions,omitempty"`
+	Audit       *audit.Option          `json:"audit,omitempty"`
+	Lock        *concurrency.Lock      `json:"lock,omitempty"`
+	file        string                 `json:"-"`
+	source      []byte                 `json:"-"`
 	compute.Computable
 	*mapping.Mapping
 }
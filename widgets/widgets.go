@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/yaoapp/kun/log"
+	"github.com/yaoapp/yao/audit"
 	"github.com/yaoapp/yao/config"
 	"github.com/yaoapp/yao/widgets/app"
 	"github.com/yaoapp/yao/widgets/chart"
@@ -70,6 +72,12 @@ func Load(cfg config.Config) error {
 		messages = append(messages, err.Error())
 	}
 
+	// audit trail table widget
+	err = loadAudit(cfg)
+	if err != nil {
+		messages = append(messages, err.Error())
+	}
+
 	// chart widget
 	err = chart.LoadAndExport(cfg)
 	if err != nil {
@@ -89,3 +97,50 @@ func Load(cfg config.Config) error {
 
 	return nil
 }
+
+// loadAudit load the audit trail table widget, unless the application overrides it.
+// The widget is loaded only if some widget is audited and the guard (YAO_AUDIT_GUARD) is set.
+func loadAudit(cfg config.Config) error {
+	if _, has := table.Tables[audit.WidgetID]; has {
+		return nil
+	}
+
+	if !audited() {
+		return nil
+	}
+
+	if cfg.AuditGuard == "" {
+		log.Warn("[audit] the audit trail widget %s is not loaded, set YAO_AUDIT_GUARD to enable it", audit.WidgetID)
+		return nil
+	}
+
+	source, err := audit.Source(cfg.AuditGuard)
+	if err != nil {
+		return err
+	}
+
+	_, err = table.LoadSource(source, audit.WidgetID)
+	return err
+}
+
+// audited check if any table, form or list widget is audited
+func audited() bool {
+	for _, tab := range table.Tables {
+		if tab.Audit.Enabled() {
+			return true
+		}
+	}
+
+	for _, f := range form.Forms {
+		if f.Audit.Enabled() {
+			return true
+		}
+	}
+
+	for _, l := range list.Lists {
+		if l.Audit.Enabled() {
+			return true
+		}
+	}
+	return false
+}
@@ -4,8 +4,10 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/yaoapp/yao/audit"
 	"github.com/yaoapp/yao/config"
 	"github.com/yaoapp/yao/test"
+	"github.com/yaoapp/yao/widgets/table"
 )
 
 func TestLoad(t *testing.T) {
@@ -14,3 +16,45 @@ func TestLoad(t *testing.T) {
 	err := Load(config.Conf)
 	assert.Nil(t, err)
 }
+
+func TestLoadAudit(t *testing.T) {
+	test.Prepare(t, config.Conf)
+	defer test.Clean()
+	err := Load(config.Conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	delete(table.Tables, audit.WidgetID)
+	tab, err := table.Get("pet")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	option := tab.Audit
+	defer func() { tab.Audit = option; delete(table.Tables, audit.WidgetID) }()
+
+	// No widget is audited
+	tab.Audit = nil
+	cfg := config.Conf
+	cfg.AuditGuard = "bearer-jwt"
+	assert.Nil(t, loadAudit(cfg))
+	_, err = table.Get(audit.WidgetID)
+	assert.Error(t, err)
+
+	// The guard is required
+	tab.Audit = &audit.Option{}
+	cfg.AuditGuard = ""
+	assert.Nil(t, loadAudit(cfg))
+	_, err = table.Get(audit.WidgetID)
+	assert.Error(t, err)
+
+	cfg.AuditGuard = "bearer-jwt,scripts.guard.Admin"
+	assert.Nil(t, loadAudit(cfg))
+	widget, err := table.Get(audit.WidgetID)
+	if assert.Nil(t, err) {
+		assert.Equal(t, "yao.audit.Search", widget.Action.Search.Process)
+		assert.Equal(t, "bearer-jwt,scripts.guard.Admin", widget.Action.Search.Guard)
+		assert.Equal(t, "bearer-jwt,scripts.guard.Admin", widget.Action.Setting.Guard)
+	}
+}